@@ -0,0 +1,169 @@
+// Copyright 2017, The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+// Package cmptest reports the differences between values in tests.
+//
+// It provides the helper that is otherwise written in every project that
+// uses cmp.Diff in its tests:
+//
+//	if diff := cmp.Diff(got, want); diff != "" {
+//		t.Errorf("mismatch (-got +want):\n%s", diff)
+//	}
+package cmptest
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"testing/quick"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// Diff reports the differences between got and want, as determined by
+// cmp.Diff with the given options, as an error of the test t.
+// It reports whether got and want are equal.
+//
+// The differences are reported with a "mismatch (-got +want):" header.
+// If the options are invalid, such that cmp.Diff panics, then the test is
+// stopped with the panic message by t.Fatal rather than crashing the test
+// binary.
+func Diff(t testing.TB, got, want interface{}, opts ...cmp.Option) bool {
+	t.Helper()
+	return diff(t, t.Errorf, got, want, opts)
+}
+
+// DiffFatal is like Diff, but reports the differences by t.Fatal,
+// which stops the test.
+func DiffFatal(t testing.TB, got, want interface{}, opts ...cmp.Option) {
+	t.Helper()
+	diff(t, t.Fatalf, got, want, opts)
+}
+
+func diff(t testing.TB, report func(string, ...interface{}), got, want interface{}, opts []cmp.Option) (eq bool) {
+	t.Helper()
+	defer func() {
+		if ex := recover(); ex != nil {
+			msg, ok := ex.(string)
+			if !ok {
+				panic(ex) // Not a panic raised by cmp
+			}
+			t.Helper()
+			t.Fatalf("cmp.Diff panicked: %s", msg)
+		}
+	}()
+	if d := cmp.Diff(got, want, opts...); d != "" {
+		report("mismatch (-got +want):\n%s", d)
+		return false
+	}
+	return true
+}
+
+// QuickCheckEqual uses testing/quick to check that the round-trip function f,
+// such as one that encodes and then decodes its argument, returns a value
+// equal to its argument, as determined by cmp.Diff with the given options.
+// The function f must have a single argument and a single result, both of
+// the same type. The config is passed to quick.Check and may be nil.
+//
+// If f fails for some argument, the error is a *QuickCheckError reporting
+// the differences. If the options are invalid, the error is a *cmp.Error.
+func QuickCheckEqual(f interface{}, config *quick.Config, opts ...cmp.Option) error {
+	fv := reflect.ValueOf(f)
+	if fv.Kind() != reflect.Func {
+		return quick.SetupError("argument is not a function")
+	}
+	ft := fv.Type()
+	if ft.NumIn() != 1 || ft.NumOut() != 1 || ft.In(0) != ft.Out(0) {
+		return quick.SetupError("function must have one argument and one result of the same type")
+	}
+
+	var out interface{}
+	var d string
+	var err error
+	checkType := reflect.FuncOf([]reflect.Type{ft.In(0)}, []reflect.Type{reflect.TypeOf(true)}, false)
+	check := reflect.MakeFunc(checkType, func(args []reflect.Value) []reflect.Value {
+		out = fv.Call(args)[0].Interface()
+		d, err = cmp.DiffE(args[0].Interface(), out, opts...)
+		return []reflect.Value{reflect.ValueOf(err == nil && d == "")}
+	})
+	qerr := quick.Check(check.Interface(), config)
+	if err != nil {
+		return err
+	}
+	if ce, ok := qerr.(*quick.CheckError); ok {
+		return &QuickCheckError{CheckError: *ce, Out: out, Diff: d}
+	}
+	return qerr
+}
+
+// QuickCheckError is the error returned by QuickCheckEqual when the round-trip
+// function returns a value that is not equal to its argument.
+type QuickCheckError struct {
+	quick.CheckError
+	Out  interface{} // The value returned for the argument In[0]
+	Diff string      // The differences between In[0] and Out, as by cmp.Diff
+}
+
+func (e *QuickCheckError) Error() string {
+	return fmt.Sprintf("#%d: failed on input %v; mismatch (-input +output):\n%s", e.Count, e.In[0], e.Diff)
+}
+
+// Codec encodes and decodes values, such as for persistence.
+type Codec interface {
+	Encode(v interface{}) ([]byte, error)
+	Decode(b []byte, v interface{}) error
+}
+
+var (
+	// Gob is a Codec using encoding/gob.
+	Gob Codec = gobCodec{}
+
+	// JSON is a Codec using encoding/json.
+	JSON Codec = jsonCodec{}
+)
+
+type gobCodec struct{}
+
+func (gobCodec) Encode(v interface{}) ([]byte, error) {
+	var b bytes.Buffer
+	err := gob.NewEncoder(&b).Encode(v)
+	return b.Bytes(), err
+}
+
+func (gobCodec) Decode(b []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(b)).Decode(v)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Encode(v interface{}) ([]byte, error) { return json.Marshal(v) }
+func (jsonCodec) Decode(b []byte, v interface{}) error { return json.Unmarshal(b, v) }
+
+// RoundTripEqual encodes x with the codec c and decodes the result into a new
+// value of the same type as x, and then returns the differences between x and
+// the decoded value, as determined by cmp.Diff with the given options.
+// The differences are empty if x survives the round-trip.
+//
+// Note that codecs typically ignore unexported fields, which are reported
+// as differences if the options allow comparing them (e.g., AllowUnexported).
+// It returns an error if x is nil, if the codec fails, or if the options
+// are misused, as by cmp.DiffE.
+func RoundTripEqual(c Codec, x interface{}, opts ...cmp.Option) (string, error) {
+	if x == nil {
+		return "", errors.New("cannot round-trip nil")
+	}
+	b, err := c.Encode(x)
+	if err != nil {
+		return "", fmt.Errorf("cannot encode %T: %v", x, err)
+	}
+	y := reflect.New(reflect.TypeOf(x))
+	if err := c.Decode(b, y.Interface()); err != nil {
+		return "", fmt.Errorf("cannot decode %T: %v", x, err)
+	}
+	return cmp.DiffE(x, y.Elem().Interface(), opts...)
+}
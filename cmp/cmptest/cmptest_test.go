@@ -0,0 +1,197 @@
+// Copyright 2017, The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package cmptest_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"testing/quick"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmptest"
+)
+
+// fakeTB records the failures reported to it. Unlike a real testing.TB,
+// Fatalf does not stop the calling goroutine.
+type fakeTB struct {
+	testing.TB
+	helper bool
+	errors []string
+	fatals []string
+}
+
+func (t *fakeTB) Helper() { t.helper = true }
+
+func (t *fakeTB) Errorf(f string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(f, args...))
+}
+
+func (t *fakeTB) Fatalf(f string, args ...interface{}) {
+	t.fatals = append(t.fatals, fmt.Sprintf(f, args...))
+}
+
+func TestDiff(t *testing.T) {
+	equalInts := cmp.Comparer(func(x, y int) bool { return x == y })
+	tests := []struct {
+		label      string
+		fatal      bool
+		got, want  interface{}
+		opts       []cmp.Option
+		wantEqual  bool
+		wantErrors []string // Substrings of each reported error
+		wantFatals []string // Substrings of each reported fatal error
+	}{{
+		label:     "Equal",
+		got:       []int{1, 2},
+		want:      []int{1, 2},
+		wantEqual: true,
+	}, {
+		label:      "Unequal",
+		got:        []int{1, 2},
+		want:       []int{1, 3},
+		wantErrors: []string{"mismatch (-got +want):\n", "-", "2", "+", "3"},
+	}, {
+		label:      "UnequalFatal",
+		fatal:      true,
+		got:        "a",
+		want:       "b",
+		wantFatals: []string{"mismatch (-got +want):\n", `-`, `"a"`, `+`, `"b"`},
+	}, {
+		label:      "InvalidOptions",
+		got:        1,
+		want:       1,
+		opts:       []cmp.Option{equalInts, cmp.Comparer(func(x, y int) bool { return x == y })},
+		wantFatals: []string{"cmp.Diff panicked: ", "ambiguous set of options"},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.label, func(t *testing.T) {
+			ft := new(fakeTB)
+			eq := false
+			if tt.fatal {
+				cmptest.DiffFatal(ft, tt.got, tt.want, tt.opts...)
+			} else {
+				eq = cmptest.Diff(ft, tt.got, tt.want, tt.opts...)
+			}
+			if !tt.fatal && eq != tt.wantEqual {
+				t.Errorf("Diff() = %v, want %v", eq, tt.wantEqual)
+			}
+			if !ft.helper {
+				t.Errorf("Helper was not called")
+			}
+			checkReports(t, "Errorf", ft.errors, tt.wantErrors)
+			checkReports(t, "Fatalf", ft.fatals, tt.wantFatals)
+		})
+	}
+}
+
+func TestQuickCheckEqual(t *testing.T) {
+	type record struct {
+		Name  string
+		Score float64
+	}
+	lossless := func(r record) record {
+		b, err := json.Marshal(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out record
+		if err := json.Unmarshal(b, &out); err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+	if err := cmptest.QuickCheckEqual(lossless, nil); err != nil {
+		t.Errorf("QuickCheckEqual(lossless) = %v, want nil", err)
+	}
+
+	// The lossy codec truncates scores to float32 precision.
+	lossy := func(r record) record {
+		r.Score = float64(float32(r.Score))
+		return r
+	}
+	err := cmptest.QuickCheckEqual(lossy, nil)
+	qerr, ok := err.(*cmptest.QuickCheckError)
+	if !ok {
+		t.Fatalf("QuickCheckEqual(lossy) = %v, want *QuickCheckError", err)
+	}
+	in := qerr.In[0].(record)
+	if want := cmp.Diff(in, lossy(in)); qerr.Diff != want || !strings.Contains(err.Error(), want) {
+		t.Errorf("QuickCheckEqual(lossy) does not report the diff:\ngot:\n%v\nwant:\n%s", err, want)
+	}
+	approx := cmp.Comparer(func(x, y float64) bool { return float32(x) == float32(y) })
+	if err := cmptest.QuickCheckEqual(lossy, nil, approx); err != nil {
+		t.Errorf("QuickCheckEqual(lossy, approx) = %v, want nil", err)
+	}
+
+	if err := cmptest.QuickCheckEqual(lossy, nil, approx, cmp.Comparer(func(x, y float64) bool { return x == y })); err == nil {
+		t.Errorf("QuickCheckEqual(ambiguous) = nil, want an error")
+	} else if _, ok := err.(*cmp.Error); !ok {
+		t.Errorf("QuickCheckEqual(ambiguous) = %T, want *cmp.Error", err)
+	}
+	if _, ok := cmptest.QuickCheckEqual(func(int) string { return "" }, nil).(quick.SetupError); !ok {
+		t.Errorf("QuickCheckEqual(mismatched types) did not return a quick.SetupError")
+	}
+}
+
+// account loses data when round-tripped through JSON.
+type account struct {
+	Owner   string
+	Balance interface{} // JSON decodes every number as a float64
+	note    string      // Neither codec encodes unexported fields
+}
+
+func TestRoundTripEqual(t *testing.T) {
+	allow := cmp.AllowUnexported(account{})
+	x := account{Owner: "gopher", Balance: 42}
+	if d, err := cmptest.RoundTripEqual(cmptest.Gob, x, allow); d != "" || err != nil {
+		t.Errorf("RoundTripEqual(Gob) = (%q, %v), want no differences", d, err)
+	}
+	d, err := cmptest.RoundTripEqual(cmptest.JSON, x, allow)
+	if err != nil {
+		t.Fatalf("RoundTripEqual(JSON) error: %v", err)
+	}
+	if !strings.Contains(d, "{cmptest_test.account}.Balance:\n") {
+		t.Errorf("RoundTripEqual(JSON) does not report the Balance:\n%s", d)
+	}
+
+	x.note = "lost"
+	for _, c := range []cmptest.Codec{cmptest.Gob, cmptest.JSON} {
+		d, err := cmptest.RoundTripEqual(c, x, allow)
+		if err != nil || !strings.Contains(d, "{cmptest_test.account}.note:") {
+			t.Errorf("RoundTripEqual(%T) = (%q, %v), want the unexported field reported", c, d, err)
+		}
+	}
+	if _, err := cmptest.RoundTripEqual(cmptest.Gob, x); err == nil {
+		t.Errorf("RoundTripEqual(unexported) error = nil, want misuse reported")
+	}
+	if _, err := cmptest.RoundTripEqual(cmptest.JSON, nil); err == nil {
+		t.Errorf("RoundTripEqual(nil) error = nil, want an error")
+	}
+	if _, err := cmptest.RoundTripEqual(cmptest.Gob, func() {}); err == nil || !strings.Contains(err.Error(), "cannot encode") {
+		t.Errorf("RoundTripEqual(func) error = %v, want an encoding error", err)
+	}
+}
+
+// checkReports checks that exactly one message was reported if substrs is
+// non-empty, and that it contains each of substrs. Otherwise, it checks that
+// no messages were reported.
+func checkReports(t *testing.T, name string, msgs, substrs []string) {
+	t.Helper()
+	switch {
+	case len(substrs) == 0 && len(msgs) > 0:
+		t.Errorf("unexpected %s calls: %q", name, msgs)
+	case len(substrs) > 0 && len(msgs) != 1:
+		t.Errorf("got %d %s calls, want 1: %q", len(msgs), name, msgs)
+	case len(substrs) > 0:
+		for _, s := range substrs {
+			if !strings.Contains(msgs[0], s) {
+				t.Errorf("%s message %q does not contain %q", name, msgs[0], s)
+			}
+		}
+	}
+}
@@ -26,9 +26,18 @@
 package cmp
 
 import (
+	"bytes"
+	"encoding"
 	"fmt"
+	"math"
+	"math/big"
+	"math/rand"
 	"reflect"
 	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode/utf8"
 )
 
 // BUG: Maps with keys containing NaN values cannot be properly compared due to
@@ -41,10 +50,16 @@ import (
 // following rules in the given order to x and y and all of their sub-values:
 //
 // • If two values are not of the same type, then they are never equal
-// and the overall result is false.
+// and the overall result is false. Two untyped nils (e.g., Equal(nil, nil))
+// are equal, but an untyped nil is never equal to a typed value,
+// including a typed nil such as (*int)(nil).
+// As exceptions, the CompareFieldsByName, EquatePointersToValues,
+// EquateBytesAndStrings, EquateRunesAndStrings, EquateNumbersInInterfaces,
+// and EquateStructsToMaps options allow certain values of different types to be compared.
 //
 // • Let S be the set of all Ignore, Transformer, and Comparer options that
 // remain after applying all path filters, value filters, and type filters.
+// Only the options in S with the highest priority (see Prioritized) are kept.
 // If at least one Ignore exists in S, then the comparison is ignored.
 // If the number of Transformer and Comparer options in S is greater than one,
 // then Equal panics because it is ambiguous which option to use.
@@ -57,14 +72,27 @@ import (
 // • If the values have an Equal method of the form "(T) Equal(T) bool" or
 // "(T) Equal(I) bool" where T is assignable to I, then use the result of
 // x.Equal(y). Otherwise, no such method exists and evaluation proceeds to
-// the next rule.
+// the next rule. For the concrete value of an interface, an Equal method of
+// the form "(*T) Equal(*T) bool" or "(*T) Equal(I) bool" is also used by
+// calling it on copies of the values, regardless of whether T is exported.
+// An Equal method promoted through an embedded interface is
+// only used if that interface is non-nil in both values, since it would
+// otherwise panic; instead, the struct fields are compared.
+// If the CompareViaTextMarshaler option is used and the values implement
+// encoding.TextMarshaler, then the marshaled text of x and y is compared.
 //
 // • Lastly, try to compare x and y based on their basic kinds.
 // Simple kinds like booleans, integers, floats, complex numbers, strings, and
 // channels are compared using the equivalent of the == operator in Go.
-// Functions are only equal if they are both nil, otherwise they are unequal.
+// Functions are only equal if they are both nil or are copies of the same
+// function value (including any variables captured by a closure),
+// otherwise they are unequal. On platforms where package unsafe cannot be
+// used, function values cannot be identified, so non-nil functions are never
+// equal. Channels are only equal if they are the same channel.
 // Pointers are equal if the underlying values they point to are also equal.
 // Interfaces are equal if their underlying concrete values are also equal.
+// As an exception, reflect.Type values are opaque and are only equal if they
+// are both nil or represent the identical type.
 //
 // Structs are equal if all of their fields are equal. If a struct contains
 // unexported fields, Equal panics unless the AllowUnexported option is used or
@@ -76,22 +104,42 @@ import (
 // comparison for map keys, use a Transformer to convert the map to a
 // corresponding slice type.
 func Equal(x, y interface{}, opts ...Option) bool {
+	if len(opts) == 0 {
+		if eq, ok := plainEqual(x, y); ok {
+			return eq
+		}
+	}
+	defer panicMessage()
+	return equal(x, y, opts)
+}
+
+// equal is Equal, but panics with an *Error on misuse.
+func equal(x, y interface{}, opts []Option) bool {
 	s := newState(opts)
 	s.compareAny(reflect.ValueOf(x), reflect.ValueOf(y))
-	return s.eq
+	s.finish()
+	eq := s.eq
+	s.release()
+	return eq
 }
 
 // Diff returns a human-readable report of the differences between two values.
 // It returns an empty string if and only if Equal returns true for the same
 // input values and options. The output string will use the "-" symbol to
 // indicate elements removed from x, and the "+" symbol to indicate elements
-// added to y.
+// added to y. Use MaxReportSize to bound the size of the output.
 //
 // Do not depend on this output being stable.
 func Diff(x, y interface{}, opts ...Option) string {
+	defer panicMessage()
+	return diff(x, y, opts)
+}
+
+// diff is Diff, but panics with an *Error on misuse.
+func diff(x, y interface{}, opts []Option) string {
 	r := new(defaultReporter)
 	opts = append(opts[:len(opts):len(opts)], r) // Force copy when appending
-	eq := Equal(x, y, opts...)
+	eq := equal(x, y, opts)
 	d := r.String()
 	if (d == "") != eq {
 		panic("inconsistent difference and equality results")
@@ -99,40 +147,755 @@ func Diff(x, y interface{}, opts ...Option) string {
 	return d
 }
 
+// EqualE is like Equal, but returns an *Error rather than panicking if
+// the options are misused or the values cannot be compared with them,
+// such as for an ambiguous set of options or an unexported field.
+// Panics that are not caused by misuse, including those in user provided
+// functions, are not recovered.
+func EqualE(x, y interface{}, opts ...Option) (eq bool, err error) {
+	defer recoverError(&err)
+	return equal(x, y, opts), nil
+}
+
+// DiffE is like Diff, but returns an *Error rather than panicking in the
+// same cases as EqualE.
+func DiffE(x, y interface{}, opts ...Option) (d string, err error) {
+	defer recoverError(&err)
+	return diff(x, y, opts), nil
+}
+
+// AssertionFunc returns a function that compares its arguments according to
+// the same rules as Equal with the given options, for use as the custom
+// comparison hook of assertion libraries. The function reports whether the
+// values are equal, and if they are not, a failure message containing the
+// report of their differences as produced by Diff.
+//
+// Misuse of the options, which would cause Diff to panic, is instead
+// reported as a failure message, as by DiffE.
+//
+// For example, the Check method of a gocheck Checker may compare the obtained
+// and expected values with:
+//
+//	func (c *cmpChecker) Check(params []interface{}, names []string) (bool, string) {
+//		return cmp.AssertionFunc(c.opts...)(params[0], params[1])
+//	}
+func AssertionFunc(opts ...Option) func(x, y interface{}) (bool, string) {
+	return func(x, y interface{}) (bool, string) {
+		d, err := DiffE(x, y, opts...)
+		if err != nil {
+			return false, fmt.Sprintf("cannot compare values: %v", err)
+		}
+		if d != "" {
+			return false, "values are not equal (-x +y):\n" + d
+		}
+		return true, ""
+	}
+}
+
+// Validate reports misuse of the options that does not depend on the values
+// being compared, which Equal would otherwise only panic with once values are
+// compared. This includes unknown and unfiltered options, Comparers that panic
+// when called with nil pointers (see DisableNilProbe), and two Comparers or
+// Transformers that would apply to every value of the same type.
+// The functions passed to options are already validated when the options are
+// created. The error returned is an *Error.
+//
+// Since no values are provided, misuse that depends on them, such as
+// ambiguous options that only apply to the same values for some paths,
+// is not reported.
+func Validate(opts ...Option) (err error) {
+	defer recoverError(&err)
+	s := newState(opts)
+	s.checkConflicts()
+	s.release()
+	return nil
+}
+
+// CheckInvariants checks that the options satisfy the invariants of Equal and
+// Diff for pairs of values generated by gen, which is called with a source of
+// randomness seeded deterministically, such that failures are reproducible.
+// For each pair of values x and y, it checks that:
+//
+// • Equal(x, x) is true.
+//
+// • Equal(x, y) is the same as Equal(y, x).
+//
+// • Diff(x, y) is empty if and only if Equal(x, y) is true.
+//
+// It returns an *InvariantError for the first violation, which includes the
+// offending values, or for the first comparison that panics, such as when
+// a user provided function is found to not be symmetric or the options are
+// misused.
+func CheckInvariants(gen func(*rand.Rand) interface{}, opts ...Option) error {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < invariantChecks; i++ {
+		x, y := gen(r), gen(r)
+		if eq, msg := tryEqual(x, x, opts); msg != "" || !eq {
+			return &InvariantError{"Equal(x, x) is false", msg, x, x}
+		}
+		exy, msg := tryEqual(x, y, opts)
+		if msg != "" {
+			return &InvariantError{"Equal(x, y) panicked", msg, x, y}
+		}
+		eyx, msg := tryEqual(y, x, opts)
+		if msg != "" || eyx != exy {
+			return &InvariantError{"Equal(x, y) differs from Equal(y, x)", msg, x, y}
+		}
+		d, msg := tryDiff(x, y, opts)
+		if msg != "" || (d == "") != exy {
+			return &InvariantError{"Diff(x, y) is inconsistent with Equal(x, y)", msg, x, y}
+		}
+	}
+	return nil
+}
+
+// invariantChecks is the number of pairs of values checked by CheckInvariants.
+const invariantChecks = 100
+
+// tryEqual is like equal, but returns the message of any panic.
+func tryEqual(x, y interface{}, opts []Option) (eq bool, msg string) {
+	defer recoverPanic(&msg)
+	return equal(x, y, opts), ""
+}
+
+// tryDiff is like diff, but returns the message of any panic.
+func tryDiff(x, y interface{}, opts []Option) (d, msg string) {
+	defer recoverPanic(&msg)
+	return diff(x, y, opts), ""
+}
+
+// recoverPanic recovers a panic and stores its message.
+func recoverPanic(msg *string) {
+	if ex := recover(); ex != nil {
+		*msg = fmt.Sprint(ex)
+	}
+}
+
+// InvariantError is a violation of an invariant found by CheckInvariants.
+type InvariantError struct {
+	// Invariant describes the violated invariant.
+	Invariant string
+
+	// Panic is the message of the panic that occurred while checking
+	// the invariant, if any.
+	Panic string
+
+	// X and Y are the generated values that violate the invariant.
+	X, Y interface{}
+}
+
+func (e *InvariantError) Error() string {
+	s := fmt.Sprintf("%s for:\n\tx: %s\n\ty: %s",
+		e.Invariant, prettyPrint(reflect.ValueOf(e.X), false), prettyPrint(reflect.ValueOf(e.Y), false))
+	if e.Panic != "" {
+		s += "\npanic: " + e.Panic
+	}
+	return s
+}
+
+// Error is a misuse of this package detected by Equal or Diff, which
+// EqualE and DiffE return rather than panic with.
+type Error struct {
+	// Path is the path to the values being compared when the misuse was
+	// detected. It is nil if the misuse is not specific to any values,
+	// such as for an invalid option.
+	Path Path
+
+	msg string
+}
+
+func (e *Error) Error() string {
+	return e.msg
+}
+
+// fail panics with an *Error with the formatted message, which occurred
+// at the path p, if non-nil.
+func fail(p Path, format string, args ...interface{}) {
+	err := &Error{msg: fmt.Sprintf(format, args...)}
+	if p != nil {
+		err.Path = p.clone()
+	}
+	panic(err)
+}
+
+// panicMessage converts a panic with an *Error into a panic with its message,
+// which is how misuse is reported by all functions other than EqualE and DiffE.
+// It must be called directly by a deferred call.
+func panicMessage() {
+	if ex := recover(); ex != nil {
+		if err, ok := ex.(*Error); ok {
+			panic(err.msg)
+		}
+		panic(ex)
+	}
+}
+
+// recoverError recovers a panic with an *Error and stores it in err.
+// It must be called directly by a deferred call.
+func recoverError(err *error) {
+	if ex := recover(); ex != nil {
+		e, ok := ex.(*Error)
+		if !ok {
+			panic(ex)
+		}
+		*err = e
+	}
+}
+
+// MustEqual panics if x and y are unequal, according to the same rules as
+// Equal, and otherwise returns normally. It is intended for invariants
+// outside of tests, such as in data migrations or initialization, that should
+// crash with a readable explanation when violated.
+//
+// The panic value is a *DiffError, whose message contains the full report
+// produced by Diff for the same input values and options.
+func MustEqual(x, y interface{}, opts ...Option) {
+	r := &defaultReporter{record: true}
+	opts = append(opts[:len(opts):len(opts)], r) // Force copy when appending
+	if !Equal(x, y, opts...) {
+		panic(&DiffError{Report: r.String(), Differences: r.diffs})
+	}
+}
+
+// DiffError is the panic value of MustEqual when the values are unequal.
+type DiffError struct {
+	// Report is the report of the differences as returned by Diff.
+	Report string
+
+	// Differences are all of the differences in the order that they were
+	// found, including any that were elided from Report.
+	Differences []Difference
+}
+
+// Difference is a pair of unequal values found when comparing two values.
+type Difference struct {
+	Path Path          // Path from the root values to X and Y
+	X, Y reflect.Value // Unequal values, either of which may be invalid
+}
+
+func (e *DiffError) Error() string {
+	return "values are not equal (-x +y):\n" + e.Report
+}
+
+// EqualAt reports whether the sub-values of x and y at the given path
+// expression are equal, according to the same rules as Equal.
+// See Within for the syntax of a path expression. Pointers and interfaces
+// along the path are automatically followed.
+//
+// It reports an error if the path expression is invalid or cannot be resolved
+// on either x or y (e.g., because of a nil pointer or a missing map key).
+func EqualAt(path string, x, y interface{}, opts ...Option) (bool, error) {
+	pe, err := parsePathExpr(path)
+	if err != nil {
+		return false, fmt.Errorf("invalid path expression %q: %v", path, err)
+	}
+	vx, px, err := pe.resolve(reflect.ValueOf(x))
+	if err != nil {
+		return false, fmt.Errorf("cannot resolve %q on x: %v", path, err)
+	}
+	vy, _, err := pe.resolve(reflect.ValueOf(y))
+	if err != nil {
+		return false, fmt.Errorf("cannot resolve %q on y: %v", path, err)
+	}
+	defer panicMessage()
+	s := newState(opts)
+	s.curPath = append(s.curPath, px...) // Report paths relative to the original values
+	s.compareAny(vx, vy)
+	s.finish()
+	eq := s.eq
+	s.release()
+	return eq, nil
+}
+
+// DiffAt returns a human-readable report of the differences between the
+// sub-values of x and y at the given path expression.
+// It is to EqualAt as Diff is to Equal.
+func DiffAt(path string, x, y interface{}, opts ...Option) (string, error) {
+	r := new(defaultReporter)
+	opts = append(opts[:len(opts):len(opts)], r) // Force copy when appending
+	eq, err := EqualAt(path, x, y, opts...)
+	if err != nil {
+		return "", err
+	}
+	d := r.String()
+	if (d == "") != eq {
+		panic("inconsistent difference and equality results")
+	}
+	return d, nil
+}
+
+// Explain compares x and y according to the same rules as Equal and
+// explains how each part of the comparison was decided. Every leaf value,
+// every difference, and every ignored sub-value is described by a Decision
+// naming the mechanism that decided it (e.g., a Comparer, an Equal method,
+// an Ignore option, or the default == rules), together with any
+// Transformers that were applied along the way.
+//
+// Explain is intended for debugging surprising results of Equal and Diff.
+// Parallel options are ignored, since decisions are recorded in order.
+func Explain(x, y interface{}, opts ...Option) *Explanation {
+	defer panicMessage()
+	e := new(Explanation)
+	s := newState(opts)
+	s.explain = &e.Decisions
+	s.lazyPath, s.workers = false, 0 // Every decision needs its full path
+	s.fastBytes = false              // Every byte is a decision of its own
+	s.compareAny(reflect.ValueOf(x), reflect.ValueOf(y))
+	s.finish()
+	e.Equal = s.eq
+	s.release()
+	return e
+}
+
+// Explanation is the result of Explain.
+type Explanation struct {
+	Equal     bool       // Whether the values are equal, as reported by Equal
+	Decisions []Decision // Every decision in the order that it was made
+}
+
+// String formats the explanation with one decision per line.
+func (e *Explanation) String() string {
+	var b strings.Builder
+	for _, d := range e.Decisions {
+		b.WriteString(d.String())
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// Decision describes how the values at a single path were decided.
+type Decision struct {
+	Path      Path      // Path from the root values to the decided values
+	Equal     bool      // Whether the values were reported as equal
+	Mechanism Mechanism // What decided the values
+	Name      string    // Name of the function or option used, if any
+}
+
+// Transforms returns the names of the Transformers applied along the path,
+// in the order that they were applied.
+func (d Decision) Transforms() []string {
+	var names []string
+	for _, ps := range d.Path {
+		if t, ok := ps.(Transform); ok {
+			names = append(names, t.Name())
+		}
+	}
+	return names
+}
+
+func (d Decision) String() string {
+	result := "unequal"
+	if d.Equal {
+		result = "equal"
+	}
+	str := fmt.Sprintf("%#v: %s by %v", d.Path, result, d.Mechanism)
+	if d.Name != "" {
+		str += " " + d.Name
+	}
+	if ts := d.Transforms(); len(ts) > 0 {
+		str += " (via " + strings.Join(ts, ", ") + ")"
+	}
+	return str
+}
+
+// Mechanism is what decided the equality of a pair of values.
+type Mechanism int
+
+const (
+	// ByDefaultRules means the values were compared with == or were found
+	// to be different by their structure (e.g., different lengths or types).
+	ByDefaultRules Mechanism = iota
+	// ByComparer means the values were compared by a Comparer option.
+	ByComparer
+	// ByEqualMethod means the values were compared by their Equal method.
+	ByEqualMethod
+	// ByTextMarshaler means the values were compared by their text encoding,
+	// as enabled by CompareViaTextMarshaler.
+	ByTextMarshaler
+	// ByIgnore means the values were not compared because of an Ignore option.
+	ByIgnore
+)
+
+func (m Mechanism) String() string {
+	switch m {
+	case ByDefaultRules:
+		return "default rules"
+	case ByComparer:
+		return "Comparer"
+	case ByEqualMethod:
+		return "Equal method"
+	case ByTextMarshaler:
+		return "text marshaler"
+	case ByIgnore:
+		return "Ignore"
+	default:
+		return fmt.Sprintf("Mechanism(%d)", int(m))
+	}
+}
+
 type state struct {
-	eq      bool // Current result of comparison
-	curPath Path // The current path in the value tree
+	eq      bool             // Current result of comparison
+	curPath Path             // The current path in the value tree
+	numCmp  int              // Number of values actually compared
+	steps   []*stepCache     // Reusable path steps for each depth of curPath
+	args    [2]reflect.Value // Reusable arguments for calls to user functions
 
-	// dsCheck tracks the state needed to periodically perform checks that
-	// user provided func(T, T) bool functions are symmetric and deterministic.
+	// dsCalls counts the calls made to each user provided func(T, T) bool
+	// function, keyed by its code pointer, in order to schedule checks that
+	// the function is symmetric and deterministic.
 	//
-	// Checks occur every Nth function call, where N is a triangular number:
-	//	0 1 3 6 10 15 21 28 36 45 55 66 78 91 105 120 136 153 171 190 ...
-	// See https://en.wikipedia.org/wiki/Triangular_number
+	// The first N calls to each function are checked, after which checks
+	// occur with exponentially decreasing frequency:
+	//	0 1 2 ... N-1 2N 4N 8N 16N 32N ...
 	//
-	// This sequence ensures that the cost of checks drops significantly as
-	// the number of functions calls grows larger.
-	dsCheck struct{ curr, next int }
+	// This catches misbehaving functions early, while the cost of checks for
+	// well-behaved functions becomes negligible as the number of calls
+	// grows larger.
+	//
+	// The schedule may be altered by a functionChecks or checkSamples option.
+	dsCalls map[uintptr]int
+	dsRand  *rand.Rand // Only used when checks is checkRandom
 
 	// These fields, once set by processOption, will not change.
 	exporters map[reflect.Type]bool // Set of structs with unexported field visibility
+	inPlace   map[reflect.Type]bool // Set of structs whose unexported fields are not copied
 	optsIgn   []option              // List of all ignore options without value filters
 	opts      []option              // List of all other options
 	reporter  reporter              // Optional reporter used for difference formatting
+	limited   limitedReporter       // Reporter if it may stop accepting differences
+	reportMax int                   // Maximum size of the report in bytes
+	details   bool                  // Report details when a Comparer reports unequal
+	checks    functionChecks        // Schedule for checking user functions
+	samples   int                   // Number of initial calls to check
+	maxDepth  int                   // Maximum number of steps below the root
+	nilIfaces bool                  // Treat interfaces holding typed nils as nil
+	aliasFunc func(Path, uintptr)   // Optional callback for aliased references
+	strictTr  bool                  // Panic when a Transformer reports an error
+	noProbe   bool                  // Skip probing Comparers with nil pointers
+	noDefault bool                  // Skip options from RegisterDefaultOptions
+	auditFunc func(Path, string)    // Optional callback for ignored differences
+	strictIgn bool                  // Treat ignored differences as differences
+	textMarsh bool                  // Compare values by encoding.TextMarshaler
+	byName    []fieldsByName        // Struct types compared by field names
+	derefPtrs bool                  // Compare pointers against values of *T
+	bytesStrs bool                  // Compare byte slices against strings
+	runesStrs bool                  // Compare rune slices against strings
+	numIfaces bool                  // Compare numbers of different kinds in interfaces
+	toMaps    *structsToMaps        // Convert structs to maps to compare against maps
+	shallow   bool                  // Compare references below the root by identity
+	drained   *sync.Map             // Contents of each channel drained so far
+	short     bool                  // Stop traversal at the first difference
+	workers   int                   // Maximum number of goroutines for large values
+	minCmp    int                   // Minimum number of values that must be compared
+	countFunc func(int)             // Optional callback for the number of comparisons
+	fastBytes bool                  // Compare byte sequences without walking each byte
+	fastTypes fastCache             // Types that may be compared using ==
+	plainType bool                  // Whether fastType only depends on the type
+	lazyPath  bool                  // Record struct fields lazily in curPath
+	newSteps  bool                  // Allocate path steps that user functions may retain
+	mapKeys   bool                  // Match map keys according to the options
+	typeOpts  optionCache           // Options that may apply to each type
+	methods   methodCache           // Equal methods of each type
+	explain   *[]Decision           // Optional record of how values were decided
+	scopes    []reflect.Type        // Optioners whose options are in effect
+
+	inAlias bool // Whether the current node is beneath a reported alias
+	inAudit bool // Whether the current node is beneath an audited Ignore
+	inChunk bool // Whether the current node is compared by a parallel worker
+}
+
+// finish reports the number of comparisons performed once the comparison
+// of the root values is complete, and verifies that it is not vacuous.
+func (s *state) finish() {
+	if s.countFunc != nil {
+		s.countFunc(s.numCmp)
+	}
+	if s.numCmp < s.minCmp && !s.stopped() {
+		fail(nil, "vacuous comparison: %d values were compared, but at least %d are required", s.numCmp, s.minCmp)
+	}
 }
 
+// defaultMaxDepth is the default maximum depth of the value tree.
+// It is large enough for any sensible value, but small enough that
+// traversal panics before the goroutine stack is exhausted.
+const defaultMaxDepth = 100000
+
+// statePool holds states whose buffers and caches may be reused by
+// later calls to Equal, which reduces the allocations made for each call.
+var statePool = sync.Pool{New: func() interface{} { return new(state) }}
+
 func newState(opts []Option) *state {
-	s := &state{eq: true}
+	s := statePool.Get().(*state)
+	s.eq, s.maxDepth, s.samples = true, defaultMaxDepth, defaultCheckSamples
 	for _, opt := range opts {
 		s.processOption(opt)
 	}
-	// Sort options such that Ignore options are evaluated first.
-	sort.SliceStable(s.opts, func(i, j int) bool {
-		return s.opts[i].op == nil && s.opts[j].op != nil
-	})
+	var defaults []option
+	if !s.noDefault {
+		defaults = registeredOptions()
+		for _, opt := range defaults {
+			s.processOption(opt)
+		}
+	}
+	if s.reporter != nil {
+		s.short = false // Every difference must be found to be reported
+		if _, ok := s.reporter.(concurrentReporter); !ok && s.workers > 1 {
+			fail(nil, "cannot use Parallel with a reporter that is not safe for concurrent use")
+		}
+		if r, ok := s.reporter.(*defaultReporter); ok && s.reportMax > 0 {
+			r.limit = s.reportMax
+		}
+		s.limited, _ = s.reporter.(limitedReporter)
+	}
+	// The plain fast types account for the registered default options,
+	// so they cannot be used if those are disabled.
+	s.plainType = len(s.opts)+len(s.optsIgn) == len(defaults) && len(s.exporters) == 0 && !s.textMarsh &&
+		!(s.noDefault && registeredOptions() != nil)
+	// Without any path filters or reporter, the path is rarely observed,
+	// so struct fields in the path are only looked up if needed.
+	s.lazyPath = s.reporter == nil
+	s.fastBytes = true
+	s.prepareOptions()
 	return s
 }
 
+// prepareOptions sorts the options and derives the settings that depend on
+// them, which may only be disabled by the options.
+func (s *state) prepareOptions() {
+	// Sort options such that higher priority options are evaluated first,
+	// and such that Ignore options are evaluated first within each priority.
+	if len(s.opts) > 1 {
+		sort.SliceStable(s.opts, func(i, j int) bool {
+			if s.opts[i].priority != s.opts[j].priority {
+				return s.opts[i].priority > s.opts[j].priority
+			}
+			return s.opts[i].op == nil && s.opts[j].op != nil
+		})
+	}
+	if !s.noProbe {
+		for _, opt := range s.opts {
+			probeComparer(opt)
+		}
+	}
+	for _, opts := range [2][]option{s.opts, s.optsIgn} {
+		for i := range opts {
+			// Path filters observe the path.
+			if observesPath(opts[i]) {
+				s.lazyPath, s.newSteps = false, true
+			}
+			if filtersMapKeys(opts[i]) {
+				s.mapKeys = true
+			}
+			// Byte sequences may only be compared in one shot if no option
+			// could possibly apply to any individual byte.
+			if t := opts[i].typeFilter; t == nil || byteType.AssignableTo(t) {
+				s.fastBytes = false
+			}
+		}
+	}
+}
+
+// release resets s and returns it to the pool once a comparison has
+// completed. A state abandoned by a panic is never released, since it may be
+// left in an inconsistent state (e.g., with steps still pushed onto curPath).
+func (s *state) release() {
+	// Clear all references to user values and options so that the pool
+	// does not keep them alive, but retain the allocated memory.
+	curPath := s.curPath
+	if s.newSteps {
+		curPath = nil // User functions may retain the path
+	}
+	for i := range curPath {
+		curPath[i] = nil
+	}
+	for _, st := range s.steps {
+		*st = stepCache{}
+	}
+	for i := range s.opts {
+		s.opts[i] = option{}
+	}
+	for i := range s.optsIgn {
+		s.optsIgn[i] = option{}
+	}
+	for i := range s.byName {
+		s.byName[i] = fieldsByName{}
+	}
+	for _, m := range []map[reflect.Type]bool{s.exporters, s.inPlace} {
+		for t := range m {
+			delete(m, t)
+		}
+	}
+	for t := range s.fastTypes {
+		delete(s.fastTypes, t)
+	}
+	for t := range s.typeOpts {
+		delete(s.typeOpts, t)
+	}
+	for p := range s.dsCalls {
+		delete(s.dsCalls, p)
+	}
+	*s = state{
+		curPath:   curPath[:0],
+		steps:     s.steps,
+		exporters: s.exporters,
+		inPlace:   s.inPlace,
+		optsIgn:   s.optsIgn[:0],
+		opts:      s.opts[:0],
+		byName:    s.byName[:0],
+		fastTypes: s.fastTypes,
+		typeOpts:  s.typeOpts,
+		methods:   s.methods,
+		dsCalls:   s.dsCalls,
+	}
+	statePool.Put(s)
+}
+
+// probeComparer calls a Comparer on pointers with two nil pointers to catch
+// the common mistake of a comparer that dereferences its inputs without
+// checking for nil. Comparers with a value filter are not probed since
+// the filter may already guard against nil pointers.
+// Each comparer is only probed once, but a failed probe panics on every use.
+func probeComparer(opt option) {
+	cmp, ok := opt.op.(*comparer)
+	if !ok || len(opt.valueFilters)+len(opt.inverted) > 0 {
+		return
+	}
+	cmp.probe.Do(func() { cmp.probeEx = probeNil(cmp.fnc) })
+	if cmp.probeEx != "" {
+		fail(nil, "%s", cmp.probeEx)
+	}
+}
+
+// checkConflicts panics if two Comparers or Transformers would apply to every
+// value of the same type with the same priority, unless an Ignore option
+// could apply to values of that type instead.
+func (s *state) checkConflicts() {
+	for i, o1 := range s.opts {
+		if !isUnconditional(o1) || s.mayIgnore(o1.typeFilter, o1.priority) {
+			continue
+		}
+		for _, o2 := range s.opts[i+1:] {
+			if isUnconditional(o2) && o2.typeFilter == o1.typeFilter && o2.priority == o1.priority {
+				fail(nil, "ambiguous set of options for type %v:\n\t%v\n\t%v\n"+
+					"consider using filters to ensure at most one Comparer or Transformer may apply",
+					o1.typeFilter, indentOption(o1), indentOption(o2))
+			}
+		}
+	}
+}
+
+// appendOption appends opt to opts unless opts already has a copy of it.
+func appendOption(opts []option, opt option) []option {
+	for _, o := range opts {
+		if sameOption(o, opt) {
+			return opts
+		}
+	}
+	return append(opts, opt)
+}
+
+// isUnfiltered reports whether opt applies to all values. An AnyOf or AllOf
+// is unfiltered if any of its comparers are.
+func isUnfiltered(opt option) bool {
+	if len(opt.pathFilters)+len(opt.valueFilters)+len(opt.inverted) > 0 {
+		return false
+	}
+	if c, ok := opt.op.(*combination); ok {
+		for _, o := range c.opts {
+			if isUnfiltered(o) {
+				return true
+			}
+		}
+		return false
+	}
+	return opt.typeFilter == nil
+}
+
+// observesPath reports whether any path filter of opt, including those of
+// inverted filters and of the comparers of an AnyOf or AllOf, may observe
+// the path.
+func observesPath(opt option) bool {
+	if len(opt.pathFilters)+len(opt.inverted) > 0 {
+		return true // Inverted filters are assumed to have path filters
+	}
+	if c, ok := opt.op.(*combination); ok {
+		for _, o := range c.opts {
+			if observesPath(o) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// filtersMapKeys reports whether opt, or any of the comparers of an AnyOf or
+// AllOf, is only evaluated for map keys (see FilterMapKeys).
+func filtersMapKeys(opt option) bool {
+	for _, f := range opt.pathFilters {
+		if reflect.ValueOf((func(Path) bool)(f)).Pointer() == reflect.ValueOf(inMapKey).Pointer() {
+			return true
+		}
+	}
+	if c, ok := opt.op.(*combination); ok {
+		for _, o := range c.opts {
+			if filtersMapKeys(o) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isUnconditional reports whether opt is a Comparer or Transformer that
+// applies to every value its type filter permits.
+func isUnconditional(opt option) bool {
+	if opt.op == nil || len(opt.pathFilters)+len(opt.valueFilters)+len(opt.inverted) > 0 {
+		return false
+	}
+	if _, ok := opt.op.(*combination); ok {
+		return false // An AnyOf or AllOf only applies where one of its comparers does
+	}
+	switch op := opt.op.(type) {
+	case *transformer:
+		return !op.fallible() && !op.once // A failed or reapplied Transformer does not apply
+	case *comparer:
+		return !op.deciding // An undecided Comparer does not apply
+	}
+	return true
+}
+
+// mayIgnore reports whether any Ignore option with at least the given
+// priority may apply to values of type t.
+func (s *state) mayIgnore(t reflect.Type, priority int) bool {
+	for _, opts := range [2][]option{s.opts, s.optsIgn} {
+		for _, opt := range opts {
+			if opt.op == nil && opt.priority >= priority && typeApplies(t, opt) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// probeNil calls the comparer f with two nil pointers if it compares
+// pointers, and returns the message to panic with if the call panicked.
+func probeNil(f reflect.Value) (msg string) {
+	t := f.Type().In(0)
+	if t.Kind() != reflect.Ptr {
+		return ""
+	}
+	defer func() {
+		if ex := recover(); ex != nil {
+			msg = fmt.Sprintf("comparer %s panicked when called with nil pointers: %v\n"+
+				"either handle nil inputs or use DisableNilProbe if they cannot occur",
+				getFuncName(f.Pointer()), ex)
+		}
+	}()
+	nilPtr := reflect.Zero(t)
+	f.Call([]reflect.Value{nilPtr, nilPtr})
+	return ""
+}
+
 func (s *state) processOption(opt Option) {
 	switch opt := opt.(type) {
 	case Options:
@@ -146,40 +909,141 @@ func (s *state) processOption(opt Option) {
 		for t := range opt {
 			s.exporters[t] = true
 		}
+	case inPlaceStructs:
+		s.processOption(visibleStructs(opt))
+		if s.inPlace == nil {
+			s.inPlace = make(map[reflect.Type]bool)
+		}
+		for t := range opt {
+			s.inPlace[t] = true
+		}
 	case option:
-		if opt.typeFilter == nil && len(opt.pathFilters)+len(opt.valueFilters) == 0 {
-			panic(fmt.Sprintf("cannot use an unfiltered option: %v", opt))
+		if isUnfiltered(opt) {
+			fail(nil, "cannot use an unfiltered option: %v", opt)
+		}
+		if _, ok := opt.op.(*channelContents); ok && s.drained == nil {
+			s.drained = new(sync.Map) // map[uintptr]reflect.Value
 		}
-		if opt.op == nil && len(opt.valueFilters) == 0 {
-			s.optsIgn = append(s.optsIgn, opt)
+		if opt.op == nil && len(opt.valueFilters)+len(opt.inverted) == 0 {
+			s.optsIgn = appendOption(s.optsIgn, opt)
 		} else {
-			s.opts = append(s.opts, opt)
+			s.opts = appendOption(s.opts, opt)
+		}
+	case comparerDetails:
+		s.details = true
+	case maxDepth:
+		s.maxDepth = int(opt)
+	case nilInterfaces:
+		s.nilIfaces = true
+	case aliasReporter:
+		s.aliasFunc, s.newSteps = opt, true
+	case minComparisons:
+		if int(opt) > s.minCmp {
+			s.minCmp = int(opt)
+		}
+	case comparisonCounter:
+		s.countFunc = opt
+	case strictTransformers:
+		s.strictTr = true
+	case nilProbe:
+		s.noProbe = true
+	case withoutDefaults:
+		s.noDefault = true
+	case ignoreAuditor:
+		s.auditFunc, s.newSteps = opt, true
+	case strictIgnores:
+		s.strictIgn = true
+	case textMarshaler:
+		s.textMarsh = true
+	case fieldsByName:
+		s.byName = append(s.byName, opt, fieldsByName{opt[1], opt[0]})
+	case pointersToValues:
+		s.derefPtrs = true
+	case bytesAndStrings:
+		s.bytesStrs = true
+	case runesAndStrings:
+		s.runesStrs = true
+	case numbersInInterfaces:
+		s.numIfaces = true
+	case structsToMaps:
+		s.toMaps = &opt
+	case shallowMode:
+		s.shallow = true
+	case shortCircuit:
+		s.short = true
+	case parallelWorkers:
+		s.workers = int(opt)
+	case reportLimit:
+		s.reportMax = int(opt)
+	case checkSamples:
+		s.samples = int(opt)
+	case functionChecks:
+		s.checks = opt
+		if opt.mode == checkRandom {
+			s.dsRand = rand.New(rand.NewSource(opt.seed))
 		}
 	case reporter:
 		if s.reporter != nil {
-			panic("difference reporter already registered")
+			fail(nil, "difference reporter already registered")
 		}
 		s.reporter = opt
 	default:
-		panic(fmt.Sprintf("unknown option %T", opt))
+		fail(nil, "unknown option %T", opt)
 	}
 }
 
 func (s *state) compareAny(vx, vy reflect.Value) {
 	// TODO: Support cyclic data structures.
+	if s.stopped() {
+		return
+	}
 
-	// Rule 0: Differing types are never equal.
-	if !vx.IsValid() || !vy.IsValid() {
-		s.report(vx.IsValid() == vy.IsValid(), vx, vy)
+	if s.tryMismatchedTypes(vx, vy) {
 		return
 	}
-	if vx.Type() != vy.Type() {
-		s.report(false, vx, vy) // Possible for path to be empty
+
+	// Rule 0: Differing types are never equal.
+	// Two untyped nils are equal, but an untyped nil is never equal to
+	// a typed value (even a typed nil).
+	if !vx.IsValid() || !vy.IsValid() || vx.Type() != vy.Type() {
+		if len(s.curPath) == 0 {
+			// The inputs to Equal are effectively of type interface{},
+			// so options on interface{} may apply to them as they would
+			// to the values of an interface{} within them.
+			s.curPath.push(&pathStep{typ: interfaceType})
+			defer s.curPath.pop()
+			ix, iy := interfaceValue(vx), interfaceValue(vy)
+			if s.tryOptions(&ix, &iy, interfaceType) {
+				return
+			}
+		}
+		s.report(vx.IsValid() == vy.IsValid() && !vx.IsValid(), vx, vy)
 		return
 	}
 	t := vx.Type()
 	if len(s.curPath) == 0 {
-		s.curPath.push(&pathStep{typ: t})
+		step := &s.nextSteps().root
+		*step = pathStep{typ: t}
+		s.curPath.push(step)
+	}
+	if len(s.curPath)-1 > s.maxDepth {
+		fail(s.curPath, "maximum depth of %d exceeded at %s; use MaxDepth to raise the limit or a Transformer to restructure the comparison", s.maxDepth, truncatePath(s.curPath))
+	}
+
+	if s.aliasFunc != nil && !s.inAlias && isAlias(vx, vy) {
+		s.aliasFunc(s.curPath.clone(), vx.Pointer())
+		s.inAlias = true
+		defer func() { s.inAlias = false }()
+	}
+
+	// Values to which no option, method, or other special rule could apply
+	// are compared before looking for any of them.
+	if s.tryFastEqual(vx, vy, t) {
+		return
+	}
+
+	if s.tryOptioner(vx, vy, t) {
+		return
 	}
 
 	// Rule 1: Check whether an option applies on this node in the value tree.
@@ -187,10 +1051,23 @@ func (s *state) compareAny(vx, vy reflect.Value) {
 		return
 	}
 
+	// Types are opaque, so compare them by identity.
+	if t.Implements(reflectTypeType) {
+		s.report(vx.IsNil() && vy.IsNil() || !vx.IsNil() && !vy.IsNil() && vx.Interface() == vy.Interface(), vx, vy)
+		return
+	}
+
+	if s.shallow && s.tryShallow(vx, vy, t) {
+		return
+	}
+
 	// Rule 2: Check whether the type has a valid Equal method.
 	if s.tryMethod(vx, vy, t) {
 		return
 	}
+	if s.textMarsh && s.tryTextMarshaler(vx, vy, t) {
+		return
+	}
 
 	// Rule 3: Recursively descend into each value's underlying kind.
 	switch t.Kind() {
@@ -212,31 +1089,63 @@ func (s *state) compareAny(vx, vy reflect.Value) {
 	case reflect.String:
 		s.report(vx.String() == vy.String(), vx, vy)
 		return
-	case reflect.Chan, reflect.UnsafePointer:
+	case reflect.UnsafePointer:
 		s.report(vx.Pointer() == vy.Pointer(), vx, vy)
 		return
-	case reflect.Func:
-		s.report(vx.IsNil() && vy.IsNil(), vx, vy)
+	case reflect.Chan:
+		eq := vx.Pointer() == vy.Pointer()
+		s.report(eq, vx, vy)
+		if !eq && !vx.IsNil() && !vy.IsNil() {
+			s.reportNote("channels are only equal if they are the same channel; " +
+				"consider using an Ignore or Comparer option for this value")
+		}
+		return
+	case reflect.Func:
+		eq := vx.IsNil() && vy.IsNil()
+		if !vx.IsNil() && !vy.IsNil() {
+			px, okx := unsafeFuncPointer(vx)
+			py, oky := unsafeFuncPointer(vy)
+			eq = okx && oky && px == py
+		}
+		s.report(eq, vx, vy)
+		if !eq && !vx.IsNil() && !vy.IsNil() {
+			s.reportNote("functions are only equal if they are both nil or the same function value; " +
+				"consider using an Ignore or Comparer option for this value")
+		}
 		return
 	case reflect.Ptr:
 		if vx.IsNil() || vy.IsNil() {
 			s.report(vx.IsNil() && vy.IsNil(), vx, vy)
 			return
 		}
-		s.curPath.push(&indirect{pathStep{t.Elem()}})
+		step := &s.nextSteps().indirect
+		*step = indirect{pathStep{t.Elem()}}
+		s.curPath.push(step)
 		defer s.curPath.pop()
 		s.compareAny(vx.Elem(), vy.Elem())
 		return
 	case reflect.Interface:
+		if s.nilIfaces {
+			if nx, ny := isNilInterface(vx), isNilInterface(vy); nx || ny {
+				s.report(nx && ny, vx, vy)
+				return
+			}
+		}
 		if vx.IsNil() || vy.IsNil() {
 			s.report(vx.IsNil() && vy.IsNil(), vx, vy)
 			return
 		}
 		if vx.Elem().Type() != vy.Elem().Type() {
-			s.report(false, vx.Elem(), vy.Elem())
+			// Values of different types may still be comparable by option,
+			// but there is no single type to assert.
+			if !s.tryMismatchedTypes(vx.Elem(), vy.Elem()) {
+				s.report(false, vx.Elem(), vy.Elem())
+			}
 			return
 		}
-		s.curPath.push(&typeAssertion{pathStep{vx.Elem().Type()}})
+		step := &s.nextSteps().typeAssertion
+		*step = typeAssertion{pathStep{vx.Elem().Type()}}
+		s.curPath.push(step)
 		defer s.curPath.pop()
 		s.compareAny(vx.Elem(), vy.Elem())
 		return
@@ -250,7 +1159,7 @@ func (s *state) compareAny(vx, vy reflect.Value) {
 		s.compareArray(vx, vy, t)
 		return
 	case reflect.Map:
-		s.compareMap(vx, vy, t)
+		s.compareMap(vx, vy, t, false)
 		return
 	case reflect.Struct:
 		s.compareStruct(vx, vy, t)
@@ -266,60 +1175,285 @@ func (s *state) compareAny(vx, vy reflect.Value) {
 func (s *state) tryOptions(vx, vy *reflect.Value, t reflect.Type) bool {
 	// Try all ignore options that do not depend on the value first.
 	// This avoids possible panics when processing unexported fields.
-	for _, opt := range s.optsIgn {
+	// An ignore may only be overridden by an option of higher priority.
+	to := s.optionsFor(t)
+	var ignored bool
+	var ignPrio int
+	var ignOpt option
+	for _, opt := range to.optsIgn {
 		var v reflect.Value // Dummy value; should never be used
-		if s.applyFilters(v, v, t, opt) {
-			return true // Ignore option applied
+		if s.applyFilters(v, v, opt) && (!ignored || opt.priority > ignPrio) {
+			ignored, ignPrio, ignOpt = true, opt.priority, opt
 		}
 	}
+	if ignored && (len(to.opts) == 0 || ignPrio >= to.opts[0].priority) {
+		s.explainIgnore(ignOpt)
+		s.auditIgnore(*vx, *vy)
+		return true // Ignore option applied
+	}
 
 	// Since the values must be used after this point, verify that the values
 	// are either exported or can be forcibly exported.
 	if sf, ok := s.curPath[len(s.curPath)-1].(*structField); ok && sf.unexported {
 		if !sf.force {
-			panic(fmt.Sprintf("cannot handle unexported field: %#v", s.curPath))
+			if ignored {
+				s.explainIgnore(ignOpt)
+				s.auditIgnore(*vx, *vy)
+				return true // Ignore option applied
+			}
+			fail(s.curPath, "cannot handle unexported field: %#v", s.curPath)
 		}
 
 		// Use unsafe pointer arithmetic to get read-write access to an
-		// unexported field in the struct.
+		// unexported field in the struct. Unless in-place access was
+		// requested, only hand out copies so that user functions cannot
+		// mutate the original structs.
 		*vx = unsafeRetrieveField(sf.pvx, sf.field)
 		*vy = unsafeRetrieveField(sf.pvy, sf.field)
+		if !s.inPlace[sf.pvx.Type()] {
+			*vx, *vy = copyValue(*vx), copyValue(*vy)
+		}
 	}
 
 	// Try all other options now.
-	optIdx := -1 // Index of Option to apply
-	for i, opt := range s.opts {
-		if !s.applyFilters(*vx, *vy, t, opt) {
+	optIdx := -1               // Index of Option to apply
+	var tvx, tvy reflect.Value // Results of a Transformer that may fail
+	var verdict Verdict        // Result of a Comparer that may not decide
+	for i, opt := range to.opts {
+		if optIdx >= 0 && opt.priority < to.opts[optIdx].priority {
+			break // Lower priority options cannot override the selected option
+		}
+		if ignored && opt.priority <= ignPrio {
+			break // Lower priority options cannot override the ignore
+		}
+		if !s.applyFilters(*vx, *vy, opt) {
 			continue
 		}
 		if opt.op == nil {
+			s.explainIgnore(opt)
+			s.auditIgnore(*vx, *vy)
 			return true // Ignored comparison
 		}
+		if c, ok := opt.op.(*combination); ok && len(s.applicable(*vx, *vy, t, c)) == 0 {
+			continue
+		}
+		if _, ok := opt.op.(*channelContents); ok && !drainable(*vx, *vy) {
+			continue
+		}
+		if tr, ok := opt.op.(*transformer); ok && tr.once && s.applied(tr) {
+			continue // The Transformer was already applied along this path
+		}
+		if c, ok := opt.op.(*comparer); ok && c.deciding {
+			// A Comparer that does not decide is treated as if it did not
+			// apply, so it is never ambiguous with another option.
+			v := s.callVerdict(c.fnc, *vx, *vy)
+			if v == Undecided {
+				continue
+			}
+			if optIdx < 0 {
+				verdict = v
+			}
+		}
 		if optIdx >= 0 {
-			panic(fmt.Sprintf("ambiguous set of options at %#v\n\n%v\n\n%v\n", s.curPath, s.opts[optIdx], opt))
+			fail(s.curPath, "ambiguous set of options at %#v for type %v:\n\t%v\n\t%v\n"+
+				"consider using filters to ensure at most one Comparer or Transformer may apply",
+				s.curPath, t, indentOption(to.opts[optIdx]), indentOption(opt))
+		}
+		if tr, ok := opt.op.(*transformer); ok && tr.fallible() {
+			// A Transformer that fails is treated as if it did not apply.
+			var errx, erry error
+			tvx, errx = s.callTransform(tr, *vx)
+			tvy, erry = s.callTransform(tr, *vy)
+			if errx != nil || erry != nil {
+				tvx, tvy = reflect.Value{}, reflect.Value{}
+				continue
+			}
 		}
 		optIdx = i
 	}
 	if optIdx >= 0 {
-		s.applyOption(*vx, *vy, t, s.opts[optIdx])
+		switch {
+		case tvx.IsValid():
+			s.compareTransformed(tvx, tvy, to.opts[optIdx].op.(*transformer))
+		case verdict != Undecided:
+			s.reportComparer(verdict == Same, *vx, *vy, to.opts[optIdx].op.(*comparer))
+		default:
+			s.applyOption(*vx, *vy, t, to.opts[optIdx])
+		}
 		return true
 	}
-	return false
+	if ignored {
+		s.auditIgnore(*vx, *vy)
+	}
+	return ignored
+}
+
+// auditIgnore compares vx and vy, which are about to be ignored, with all
+// Ignore options suppressed to determine whether the ignore is hiding
+// any differences. Depending on the options, the suppressed differences are
+// either passed to the audit function or reported as actual differences.
+// Values that cannot be evaluated (e.g., because of unexported fields)
+// are skipped with a note passed to the audit function.
+func (s *state) auditIgnore(vx, vy reflect.Value) {
+	if (s.auditFunc == nil && !s.strictIgn) || s.inAudit {
+		return
+	}
+	s2 := *s
+	s2.eq, s2.inAudit, s2.details, s2.aliasFunc, s2.explain = true, true, false, nil, nil
+	s2.optsIgn, s2.opts, s2.fastTypes, s2.typeOpts = nil, nil, nil, nil
+	for _, opt := range s.opts {
+		if opt.op != nil {
+			s2.opts = append(s2.opts, opt)
+		}
+	}
+	s2.curPath = append(Path(nil), s.curPath...)
+	if sf, ok := s2.curPath[len(s2.curPath)-1].(*structField); ok && sf.unexported {
+		sf2 := *sf
+		sf2.force = true // The ignored field itself may always be evaluated
+		s2.curPath[len(s2.curPath)-1] = &sf2
+	}
+	sr := new(defaultReporter)
+	if !s.strictIgn {
+		s2.reporter, s2.short = sr, false
+	}
+
+	var reason string
+	func() {
+		defer func() {
+			switch ex := recover().(type) {
+			case nil:
+			case *Error:
+				reason = ex.msg
+			case string:
+				reason = ex
+			default:
+				panic(ex)
+			}
+		}()
+		s2.compareAny(vx, vy)
+	}()
+	switch {
+	case s.strictIgn:
+		s.eq = s.eq && s2.eq
+		if !s2.eq {
+			s.reportNote("differences at %#v are suppressed by an Ignore option", s.curPath)
+		}
+	case reason != "":
+		s.auditFunc(s.curPath.clone(), fmt.Sprintf("(ignored values could not be evaluated: %s)", reason))
+	case !s2.eq:
+		s.auditFunc(s.curPath.clone(), sr.String())
+	}
+}
+
+// optionCache records for each type the options whose type filters
+// permit them to apply to values of that type.
+type optionCache map[reflect.Type]*typeOptions
+
+type typeOptions struct {
+	optsIgn []option // Subset of state.optsIgn, in the same order
+	opts    []option // Subset of state.opts, in the same order
+}
+
+// optionsFor returns the options that may apply to values of type t,
+// such that only the path and value filters remain to be evaluated.
+func (s *state) optionsFor(t reflect.Type) *typeOptions {
+	if len(s.opts)+len(s.optsIgn) == 0 {
+		return &noTypeOptions
+	}
+	if to, ok := s.typeOpts[t]; ok {
+		return to
+	}
+	var optsIgn, opts []option
+	for _, opt := range s.optsIgn {
+		if typeApplies(t, opt) {
+			optsIgn = append(optsIgn, opt)
+		}
+	}
+	for _, opt := range s.opts {
+		if typeApplies(t, opt) {
+			opts = append(opts, opt)
+		}
+	}
+	to := &noTypeOptions // Most types have no options, so avoid allocating
+	if len(optsIgn)+len(opts) > 0 {
+		to = &typeOptions{optsIgn, opts}
+	}
+	if s.typeOpts == nil {
+		s.typeOpts = make(optionCache)
+	}
+	s.typeOpts[t] = to
+	return to
 }
 
-func (s *state) applyFilters(vx, vy reflect.Value, t reflect.Type, opt option) bool {
-	if opt.typeFilter != nil {
-		if !t.AssignableTo(opt.typeFilter) {
+// noTypeOptions is the shared result of optionsFor for types to which
+// no options apply. It must not be modified.
+var noTypeOptions typeOptions
+
+// typeApplies reports whether the type filters of opt permit it to apply
+// to values of type t.
+func typeApplies(t reflect.Type, opt option) bool {
+	if opt.typeFilter != nil && !t.AssignableTo(opt.typeFilter) {
+		return false
+	}
+	if c, ok := opt.op.(*comparer); ok && c.exact && t != opt.typeFilter {
+		return false
+	}
+	if _, ok := opt.op.(*channelContents); ok && (t.Kind() != reflect.Chan || t.ChanDir() != reflect.BothDir) {
+		return false
+	}
+	for _, f := range opt.valueFilters {
+		if !t.AssignableTo(f.in) {
 			return false
 		}
 	}
+	if c, ok := opt.op.(*combination); ok {
+		for _, o := range c.opts {
+			if typeApplies(t, o) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// applyFilters reports whether the path and value filters of opt permit it
+// to apply to vx and vy. The type filters must already have been checked.
+func (s *state) applyFilters(vx, vy reflect.Value, opt option) bool {
 	for _, f := range opt.pathFilters {
 		if !f(s.curPath) {
 			return false
 		}
 	}
 	for _, f := range opt.valueFilters {
-		if !t.AssignableTo(f.in) || !s.callFunc(f.fnc, vx, vy) {
+		if !s.callFunc(f.fnc, f.direct, vx, vy) {
+			return false
+		}
+	}
+	for _, f := range opt.inverted {
+		if s.passes(vx, vy, f) {
+			return false
+		}
+	}
+	return true
+}
+
+// passes reports whether all of the filters permit vx and vy, where a value
+// filter never permits values that are not assignable to its type.
+func (s *state) passes(vx, vy reflect.Value, fs filters) bool {
+	for _, f := range fs.pathFilters {
+		if !f(s.curPath) {
+			return false
+		}
+	}
+	for _, f := range fs.valueFilters {
+		if !vx.IsValid() || !vx.Type().AssignableTo(f.in) || !vy.Type().AssignableTo(f.in) ||
+			!s.callFunc(f.fnc, f.direct, vx, vy) {
+			return false
+		}
+	}
+	for _, f := range fs.inverted {
+		if s.passes(vx, vy, f) {
 			return false
 		}
 	}
@@ -329,171 +1463,1906 @@ func (s *state) applyFilters(vx, vy reflect.Value, t reflect.Type, opt option) b
 func (s *state) applyOption(vx, vy reflect.Value, t reflect.Type, opt option) {
 	switch op := opt.op.(type) {
 	case *transformer:
-		vx = op.fnc.Call([]reflect.Value{vx})[0]
-		vy = op.fnc.Call([]reflect.Value{vy})[0]
-		s.curPath.push(&transform{pathStep{op.fnc.Type().Out(0)}, op})
-		defer s.curPath.pop()
-		s.compareAny(vx, vy)
+		vx, _ = s.callTransform(op, vx)
+		vy, _ = s.callTransform(op, vy)
+		s.compareTransformed(vx, vy, op)
 		return
 	case *comparer:
-		eq := s.callFunc(op.fnc, vx, vy)
-		s.report(eq, vx, vy)
+		s.reportComparer(s.callEqual(op.fnc, op.direct, vx, vy), vx, vy, op)
+		return
+	case *combination:
+		eq, by := s.combinedEqual(vx, vy, t, op)
+		s.reportBy(eq, vx, vy, ByComparer, by.op.(*comparer).name, by.op.(*comparer).fnc)
+		if !eq && op.all && s.wantsNote() {
+			if by.src != "" {
+				s.reportNote("%v reported a difference (created at %s)", by, by.src)
+			} else {
+				s.reportNote("%v reported a difference", by)
+			}
+		}
+		return
+	case *multiset:
+		s.compareMultiset(vx, vy, t, op.set)
+		return
+	case *sharedKeys:
+		s.compareMap(vx, vy, t, true)
+		return
+	case *channelContents:
+		s.compareDrained(vx, vy, t)
 		return
 	}
 }
 
-func (s *state) tryMethod(vx, vy reflect.Value, t reflect.Type) bool {
-	// Check if this type even has an Equal method.
-	m, ok := t.MethodByName("Equal")
-	ft := functionType(m.Type)
-	if !ok || (ft != equalFunc && ft != equalIfaceFunc) {
-		return false
+// combinedEqual evaluates the applicable comparers of an AnyOf or AllOf,
+// of which there must be at least one, and reports whether vx and vy are
+// equal along with the comparer that decided so. That is the first comparer
+// to report a result that decides the combination, or otherwise the first
+// comparer evaluated.
+func (s *state) combinedEqual(vx, vy reflect.Value, t reflect.Type, c *combination) (eq bool, by option) {
+	for i, o := range s.applicable(vx, vy, t, c) {
+		var oeq bool
+		var oby option
+		switch op := o.op.(type) {
+		case *comparer:
+			oeq, oby = s.callEqual(op.fnc, op.direct, vx, vy), o
+		case *combination:
+			oeq, oby = s.combinedEqual(vx, vy, t, op)
+		}
+		if i == 0 {
+			eq, by = oeq, oby
+		}
+		if oeq != c.all {
+			return oeq, oby
+		}
 	}
+	return eq, by
+}
 
-	eq := s.callFunc(m.Func, vx, vy)
-	s.report(eq, vx, vy)
-	return true
+// applied reports whether the current path already contains a Transform
+// by the transformer tr.
+func (s *state) applied(tr *transformer) bool {
+	for _, ps := range s.curPath {
+		if t, ok := ps.(*transform); ok && t.trans == tr {
+			return true
+		}
+	}
+	return false
 }
 
-func (s *state) callFunc(f, x, y reflect.Value) bool {
-	got := f.Call([]reflect.Value{x, y})[0].Bool()
-	if s.dsCheck.curr == s.dsCheck.next {
-		// Swapping the input arguments is sufficient to check that
-		// f is symmetric and deterministic.
-		want := f.Call([]reflect.Value{y, x})[0].Bool()
-		if got != want {
-			fn := getFuncName(f.Pointer())
-			panic(fmt.Sprintf("non-deterministic or non-symmetric function detected: %s", fn))
+// reportComparer reports whether vx and vy are equal as decided by op,
+// along with any note or details about why they are unequal.
+func (s *state) reportComparer(eq bool, vx, vy reflect.Value, op *comparer) {
+	s.reportBy(eq, vx, vy, ByComparer, op.name, op.fnc)
+	if !eq && op.note != nil && s.wantsNote() {
+		s.reportNote("%s", op.note(vx, vy))
+	}
+	if !eq && s.details {
+		s.reportDetails(vx, vy, op)
+	}
+}
+
+// applicable returns the options of an AnyOf or AllOf that apply to vx and vy.
+func (s *state) applicable(vx, vy reflect.Value, t reflect.Type, c *combination) []option {
+	var opts []option
+	for _, o := range c.opts {
+		if !typeApplies(t, o) || !s.applyFilters(vx, vy, o) {
+			continue
+		}
+		if nc, ok := o.op.(*combination); ok && len(s.applicable(vx, vy, t, nc)) == 0 {
+			continue
 		}
-		s.dsCheck.curr = 0
-		s.dsCheck.next++
+		opts = append(opts, o)
 	}
-	s.dsCheck.curr++
-	return got
+	return opts
 }
 
-func (s *state) compareArray(vx, vy reflect.Value, t reflect.Type) {
+// compareMultiset compares the slices or arrays vx and vy regardless of the
+// order of their elements. If set is true, then duplicate elements are also
+// disregarded. Unmatched elements are reported as differences.
+func (s *state) compareMultiset(vx, vy reflect.Value, t reflect.Type, set bool) {
+	if t.Kind() == reflect.Slice && (vx.IsNil() || vy.IsNil()) {
+		s.report(vx.IsNil() && vy.IsNil(), vx, vy)
+		return
+	}
 	step := &sliceIndex{pathStep{t.Elem()}, 0}
 	s.curPath.push(step)
 	defer s.curPath.pop()
 
-	// Regardless of the lengths, we always try to compare the elements.
-	// If one slice is longer, we will report the elements of the longer
-	// slice as different (relative to an invalid reflect.Value).
-	nmin := vx.Len()
-	if nmin > vy.Len() {
-		nmin = vy.Len()
-	}
-	for i := 0; i < nmin; i++ {
+	// Determine which pairs of elements are equal.
+	nx, ny := vx.Len(), vy.Len()
+	edges := make([][]int, nx) // Indexes in vy that are equal to each index in vx
+	for i := 0; i < nx; i++ {
 		step.key = i
-		s.compareAny(vx.Index(i), vy.Index(i))
+		for j := 0; j < ny; j++ {
+			if s.isEqual(vx.Index(i), vy.Index(j)) {
+				edges[i] = append(edges[i], j)
+			}
+		}
 	}
-	for i := nmin; i < vx.Len(); i++ {
-		step.key = i
-		s.report(false, vx.Index(i), reflect.Value{})
+
+	// Find a maximum matching using augmenting paths.
+	// For sets, any equal element is a sufficient match.
+	matchX, matchY := make([]int, nx), make([]int, ny)
+	for j := range matchY {
+		matchY[j] = -1
 	}
-	for i := nmin; i < vy.Len(); i++ {
-		step.key = i
-		s.report(false, reflect.Value{}, vy.Index(i))
+	if set {
+		for i, js := range edges {
+			matchX[i] = -1
+			for _, j := range js {
+				matchX[i], matchY[j] = j, i
+			}
+		}
+		edges = nil
+	}
+	var augment func(i int, seen []bool) bool
+	augment = func(i int, seen []bool) bool {
+		for _, j := range edges[i] {
+			if seen[j] {
+				continue
+			}
+			seen[j] = true
+			if matchY[j] < 0 || augment(matchY[j], seen) {
+				matchX[i], matchY[j] = j, i
+				return true
+			}
+		}
+		return false
+	}
+	for i := range edges {
+		matchX[i] = -1
+		augment(i, make([]bool, ny))
+	}
+
+	for i := 0; i < nx && !s.stopped(); i++ {
+		if matchX[i] < 0 {
+			step.key = i
+			s.report(false, vx.Index(i), reflect.Value{})
+		}
+	}
+	for j := 0; j < ny && !s.stopped(); j++ {
+		if matchY[j] < 0 {
+			step.key = j
+			s.report(false, reflect.Value{}, vy.Index(j))
+		}
+	}
+}
+
+// isEqual reports whether vx and vy are equal at the current path
+// according to all options, without reporting any differences.
+// The comparison stops at the first difference.
+func (s *state) isEqual(vx, vy reflect.Value) bool {
+	s2 := *s
+	s2.eq, s2.details, s2.aliasFunc, s2.auditFunc, s2.explain = true, false, nil, nil, nil
+	s2.reporter, s2.limited = nil, discardReporter{}
+	s2.compareAny(vx, vy)
+	// Retain any caches allocated by s2 for the comparison of the next pair.
+	s.curPath, s.steps, s.dsCalls = s2.curPath, s2.steps, s2.dsCalls
+	s.fastTypes, s.typeOpts, s.methods = s2.fastTypes, s2.typeOpts, s2.methods
+	return s2.eq
+}
+
+// callTransform applies the transformer to v. If the transformer reports
+// an error, then it panics if strict transformers are requested.
+func (s *state) callTransform(tr *transformer, v reflect.Value) (reflect.Value, error) {
+	outs := s.call(tr.fnc, v)
+	if len(outs) == 2 && !outs[1].IsNil() {
+		err := outs[1].Interface().(error)
+		if s.strictTr {
+			fail(s.curPath, "transformer %s failed at %#v: %v", tr.name, s.curPath, err)
+		}
+		return reflect.Value{}, err
+	}
+	return outs[0], nil
+}
+
+// identity is the function recorded in the Path for a Transform that
+// presents the input values as-is.
+func identity(v interface{}) interface{} { return v }
+
+// tryShallow compares pointers, slices, and maps by identity if they are
+// reached through a struct field, slice or array element, or map entry,
+// reporting whether it did so.
+func (s *state) tryShallow(vx, vy reflect.Value, t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Map:
+	default:
+		return false
+	}
+	var belowRoot bool
+	for _, ps := range s.curPath {
+		switch ps.(type) {
+		case *structField, *sliceIndex, *mapIndex, *mapKey:
+			belowRoot = true
+		}
+	}
+	if !belowRoot {
+		return false
+	}
+	eq := vx.Pointer() == vy.Pointer()
+	if t.Kind() == reflect.Slice {
+		eq = eq && vx.Len() == vy.Len() && vx.Cap() == vy.Cap()
+	}
+	s.report(eq, vx, vy)
+	if !eq {
+		s.reportNote("compared by reference in shallow mode: %#x and %#x", vx.Pointer(), vy.Pointer())
+	}
+	return true
+}
+
+// tryMismatchedTypes compares vx and vy of different types if an option
+// allows them to be compared, reporting whether it did so.
+func (s *state) tryMismatchedTypes(vx, vy reflect.Value) bool {
+	if !vx.IsValid() || !vy.IsValid() || vx.Type() == vy.Type() {
+		return false
+	}
+	var compare func(vx, vy reflect.Value)
+	switch {
+	case s.isFieldsByName(vx, vy):
+		compare = s.compareFieldsByName
+	case s.isPtrToValue(vx, vy):
+		compare = s.comparePtrToValue
+	case s.isBytesAndString(vx, vy):
+		compare = s.compareBytesAndString
+	case s.isRunesAndString(vx, vy):
+		compare = s.compareRunesAndString
+	case s.isNumbers(vx, vy):
+		compare = s.compareNumbers
+	case s.isStructAndMap(vx, vy):
+		compare = s.compareStructAndMap
+	default:
+		return false
+	}
+	if len(s.curPath) == 0 {
+		// The inputs to Equal are effectively of type interface{}.
+		s.curPath.push(&pathStep{typ: interfaceType})
+		defer s.curPath.pop()
+	}
+	compare(vx, vy)
+	return true
+}
+
+// isFieldsByName reports whether vx and vy are of different struct types,
+// or pointers to such, that are compared by field names.
+func (s *state) isFieldsByName(vx, vy reflect.Value) bool {
+	return vx.IsValid() && vy.IsValid() && s.isFieldsByNameType(vx.Type(), vy.Type())
+}
+
+func (s *state) isFieldsByNameType(tx, ty reflect.Type) bool {
+	if len(s.byName) == 0 || tx == ty {
+		return false
+	}
+	if tx.Kind() == reflect.Ptr && ty.Kind() == reflect.Ptr {
+		tx, ty = tx.Elem(), ty.Elem()
 	}
+	for _, ts := range s.byName {
+		if ts == (fieldsByName{tx, ty}) {
+			return true
+		}
+	}
+	return false
 }
 
-func (s *state) compareMap(vx, vy reflect.Value, t reflect.Type) {
-	if vx.IsNil() || vy.IsNil() {
+// compareFieldsByName compares the values of different struct types, or
+// pointers to such, by matching their exported fields by name.
+func (s *state) compareFieldsByName(vx, vy reflect.Value) {
+	if vx.Kind() == reflect.Ptr && (vx.IsNil() || vy.IsNil()) {
 		s.report(vx.IsNil() && vy.IsNil(), vx, vy)
 		return
 	}
+	tx, ty := reflect.Indirect(vx).Type(), reflect.Indirect(vy).Type()
+	tr := &transformer{name: "λbyName", fnc: reflect.ValueOf(identity)}
+	s.curPath.push(&transform{pathStep{interfaceType}, tr})
+	defer s.curPath.pop()
+	if vx.Kind() == reflect.Ptr {
+		s.curPath.push(&indirect{pathStep{interfaceType}})
+		defer s.curPath.pop()
+		vx, vy = vx.Elem(), vy.Elem()
+	}
 
-	// We combine and sort the two map keys so that we can perform the
-	// comparisons in a deterministic order.
-	step := &mapIndex{pathStep: pathStep{t.Elem()}}
+	step := &structField{}
 	s.curPath.push(step)
 	defer s.curPath.pop()
-	for _, k := range sortKeys(append(vx.MapKeys(), vy.MapKeys()...)) {
-		step.key = k
-		vvx := vx.MapIndex(k)
-		vvy := vy.MapIndex(k)
+	compareField := func(fx, fy reflect.StructField, okx, oky bool) {
+		var vvx, vvy reflect.Value
+		if okx {
+			vvx = vx.FieldByIndex(fx.Index)
+			step.typ, step.name, step.idx = fx.Type, fx.Name, fx.Index[0]
+		}
+		if oky {
+			vvy = vy.FieldByIndex(fy.Index)
+			if !okx {
+				step.typ, step.name, step.idx = fy.Type, fy.Name, fy.Index[0]
+			}
+		}
 		switch {
-		case vvx.IsValid() && vvy.IsValid():
-			s.compareAny(vvx, vvy)
-		case vvx.IsValid() && !vvy.IsValid():
-			s.report(false, vvx, reflect.Value{})
-		case !vvx.IsValid() && vvy.IsValid():
-			s.report(false, reflect.Value{}, vvy)
+		case !okx:
+			s.report(false, vvx, vvy)
+			s.reportNote("field %s does not exist in %v", fy.Name, tx)
+		case !oky:
+			s.report(false, vvx, vvy)
+			s.reportNote("field %s does not exist in %v", fx.Name, ty)
+		case fx.Type != fy.Type && !s.isFieldsByNameType(fx.Type, fy.Type):
+			s.report(false, vvx, vvy)
+			s.reportNote("field %s has type %v in %v, but %v in %v",
+				fx.Name, fx.Type, tx, fy.Type, ty)
 		default:
-			// It is possible for both vvx and vvy to be invalid if the
-			// key contained a NaN value in it. There is no way in
-			// reflection to be able to retrieve these values.
-			// See https://golang.org/issue/11104
-			panic(fmt.Sprintf("%#v has map key with NaNs", s.curPath))
+			s.compareAny(vvx, vvy)
+		}
+	}
+	for i := 0; i < tx.NumField() && !s.stopped(); i++ {
+		if fx := tx.Field(i); isExported(fx.Name) {
+			fy, ok := ty.FieldByName(fx.Name)
+			compareField(fx, fy, true, ok && len(fy.Index) == 1)
+		}
+	}
+	for i := 0; i < ty.NumField() && !s.stopped(); i++ {
+		if fy := ty.Field(i); isExported(fy.Name) {
+			if fx, ok := tx.FieldByName(fy.Name); !ok || len(fx.Index) != 1 {
+				compareField(fx, fy, false, true)
+			}
 		}
 	}
 }
 
-func (s *state) compareStruct(vx, vy reflect.Value, t reflect.Type) {
-	var vax, vay reflect.Value // Addressable versions of vx and vy
+// isPtrToValue reports whether exactly one of vx and vy is a pointer to
+// the type of the other and pointers may be compared against values.
+func (s *state) isPtrToValue(vx, vy reflect.Value) bool {
+	if !s.derefPtrs || !vx.IsValid() || !vy.IsValid() {
+		return false
+	}
+	tx, ty := vx.Type(), vy.Type()
+	return tx.Kind() == reflect.Ptr && tx.Elem() == ty || ty.Kind() == reflect.Ptr && ty.Elem() == tx
+}
 
-	step := &structField{}
-	s.curPath.push(step)
+// comparePtrToValue compares a pointer against a value of its element type
+// by dereferencing the pointer. A nil pointer is never equal to a value.
+func (s *state) comparePtrToValue(vx, vy reflect.Value) {
+	ptrX := vx.Kind() == reflect.Ptr && vx.Type().Elem() == vy.Type()
+	vp := vy
+	if ptrX {
+		vp = vx
+	}
+	if vp.IsNil() {
+		s.report(false, vx, vy)
+		s.reportNote("nil %v cannot be dereferenced to compare with %v", vp.Type(), vp.Type().Elem())
+		return
+	}
+	s.curPath.push(&indirect{pathStep{vp.Type().Elem()}})
 	defer s.curPath.pop()
-	for i := 0; i < t.NumField(); i++ {
-		vvx := vx.Field(i)
-		vvy := vy.Field(i)
-		step.typ = t.Field(i).Type
-		step.name = t.Field(i).Name
-		step.idx = i
-		step.unexported = !isExported(step.name)
-		if step.unexported {
-			// Defer checking of unexported fields until later to give an
-			// Ignore a chance to ignore the field.
-			if !vax.IsValid() || !vay.IsValid() {
-				// For unsafeRetrieveField to work, the parent struct must
-				// be addressable. Create a new copy of the values if
-				// necessary to make them addressable.
-				vax = makeAddressable(vx)
-				vay = makeAddressable(vy)
-			}
-			step.force = s.exporters[t]
-			step.pvx = vax
-			step.pvy = vay
-			step.field = t.Field(i)
-		}
-		s.compareAny(vvx, vvy)
+	if ptrX {
+		s.compareAny(vx.Elem(), vy)
+	} else {
+		s.compareAny(vx, vy.Elem())
 	}
 }
 
-// report records the result of a single comparison.
-// It also calls Report if any reporter is registered.
-func (s *state) report(eq bool, vx, vy reflect.Value) {
-	s.eq = s.eq && eq
-	if s.reporter != nil {
-		s.reporter.Report(vx, vy, eq, s.curPath)
+// isBytesAndString reports whether one of vx and vy is a string and the
+// other is a []byte and byte slices may be compared against strings.
+func (s *state) isBytesAndString(vx, vy reflect.Value) bool {
+	if !s.bytesStrs {
+		return false
+	}
+	isBytes := func(t reflect.Type) bool {
+		return t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8
 	}
+	tx, ty := vx.Type(), vy.Type()
+	return isBytes(tx) && ty.Kind() == reflect.String || tx.Kind() == reflect.String && isBytes(ty)
 }
 
-// makeAddressable returns a value that is always addressable.
-// It returns the input verbatim if it is already addressable,
-// otherwise it creates a new value and returns an addressable copy.
-func makeAddressable(v reflect.Value) reflect.Value {
-	if v.CanAddr() {
-		return v
+// compareBytesAndString compares the contents of a []byte and a string.
+// Both values are reported as strings.
+func (s *state) compareBytesAndString(vx, vy reflect.Value) {
+	toString := func(v reflect.Value) reflect.Value {
+		if v.Kind() == reflect.String {
+			return reflect.ValueOf(v.String())
+		}
+		return reflect.ValueOf(string(v.Bytes()))
 	}
-	vc := reflect.New(v.Type()).Elem()
-	vc.Set(v)
-	return vc
+	sx, sy := toString(vx), toString(vy)
+	s.report(sx.String() == sy.String(), sx, sy)
 }
 
-type funcType int
-
-const (
-	invalidFunc     funcType    = iota
-	equalFunc                   // func(T, T) bool
-	equalIfaceFunc              // func(T, I) bool
-	transformFunc               // func(T) R
-	valueFilterFunc = equalFunc // func(T, T) bool
+// isRunesAndString reports whether one of vx and vy is a string and the
+// other is a []rune and rune slices may be compared against strings.
+func (s *state) isRunesAndString(vx, vy reflect.Value) bool {
+	if !s.runesStrs {
+		return false
+	}
+	isRunes := func(t reflect.Type) bool {
+		return t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Int32
+	}
+	tx, ty := vx.Type(), vy.Type()
+	return isRunes(tx) && ty.Kind() == reflect.String || tx.Kind() == reflect.String && isRunes(ty)
+}
+
+// compareRunesAndString compares the contents of a []rune and a string.
+// Both values are reported as strings. A []rune with an invalid code point
+// is never equal, since that code point cannot be encoded in a string.
+func (s *state) compareRunesAndString(vx, vy reflect.Value) {
+	invalid, index := rune(-1), -1
+	toString := func(v reflect.Value) reflect.Value {
+		if v.Kind() == reflect.String {
+			return reflect.ValueOf(v.String())
+		}
+		rs := make([]rune, v.Len())
+		for i := range rs {
+			rs[i] = rune(v.Index(i).Int())
+			if index < 0 && !utf8.ValidRune(rs[i]) {
+				invalid, index = rs[i], i
+			}
+		}
+		return reflect.ValueOf(string(rs))
+	}
+	sx, sy := toString(vx), toString(vy)
+	s.report(index < 0 && sx.String() == sy.String(), sx, sy)
+	if index >= 0 {
+		s.reportNote("[]rune contains invalid code point %U at index %d", invalid, index)
+	}
+}
+
+// isNumbers reports whether vx and vy are both integers or floating-point
+// numbers and numbers of different kinds may be compared.
+// Only values within an interface are ever of different types.
+func (s *state) isNumbers(vx, vy reflect.Value) bool {
+	return s.numIfaces && isNumber(vx.Kind()) && isNumber(vy.Kind())
+}
+
+func isNumber(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// compareNumbers compares numbers of different kinds by their exact values,
+// such that an integer is only equal to a floating-point number if that
+// number is integral and within range. NaNs are never equal.
+func (s *state) compareNumbers(vx, vy reflect.Value) {
+	toBig := func(v reflect.Value) *big.Float {
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return new(big.Float).SetInt64(v.Int())
+		case reflect.Float32, reflect.Float64:
+			if math.IsNaN(v.Float()) {
+				return nil
+			}
+			return new(big.Float).SetFloat64(v.Float())
+		default:
+			return new(big.Float).SetUint64(v.Uint())
+		}
+	}
+	nx, ny := toBig(vx), toBig(vy)
+	eq := nx != nil && ny != nil && nx.Cmp(ny) == 0
+	s.report(eq, vx, vy)
+	if !eq {
+		s.reportNote("compared %v and %v by their numeric values", vx.Type(), vy.Type())
+	}
+}
+
+// isStructAndMap reports whether one of vx and vy is a struct (or a pointer
+// to a struct) and the other is a map with string keys, and structs may be
+// converted to maps.
+func (s *state) isStructAndMap(vx, vy reflect.Value) bool {
+	if s.toMaps == nil {
+		return false
+	}
+	isStruct := func(t reflect.Type) bool {
+		return t.Kind() == reflect.Struct || t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Struct
+	}
+	isMap := func(t reflect.Type) bool {
+		return t.Kind() == reflect.Map && t.Key().Kind() == reflect.String
+	}
+	tx, ty := vx.Type(), vy.Type()
+	return isStruct(tx) && isMap(ty) || isMap(tx) && isStruct(ty)
+}
+
+// compareStructAndMap converts the struct to a map[string]interface{} and
+// compares it against the other map.
+func (s *state) compareStructAndMap(vx, vy reflect.Value) {
+	tr := &transformer{name: "λtoMap", fnc: reflect.ValueOf(identity)}
+	s.curPath.push(&transform{pathStep{interfaceType}, tr})
+	defer s.curPath.pop()
+	toMap := func(v reflect.Value) reflect.Value {
+		if v.Kind() == reflect.Map {
+			return v
+		}
+		m := s.toMaps.convert(v)
+		if m == nil {
+			return reflect.Zero(reflect.TypeOf(map[string]interface{}(nil)))
+		}
+		return reflect.ValueOf(m)
+	}
+	s.compareAny(toMap(vx), toMap(vy))
+}
+
+// compareTransformed compares the outputs of the transformer.
+func (s *state) compareTransformed(vx, vy reflect.Value, tr *transformer) {
+	s.curPath.push(&transform{pathStep{tr.fnc.Type().Out(0)}, tr})
+	defer s.curPath.pop()
+	s.checkTransforms()
+	s.compareAny(vx, vy)
+}
+
+// reportDetails re-compares vx and vy with the Comparer in opt suppressed
+// and attaches the resulting differences to the report as supplemental
+// information. If the structural comparison is not possible because of
+// unexported fields, then only the summary reported by the Comparer remains.
+func (s *state) reportDetails(vx, vy reflect.Value, op *comparer) {
+	r, ok := s.reporter.(*defaultReporter)
+	if !ok || !isAggregate(vx.Type()) {
+		return
+	}
+
+	s2 := *s // Inherit all other configuration
+	s2.eq, s2.details, s2.aliasFunc, s2.explain, s2.opts = true, false, nil, nil, nil
+	s2.fastTypes, s2.typeOpts = nil, nil
+	for _, o := range s.opts {
+		if o.op != op {
+			s2.opts = append(s2.opts, o)
+		}
+	}
+	sr := new(defaultReporter)
+	s2.reporter = sr
+	s2.curPath = append(Path(nil), s.curPath...)
+	defer func() {
+		if ex := recover(); ex != nil {
+			if err, ok := ex.(*Error); !ok || !strings.HasPrefix(err.msg, "cannot handle unexported field") {
+				panic(ex)
+			}
+			return // Fallback to the summary form
+		}
+		if !s2.eq {
+			r.reportDetails(fmt.Sprintf("Comparer(%s)", getFuncName(op.fnc.Pointer())), sr)
+		}
+	}()
+	s2.compareAny(vx, vy)
+}
+
+// maxTransforms is the maximum number of consecutive transformations that
+// may be applied before assuming that a set of Transformers is recursive.
+const maxTransforms = 16
+
+// checkTransforms panics if the current path ends with too many consecutive
+// transformations, which indicates that some set of Transformers is being
+// recursively applied forever. Type assertions and pointer indirections
+// between transformations do not break up the sequence.
+func (s *state) checkTransforms() {
+	var idxs []int // Indexes of the trailing transform steps in reverse
+loop:
+	for i := len(s.curPath) - 1; i > 0; i-- {
+		switch s.curPath[i].(type) {
+		case *transform:
+			idxs = append(idxs, i)
+		case *typeAssertion, *indirect:
+			continue
+		default:
+			break loop
+		}
+	}
+	if len(idxs) <= maxTransforms {
+		return
+	}
+	for i, j := 0, len(idxs)-1; i < j; i, j = i+1, j-1 {
+		idxs[i], idxs[j] = idxs[j], idxs[i]
+	}
+
+	// Report the chain of transformations up to the first repetition.
+	seen := make(map[*transformer]bool)
+	var ss []string
+	var rep Path
+	for _, i := range idxs {
+		tf := s.curPath[i].(*transform)
+		name := tf.trans.name
+		if name == "λ" {
+			// Identify anonymous transformers by their function name.
+			name += "[" + getFuncName(tf.trans.fnc.Pointer()) + "]"
+		}
+		ss = append(ss, fmt.Sprintf("\t%s: %v => %v", name, s.curPath[i-1].Type(), tf.Type()))
+		if seen[tf.trans] {
+			rep = s.curPath[:i+1]
+			break
+		}
+		seen[tf.trans] = true
+	}
+	if rep == nil {
+		rep = s.curPath
+	}
+	fail(rep, "recursive set of Transformers detected at %#v:\n%s\nconsider using FilterValues to limit when the Transformers apply", rep, strings.Join(ss, "\n"))
+}
+
+// fastCache records for each type whether values of that type may be
+// compared using the == operator, since the result is guaranteed to be
+// the same as recursively descending into the values.
+type fastCache map[reflect.Type]fastType
+
+// plainFastTypes records the result of fastType for each type compared
+// without any options, unexported fields, or TextMarshalers, in which case
+// the result only depends on the type. Unlike the fastCache of a state,
+// it is retained across comparisons so that the work is only done once.
+// The method set of a type cannot change, so neither can the result,
+// except that RegisterDefaultOptions clears it as the new options may apply.
+var plainFastTypes sync.Map // map[reflect.Type]fastType
+
+type fastType struct {
+	ok     bool
+	leaves int        // Number of values compared when descending into the type
+	depth  int        // Maximum number of steps below the type
+	plan   []fastLeaf // Primitive values within the type, if not too many
+}
+
+// fastLeaf is a value of a primitive kind at some offset within a type,
+// such that comparing all the leaves of a type is equivalent to ==.
+// The plan of leaves for a type is only built once for plain states and
+// is then replayed by unsafeFastEqual for every comparison.
+type fastLeaf struct {
+	off  uintptr
+	kind reflect.Kind
+}
+
+// maxFastLeaves is the largest number of leaves in the plan for a type,
+// beyond which the type is compared using == instead.
+const maxFastLeaves = 64
+
+// tryFastEqual compares structs and arrays of type t using the == operator,
+// if no option, Equal method, or other special rule could apply to any
+// value within them. If the values are unequal and the differences must be
+// reported, or if every decision is explained, it reports false so that
+// the values are compared as usual.
+func (s *state) tryFastEqual(vx, vy reflect.Value, t reflect.Type) bool {
+	if t.Kind() != reflect.Struct && t.Kind() != reflect.Array || s.explain != nil {
+		return false
+	}
+	ft := s.fastType(t)
+	if !ft.ok || len(s.curPath)-1+ft.depth > s.maxDepth || !vx.CanInterface() || !vy.CanInterface() {
+		return false
+	}
+	eq, ok := false, false
+	if ft.plan != nil && vx.CanAddr() && vy.CanAddr() {
+		// Addressable values would be copied by Interface.
+		eq, ok = unsafeFastEqual(vx, vy, ft.plan)
+	}
+	if !ok {
+		eq = vx.Interface() == vy.Interface()
+	}
+	if !eq && s.reporter != nil {
+		return false
+	}
+	s.eq = s.eq && eq
+	s.numCmp += ft.leaves
+	return true
+}
+
+// plainEqual compares x and y without any options if they are values of,
+// or non-nil pointers to, a struct or array type whose plan was already built
+// by fastType, which avoids the fixed cost of preparing a state.
+// It reports false if the values must be compared as usual.
+func plainEqual(x, y interface{}) (eq, ok bool) {
+	vx, vy := reflect.ValueOf(x), reflect.ValueOf(y)
+	if !vx.IsValid() || !vy.IsValid() || vx.Type() != vy.Type() {
+		return false, false
+	}
+	depth := 0
+	if vx.Kind() == reflect.Ptr {
+		// The fast type has no Equal method on its pointer type either.
+		if vx.IsNil() || vy.IsNil() {
+			return false, false
+		}
+		vx, vy, depth = vx.Elem(), vy.Elem(), 1
+	}
+	if k := vx.Kind(); k != reflect.Struct && k != reflect.Array {
+		return false, false
+	}
+	v, found := plainFastTypes.Load(vx.Type())
+	if !found {
+		return false, false
+	}
+	ft := v.(fastType)
+	if !ft.ok || ft.plan == nil || depth+ft.depth > defaultMaxDepth {
+		return false, false
+	}
+	if vx.CanAddr() && vy.CanAddr() {
+		if eq, ok := unsafeFastEqual(vx, vy, ft.plan); ok {
+			return eq, true
+		}
+	}
+	return vx.Interface() == vy.Interface(), true
+}
+
+// fastType reports whether values of type t may be compared using the ==
+// operator. This is the case if t is comparable without containing any
+// pointers or interfaces, and no option or method could apply to t or any
+// type within it.
+func (s *state) fastType(t reflect.Type) fastType {
+	if s.plainType {
+		if ft, ok := plainFastTypes.Load(t); ok {
+			return ft.(fastType)
+		}
+	}
+	if ft, ok := s.fastTypes[t]; ok {
+		return ft
+	}
+	if s.fastTypes == nil {
+		s.fastTypes = make(fastCache)
+	}
+	s.fastTypes[t] = fastType{} // Conservatively handle recursive types
+
+	var ft fastType
+	switch t.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		ft = fastType{ok: true, leaves: 1}
+		if s.plainType {
+			ft.plan = []fastLeaf{{0, t.Kind()}}
+		}
+	case reflect.Array:
+		if et := s.fastType(t.Elem()); et.ok {
+			ft = fastType{ok: true, leaves: t.Len() * et.leaves, depth: et.depth + 1}
+			if et.plan != nil && t.Len()*len(et.plan) <= maxFastLeaves {
+				ft.plan = []fastLeaf{}
+				for i := 0; i < t.Len(); i++ {
+					ft.plan = appendLeaves(ft.plan, et.plan, uintptr(i)*t.Elem().Size())
+				}
+			}
+		}
+	case reflect.Struct:
+		ft = fastType{ok: true}
+		if s.plainType {
+			ft.plan = []fastLeaf{}
+		}
+		for i := 0; i < t.NumField() && ft.ok; i++ {
+			// The == operator disregards blank fields, while unexported
+			// fields may need to be forcibly retrieved or reported.
+			if name := t.Field(i).Name; name == "_" || !isExported(name) && !s.exporters[t] {
+				ft.ok = false
+				break
+			}
+			et := s.fastType(t.Field(i).Type)
+			ft.ok = et.ok
+			ft.leaves += et.leaves
+			if et.depth+1 > ft.depth {
+				ft.depth = et.depth + 1
+			}
+			if et.plan == nil || len(ft.plan)+len(et.plan) > maxFastLeaves {
+				ft.plan = nil
+			} else if ft.plan != nil {
+				ft.plan = appendLeaves(ft.plan, et.plan, t.Field(i).Offset)
+			}
+		}
+	}
+	if ft.ok && s.hasSpecialRules(t) {
+		ft = fastType{}
+	}
+	s.fastTypes[t] = ft
+	if s.plainType {
+		plainFastTypes.Store(t, ft)
+	}
+	return ft
+}
+
+// appendLeaves appends the leaves of a value at offset off to plan.
+func appendLeaves(plan, leaves []fastLeaf, off uintptr) []fastLeaf {
+	for _, l := range leaves {
+		plan = append(plan, fastLeaf{off + l.off, l.kind})
+	}
+	return plan
+}
+
+// hasSpecialRules reports whether values of type t may be compared by any
+// means other than the default rules for its kind.
+func (s *state) hasSpecialRules(t reflect.Type) bool {
+	if to := s.optionsFor(t); len(to.opts)+len(to.optsIgn) > 0 {
+		return true
+	}
+	if _, ok := reflect.PtrTo(t).MethodByName("Equal"); ok {
+		return true // Method set of *T is a superset of that of T
+	}
+	if isOptioner(t) {
+		return true
+	}
+	return s.textMarsh && reflect.PtrTo(t).Implements(textMarshalerType)
+}
+
+// tryOptioner compares vx and vy with the options of their type in addition
+// to all other options, if the type implements Optioner and its options are
+// not already in effect.
+func (s *state) tryOptioner(vx, vy reflect.Value, t reflect.Type) bool {
+	if !isOptioner(t) {
+		return false
+	}
+	for _, st := range s.scopes {
+		if st == t {
+			return false
+		}
+	}
+
+	// Compare the values with a copy of the state using the options,
+	// which is discarded once done, as if the options were filtered to apply
+	// to only these values.
+	s2 := *s
+	s2.scopes = append(s.scopes[:len(s.scopes):len(s.scopes)], t) // Force copy when appending
+	s2.opts = s.opts[:len(s.opts):len(s.opts)]
+	s2.optsIgn = s.optsIgn[:len(s.optsIgn):len(s.optsIgn)]
+	var add func(Option)
+	add = func(opt Option) {
+		switch opt := opt.(type) {
+		case Options:
+			for _, o := range opt {
+				add(o)
+			}
+		case option:
+			s2.processOption(opt)
+		default:
+			fail(s.curPath, "cannot use option of type %T from (%v).CmpOptions", opt, t)
+		}
+	}
+	for _, opt := range optionerOptions(vx) {
+		add(opt)
+	}
+	s2.fastTypes, s2.typeOpts, s2.plainType = nil, nil, false
+	s2.prepareOptions()
+	s2.compareAny(vx, vy)
+	s.eq, s.numCmp, s.curPath, s.steps = s2.eq, s2.numCmp, s2.curPath, s2.steps
+	return true
+}
+
+// drainTransformers records the transformer of the Drain step for each
+// channel type.
+var drainTransformers sync.Map // map[reflect.Type]*transformer
+
+// drainable reports whether the channels vx and vy may be compared by their
+// contents, as specified by TransformChannelContents. The same channel is
+// equal to itself, and is not drained.
+func drainable(vx, vy reflect.Value) bool {
+	return !vx.IsNil() && !vy.IsNil() && vx.Cap() > 0 && vy.Cap() > 0 && vx.Pointer() != vy.Pointer()
+}
+
+// compareDrained compares the contents of the channels vx and vy of type t.
+// Each channel is only drained once, even if it is compared again.
+func (s *state) compareDrained(vx, vy reflect.Value, t reflect.Type) {
+	var cs [2]reflect.Value
+	for i, v := range []reflect.Value{vx, vy} {
+		c, ok := s.drained.Load(v.Pointer())
+		if !ok {
+			c, _ = s.drained.LoadOrStore(v.Pointer(), drainChan(v))
+		}
+		cs[i] = c.(reflect.Value)
+	}
+
+	tr, ok := drainTransformers.Load(t)
+	if !ok {
+		fnc := reflect.MakeFunc(reflect.FuncOf([]reflect.Type{t}, []reflect.Type{reflect.SliceOf(t.Elem())}, false),
+			func(args []reflect.Value) []reflect.Value { return []reflect.Value{drainChan(args[0])} })
+		tr, _ = drainTransformers.LoadOrStore(t, &transformer{name: "λdrain", fnc: fnc})
+	}
+	s.compareTransformed(cs[0], cs[1], tr.(*transformer))
+}
+
+// drainChan receives the buffered values of the channel v without blocking.
+// No more values than the capacity of v are received, so that a concurrent
+// sender cannot keep the receive going forever.
+func drainChan(v reflect.Value) reflect.Value {
+	elems := reflect.MakeSlice(reflect.SliceOf(v.Type().Elem()), 0, v.Len())
+	for elems.Len() < v.Cap() {
+		e, ok := v.TryRecv()
+		if !ok {
+			break // Either closed or empty
+		}
+		elems = reflect.Append(elems, e)
+	}
+	return elems
+}
+
+func (s *state) tryMethod(vx, vy reflect.Value, t reflect.Type) bool {
+	// Check if this type even has an Equal method.
+	em := s.equalMethods(t)
+	if !em.fnc.IsValid() && em.ptrFnc.IsValid() && s.inInterface() {
+		// Values within an interface are unaddressable, so an Equal method
+		// on the pointer receiver is only usable on addressable copies.
+		// This is common for unexported implementations of an interface.
+		eq := s.callEqual(em.ptrFnc, nil, makeAddressable(vx).Addr(), makeAddressable(vy).Addr())
+		s.reportBy(eq, vx, vy, ByEqualMethod, "", em.ptrFnc)
+		return true
+	}
+	if !em.fnc.IsValid() {
+		return false
+	}
+	if hasNilEmbeddedEqual(vx, t) || hasNilEmbeddedEqual(vy, t) {
+		return false // Promoted Equal method would panic on a nil receiver
+	}
+
+	eq := s.callEqual(em.fnc, nil, vx, vy)
+	s.reportBy(eq, vx, vy, ByEqualMethod, "", em.fnc)
+	return true
+}
+
+// methodCache records the Equal methods of each type, since looking up
+// a method by name is relatively expensive. The methods of a type cannot
+// change, so the cache is retained when a state is released to the pool.
+type methodCache map[reflect.Type]equalMethods
+
+// equalMethods are the Equal methods usable for comparing values of type T.
+type equalMethods struct {
+	fnc    reflect.Value // Equal method of T, if valid
+	ptrFnc reflect.Value // Equal method of *T, if valid and T has none
+}
+
+// equalMethods returns the Equal methods for values of type t.
+func (s *state) equalMethods(t reflect.Type) equalMethods {
+	if em, ok := s.methods[t]; ok {
+		return em
+	}
+	var em equalMethods
+	if m, ok := t.MethodByName("Equal"); ok && isEqualMethod(m) {
+		em.fnc = m.Func
+	} else if t.Kind() != reflect.Ptr {
+		if m, ok := reflect.PtrTo(t).MethodByName("Equal"); ok && isEqualMethod(m) {
+			em.ptrFnc = m.Func
+		}
+	}
+	if s.methods == nil {
+		s.methods = make(methodCache)
+	}
+	s.methods[t] = em
+	return em
+}
+
+// isEqualMethod reports whether m has the signature of a valid Equal method.
+func isEqualMethod(m reflect.Method) bool {
+	ft := functionType(m.Type)
+	return ft == equalFunc || ft == equalIfaceFunc
+}
+
+func (s *state) callFunc(f reflect.Value, df directFunc, x, y reflect.Value) bool {
+	got := s.callBool(f, df, x, y)
+	if s.shouldCheck(f) {
+		s.checkFunc(f, df, x, y, got)
+	}
+	return got
+}
+
+// callEqual is like callFunc, but for a function f that reports whether
+// x and y are equal. If the traversal stops early because f reports that
+// they are unequal, then the result of Equal rests entirely on that call,
+// so it is verified unless function checks are disabled.
+func (s *state) callEqual(f reflect.Value, df directFunc, x, y reflect.Value) bool {
+	got := s.callBool(f, df, x, y)
+	if s.shouldCheck(f) || !got && s.short && s.checks.mode != checkNever {
+		s.checkFunc(f, df, x, y, got)
+	}
+	return got
+}
+
+// callVerdict calls f, a func(T, T) Verdict, with x and y, and verifies
+// that it is symmetric and deterministic in the same manner as callFunc.
+func (s *state) callVerdict(f reflect.Value, x, y reflect.Value) Verdict {
+	got := Verdict(s.call(f, x, y)[0].Int())
+	if s.shouldCheck(f) {
+		if want := Verdict(s.call(f, y, x)[0].Int()); got != want {
+			fn := getFuncName(f.Pointer())
+			fail(s.curPath, "non-deterministic or non-symmetric function detected: %s", fn)
+		}
+	}
+	return got
+}
+
+// checkFunc panics if f, which returned got when called with x and y,
+// is not symmetric and deterministic.
+func (s *state) checkFunc(f reflect.Value, df directFunc, x, y reflect.Value, got bool) {
+	// Swapping the input arguments is sufficient to check that
+	// f is symmetric and deterministic.
+	want := s.callBool(f, df, y, x)
+	if got != want {
+		fn := getFuncName(f.Pointer())
+		fail(s.curPath, "non-deterministic or non-symmetric function detected: %s", fn)
+	}
+}
+
+// call calls the user provided function f with the given arguments.
+// If f panics, then the panic is annotated with the name of f, the current
+// Path, and the inputs to f, since the original panic is otherwise difficult
+// to attribute from within the reflect.Value.Call stack.
+func (s *state) call(f reflect.Value, args ...reflect.Value) []reflect.Value {
+	s.checkArgs(f, args...)
+	defer s.annotatePanic(f, args...)
+	outs := f.Call(append(s.args[:0], args...))
+	s.args = [2]reflect.Value{} // Do not retain the arguments
+	return outs
+}
+
+// callBool calls the user provided function f, a func(T, T) bool, with x
+// and y in the same manner as call. If df is non-nil, then it is called
+// instead of f, which avoids the allocations made by reflect.Value.Call.
+func (s *state) callBool(f reflect.Value, df directFunc, x, y reflect.Value) bool {
+	// An untyped nil is passed as the zero value of an interface parameter.
+	if !x.IsValid() {
+		x = reflect.Zero(f.Type().In(0))
+	}
+	if !y.IsValid() {
+		y = reflect.Zero(f.Type().In(1))
+	}
+	if df == nil {
+		return s.call(f, x, y)[0].Bool()
+	}
+	s.checkArgs(f, x, y)
+	defer s.annotatePanic(f, x, y)
+	return df(x, y)
+}
+
+// checkArgs panics if f or any of the arguments to f are read-only.
+func (s *state) checkArgs(f reflect.Value, args ...reflect.Value) {
+	for _, v := range args {
+		if v.IsValid() && !v.CanInterface() || !f.CanInterface() {
+			fail(s.curPath, "cannot pass read-only value of unexported field to function at %#v", s.curPath)
+		}
+	}
+}
+
+// annotatePanic annotates a panic in f, which was called with args.
+// It must be called directly by a deferred call.
+func (s *state) annotatePanic(f reflect.Value, args ...reflect.Value) {
+	if ex := recover(); ex != nil {
+		var ss []string
+		for i, arg := range args {
+			ss = append(ss, fmt.Sprintf("\targ%d: %s", i, prettyPrint(arg, false)))
+		}
+		panic(fmt.Sprintf("function %s panicked at %#v with inputs:\n%s\npanic: %v",
+			getFuncName(f.Pointer()), s.curPath, strings.Join(ss, "\n"), ex))
+	}
+}
+
+// directFunc is an equivalent of a user provided func(T, T) bool that
+// is called without reflection.
+type directFunc func(x, y reflect.Value) bool
+
+// newDirectFunc returns a directFunc for f, which must be a func(T, T) bool,
+// if T is one of the common predeclared types or the empty interface.
+// Otherwise, it returns nil.
+func newDirectFunc(f interface{}) directFunc {
+	switch f := f.(type) {
+	case func(interface{}, interface{}) bool:
+		return func(x, y reflect.Value) bool { return f(x.Interface(), y.Interface()) }
+	case func(bool, bool) bool:
+		return func(x, y reflect.Value) bool { return f(x.Bool(), y.Bool()) }
+	case func(int, int) bool:
+		return func(x, y reflect.Value) bool { return f(int(x.Int()), int(y.Int())) }
+	case func(int64, int64) bool:
+		return func(x, y reflect.Value) bool { return f(x.Int(), y.Int()) }
+	case func(uint, uint) bool:
+		return func(x, y reflect.Value) bool { return f(uint(x.Uint()), uint(y.Uint())) }
+	case func(uint64, uint64) bool:
+		return func(x, y reflect.Value) bool { return f(x.Uint(), y.Uint()) }
+	case func(float32, float32) bool:
+		return func(x, y reflect.Value) bool { return f(float32(x.Float()), float32(y.Float())) }
+	case func(float64, float64) bool:
+		return func(x, y reflect.Value) bool { return f(x.Float(), y.Float()) }
+	case func(complex64, complex64) bool:
+		return func(x, y reflect.Value) bool { return f(complex64(x.Complex()), complex64(y.Complex())) }
+	case func(complex128, complex128) bool:
+		return func(x, y reflect.Value) bool { return f(x.Complex(), y.Complex()) }
+	case func(string, string) bool:
+		return func(x, y reflect.Value) bool { return f(x.String(), y.String()) }
+	}
+	return nil
+}
+
+// defaultCheckSamples is the default number of initial calls to each
+// user provided function that are verified.
+const defaultCheckSamples = 32
+
+// shouldCheck reports whether the current call to the user provided
+// function f should be verified for symmetry and determinism.
+func (s *state) shouldCheck(f reflect.Value) bool {
+	switch s.checks.mode {
+	case checkNever:
+		return false
+	case checkAlways:
+		return true
+	}
+	if s.dsCalls == nil {
+		s.dsCalls = make(map[uintptr]int)
+	}
+	p := f.Pointer()
+	c := s.dsCalls[p] // Number of prior calls to f
+	s.dsCalls[p] = c + 1
+	if c < s.samples {
+		return true
+	}
+	if s.checks.mode == checkRandom {
+		// Check with a probability of 1/C after C prior calls, which has
+		// about the same expected frequency as the exponential schedule.
+		return s.dsRand.Intn(c) == 0
+	}
+	k := c / s.samples
+	return c%s.samples == 0 && k&(k-1) == 0 // C is N times a power of two
+}
+
+func (s *state) compareArray(vx, vy reflect.Value, t reflect.Type) {
+	step := &s.nextSteps().sliceIndex
+	*step = sliceIndex{pathStep{t.Elem()}, 0}
+	s.curPath.push(step)
+	defer s.curPath.pop()
+
+	// Byte sequences are compared in one shot. If they differ, fall back to
+	// comparing each byte only if the differences need to be reported.
+	if s.fastBytes && t.Elem() == byteType && len(s.curPath)-1 <= s.maxDepth {
+		if bx, by, ok := rawBytes(vx, vy); ok {
+			eq := bytes.Equal(bx, by)
+			if eq || s.reporter == nil {
+				s.eq = s.eq && eq
+				s.numCmp += len(bx)
+				if len(by) > len(bx) {
+					s.numCmp += len(by) - len(bx)
+				}
+				return
+			}
+		}
+	}
+
+	// Regardless of the lengths, we always try to compare the elements.
+	// If one slice is longer, we will report the elements of the longer
+	// slice as different (relative to an invalid reflect.Value).
+	nmin := vx.Len()
+	if nmin > vy.Len() {
+		nmin = vy.Len()
+	}
+	if s.parallel(nmin) {
+		s.compareChunks(nmin, func(s *state, lo, hi int) { s.compareElems(vx, vy, lo, hi) })
+	} else {
+		s.compareElems(vx, vy, 0, nmin) // Avoid allocating a closure
+	}
+	for i := nmin; i < vx.Len() && !s.stopped(); i++ {
+		step.key = i
+		s.report(false, vx.Index(i), reflect.Value{})
+	}
+	for i := nmin; i < vy.Len() && !s.stopped(); i++ {
+		step.key = i
+		s.report(false, reflect.Value{}, vy.Index(i))
+	}
+}
+
+// compareElems compares the elements of vx and vy in the range [lo, hi),
+// where the step for the current element is the last step of the path.
+//
+// Elements of primitive kinds, to which no option or method could apply,
+// are first compared in chunks. Only the elements of chunks that differ are
+// compared individually, such that differences are reported as usual.
+func (s *state) compareElems(vx, vy reflect.Value, lo, hi int) {
+	step := s.curPath[len(s.curPath)-1].(*sliceIndex)
+	et := vx.Type().Elem()
+	chunked := isPrimitive(et.Kind()) && s.fastType(et).ok && len(s.curPath)-1 <= s.maxDepth && s.explain == nil
+	var ix, iy interface{} // Slices of all elements, if worth converting
+	if chunked && hi-lo > elemChunkLen && (vx.Kind() == reflect.Slice || vx.CanAddr() && vy.CanAddr()) &&
+		vx.CanInterface() && vy.CanInterface() {
+		ix, iy = vx.Slice(0, vx.Len()).Interface(), vy.Slice(0, vy.Len()).Interface()
+	}
+	for i := lo; i < hi && !s.stopped(); {
+		n := hi - i
+		if n > elemChunkLen {
+			n = elemChunkLen
+		}
+		if chunked && equalElemChunk(vx, vy, ix, iy, i, i+n) {
+			s.numCmp += n
+			i += n
+			continue
+		}
+		for j := i + n; i < j && !s.stopped(); i++ {
+			step.key = i
+			s.compareAny(vx.Index(i), vy.Index(i))
+		}
+	}
+}
+
+// elemChunkLen is the number of primitive elements compared at once.
+const elemChunkLen = 64
+
+// isPrimitive reports whether values of kind k are compared by value
+// without descending into them.
+func isPrimitive(k reflect.Kind) bool {
+	return k == reflect.Bool || k == reflect.String || isNumber(k) ||
+		k == reflect.Complex64 || k == reflect.Complex128
+}
+
+// equalElemChunk reports whether the elements of vx and vy in the range
+// [lo, hi), which must be of a primitive kind, are all equal according to
+// the default rules for that kind. If ix and iy are slices of all elements of
+// vx and vy, then common slice types are compared without reflection.
+func equalElemChunk(vx, vy reflect.Value, ix, iy interface{}, lo, hi int) bool {
+	switch x := ix.(type) {
+	case []int:
+		y := iy.([]int)
+		for i := lo; i < hi; i++ {
+			if x[i] != y[i] {
+				return false
+			}
+		}
+		return true
+	case []int64:
+		y := iy.([]int64)
+		for i := lo; i < hi; i++ {
+			if x[i] != y[i] {
+				return false
+			}
+		}
+		return true
+	case []uint64:
+		y := iy.([]uint64)
+		for i := lo; i < hi; i++ {
+			if x[i] != y[i] {
+				return false
+			}
+		}
+		return true
+	case []float64:
+		y := iy.([]float64)
+		for i := lo; i < hi; i++ {
+			if x[i] != y[i] {
+				return false
+			}
+		}
+		return true
+	case []string:
+		y := iy.([]string)
+		for i := lo; i < hi; i++ {
+			if x[i] != y[i] {
+				return false
+			}
+		}
+		return true
+	}
+
+	switch vx.Type().Elem().Kind() {
+	case reflect.Bool:
+		for i := lo; i < hi; i++ {
+			if vx.Index(i).Bool() != vy.Index(i).Bool() {
+				return false
+			}
+		}
+	case reflect.String:
+		for i := lo; i < hi; i++ {
+			if vx.Index(i).String() != vy.Index(i).String() {
+				return false
+			}
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		for i := lo; i < hi; i++ {
+			if vx.Index(i).Int() != vy.Index(i).Int() {
+				return false
+			}
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		for i := lo; i < hi; i++ {
+			if vx.Index(i).Uint() != vy.Index(i).Uint() {
+				return false
+			}
+		}
+	case reflect.Float32, reflect.Float64:
+		for i := lo; i < hi; i++ {
+			if vx.Index(i).Float() != vy.Index(i).Float() {
+				return false
+			}
+		}
+	case reflect.Complex64, reflect.Complex128:
+		for i := lo; i < hi; i++ {
+			if vx.Index(i).Complex() != vy.Index(i).Complex() {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// rawBytes returns the contents of vx and vy, which must be byte slices or
+// byte arrays of the same type. It reports false if the contents of arrays
+// cannot be accessed without copying, since they are not addressable.
+func rawBytes(vx, vy reflect.Value) (bx, by []byte, ok bool) {
+	if vx.Kind() == reflect.Array {
+		if !vx.CanAddr() || !vy.CanAddr() {
+			return nil, nil, false
+		}
+		vx, vy = vx.Slice(0, vx.Len()), vy.Slice(0, vy.Len())
+	}
+	return vx.Bytes(), vy.Bytes(), true
+}
+
+// compareMap compares the maps vx and vy. If shared is true, then keys that
+// are present in only one of the maps are disregarded.
+func (s *state) compareMap(vx, vy reflect.Value, t reflect.Type, shared bool) {
+	if !shared && (vx.IsNil() || vy.IsNil()) {
+		s.report(vx.IsNil() && vy.IsNil(), vx, vy)
+		return
+	}
+
+	// We combine and sort the two map keys so that we can perform the
+	// comparisons in a deterministic order.
+	keys := sortKeys(append(vx.MapKeys(), vy.MapKeys()...))
+	type entry struct {
+		vx, vy  reflect.Value
+		err     interface{} // Non-nil if the key could not be looked up
+		matched bool        // Whether the key in vy is matched with another key
+	}
+	entries := make([]entry, len(keys))
+	for i, k := range keys {
+		entries[i].vx, entries[i].vy, entries[i].err = lookupMapKey(vx, vy, k)
+	}
+	if s.mapKeys {
+		matches := s.matchMapKeys(t, keys, func(i int) (bool, bool) {
+			return entries[i].err == nil && entries[i].vx.IsValid(), entries[i].err == nil && entries[i].vy.IsValid()
+		})
+		for i, j := range matches {
+			entries[i].vy, entries[j].vy = entries[j].vy, reflect.Value{}
+			entries[j].matched = true
+		}
+	}
+	var pairs map[int]int
+	if s.reporter != nil {
+		// Pairing keys requires formatting them, which is only worthwhile
+		// if the differences are actually reported.
+		pairs = pairMapKeys(t, keys, func(i int) (bool, bool) {
+			if entries[i].matched {
+				return true, true // Neither only in x nor only in y
+			}
+			return entries[i].vx.IsValid(), entries[i].vy.IsValid()
+		})
+	}
+	step := &s.nextSteps().mapIndex
+	*step = mapIndex{pathStep: pathStep{t.Elem()}}
+	s.curPath.push(step)
+	defer s.curPath.pop()
+
+	var numX, numY int // Number of disregarded keys only in vx or vy
+	if shared {
+		for _, e := range entries {
+			if e.err == nil && !e.matched && e.vx.IsValid() != e.vy.IsValid() {
+				if e.vx.IsValid() {
+					numX++
+				} else {
+					numY++
+				}
+			}
+		}
+		eq := s.eq
+		s.eq = true
+		defer func() {
+			if !s.eq && numX+numY > 0 {
+				s.reportNote("disregarded %d map keys only in x and %d map keys only in y", numX, numY)
+			}
+			s.eq = s.eq && eq
+		}()
+	}
+	s.compareChunks(len(keys), func(s *state, lo, hi int) {
+		step := s.curPath[len(s.curPath)-1].(*mapIndex)
+		for i := lo; i < hi && !s.stopped(); i++ {
+			k := keys[i]
+			step.key = k
+			vvx, vvy := entries[i].vx, entries[i].vy
+			if entries[i].matched {
+				continue // Compared with the key in vx that it is matched with
+			}
+			if shared && entries[i].err == nil && vvx.IsValid() != vvy.IsValid() {
+				continue // Disregarded key
+			}
+			switch j, paired := pairs[i]; {
+			case paired && j < 0:
+				continue // Already reported as part of a key mismatch
+			case paired:
+				s.report(false, k, keys[j])
+				s.reportNote("map keys are equal except for their dynamic types: %v and %v",
+					k.Elem().Type(), keys[j].Elem().Type())
+			case entries[i].err != nil:
+				s.report(false, k, k)
+				s.reportNote("map key cannot be looked up: %v", entries[i].err)
+			case vvx.IsValid() && vvy.IsValid():
+				s.compareAny(vvx, vvy)
+			case vvx.IsValid() && !vvy.IsValid():
+				s.report(false, vvx, reflect.Value{})
+			case !vvx.IsValid() && vvy.IsValid():
+				s.report(false, reflect.Value{}, vvy)
+			default:
+				// It is possible for both vvx and vvy to be invalid if the
+				// key contained a NaN value in it. There is no way in
+				// reflection to be able to retrieve these values.
+				// See https://golang.org/issue/11104
+				fail(s.curPath, "%#v has map key with NaNs", s.curPath)
+			}
+		}
+	})
+}
+
+// minParallelLen is the minimum number of elements in a slice, array,
+// or map for which the elements are compared concurrently, below which
+// the overhead of the goroutines outweighs the benefit.
+const minParallelLen = 1024
+
+// parallel reports whether n elements at the current node should be
+// compared concurrently.
+func (s *state) parallel(n int) bool {
+	return s.workers > 1 && !s.inChunk && n >= minParallelLen
+}
+
+// compareChunks calls f to compare the elements in the range [lo, hi)
+// for the whole range [0, n). If the elements should be compared
+// concurrently, then the range is divided into chunks that are each compared
+// by a worker with its own copy of the state and its own forked reporter.
+// The results of each chunk are then merged in order, such that the outcome
+// is the same as if the whole range had been compared sequentially.
+// A panic in any chunk is propagated once all workers have finished.
+func (s *state) compareChunks(n int, f func(s *state, lo, hi int)) {
+	if !s.parallel(n) {
+		f(s, 0, n)
+		return
+	}
+
+	type chunk struct {
+		eq     bool
+		numCmp int
+		rep    reporter
+		ex     interface{} // Recovered panic, if any
+	}
+	size := (n + 4*s.workers - 1) / (4 * s.workers)
+	chunks := make([]chunk, (n+size-1)/size)
+	var next, stop int32 // Index of the next chunk and whether to stop early
+	var wg sync.WaitGroup
+	for w := 0; w < s.workers && w < len(chunks); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s2 := *s
+			s2.inChunk = true
+			s2.steps, s2.fastTypes, s2.typeOpts, s2.methods = nil, nil, nil, nil
+			for atomic.LoadInt32(&stop) == 0 {
+				i := int(atomic.AddInt32(&next, 1)) - 1
+				if i >= len(chunks) {
+					return
+				}
+				c := &chunks[i]
+				s2.eq, s2.numCmp, s2.dsCalls = true, 0, make(map[uintptr]int, len(s.dsCalls))
+				for p, c := range s.dsCalls {
+					s2.dsCalls[p] = c // Continue the schedule of the parent
+				}
+				s2.curPath = s.curPath.clone()
+				if s.dsRand != nil {
+					// Derive the checks from the chunk for reproducibility.
+					s2.dsRand = rand.New(rand.NewSource(s.checks.seed + int64(i)))
+				}
+				if s.reporter != nil {
+					s2.reporter = s.reporter.(concurrentReporter).fork()
+					s2.limited, _ = s2.reporter.(limitedReporter)
+				}
+				c.rep = s2.reporter
+				lo, hi := i*size, (i+1)*size
+				if hi > n {
+					hi = n
+				}
+				func() {
+					defer func() { c.ex = recover() }()
+					f(&s2, lo, hi)
+				}()
+				c.eq, c.numCmp = s2.eq, s2.numCmp
+				if c.ex != nil || s2.stopped() {
+					atomic.StoreInt32(&stop, 1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, c := range chunks {
+		// Chunks are only skipped after an earlier chunk panicked or stopped,
+		// so the loop always ends before reaching a skipped chunk.
+		if s.stopped() {
+			break // Remaining chunks would not have been compared sequentially
+		}
+		if c.ex != nil {
+			panic(c.ex)
+		}
+		s.eq = s.eq && c.eq
+		s.numCmp += c.numCmp
+		if c.rep != nil {
+			s.reporter.(concurrentReporter).join(c.rep)
+		}
+	}
+}
+
+// matchMapKeys matches up keys that are only present in x with keys that are
+// only present in y and are equal to them according to the options, which
+// are evaluated with a MapKey as the last step of the path. It returns the
+// index of the key in y matched with the index of each key in x.
+// The function has reports whether the key at an index is present in x and y.
+func (s *state) matchMapKeys(t reflect.Type, keys []reflect.Value, has func(int) (bool, bool)) map[int]int {
+	s.curPath.push(&mapKey{pathStep{t.Key()}})
+	defer s.curPath.pop()
+	matches := make(map[int]int)
+	matched := make(map[int]bool)
+	for i := range keys {
+		if hx, hy := has(i); !hx || hy {
+			continue
+		}
+		for j := range keys {
+			if hx, hy := has(j); hx || !hy || matched[j] {
+				continue
+			}
+			if s.isEqual(keys[i], keys[j]) {
+				matches[i], matched[j] = j, true
+				break
+			}
+		}
+	}
+	return matches
+}
+
+// lookupMapKey retrieves the entries for key k in the maps vx and vy.
+// Looking up a key may panic if the key is an interface that holds
+// an uncomparable value, in which case the recovered value is returned.
+func lookupMapKey(vx, vy, k reflect.Value) (vvx, vvy reflect.Value, err interface{}) {
+	defer func() {
+		if ex := recover(); ex != nil {
+			vvx, vvy, err = reflect.Value{}, reflect.Value{}, ex
+		}
+	}()
+	return vx.MapIndex(k), vy.MapIndex(k), nil
+}
+
+// pairMapKeys pairs up keys that are only present in x with keys that are
+// only present in y, where both keys are interfaces holding values that
+// format identically but have different dynamic types (e.g., int(1) and
+// int64(1)). The presence of the key at index i is reported by has.
+// The returned map maps the index of the x key to the index of the y key,
+// and the index of the y key to -1.
+func pairMapKeys(t reflect.Type, keys []reflect.Value, has func(int) (bool, bool)) map[int]int {
+	if t.Key().Kind() != reflect.Interface {
+		return nil
+	}
+	var pairs map[int]int
+	conf := formatConfig{followPointers: true}
+	for i, kx := range keys {
+		if okx, oky := has(i); !okx || oky || kx.IsNil() {
+			continue
+		}
+		for j, ky := range keys {
+			if okx, oky := has(j); okx || !oky || ky.IsNil() {
+				continue
+			}
+			if _, ok := pairs[j]; ok || kx.Elem().Type() == ky.Elem().Type() {
+				continue
+			}
+			if formatAny(kx.Elem(), conf, nil) == formatAny(ky.Elem(), conf, nil) {
+				if pairs == nil {
+					pairs = make(map[int]int)
+				}
+				pairs[i], pairs[j] = j, -1
+				break
+			}
+		}
+	}
+	return pairs
+}
+
+func (s *state) compareStruct(vx, vy reflect.Value, t reflect.Type) {
+	var vax, vay reflect.Value // Addressable versions of vx and vy
+
+	step := &s.nextSteps().structField
+	*step = structField{}
+	s.curPath.push(step)
+	defer s.curPath.pop()
+	var unexported []bool
+	if s.lazyPath {
+		unexported = unexportedFields(t)
+		step.parent = t
+	}
+	for i := 0; i < t.NumField() && !s.stopped(); i++ {
+		vvx := vx.Field(i)
+		vvy := vy.Field(i)
+		if s.lazyPath {
+			step.idx, step.unexported = i, unexported[i]
+		} else {
+			step.typ = t.Field(i).Type
+			step.name = t.Field(i).Name
+			step.idx = i
+			step.unexported = !isExported(step.name)
+		}
+		if step.unexported {
+			// Defer checking of unexported fields until later to give an
+			// Ignore a chance to ignore the field.
+			if !vax.IsValid() || !vay.IsValid() {
+				// For unsafeRetrieveField to work, the parent struct must
+				// be addressable. Create a new copy of the values if
+				// necessary to make them addressable.
+				vax = makeAddressable(vx)
+				vay = makeAddressable(vy)
+			}
+			step.force = s.exporters[t]
+			step.pvx = vax
+			step.pvy = vay
+			step.field = t.Field(i)
+		}
+		s.compareAny(vvx, vvy)
+	}
+}
+
+// structExports records which fields of each struct type are unexported,
+// which is cheaper to look up than each reflect.StructField.
+var structExports sync.Map // map[reflect.Type][]bool
+
+// unexportedFields reports whether each field of the struct type t
+// is unexported.
+func unexportedFields(t reflect.Type) []bool {
+	if v, ok := structExports.Load(t); ok {
+		return v.([]bool)
+	}
+	unexported := make([]bool, t.NumField())
+	for i := range unexported {
+		unexported[i] = !isExported(t.Field(i).Name)
+	}
+	structExports.Store(t, unexported)
+	return unexported
+}
+
+// stepCache holds the path steps that may be pushed at some depth of the
+// value tree. Since at most one step is active at each depth at any time,
+// the steps are reused rather than allocated for every node visited,
+// unless a user function may observe and retain the path.
+type stepCache struct {
+	root          pathStep
+	indirect      indirect
+	typeAssertion typeAssertion
+	sliceIndex    sliceIndex
+	mapIndex      mapIndex
+	structField   structField
+}
+
+// nextSteps returns the reusable path steps for the next step to be pushed.
+func (s *state) nextSteps() *stepCache {
+	if s.newSteps {
+		return new(stepCache)
+	}
+	for len(s.steps) <= len(s.curPath) {
+		s.steps = append(s.steps, new(stepCache))
+	}
+	return s.steps[len(s.curPath)]
+}
+
+// report records the result of a single comparison.
+// It also calls Report if any reporter is registered.
+func (s *state) report(eq bool, vx, vy reflect.Value) {
+	s.eq = s.eq && eq
+	s.numCmp++
+	if s.reporter != nil {
+		s.reporter.Report(vx, vy, eq, s.curPath)
+	}
+	if s.explain != nil {
+		s.explainReport(eq, ByDefaultRules, "")
+	}
+}
+
+// explainReport records a decision at the current path, if explaining.
+func (s *state) explainReport(eq bool, m Mechanism, name string) {
+	*s.explain = append(*s.explain, Decision{s.curPath.clone(), eq, m, name})
+}
+
+// explainIgnore records that the current values were ignored by opt.
+func (s *state) explainIgnore(opt option) {
+	if s.explain != nil {
+		s.explainReport(true, ByIgnore, opt.String())
+	}
+}
+
+// reportBy is like report, but for values decided by the mechanism m
+// rather than the default rules, which used the function f, if valid.
+// The function is identified by name, or by its own name if empty.
+func (s *state) reportBy(eq bool, vx, vy reflect.Value, m Mechanism, name string, f reflect.Value) {
+	if s.explain == nil {
+		s.report(eq, vx, vy)
+		return
+	}
+	explain := s.explain
+	s.explain = nil
+	s.report(eq, vx, vy)
+	s.explain = explain
+	if name == "" && f.IsValid() {
+		name = getFuncName(f.Pointer())
+	}
+	s.explainReport(eq, m, name)
+}
+
+// stopped reports whether the traversal may stop early, since the values
+// are already known to be unequal and either only the result matters or
+// the reporter accepts no further differences.
+func (s *state) stopped() bool {
+	if s.eq {
+		return false
+	}
+	return s.short || (s.limited != nil && s.limited.isFull())
+}
+
+// reportNote attaches a note to the most recently reported difference.
+// The note is only formatted if it will actually be reported.
+func (s *state) reportNote(format string, args ...interface{}) {
+	if s.wantsNote() {
+		s.reporter.(*defaultReporter).reportNote(fmt.Sprintf(format, args...))
+	}
+}
+
+// wantsNote reports whether a note on the most recently reported difference
+// would actually be reported.
+func (s *state) wantsNote() bool {
+	r, ok := s.reporter.(*defaultReporter)
+	return ok && r.acceptsNote()
+}
+
+// isAlias reports whether vx and vy are non-nil references to the same
+// underlying memory. Slices are only aliases if they also have the same length.
+func isAlias(vx, vy reflect.Value) bool {
+	switch vx.Kind() {
+	case reflect.Slice:
+		if vx.Len() != vy.Len() {
+			return false
+		}
+		fallthrough
+	case reflect.Ptr, reflect.Map:
+		return !vx.IsNil() && vx.Pointer() == vy.Pointer()
+	default:
+		return false
+	}
+}
+
+// isNilInterface reports whether the interface v is nil or holds a nil
+// pointer, map, slice, channel, or function.
+func isNilInterface(v reflect.Value) bool {
+	if v.IsNil() {
+		return true
+	}
+	switch v.Elem().Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Chan, reflect.Func:
+		return v.Elem().IsNil()
+	default:
+		return false
+	}
+}
+
+// truncatePath formats p using Go syntax, eliding the middle of the path
+// if it is too long to be reasonably printed.
+func truncatePath(p Path) string {
+	const maxLen = 256
+	s := p.GoString()
+	if len(s) <= maxLen {
+		return s
+	}
+	return s[:maxLen/2] + "..." + s[len(s)-maxLen/2:]
+}
+
+// indentOption formats an option, along with where it was created,
+// for display within a nested list.
+func indentOption(opt option) string {
+	s := opt.String()
+	if opt.src != "" {
+		s += "\n\tcreated at " + opt.src
+	}
+	return strings.Replace(s, "\n", "\n\t", -1)
+}
+
+// tryTextMarshaler compares vx and vy by their MarshalText output if t or *t
+// implements encoding.TextMarshaler. Pointers are not marshaled directly,
+// but rather the values that they point to.
+func (s *state) tryTextMarshaler(vx, vy reflect.Value, t reflect.Type) bool {
+	switch {
+	case t.Kind() == reflect.Ptr || t.Kind() == reflect.Interface:
+		return false
+	case t.Implements(textMarshalerType):
+	case reflect.PtrTo(t).Implements(textMarshalerType):
+		vx, vy = makeAddressable(vx).Addr(), makeAddressable(vy).Addr()
+	default:
+		return false
+	}
+	tx, ty := s.marshalText(vx), s.marshalText(vy)
+	s.reportBy(tx == ty, reflect.ValueOf(tx), reflect.ValueOf(ty), ByTextMarshaler, "", reflect.Value{})
+	return true
+}
+
+func (s *state) marshalText(v reflect.Value) string {
+	out := s.call(v.MethodByName("MarshalText"))
+	if err, _ := out[1].Interface().(error); err != nil {
+		fail(s.curPath, "MarshalText failed at %#v: %v", s.curPath, err)
+	}
+	return string(out[0].Bytes())
+}
+
+// inInterface reports whether the current value is the concrete value of
+// an interface.
+func (s *state) inInterface() bool {
+	_, ok := s.curPath[len(s.curPath)-1].(*typeAssertion)
+	return ok
+}
+
+// hasNilEmbeddedEqual reports whether the struct v has a nil embedded
+// interface field that has an Equal method, which may be promoted to v.
+func hasNilEmbeddedEqual(v reflect.Value, t reflect.Type) bool {
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Interface && v.Field(i).IsNil() {
+			if _, ok := f.Type.MethodByName("Equal"); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isAggregate reports whether t is a composite type (or a reference to one)
+// whose sub-values may be meaningfully compared.
+func isAggregate(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return isAggregate(t.Elem())
+	case reflect.Struct, reflect.Slice, reflect.Array, reflect.Map, reflect.Interface:
+		return true
+	default:
+		return false
+	}
+}
+
+// makeAddressable returns a value that is always addressable.
+// It returns the input verbatim if it is already addressable,
+// otherwise it creates a new value and returns an addressable copy.
+// As an exception, read-only values obtained from unexported fields without
+// the use of package unsafe cannot be copied and are returned verbatim.
+func makeAddressable(v reflect.Value) reflect.Value {
+	if v.CanAddr() || !v.CanInterface() {
+		return v
+	}
+	vc := reflect.New(v.Type()).Elem()
+	vc.Set(v)
+	return vc
+}
+
+// interfaceValue returns v as a value of type interface{},
+// which is nil if v is invalid.
+func interfaceValue(v reflect.Value) reflect.Value {
+	iv := reflect.New(interfaceType).Elem()
+	if v.IsValid() {
+		iv.Set(v)
+	}
+	return iv
+}
+
+// copyValue returns an addressable shallow copy of v,
+// which is not backed by the same memory as v.
+func copyValue(v reflect.Value) reflect.Value {
+	if !v.CanInterface() {
+		return v // Read-only values cannot be written through anyways
+	}
+	vc := reflect.New(v.Type()).Elem()
+	vc.Set(v)
+	return vc
+}
+
+type funcType int
+
+const (
+	invalidFunc      funcType    = iota
+	equalFunc                    // func(T, T) bool
+	equalIfaceFunc               // func(T, I) bool
+	transformFunc                // func(T) R
+	transformErrFunc             // func(T) (R, error)
+	valueFilterFunc  = equalFunc // func(T, T) bool
 )
 
-var boolType = reflect.TypeOf(true)
+var (
+	boolType          = reflect.TypeOf(true)
+	byteType          = reflect.TypeOf(byte(0))
+	errorType         = reflect.TypeOf((*error)(nil)).Elem()
+	interfaceType     = reflect.TypeOf((*interface{})(nil)).Elem()
+	reflectTypeType   = reflect.TypeOf((*reflect.Type)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
 
 // functionType identifies which type of function signature this is.
 func functionType(t reflect.Type) funcType {
@@ -508,6 +3377,8 @@ func functionType(t reflect.Type) funcType {
 		return equalIfaceFunc
 	case ni == 1 && no == 1:
 		return transformFunc
+	case ni == 1 && no == 2 && t.Out(1) == errorType:
+		return transformErrFunc
 	default:
 		return invalidFunc
 	}
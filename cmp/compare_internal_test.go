@@ -0,0 +1,87 @@
+// Copyright 2017, The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package cmp
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Test that functions called without reflection return the same results
+// as when they are called using reflection.
+func TestDirectFunc(t *testing.T) {
+	tests := []struct {
+		f    interface{}
+		x, y interface{}
+	}{
+		{func(x, y bool) bool { return x && !y }, true, false},
+		{func(x, y int) bool { return x < y }, -1, 1},
+		{func(x, y int64) bool { return x < y }, int64(-1), int64(1)},
+		{func(x, y uint) bool { return x < y }, uint(1), uint(2)},
+		{func(x, y uint64) bool { return x < y }, uint64(1), uint64(2)},
+		{func(x, y float32) bool { return x+1 == y }, float32(0.1), float32(1.1)},
+		{func(x, y float64) bool { return x+1 == y }, 0.1, 1.1},
+		{func(x, y complex64) bool { return x+1 == y }, complex64(0.1i), complex64(1 + 0.1i)},
+		{func(x, y complex128) bool { return x+1 == y }, 0.1i, 1 + 0.1i},
+		{func(x, y string) bool { return x+"b" == y }, "a", "ab"},
+		{func(x, y interface{}) bool { return x == "a" && y == 1 }, "a", 1},
+	}
+	for _, tt := range tests {
+		df := newDirectFunc(tt.f)
+		if df == nil {
+			t.Errorf("newDirectFunc(%T) = nil, want non-nil", tt.f)
+			continue
+		}
+		vx, vy := reflect.ValueOf(tt.x), reflect.ValueOf(tt.y)
+		for _, args := range [][2]reflect.Value{{vx, vy}, {vy, vx}} {
+			want := reflect.ValueOf(tt.f).Call(args[:])[0].Bool()
+			if got := df(args[0], args[1]); got != want {
+				t.Errorf("direct %T(%v, %v) = %v, want %v", tt.f, args[0], args[1], got, want)
+			}
+		}
+	}
+	if df := newDirectFunc(func(x, y []int) bool { return true }); df != nil {
+		t.Errorf("newDirectFunc(func([]int, []int) bool) = non-nil, want nil")
+	}
+}
+
+// Test that looking up a map key holding an uncomparable value does not panic.
+// Such a key cannot be inserted into a map, but it can be looked up.
+func TestLookupMapKey(t *testing.T) {
+	type key struct{ A interface{} }
+	vx := reflect.ValueOf(map[interface{}]int{key{"a"}: 1})
+	vy := reflect.ValueOf(map[interface{}]int{key{"a"}: 2})
+	k := reflect.ValueOf(key{[]int{1}})
+
+	vvx, vvy, err := lookupMapKey(vx, vy, k)
+	if vvx.IsValid() || vvy.IsValid() {
+		t.Errorf("lookupMapKey() = (%v, %v), want invalid values", vvx, vvy)
+	}
+	if got, want := fmt.Sprint(err), "unhashable type"; !strings.Contains(got, want) {
+		t.Errorf("lookupMapKey() error = %v, want %q", got, want)
+	}
+
+	vvx, vvy, err = lookupMapKey(vx, vy, reflect.ValueOf(key{"a"}))
+	if err != nil || vvx.Int() != 1 || vvy.Int() != 2 {
+		t.Errorf("lookupMapKey() = (%v, %v, %v), want (1, 2, <nil>)", vvx, vvy, err)
+	}
+}
+
+type plainReporter struct{ Option }
+
+func (plainReporter) Report(x, y reflect.Value, eq bool, p Path) {}
+
+// Test that Parallel refuses a reporter that cannot be forked.
+func TestParallelReporter(t *testing.T) {
+	defer func() {
+		want := "cannot use Parallel with a reporter that is not safe for concurrent use"
+		if got := fmt.Sprint(recover()); got != want {
+			t.Errorf("Equal() panic = %q, want %q", got, want)
+		}
+	}()
+	Equal(1, 2, Parallel(4), plainReporter{})
+}
@@ -6,14 +6,22 @@ package cmp_test
 
 import (
 	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math"
+	"math/big"
 	"math/rand"
+	"net/url"
 	"reflect"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 	"unicode"
@@ -40,9 +48,12 @@ func equalRegexp(x, y *regexp.Regexp) bool {
 func IgnoreUnexported(typs ...interface{}) cmp.Option {
 	m := make(map[reflect.Type]bool)
 	for _, typ := range typs {
-		t := reflect.TypeOf(typ)
+		t, ok := typ.(reflect.Type)
+		if !ok {
+			t = reflect.TypeOf(typ)
+		}
 		if t.Kind() != reflect.Struct {
-			panic(fmt.Sprintf("invalid struct type: %T", typ))
+			panic(fmt.Sprintf("invalid struct type: %v", t))
 		}
 		m[t] = true
 	}
@@ -81,6 +92,48 @@ func TestDiff(t *testing.T) {
 	tests = append(tests, project2Tests()...)
 	tests = append(tests, project3Tests()...)
 	tests = append(tests, project4Tests()...)
+	tests = append(tests, comparerDetailsTests()...)
+	tests = append(tests, maxDepthTests()...)
+	tests = append(tests, nilInterfaceTests()...)
+	tests = append(tests, priorityTests()...)
+	tests = append(tests, mapKeyTests()...)
+	tests = append(tests, funcChanTests()...)
+	tests = append(tests, untypedNilTests()...)
+	tests = append(tests, strictIgnoreTests()...)
+	tests = append(tests, equateApproxTests()...)
+	tests = append(tests, equateApproxComplexTests()...)
+	tests = append(tests, equateBigTests()...)
+	tests = append(tests, bitwiseFloatTests()...)
+	tests = append(tests, compareByStringTests()...)
+	tests = append(tests, compareErrorsTests()...)
+	tests = append(tests, multisetTests()...)
+	tests = append(tests, setTests()...)
+	tests = append(tests, fieldsByNameTests()...)
+	tests = append(tests, pointersToValuesTests()...)
+	tests = append(tests, bytesAndStringsTests()...)
+	tests = append(tests, runesAndStringsTests()...)
+	tests = append(tests, numbersInInterfacesTests()...)
+	tests = append(tests, structsToMapsTests()...)
+	tests = append(tests, textMarshalerTests()...)
+	tests = append(tests, reflectTypeTests()...)
+	tests = append(tests, comparePointersByIdentityTests()...)
+	tests = append(tests, shallowTests()...)
+	tests = append(tests, requireComparisonsTests()...)
+	tests = append(tests, transformChannelContentsTests()...)
+	tests = append(tests, transformSyncMapsTests()...)
+	tests = append(tests, transformLoadsTests()...)
+	tests = append(tests, transformReadersTests()...)
+	tests = append(tests, normalizeLineEndingsTests()...)
+	tests = append(tests, collapseWhitespaceTests()...)
+	tests = append(tests, anyOfTests()...)
+	tests = append(tests, allOfTests()...)
+	tests = append(tests, notTests()...)
+	tests = append(tests, filterPathValuesTests()...)
+	tests = append(tests, decidingComparerTests()...)
+	tests = append(tests, optionsDedupTests()...)
+	tests = append(tests, exactComparerTests()...)
+	tests = append(tests, filterMapKeysTests()...)
+	tests = append(tests, optionerTests()...)
 
 	for _, tt := range tests {
 		t.Run(tt.label, func(t *testing.T) {
@@ -113,1649 +166,6687 @@ func TestDiff(t *testing.T) {
 	}
 }
 
-func comparerTests() []test {
-	const label = "Comparer"
+func TestAmbiguousOptions(t *testing.T) {
+	var gotPanic string
+	func() {
+		defer func() { gotPanic, _ = recover().(string) }()
+		cmp.Equal(struct{ A int }{}, struct{ A int }{},
+			cmp.FilterPath(func(p cmp.Path) bool { return p.String() == "A" },
+				cmp.Comparer(func(x, y int) bool { return x == y })),
+			cmp.Transformer("Negate", func(in int) int { return -in }),
+		)
+	}()
+	for _, want := range []string{
+		"ambiguous set of options at root.A for type int",
+		"Comparer(cmp_test.TestAmbiguousOptions.",
+		"FilterPath(cmp_test.TestAmbiguousOptions.",
+		"Transformer(Negate, cmp_test.TestAmbiguousOptions.",
+		"created at compare_test.go:",
+	} {
+		if !strings.Contains(gotPanic, want) {
+			t.Errorf("panic message does not contain %q:\n%s", want, gotPanic)
+		}
+	}
+}
 
-	return []test{{
-		label:    label,
-		x:        1,
-		y:        1,
-		wantDiff: "",
-	}, {
-		label:     label,
-		x:         1,
-		y:         1,
-		opts:      []cmp.Option{cmp.Ignore()},
-		wantPanic: "cannot use an unfiltered option",
-	}, {
-		label:     label,
-		x:         1,
-		y:         1,
-		opts:      []cmp.Option{cmp.Comparer(func(_, _ interface{}) bool { return true })},
-		wantPanic: "cannot use an unfiltered option",
-	}, {
-		label:     label,
-		x:         1,
-		y:         1,
-		opts:      []cmp.Option{cmp.Transformer("", func(x interface{}) interface{} { return x })},
-		wantPanic: "cannot use an unfiltered option",
-	}, {
-		label: label,
-		x:     1,
-		y:     1,
-		opts: []cmp.Option{
-			cmp.Comparer(func(x, y int) bool { return true }),
-			cmp.Transformer("", func(x int) float64 { return float64(x) }),
-		},
-		wantPanic: "ambiguous set of options",
-	}, {
-		label: label,
-		x:     1,
-		y:     1,
-		opts: []cmp.Option{
-			cmp.FilterPath(func(p cmp.Path) bool {
-				return len(p) > 0 && p[len(p)-1].Type().Kind() == reflect.Int
-			}, cmp.Options{cmp.Ignore(), cmp.Ignore(), cmp.Ignore()}),
-			cmp.Comparer(func(x, y int) bool { return true }),
-			cmp.Transformer("", func(x int) float64 { return float64(x) }),
-		},
-	}, {
-		label:     label,
-		opts:      []cmp.Option{struct{ cmp.Option }{}},
-		wantPanic: "unknown option",
-	}, {
-		label:    label,
-		x:        struct{ A, B, C int }{1, 2, 3},
-		y:        struct{ A, B, C int }{1, 2, 3},
-		wantDiff: "",
-	}, {
-		label:    label,
-		x:        struct{ A, B, C int }{1, 2, 3},
-		y:        struct{ A, B, C int }{1, 2, 4},
-		wantDiff: "root.C:\n\t-: 3\n\t+: 4\n",
-	}, {
-		label:     label,
-		x:         struct{ a, b, c int }{1, 2, 3},
-		y:         struct{ a, b, c int }{1, 2, 4},
-		wantPanic: "cannot handle unexported field",
-	}, {
-		label: label,
-		x:     &struct{ A *int }{intPtr(4)},
-		y:     &struct{ A *int }{intPtr(4)},
-	}, {
-		label:    label,
-		x:        &struct{ A *int }{intPtr(4)},
-		y:        &struct{ A *int }{intPtr(5)},
-		wantDiff: "*root.A:\n\t-: 4\n\t+: 5\n",
-	}, {
-		label: label,
-		x:     &struct{ A *int }{intPtr(4)},
-		y:     &struct{ A *int }{intPtr(5)},
-		opts: []cmp.Option{
-			cmp.Comparer(func(x, y int) bool { return true }),
-		},
-	}, {
-		label: label,
-		x:     &struct{ A *int }{intPtr(4)},
-		y:     &struct{ A *int }{intPtr(5)},
-		opts: []cmp.Option{
-			cmp.Comparer(func(x, y *int) bool { return x != nil && y != nil }),
-		},
-	}, {
-		label: label,
-		x:     &struct{ R *bytes.Buffer }{},
-		y:     &struct{ R *bytes.Buffer }{},
-	}, {
-		label:    label,
-		x:        &struct{ R *bytes.Buffer }{new(bytes.Buffer)},
-		y:        &struct{ R *bytes.Buffer }{},
-		wantDiff: "root.R:\n\t-: \"\"\n\t+: <nil>\n",
-	}, {
-		label: label,
-		x:     &struct{ R *bytes.Buffer }{new(bytes.Buffer)},
-		y:     &struct{ R *bytes.Buffer }{},
-		opts: []cmp.Option{
-			cmp.Comparer(func(x, y io.Reader) bool { return true }),
-		},
-	}, {
-		label:     label,
-		x:         &struct{ R bytes.Buffer }{},
-		y:         &struct{ R bytes.Buffer }{},
-		wantPanic: "cannot handle unexported field",
-	}, {
-		label: label,
-		x:     &struct{ R bytes.Buffer }{},
-		y:     &struct{ R bytes.Buffer }{},
-		opts: []cmp.Option{
-			cmp.Comparer(func(x, y io.Reader) bool { return true }),
-		},
-		wantPanic: "cannot handle unexported field",
-	}, {
-		label: label,
-		x:     &struct{ R bytes.Buffer }{},
-		y:     &struct{ R bytes.Buffer }{},
-		opts: []cmp.Option{
-			cmp.Transformer("Ref", func(x bytes.Buffer) *bytes.Buffer { return &x }),
-			cmp.Comparer(func(x, y io.Reader) bool { return true }),
-		},
-	}, {
-		label:     label,
-		x:         []*regexp.Regexp{nil, regexp.MustCompile("a*b*c*")},
-		y:         []*regexp.Regexp{nil, regexp.MustCompile("a*b*c*")},
-		wantPanic: "cannot handle unexported field",
-	}, {
-		label:    label,
-		x:        []*regexp.Regexp{nil, regexp.MustCompile("a*b*c*")},
-		y:        []*regexp.Regexp{nil, regexp.MustCompile("a*b*c*")},
-		opts:     []cmp.Option{cmp.Comparer(equalRegexp)},
-		wantDiff: "",
-	}, {
-		label:    label,
-		x:        []*regexp.Regexp{nil, regexp.MustCompile("a*b*c*")},
-		y:        []*regexp.Regexp{nil, regexp.MustCompile("a*b*d*")},
-		opts:     []cmp.Option{cmp.Comparer(equalRegexp)},
-		wantDiff: "{[]*regexp.Regexp}[1]:\n\t-: \"a*b*c*\"\n\t+: \"a*b*d*\"\n",
-	}, {
-		label: label,
-		x: func() ***int {
-			a := 0
-			b := &a
-			c := &b
-			return &c
-		}(),
-		y: func() ***int {
-			a := 0
-			b := &a
-			c := &b
-			return &c
-		}(),
-	}, {
-		label: label,
-		x: func() ***int {
-			a := 0
-			b := &a
-			c := &b
-			return &c
-		}(),
-		y: func() ***int {
-			a := 1
-			b := &a
-			c := &b
-			return &c
-		}(),
-		wantDiff: `
-***{***int}:
-	-: 0
-	+: 1`,
-	}, {
-		label: label,
-		x:     []int{1, 2, 3, 4, 5}[:3],
-		y:     []int{1, 2, 3},
-	}, {
-		label: label,
-		x:     struct{ fmt.Stringer }{bytes.NewBufferString("hello")},
-		y:     struct{ fmt.Stringer }{regexp.MustCompile("hello")},
-		opts:  []cmp.Option{cmp.Comparer(func(x, y fmt.Stringer) bool { return x.String() == y.String() })},
-	}, {
-		label: label,
-		x:     struct{ fmt.Stringer }{bytes.NewBufferString("hello")},
-		y:     struct{ fmt.Stringer }{regexp.MustCompile("hello2")},
-		opts:  []cmp.Option{cmp.Comparer(func(x, y fmt.Stringer) bool { return x.String() == y.String() })},
-		wantDiff: `
-root:
-	-: "hello"
-	+: "hello2"`,
-	}, {
-		label: label,
-		x:     make([]int, 1000),
-		y:     make([]int, 1000),
-		opts: []cmp.Option{
-			cmp.Comparer(func(_, _ int) bool {
-				return rand.Intn(2) == 0
-			}),
-		},
-		wantPanic: "non-deterministic or non-symmetric function detected",
-	}, {
-		label: label,
-		x:     make([]int, 1000),
-		y:     make([]int, 1000),
-		opts: []cmp.Option{
-			cmp.FilterValues(func(_, _ int) bool {
-				return rand.Intn(2) == 0
-			}, cmp.Ignore()),
-		},
-		wantPanic: "non-deterministic or non-symmetric function detected",
-	}}
+func TestChanHint(t *testing.T) {
+	type Handler struct{ Done chan bool }
+	got := cmp.Diff(Handler{make(chan bool)}, Handler{make(chan bool)})
+	want := "(channels are only equal if they are the same channel; consider using an Ignore or Comparer option for this value)"
+	if !strings.Contains(got, want) {
+		t.Errorf("Diff() = %q, want substring %q", got, want)
+	}
 }
 
-func transformerTests() []test {
-	const label = "Transformer/"
+func TestComparePointersByIdentity(t *testing.T) {
+	type Node struct {
+		Name string
+		Next *Node
+	}
+	type Route struct {
+		From, To *Node
+	}
+	a1, a2 := &Node{Name: "a"}, &Node{Name: "a"}
+	cyclic := &Node{Name: "b"}
+	cyclic.Next = cyclic
+	opt := cmp.ComparePointersByIdentity((*Node)(nil))
 
-	return []test{{
-		label: label,
-		x:     uint8(0),
-		y:     uint8(1),
-		opts: []cmp.Option{
-			cmp.Transformer("", func(in uint8) uint16 { return uint16(in) }),
-			cmp.Transformer("", func(in uint16) uint32 { return uint32(in) }),
-			cmp.Transformer("", func(in uint32) uint64 { return uint64(in) }),
-		},
-		wantDiff: `
-λ(λ(λ({uint8}))):
-	-: 0x00
-	+: 0x01`,
-	}, {
-		label: label,
-		x:     0,
-		y:     1,
-		opts: []cmp.Option{
-			cmp.Transformer("", func(in int) int { return in / 2 }),
-			cmp.Transformer("", func(in int) int { return in }),
-		},
-		wantPanic: "ambiguous set of options",
-	}, {
-		label: label,
-		x:     []int{0, -5, 0, -1},
-		y:     []int{1, 3, 0, -5},
-		opts: []cmp.Option{
-			cmp.FilterValues(
-				func(x, y int) bool { return x+y >= 0 },
-				cmp.Transformer("", func(in int) int64 { return int64(in / 2) }),
-			),
-			cmp.FilterValues(
-				func(x, y int) bool { return x+y < 0 },
-				cmp.Transformer("", func(in int) int64 { return int64(in) }),
-			),
-		},
-		wantDiff: `
-λ({[]int}[1]):
-	-: -5
-	+: 3
-λ({[]int}[3]):
-	-: -1
-	+: -5`,
-	}, {
-		label: label,
-		x:     0,
-		y:     1,
-		opts: []cmp.Option{
-			cmp.Transformer("", func(in int) interface{} {
-				if in == 0 {
-					return "string"
-				}
-				return in
-			}),
-		},
-		wantDiff: `
-λ({int}):
-	-: "string"
-	+: 1`,
+	// The pointer addresses in the report vary between runs,
+	// so unequal nodes are not covered by comparePointersByIdentityTests.
+	if cmp.Equal(Route{a1, nil}, Route{a2, nil}, opt) {
+		t.Errorf("Equal(distinct nodes, ComparePointersByIdentity) = true, want false")
+	}
+	if cmp.Equal(Route{a1, nil}, Route{a1, a1}, opt) {
+		t.Errorf("Equal(nil and non-nil nodes, ComparePointersByIdentity) = true, want false")
+	}
+
+	got := cmp.Diff(Route{a1, cyclic}, Route{a2, cyclic}, opt)
+	for _, want := range []string{
+		"{cmp_test.Route}.From:\n",
+		fmt.Sprintf("(pointers are compared by identity: (*cmp_test.Node)(%#x) and (*cmp_test.Node)(%#x))", reflect.ValueOf(a1).Pointer(), reflect.ValueOf(a2).Pointer()),
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Diff() = %q, want substring %q", got, want)
+		}
+	}
+}
+
+func TestShallow(t *testing.T) {
+	type Buffer struct {
+		Name  string
+		Data  []byte
+		Index map[string]int
+		Next  *Buffer
+	}
+	data := []byte("hello")
+	index := map[string]int{"h": 0}
+	next := &Buffer{Name: "next"}
+	x := &Buffer{"a", data, index, next}
+
+	// The addresses in the report vary between runs, so copied references
+	// are not covered by shallowTests.
+	tests := []struct {
+		label string
+		y     *Buffer
+	}{
+		{"CopiedData", &Buffer{"a", []byte("hello"), index, next}},
+		{"ResizedData", &Buffer{"a", data[:4], index, next}},
+		{"CopiedIndex", &Buffer{"a", data, map[string]int{"h": 0}, next}},
+		{"CopiedNext", &Buffer{"a", data, index, &Buffer{Name: "next"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.label, func(t *testing.T) {
+			if cmp.Equal(x, tt.y, cmp.Shallow()) {
+				t.Errorf("Equal(Shallow) = true, want false")
+			}
+		})
+	}
+
+	got := cmp.Diff(x, &Buffer{"a", []byte("hello"), index, next}, cmp.Shallow())
+	want := fmt.Sprintf("(compared by reference in shallow mode: %#x and ", reflect.ValueOf(data).Pointer())
+	if !strings.Contains(got, "{*cmp_test.Buffer}.Data:\n") || !strings.Contains(got, want) {
+		t.Errorf("Diff(Shallow) = %q, want substring %q", got, want)
+	}
+}
+
+func TestRequireComparisons(t *testing.T) {
+	type Record struct {
+		ID    int
+		Tags  []string
+		notes string
+	}
+	x := Record{1, []string{"a", "b"}, "x"}
+	y := Record{2, []string{"c"}, "y"}
+
+	var got int
+	count := cmp.CountComparisons(func(n int) { got = n })
+	if cmp.Equal(x, x, IgnoreUnexported(Record{}), count); got != 3 {
+		t.Errorf("CountComparisons() = %d, want 3", got)
+	}
+
+	// An over-broad Ignore leaves nothing to be compared.
+	ignoreAll := cmp.Options{
+		IgnoreUnexported(Record{}),
+		cmp.FilterPath(func(p cmp.Path) bool { return len(p) > 1 }, cmp.Ignore()),
+	}
+	if !cmp.Equal(x, y, ignoreAll, count) || got != 0 {
+		t.Errorf("Equal(ignoreAll) = (false, %d), want (true, 0)", got)
+	}
+}
+
+func TestBytes(t *testing.T) {
+	x := bytes.Repeat([]byte("abcd"), 1<<8)
+	y := append([]byte(nil), x...)
+	y[len(y)-1] = 'e'
+
+	tests := []struct {
+		label     string
+		x, y      interface{}
+		opts      []cmp.Option
+		wantEqual bool
+		wantDiff  string
+	}{{
+		label:     "EqualSlices",
+		x:         x,
+		y:         append([]byte(nil), x...),
+		wantEqual: true,
+	}, {
+		label:    "UnequalSlices",
+		x:        x,
+		y:        y,
+		wantDiff: "{[]uint8}[1023]:\n\t-: 0x64\n\t+: 0x65\n",
+	}, {
+		label:    "UnequalLengths",
+		x:        x,
+		y:        x[:len(x)-1],
+		wantDiff: "{[]uint8}[1023]:\n\t-: 0x64\n\t+: <non-existent>\n",
+	}, {
+		label:     "EqualArrays",
+		x:         &[4]byte{1, 2, 3, 4},
+		y:         &[4]byte{1, 2, 3, 4},
+		wantEqual: true,
+	}, {
+		label:    "UnequalArrays",
+		x:        &[4]byte{1, 2, 3, 4},
+		y:        &[4]byte{1, 2, 3, 5},
+		wantDiff: "(*{*[4]uint8})[3]:\n\t-: 0x04\n\t+: 0x05\n",
+	}, {
+		// Options on individual bytes must still be applied.
+		label:     "ByteComparer",
+		x:         []byte("abc"),
+		y:         []byte("ABC"),
+		opts:      []cmp.Option{cmp.Comparer(func(x, y byte) bool { return x|0x20 == y|0x20 })},
+		wantEqual: true,
+	}, {
+		label: "PathFilter",
+		x:     []byte("abc"),
+		y:     []byte("abc"),
+		opts: []cmp.Option{cmp.FilterPath(func(p cmp.Path) bool { return len(p) > 1 },
+			cmp.Comparer(func(x, y interface{}) bool { return false }))},
+		wantDiff: "{[]uint8}[0]:\n\t-: 0x61\n\t+: 0x61\n",
 	}}
+
+	for _, tt := range tests {
+		t.Run(tt.label, func(t *testing.T) {
+			var n int
+			opts := append(tt.opts, cmp.CountComparisons(func(i int) { n = i }))
+			if got := cmp.Equal(tt.x, tt.y, opts...); got != tt.wantEqual {
+				t.Errorf("Equal() = %v, want %v", got, tt.wantEqual)
+			}
+			if want := reflect.ValueOf(tt.x).Len(); tt.wantEqual && n != want {
+				t.Errorf("CountComparisons() = %d, want %d", n, want)
+			}
+			if got := cmp.Diff(tt.x, tt.y, tt.opts...); !strings.HasPrefix(got, tt.wantDiff) {
+				t.Errorf("Diff() = %q, want prefix %q", got, tt.wantDiff)
+			}
+		})
+	}
 }
 
-func embeddedTests() []test {
-	const label = "EmbeddedStruct/"
+func BenchmarkBytes(b *testing.B) {
+	x := bytes.Repeat([]byte{0xa5}, 1<<20)
+	y := append([]byte(nil), x...)
+	z := append([]byte(nil), x...)
+	z[len(z)-1] = 0
 
-	privateStruct := *new(ts.ParentStructA).PrivateStruct()
+	b.Run("Equal", func(b *testing.B) {
+		b.SetBytes(int64(len(x)))
+		for i := 0; i < b.N; i++ {
+			cmp.Equal(x, y)
+		}
+	})
+	b.Run("AlmostEqual", func(b *testing.B) {
+		b.SetBytes(int64(len(x)))
+		for i := 0; i < b.N; i++ {
+			cmp.Equal(x, z)
+		}
+	})
+}
 
-	createStructA := func(i int) ts.ParentStructA {
-		s := ts.ParentStructA{}
-		s.PrivateStruct().Public = 1 + i
-		s.PrivateStruct().SetPrivate(2 + i)
-		return s
+// pixel has an Equal method that must not be bypassed by using ==.
+type pixel struct{ R, G, B uint8 }
+
+func (p pixel) Equal(q pixel) bool { return p.R == q.R && p.G == q.G }
+
+func TestFastEqual(t *testing.T) {
+	type Point struct{ X, Y float64 }
+	nan := math.NaN()
+
+	tests := []struct {
+		label     string
+		x, y      interface{}
+		opts      []cmp.Option
+		wantEqual bool
+		wantCount int
+	}{
+		{"Equal", [2]Point{{1, 2}, {3, 4}}, [2]Point{{1, 2}, {3, 4}}, nil, true, 4},
+		{"Unequal", [2]Point{{1, 2}, {3, 4}}, [2]Point{{1, 2}, {3, 5}}, nil, false, 4},
+		{"NaN", Point{nan, 0}, Point{nan, 0}, nil, false, 2},
+		{"NegativeZero", Point{math.Copysign(0, -1), 0}, Point{0, 0}, nil, true, 2},
+		{"EqualMethod", [2]pixel{{1, 2, 3}}, [2]pixel{{1, 2, 4}}, nil, true, 2},
+		{"Comparer", [2]Point{{1, 2}}, [2]Point{{1, 3}}, []cmp.Option{
+			cmp.Comparer(func(x, y float64) bool { return true }),
+		}, true, 4},
+		{"EquateNaNs", Point{nan, 0}, Point{nan, 0}, []cmp.Option{
+			cmp.FilterValues(func(x, y float64) bool { return x != x && y != y }, cmp.Comparer(func(x, y float64) bool { return true })),
+		}, true, 2},
 	}
 
-	createStructB := func(i int) ts.ParentStructB {
-		s := ts.ParentStructB{}
-		s.PublicStruct.Public = 1 + i
-		s.PublicStruct.SetPrivate(2 + i)
-		return s
+	for _, tt := range tests {
+		t.Run(tt.label, func(t *testing.T) {
+			var n int
+			opts := append(tt.opts, cmp.CountComparisons(func(i int) { n = i }))
+			if got := cmp.Equal(tt.x, tt.y, opts...); got != tt.wantEqual {
+				t.Errorf("Equal() = %v, want %v", got, tt.wantEqual)
+			}
+			if n != tt.wantCount {
+				t.Errorf("CountComparisons() = %d, want %d", n, tt.wantCount)
+			}
+			if got := cmp.Diff(tt.x, tt.y, tt.opts...); (got == "") != tt.wantEqual {
+				t.Errorf("Diff() = %q, want empty: %v", got, tt.wantEqual)
+			}
+		})
 	}
+}
 
-	createStructC := func(i int) ts.ParentStructC {
-		s := ts.ParentStructC{}
-		s.PrivateStruct().Public = 1 + i
-		s.PrivateStruct().SetPrivate(2 + i)
-		s.Public = 3 + i
-		s.SetPrivate(4 + i)
-		return s
+func BenchmarkFastEqual(b *testing.B) {
+	type Point struct {
+		X, Y, Z int32
+		Valid   bool
 	}
+	x := make([]Point, 1<<16)
+	for i := range x {
+		x[i] = Point{int32(i), int32(-i), int32(i * i), i%2 == 0}
+	}
+	y := append([]Point(nil), x...)
+	var ax, ay [1 << 12]Point
+	copy(ax[:], x)
+	copy(ay[:], x)
 
-	createStructD := func(i int) ts.ParentStructD {
-		s := ts.ParentStructD{}
-		s.PublicStruct.Public = 1 + i
-		s.PublicStruct.SetPrivate(2 + i)
-		s.Public = 3 + i
-		s.SetPrivate(4 + i)
-		return s
+	b.Run("Slice", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			cmp.Equal(x, y)
+		}
+	})
+	b.Run("Array", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			cmp.Equal(&ax, &ay)
+		}
+	})
+}
+
+func BenchmarkOptions(b *testing.B) {
+	x := make([]*pb.Germ, 10000)
+	for i := range x {
+		x[i] = &pb.Germ{Stringer: pb.Stringer{X: fmt.Sprint(i)}}
+	}
+	y := append([]*pb.Germ(nil), x...)
+	opts := []cmp.Option{
+		cmp.Comparer(func(x, y *pb.Germ) bool { return (x == nil) == (y == nil) && (x == nil || x.X == y.X) }),
+		cmp.Comparer(func(x, y *pb.Dish) bool { return (x == nil) == (y == nil) && (x == nil || x.X == y.X) }),
+		cmp.Comparer(func(x, y float64) bool { return x == y }),
+		cmp.FilterValues(func(x, y []int) bool { return len(x) > 0 },
+			cmp.Transformer("Sort", func(in []int) []int { return in })),
+		cmp.FilterValues(func(x, y string) bool { return x == "" }, cmp.Ignore()),
 	}
 
-	createStructE := func(i int) ts.ParentStructE {
-		s := ts.ParentStructE{}
-		s.PrivateStruct().Public = 1 + i
-		s.PrivateStruct().SetPrivate(2 + i)
-		s.PublicStruct.Public = 3 + i
-		s.PublicStruct.SetPrivate(4 + i)
-		return s
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cmp.Equal(x, y, opts...)
 	}
+}
 
-	createStructF := func(i int) ts.ParentStructF {
-		s := ts.ParentStructF{}
-		s.PrivateStruct().Public = 1 + i
-		s.PrivateStruct().SetPrivate(2 + i)
-		s.PublicStruct.Public = 3 + i
-		s.PublicStruct.SetPrivate(4 + i)
-		s.Public = 5 + i
-		s.SetPrivate(6 + i)
-		return s
+func BenchmarkOptionOverhead(b *testing.B) {
+	type Point struct{ X, Y int }
+	opts := []cmp.Option{
+		cmp.Comparer(func(x, y *pb.Germ) bool { return (x == nil) == (y == nil) && (x == nil || x.X == y.X) }),
+		cmp.Comparer(func(x, y *pb.Dish) bool { return (x == nil) == (y == nil) && (x == nil || x.X == y.X) }),
+		cmp.Comparer(func(x, y *Point) bool { return (x == nil) == (y == nil) && (x == nil || *x == *y) }),
+		cmp.Comparer(func(x, y float64) bool { return x == y }),
+		cmp.Comparer(func(x, y Point) bool { return x == y }),
+		cmp.Transformer("Sort", func(in []int) []int { return in }),
+		cmp.Transformer("Lower", strings.ToLower),
+		cmp.FilterValues(func(x, y []byte) bool { return len(x) > 0 }, cmp.Ignore()),
+		cmp.FilterValues(func(x, y string) bool { return x == "" }, cmp.Ignore()),
+		cmp.FilterPath(func(p cmp.Path) bool { return len(p) > 3 }, cmp.Ignore()),
 	}
 
-	createStructG := func(i int) *ts.ParentStructG {
-		s := ts.NewParentStructG()
-		s.PrivateStruct().Public = 1 + i
-		s.PrivateStruct().SetPrivate(2 + i)
-		return s
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		cmp.Equal(1, 1, opts...)
 	}
+}
 
-	createStructH := func(i int) *ts.ParentStructH {
-		s := ts.NewParentStructH()
-		s.PublicStruct.Public = 1 + i
-		s.PublicStruct.SetPrivate(2 + i)
-		return s
+// flatStruct is a small struct of primitive fields, which is commonly
+// compared in hot paths.
+type flatStruct struct {
+	ID, Count, Size   int
+	Name, Kind, Owner string
+	Active, Visible   bool
+	Score, Weight     float64
+	Flags             uint32
+	Version           int64
+}
+
+func newFlatStruct() flatStruct {
+	return flatStruct{1, 2, 3, "name", "kind", "owner", true, false, 1.5, 2.5, 7, 9}
+}
+
+// equalFlatStruct is the handwritten equivalent of cmp.Equal on flatStruct.
+func equalFlatStruct(x, y *flatStruct) bool {
+	return x.ID == y.ID && x.Count == y.Count && x.Size == y.Size &&
+		x.Name == y.Name && x.Kind == y.Kind && x.Owner == y.Owner &&
+		x.Active == y.Active && x.Visible == y.Visible &&
+		x.Score == y.Score && x.Weight == y.Weight &&
+		x.Flags == y.Flags && x.Version == y.Version
+}
+
+func BenchmarkEqualFlatStruct(b *testing.B) {
+	x, y := newFlatStruct(), newFlatStruct()
+	b.Run("Handwritten", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if !equalFlatStruct(&x, &y) {
+				b.Fatal("not equal")
+			}
+		}
+	})
+	b.Run("Equal", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if !cmp.Equal(&x, &y) {
+				b.Fatal("not equal")
+			}
+		}
+	})
+}
+
+// deepNode is a deeply nested structure that cannot be compared using ==.
+type deepNode struct {
+	Name     string
+	Attrs    map[string]string
+	Weights  []float64
+	Parent   *deepNode
+	Children []deepNode
+}
+
+func newDeepNode(depth int) deepNode {
+	n := deepNode{Name: fmt.Sprint(depth), Attrs: map[string]string{"depth": fmt.Sprint(depth)}, Weights: []float64{1, 2, 3}}
+	for i := 0; depth > 0 && i < 3; i++ {
+		c := newDeepNode(depth - 1)
+		c.Parent = &deepNode{Name: n.Name}
+		n.Children = append(n.Children, c)
 	}
+	return n
+}
 
-	createStructI := func(i int) *ts.ParentStructI {
-		s := ts.NewParentStructI()
-		s.PrivateStruct().Public = 1 + i
-		s.PrivateStruct().SetPrivate(2 + i)
-		s.PublicStruct.Public = 3 + i
-		s.PublicStruct.SetPrivate(4 + i)
-		return s
+func BenchmarkEqualDeep(b *testing.B) {
+	x, y := newDeepNode(6), newDeepNode(6)
+	for _, tt := range []struct {
+		label string
+		opts  []cmp.Option
+	}{
+		{"NoPathOptions", nil},
+		{"FilterPath", []cmp.Option{cmp.FilterPath(func(p cmp.Path) bool { return false }, cmp.Ignore())}},
+	} {
+		b.Run(tt.label, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if !cmp.Equal(x, y, tt.opts...) {
+					b.Fatal("not equal")
+				}
+			}
+		})
 	}
+}
 
-	createStructJ := func(i int) *ts.ParentStructJ {
-		s := ts.NewParentStructJ()
-		s.PrivateStruct().Public = 1 + i
-		s.PrivateStruct().SetPrivate(2 + i)
-		s.PublicStruct.Public = 3 + i
-		s.PublicStruct.SetPrivate(4 + i)
-		s.Private().Public = 5 + i
-		s.Private().SetPrivate(6 + i)
-		s.Public.Public = 7 + i
-		s.Public.SetPrivate(8 + i)
-		return s
+func BenchmarkProject2(b *testing.B) {
+	sortGerms := cmp.FilterValues(func(x, y []*pb.Germ) bool {
+		ok1 := sort.SliceIsSorted(x, func(i, j int) bool { return x[i].String() < x[j].String() })
+		ok2 := sort.SliceIsSorted(y, func(i, j int) bool { return y[i].String() < y[j].String() })
+		return !ok1 || !ok2
+	}, cmp.Transformer("Sort", func(in []*pb.Germ) []*pb.Germ {
+		out := append([]*pb.Germ(nil), in...) // Make copy
+		sort.Slice(out, func(i, j int) bool { return out[i].String() < out[j].String() })
+		return out
+	}))
+	opts := []cmp.Option{cmp.Comparer(pb.Equal), sortGerms}
+
+	createBatch := func(reverse bool) ts.GermBatch {
+		gb := ts.GermBatch{
+			DirtyGerms: make(map[int32][]*pb.Germ),
+			CleanGerms: make(map[int32][]*pb.Germ),
+			GermMap:    make(map[int32]*pb.Germ),
+		}
+		for i := int32(0); i < 1000; i++ {
+			var gs []*pb.Germ
+			for j := 0; j < 10; j++ {
+				gs = append(gs, &pb.Germ{Stringer: pb.Stringer{X: fmt.Sprintf("germ%d-%d", i, j)}})
+			}
+			gb.GermMap[i] = gs[0]
+			gb.CleanGerms[i] = append([]*pb.Germ(nil), gs[:5]...)
+			for _, gs := range [][]*pb.Germ{gs, gb.CleanGerms[i]} {
+				for l, r := 0, len(gs)-1; reverse && l < r; l, r = l+1, r-1 {
+					gs[l], gs[r] = gs[r], gs[l]
+				}
+			}
+			gb.DirtyGerms[i] = gs
+		}
+		return gb
 	}
+	x, y := createBatch(false), createBatch(true)
 
-	return []test{{
-		label:     label + "ParentStructA",
-		x:         ts.ParentStructA{},
-		y:         ts.ParentStructA{},
-		wantPanic: "cannot handle unexported field",
-	}, {
-		label: label + "ParentStructA",
-		x:     ts.ParentStructA{},
-		y:     ts.ParentStructA{},
-		opts: []cmp.Option{
-			IgnoreUnexported(ts.ParentStructA{}),
-		},
-	}, {
-		label: label + "ParentStructA",
-		x:     createStructA(0),
-		y:     createStructA(0),
-		opts: []cmp.Option{
-			cmp.AllowUnexported(ts.ParentStructA{}),
-		},
-		wantPanic: "cannot handle unexported field",
-	}, {
-		label: label + "ParentStructA",
-		x:     createStructA(0),
-		y:     createStructA(0),
-		opts: []cmp.Option{
-			cmp.AllowUnexported(ts.ParentStructA{}, privateStruct),
-		},
-	}, {
-		label: label + "ParentStructA",
-		x:     createStructA(0),
-		y:     createStructA(1),
-		opts: []cmp.Option{
-			cmp.AllowUnexported(ts.ParentStructA{}, privateStruct),
-		},
-		wantDiff: `
-{teststructs.ParentStructA}.privateStruct.Public:
-	-: 1
-	+: 2
-{teststructs.ParentStructA}.privateStruct.private:
-	-: 2
-	+: 3`,
-	}, {
-		label: label + "ParentStructB",
-		x:     ts.ParentStructB{},
-		y:     ts.ParentStructB{},
-		opts: []cmp.Option{
-			IgnoreUnexported(ts.ParentStructB{}),
-		},
-		wantPanic: "cannot handle unexported field",
-	}, {
-		label: label + "ParentStructB",
-		x:     ts.ParentStructB{},
-		y:     ts.ParentStructB{},
-		opts: []cmp.Option{
-			IgnoreUnexported(ts.ParentStructB{}),
-			IgnoreUnexported(ts.PublicStruct{}),
-		},
-	}, {
-		label: label + "ParentStructB",
-		x:     createStructB(0),
-		y:     createStructB(0),
-		opts: []cmp.Option{
-			cmp.AllowUnexported(ts.ParentStructB{}),
-		},
-		wantPanic: "cannot handle unexported field",
-	}, {
-		label: label + "ParentStructB",
-		x:     createStructB(0),
-		y:     createStructB(0),
-		opts: []cmp.Option{
-			cmp.AllowUnexported(ts.ParentStructB{}, ts.PublicStruct{}),
-		},
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if !cmp.Equal(x, y, opts...) {
+			b.Fatal("not equal")
+		}
+	}
+}
+
+func BenchmarkAllocs(b *testing.B) {
+	type Node struct {
+		Name     string
+		Attrs    map[string]interface{}
+		Children []*Node
+	}
+	var build func(depth int) *Node
+	build = func(depth int) *Node {
+		n := &Node{Name: fmt.Sprint(depth), Attrs: map[string]interface{}{"depth": depth, "leaf": depth == 0}}
+		for i := 0; depth > 0 && i < 4; i++ {
+			n.Children = append(n.Children, build(depth-1))
+		}
+		return n
+	}
+	x, y := build(6), build(6)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		cmp.Equal(x, y)
+	}
+}
+
+// Test that a comparison that panics does not affect later comparisons,
+// which may reuse internal state.
+func TestReuseAfterPanic(t *testing.T) {
+	type Inner struct{ secret int }
+	type Outer struct {
+		Name  string
+		Inner []Inner
+	}
+	x := Outer{"x", []Inner{{1}}}
+	y := Outer{"y", []Inner{{2}}}
+	for i := 0; i < 3; i++ {
+		func() {
+			defer func() {
+				want := "cannot handle unexported field"
+				if got := fmt.Sprint(recover()); !strings.Contains(got, want) {
+					t.Errorf("Diff() panic = %q, want %q", got, want)
+				}
+			}()
+			cmp.Diff(x, y, cmp.Comparer(func(x, y string) bool { return true }))
+		}()
+		want := "{cmp_test.Outer}.Name:\n\t-: \"x\"\n\t+: \"y\"\n"
+		if got := cmp.Diff(x, y, cmp.AllowUnexported(Inner{}), cmp.Comparer(func(x, y Inner) bool { return true })); got != want {
+			t.Errorf("Diff() mismatch:\ngot:\n%s\nwant:\n%s", got, want)
+		}
+	}
+}
+
+func BenchmarkRepeated(b *testing.B) {
+	type Address struct {
+		Street, City string
+		Zip          int
+	}
+	type Person struct {
+		Name      string
+		Age       int
+		Emails    []string
+		Addresses []Address
+		Labels    map[string]string
+	}
+	newPerson := func() Person {
+		return Person{
+			Name:      "Gopher",
+			Age:       10,
+			Emails:    []string{"gopher@example.com", "gopher@example.org"},
+			Addresses: []Address{{"1 Main St", "Springfield", 12345}, {"2 Side St", "Shelbyville", 54321}},
+			Labels:    map[string]string{"team": "go", "role": "mascot"},
+		}
+	}
+	x, y := newPerson(), newPerson()
+	opts := []cmp.Option{cmp.Comparer(func(x, y Address) bool { return x == y })}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		cmp.Equal(&x, &y, opts...)
+	}
+}
+
+func BenchmarkEquateApprox(b *testing.B) {
+	x := make([]float64, 1000000)
+	y := make([]float64, len(x))
+	for i := range x {
+		x[i] = float64(i)
+		y[i] = float64(i) * 1.0001
+	}
+	opt := cmp.EquateApprox(0.001, 0)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		cmp.Equal(x, y, opt)
+	}
+}
+
+func BenchmarkEquateMultisets(b *testing.B) {
+	// Reversing the elements maximizes the number of pairwise comparisons.
+	type T struct {
+		Name string
+		Tags []string
+	}
+	x := make([]T, 1000)
+	y := make([]T, len(x))
+	for i := range x {
+		x[i] = T{Name: strconv.Itoa(i), Tags: []string{"a", "b"}}
+		y[len(y)-1-i] = x[i]
+	}
+	opt := cmp.EquateMultisets()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		cmp.Equal(x, y, opt)
+	}
+}
+
+func BenchmarkEqualMethod(b *testing.B) {
+	x := make([]ts.StructA, 100000)
+	y := make([]ts.StructA, len(x))
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		cmp.Equal(x, y)
+	}
+}
+
+func BenchmarkFunctionChecks(b *testing.B) {
+	x := make([]int, 1000000)
+	y := make([]int, len(x))
+	var calls int
+	opt := cmp.Comparer(func(x, y int) bool { calls++; return x == y })
+	for _, tt := range []struct {
+		label string
+		opts  []cmp.Option
+	}{
+		{"Default", []cmp.Option{opt}},
+		{"Samples1", []cmp.Option{opt, cmp.SampleFunctionChecks(1)}},
+		{"Samples1024", []cmp.Option{opt, cmp.SampleFunctionChecks(1024)}},
+		{"Disabled", []cmp.Option{opt, cmp.DisableFunctionChecks()}},
+	} {
+		b.Run(tt.label, func(b *testing.B) {
+			calls = 0
+			for i := 0; i < b.N; i++ {
+				cmp.Equal(x, y, tt.opts...)
+			}
+			b.ReportMetric(float64(calls)/float64(b.N), "calls/op")
+		})
+	}
+}
+
+func BenchmarkDiff(b *testing.B) {
+	x := make([]string, 10000)
+	y := make([]string, 10000)
+	for i := range x {
+		x[i] = fmt.Sprintf("x%d", i)
+		y[i] = fmt.Sprintf("y%d", i)
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		cmp.Diff(x, y)
+	}
+}
+
+func BenchmarkEqualLarge(b *testing.B) {
+	type Record struct {
+		ID    int
+		Name  string
+		Attrs map[interface{}]interface{}
+		Next  func()
+	}
+	build := func() []Record {
+		rs := make([]Record, 1<<14)
+		for i := range rs {
+			rs[i] = Record{i, strings.Repeat("x", 64), map[interface{}]interface{}{i: int64(i), "s": fmt.Sprint(i)}, nil}
+		}
+		return rs
+	}
+	x, y, z := build(), build(), build()
+	for i := range z {
+		delete(z[i].Attrs, i)
+		z[i].Attrs[int64(i)] = int64(i) // Formats the same as int(i)
+		z[i].Next = func() {}
+	}
+
+	b.Run("Equal", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			cmp.Equal(x, y)
+		}
+	})
+	b.Run("Unequal", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			cmp.Equal(x, z)
+		}
+	})
+}
+
+func TestShortCircuit(t *testing.T) {
+	type Record struct {
+		ID     int
+		Values []int
+		Parts  []string
+	}
+	x := Record{1, []int{1, 1, 3}, []string{"a", "b"}}
+	y := Record{2, []int{1, 1, 2}, []string{"a", "c"}}
+
+	var calls int
+	countCalls := cmp.Comparer(func(x, y int) bool { calls++; return x == y })
+	var got int
+	count := cmp.CountComparisons(func(n int) { got = n })
+
+	if cmp.Equal(x, y, cmp.ShortCircuit(), countCalls, count) {
+		t.Errorf("Equal(ShortCircuit) = true, want false")
+	}
+	if calls != 2 || got != 1 {
+		t.Errorf("Equal(ShortCircuit) made (%d calls, %d comparisons), want (2, 1)", calls, got)
+	}
+
+	// The traversal unwinds through Transformers.
+	calls = 0
+	split := cmp.Transformer("Split", func(s []string) string { return strings.Join(s, ",") })
+	if cmp.Equal(x, y, cmp.ShortCircuit(), countCalls, split, count) || calls != 2 || got != 1 {
+		t.Errorf("Equal(ShortCircuit, Split) = (%d calls, %d comparisons), want (2, 1)", calls, got)
+	}
+	y.ID = 1
+	if cmp.Equal(x, y, cmp.ShortCircuit(), split, count) || got != 4 {
+		t.Errorf("Equal(ShortCircuit, Split) = (true, %d), want (false, 4)", got)
+	}
+
+	// Diff always reports every difference.
+	if got, want := cmp.Diff(x, y, cmp.ShortCircuit()), cmp.Diff(x, y); got != want {
+		t.Errorf("Diff(ShortCircuit) mismatch:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+
+	// Finding a difference means the comparison is not vacuous.
+	ignoreParts := cmp.FilterPath(func(p cmp.Path) bool { return p.String() == "Parts" }, cmp.Ignore())
+	if cmp.Equal(x, y, cmp.ShortCircuit(), ignoreParts, cmp.RequireComparisons(6)) {
+		t.Errorf("Equal(ShortCircuit, RequireComparisons) = true, want false")
+	}
+
+	// The comparison that stops the traversal is always verified,
+	// even if the schedule would not check it (i.e., the fourth call).
+	xs, ys := []int{1, 1, 1, 3}, []int{1, 1, 1, 2}
+	lessEqual := cmp.Comparer(func(x, y int) bool { return x <= y })
+	if cmp.Equal(xs, ys, lessEqual, cmp.SampleFunctionChecks(1)) {
+		t.Errorf("Equal(lessEqual) = true, want false")
+	}
+	func() {
+		defer func() {
+			want := "non-deterministic or non-symmetric function detected"
+			if got := fmt.Sprint(recover()); !strings.Contains(got, want) {
+				t.Errorf("Equal(ShortCircuit, lessEqual) panic = %q, want %q", got, want)
+			}
+		}()
+		cmp.Equal(xs, ys, cmp.ShortCircuit(), lessEqual, cmp.SampleFunctionChecks(1))
+	}()
+}
+
+func BenchmarkShortCircuit(b *testing.B) {
+	type Record struct {
+		ID      int
+		Payload []string
+	}
+	for _, n := range []int{1 << 10, 1 << 16} {
+		x := Record{1, make([]string, n)}
+		y := Record{2, make([]string, n)}
+		for i := range x.Payload {
+			x.Payload[i] = fmt.Sprint(i)
+			y.Payload[i] = fmt.Sprint(i)
+		}
+		b.Run(fmt.Sprintf("Full/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				cmp.Equal(x, y)
+			}
+		})
+		b.Run(fmt.Sprintf("ShortCircuit/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				cmp.Equal(x, y, cmp.ShortCircuit())
+			}
+		})
+	}
+}
+
+func TestParallel(t *testing.T) {
+	type Entry struct {
+		Name string
+		Next func()
+	}
+	x := make(map[int]Entry)
+	y := make(map[int]Entry)
+	var xs, ys []Entry
+	for i := 0; i < 5000; i++ {
+		e := Entry{Name: fmt.Sprint(i)}
+		x[i], y[i] = e, e
+		if i%7 == 0 {
+			e.Name += "!"
+		}
+		if i%11 == 0 {
+			e.Next = func() {}
+		}
+		if i%13 != 0 {
+			y[i+1] = e
+		}
+		xs, ys = append(xs, x[i]), append(ys, e)
+	}
+
+	for _, tt := range []struct {
+		label string
+		x, y  interface{}
+	}{
+		{"Map", x, y},
+		{"Slice", xs, ys},
+		{"SliceAlmostEqual", xs, append(append([]Entry(nil), xs[:4999]...), Entry{})},
+		{"Equal", xs, xs},
+	} {
+		t.Run(tt.label, func(t *testing.T) {
+			var want, got int
+			wantDiff := cmp.Diff(tt.x, tt.y, cmp.CountComparisons(func(n int) { want = n }))
+			gotDiff := cmp.Diff(tt.x, tt.y, cmp.Parallel(4), cmp.CountComparisons(func(n int) { got = n }))
+			if gotDiff != wantDiff || got != want {
+				t.Errorf("Diff(Parallel) mismatch (%d comparisons, want %d):\ngot:\n%s\nwant:\n%s", got, want, gotDiff, wantDiff)
+			}
+			if got, want := cmp.Equal(tt.x, tt.y, cmp.Parallel(4), cmp.ShortCircuit()), wantDiff == ""; got != want {
+				t.Errorf("Equal(Parallel, ShortCircuit) = %v, want %v", got, want)
+			}
+		})
+	}
+
+	// A panic in a worker is propagated to the caller.
+	func() {
+		defer func() {
+			want := "panicked at {[]cmp_test.Entry}[4321].Name"
+			if got := fmt.Sprint(recover()); !strings.Contains(got, want) {
+				t.Errorf("Equal(Parallel) panic = %q, want %q", got, want)
+			}
+		}()
+		cmp.Equal(xs, xs, cmp.Parallel(4), cmp.Comparer(func(x, y string) bool {
+			if x == "4321" {
+				panic("boom")
+			}
+			return x == y
+		}))
+	}()
+}
+
+func BenchmarkParallel(b *testing.B) {
+	x := make(map[string][]int)
+	y := make(map[string][]int)
+	for i := 0; i < 500000; i++ {
+		k := fmt.Sprint(i)
+		x[k], y[k] = []int{i, i + 1}, []int{i, i + 1}
+	}
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("Workers%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				cmp.Equal(x, y, cmp.Parallel(workers))
+			}
+		})
+	}
+}
+
+func TestPrimitiveChunks(t *testing.T) {
+	type MyInt int
+	type Unexported struct{ vals []float32 }
+	ints := func(n int, diffs ...int) []int {
+		s := make([]int, n)
+		for _, i := range diffs {
+			s[i] = i + 1
+		}
+		return s
+	}
+	nan := math.NaN()
+	tests := []struct {
+		label string
+		x, y  interface{}
+		opts  []cmp.Option
+	}{
+		{"Equal", ints(1000), ints(1000), nil},
+		{"ChunkBoundaries", ints(1000), ints(1000, 0, 63, 64, 127, 999), nil},
+		{"Lengths", ints(1000), ints(1064, 999), nil},
+		{"Floats", []float64{0, 1, nan, 3}, []float64{math.Copysign(0, -1), 1, nan, 3}, nil},
+		{"Strings", []string{"a", "b", "c"}, []string{"a", "B", "c"}, nil},
+		{"Named", []MyInt{1, 2, 3}, []MyInt{1, 2, 4}, nil},
+		{"Complex", []complex64{1, 2i}, []complex64{1, 3i}, nil},
+		{"Array", [3]uint16{1, 2, 3}, [3]uint16{1, 2, 4}, nil},
+		{"Unexported", Unexported{[]float32{1, 2}}, Unexported{[]float32{1, 3}}, []cmp.Option{cmp.AllowUnexported(Unexported{})}},
+		{"EquateApprox", []float64{1, 2}, []float64{1, 2.0001}, []cmp.Option{cmp.EquateApprox(0.001, 0)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.label, func(t *testing.T) {
+			// An option that applies to every value, but never matches,
+			// forces each element to be compared individually.
+			never := cmp.FilterPath(func(cmp.Path) bool { return false }, cmp.Ignore())
+			var got, want int
+			gotDiff := cmp.Diff(tt.x, tt.y, append(tt.opts, cmp.CountComparisons(func(n int) { got = n }))...)
+			wantDiff := cmp.Diff(tt.x, tt.y, append(tt.opts, never, cmp.CountComparisons(func(n int) { want = n }))...)
+			if gotDiff != wantDiff || got != want {
+				t.Errorf("Diff() mismatch (%d comparisons, want %d):\ngot:\n%s\nwant:\n%s", got, want, gotDiff, wantDiff)
+			}
+			if gotEqual, wantEqual := cmp.Equal(tt.x, tt.y, tt.opts...), cmp.Equal(tt.x, tt.y, append(tt.opts, never)...); gotEqual != wantEqual {
+				t.Errorf("Equal() = %v, want %v", gotEqual, wantEqual)
+			}
+		})
+	}
+}
+
+func BenchmarkPrimitiveChunks(b *testing.B) {
+	const n = 10000000
+	for _, tt := range []struct {
+		label string
+		make  func() (x, y interface{})
+	}{{
+		"Ints", func() (interface{}, interface{}) {
+			x, y := make([]int, n), make([]int, n)
+			for i := n - 10; i < n; i++ {
+				y[i] = i
+			}
+			return x, y
+		},
 	}, {
-		label: label + "ParentStructB",
-		x:     createStructB(0),
-		y:     createStructB(1),
+		"Floats", func() (interface{}, interface{}) {
+			x, y := make([]float64, n), make([]float64, n)
+			for i := n - 10; i < n; i++ {
+				y[i] = float64(i)
+			}
+			return x, y
+		},
+	}} {
+		b.Run(tt.label, func(b *testing.B) {
+			x, y := tt.make()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				cmp.Equal(x, y)
+			}
+		})
+	}
+}
+
+func TestMaxReportSize(t *testing.T) {
+	type Record struct {
+		ID   int
+		Data string
+		Tags map[string][]byte
+	}
+	huge := strings.Repeat("\x00gopher", 1<<20)
+	var xs, ys []Record
+	for i := 0; i < 100000; i++ {
+		xs = append(xs, Record{ID: i, Data: huge[:i%1000], Tags: map[string][]byte{"x": []byte(huge[:100])}})
+		ys = append(ys, Record{ID: -i, Data: huge[i%1000:], Tags: map[string][]byte{"y": nil}})
+	}
+
+	for _, tt := range []struct {
+		label     string
+		x, y      interface{}
+		truncated bool // Whether the report is truncated, rather than values
+	}{
+		{"HugeString", huge, huge[1:], false},
+		{"HugeStruct", Record{Data: huge}, Record{Data: huge[1:]}, false},
+		{"ManyRecords", xs, ys, true},
+		{"ManyMaps", map[string][]Record{"x": xs, "y": ys}, map[string][]Record{"x": ys, "y": xs}, true},
+	} {
+		t.Run(tt.label, func(t *testing.T) {
+			for _, n := range []int{256, 1000, 1 << 16} {
+				var numCmp int
+				got := cmp.Diff(tt.x, tt.y, cmp.MaxReportSize(n), cmp.CountComparisons(func(n int) { numCmp = n }))
+				if len(got) > n {
+					t.Errorf("Diff(MaxReportSize(%d)) is %d bytes:\n%s", n, len(got), got)
+				}
+				if strings.Contains(got, "... report truncated at") != tt.truncated {
+					t.Errorf("Diff(MaxReportSize(%d)) truncation notice mismatch (want %v):\n%s", n, tt.truncated, got)
+				}
+				if tt.truncated && numCmp > 1000 {
+					t.Errorf("Diff(MaxReportSize(%d)) compared %d values, want comparison to stop", n, numCmp)
+				}
+				if par := cmp.Diff(tt.x, tt.y, cmp.MaxReportSize(n), cmp.Parallel(4)); par != got {
+					t.Errorf("Diff(MaxReportSize(%d), Parallel) mismatch:\ngot:\n%s\nwant:\n%s", n, par, got)
+				}
+			}
+
+			// Without a limit, each formatted value is still bounded.
+			if got := cmp.Diff(tt.x, tt.y); len(got) > 1<<16 {
+				t.Errorf("Diff is %d bytes, want at most %d", len(got), 1<<16)
+			}
+		})
+	}
+
+	// Small reports are unaffected by the limit.
+	x, y := []int{1, 2, 3}, []int{1, 2, 4}
+	if got, want := cmp.Diff(x, y, cmp.MaxReportSize(256)), cmp.Diff(x, y); got != want {
+		t.Errorf("Diff(MaxReportSize) mismatch:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// Test that a Comparer reused across comparisons is only probed once,
+// while misuse of an option is still reported by every comparison.
+func TestNilProbeOnce(t *testing.T) {
+	var probes int
+	ok := cmp.Comparer(func(x, y *int) bool {
+		if x == nil || y == nil {
+			probes++
+			return x == y
+		}
+		return *x == *y
+	})
+	for i := 0; i < 3; i++ {
+		if !cmp.Equal(intPtr(1), intPtr(1), ok) {
+			t.Errorf("Equal() = false, want true")
+		}
+	}
+	if probes != 1 {
+		t.Errorf("comparer probed %d times, want 1", probes)
+	}
+
+	for _, tt := range []struct {
+		opt       cmp.Option
+		wantPanic string
+	}{
+		{cmp.Comparer(func(x, y *int) bool { return *x == *y }), "panicked when called with nil pointers"},
+		{cmp.Ignore(), "cannot use an unfiltered option"},
+	} {
+		for i := 0; i < 3; i++ {
+			func() {
+				defer func() {
+					if got := fmt.Sprint(recover()); !strings.Contains(got, tt.wantPanic) {
+						t.Errorf("Equal() panic = %q, want %q", got, tt.wantPanic)
+					}
+				}()
+				cmp.Equal(intPtr(1), intPtr(1), tt.opt)
+			}()
+		}
+	}
+}
+
+// Test that comparing flat structs of primitives by a compiled plan agrees
+// with comparing them field by field.
+func TestFlatStructPlan(t *testing.T) {
+	type Inner struct {
+		A [3]int8
+		B float32
+		C complex128
+	}
+	type Outer struct {
+		Name  string
+		Inner [2]Inner
+		Ok    bool
+		Value float64
+	}
+	type ValueEqual struct{ A, B int }
+	type PtrEqual struct{ A, B int }
+
+	nan := math.NaN()
+	for _, tt := range []struct {
+		label string
+		x, y  interface{}
+		want  bool
+	}{
+		{"Equal", newFlatStruct(), newFlatStruct(), true},
+		{"String", flatStruct{Name: "a"}, flatStruct{Name: "b"}, false},
+		{"Bool", &flatStruct{Active: true}, &flatStruct{}, false},
+		{"NaN", flatStruct{Score: nan}, flatStruct{Score: nan}, false},
+		{"NegativeZero", flatStruct{Score: math.Copysign(0, -1)}, flatStruct{}, true},
+		{"Nested", Outer{Inner: [2]Inner{{A: [3]int8{1}}}}, Outer{Inner: [2]Inner{{A: [3]int8{1}}}}, true},
+		{"NestedInt8", Outer{Inner: [2]Inner{{A: [3]int8{1}}}}, Outer{Inner: [2]Inner{{A: [3]int8{2}}}}, false},
+		{"NestedNaN", &Outer{Inner: [2]Inner{1: {B: float32(nan)}}}, &Outer{Inner: [2]Inner{1: {B: float32(nan)}}}, false},
+		{"NestedComplex", [1]Outer{{Inner: [2]Inner{{C: 1i}}}}, [1]Outer{{Inner: [2]Inner{{C: 2i}}}}, false},
+		{"Slice", []flatStruct{{ID: 1}, {ID: 2}}, []flatStruct{{ID: 1}, {ID: 3}}, false},
+		{"ValueEqual", valueEqual{1, 2}, valueEqual{1, 3}, true},
+		{"PtrEqual", &ptrEqual{1, 2}, &ptrEqual{1, 3}, true},
+		{"PtrEqualInInterface", []interface{}{ptrEqual{1, 2}}, []interface{}{ptrEqual{1, 3}}, true},
+		{"NoEqual", ValueEqual{1, 2}, ValueEqual{1, 3}, false},
+		{"NoEqualPtr", &PtrEqual{1, 2}, &PtrEqual{1, 3}, false},
+	} {
+		t.Run(tt.label, func(t *testing.T) {
+			// Compare repeatedly, since the plan is only used once built.
+			for i := 0; i < 3; i++ {
+				if got := cmp.Equal(tt.x, tt.y); got != tt.want {
+					t.Errorf("Equal() = %v, want %v", got, tt.want)
+				}
+				if got := cmp.Diff(tt.x, tt.y) == ""; got != tt.want {
+					t.Errorf("Diff() == \"\" is %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+// valueEqual and ptrEqual only compare their A fields.
+type valueEqual struct{ A, B int }
+type ptrEqual struct{ A, B int }
+
+func (x valueEqual) Equal(y valueEqual) bool { return x.A == y.A }
+func (x *ptrEqual) Equal(y *ptrEqual) bool   { return x.A == y.A }
+
+// Test that struct fields recorded lazily in the path when no path
+// dependent option is used are indistinguishable from the usual path.
+func TestLazyPath(t *testing.T) {
+	type Inner struct {
+		P *int
+		F float64
+	}
+	type Outer struct {
+		Name  string
+		Inner []Inner
+	}
+	p := intPtr(1)
+	x := Outer{"x", []Inner{{P: p, F: 1}, {P: p, F: 2}}}
+	y := Outer{"x", []Inner{{P: p, F: 1}, {P: intPtr(1), F: 2}}}
+
+	// A FilterPath that never applies forces the usual path.
+	eager := cmp.FilterPath(func(cmp.Path) bool { return false }, cmp.Ignore())
+	type alias struct {
+		path, last string
+		typ        reflect.Type
+	}
+	run := func(opts ...cmp.Option) (aliases []alias, panicMsg string) {
+		defer func() { panicMsg = fmt.Sprint(recover()) }()
+		opts = append(opts, cmp.ReportAliases(func(p cmp.Path, _ uintptr) {
+			aliases = append(aliases, alias{p.GoString(), p[len(p)-1].String(), p[len(p)-1].Type()})
+		}), cmp.Comparer(func(x, y float64) bool {
+			if x == 2 {
+				panic("two")
+			}
+			return x == y
+		}))
+		cmp.Equal(x, y, opts...)
+		return aliases, ""
+	}
+	gotAliases, gotPanic := run()
+	wantAliases, wantPanic := run(eager)
+	if !reflect.DeepEqual(gotAliases, wantAliases) || len(gotAliases) != 1 {
+		t.Errorf("aliases mismatch:\ngot:  %v\nwant: %v", gotAliases, wantAliases)
+	}
+	if gotPanic != wantPanic || !strings.Contains(gotPanic, "panicked at {cmp_test.Outer}.Inner[1].F") {
+		t.Errorf("panic mismatch:\ngot:  %s\nwant: %s", gotPanic, wantPanic)
+	}
+
+	// Retained paths must also resolve struct fields correctly.
+	var retained []string
+	cmp.Equal(x, x, cmp.ReportAliases(func(p cmp.Path, _ uintptr) {
+		retained = append(retained, fmt.Sprintf("%#v %v", p, p[len(p)-1].(cmp.StructField).Name()))
+	}))
+	want := []string{"{cmp_test.Outer}.Inner Inner"}
+	if !reflect.DeepEqual(retained, want) {
+		t.Errorf("retained paths mismatch:\ngot:  %q\nwant: %q", retained, want)
+	}
+}
+
+func TestMustEqual(t *testing.T) {
+	type S struct {
+		A int
+		B []string
+	}
+	mustEqual := func(x, y interface{}, opts ...cmp.Option) (ex interface{}) {
+		defer func() { ex = recover() }()
+		cmp.MustEqual(x, y, opts...)
+		return nil
+	}
+
+	x, y := S{1, []string{"a", "b"}}, S{1, []string{"a", "b"}}
+	if ex := mustEqual(x, y); ex != nil {
+		t.Fatalf("MustEqual() panicked with equal values: %v", ex)
+	}
+
+	y = S{2, []string{"a", "c"}}
+	ex := mustEqual(x, y)
+	err, ok := ex.(*cmp.DiffError)
+	if !ok {
+		t.Fatalf("MustEqual() panicked with %T, want *cmp.DiffError", ex)
+	}
+	if want := cmp.Diff(x, y); err.Report != want {
+		t.Errorf("DiffError.Report = %q, want %q", err.Report, want)
+	}
+	if !strings.Contains(err.Error(), err.Report) {
+		t.Errorf("DiffError.Error() = %q, want it to contain the report", err.Error())
+	}
+	type diff struct {
+		path string
+		x, y interface{}
+	}
+	var got []diff
+	for _, d := range err.Differences {
+		got = append(got, diff{d.Path.GoString(), d.X.Interface(), d.Y.Interface()})
+	}
+	want := []diff{{"{cmp_test.S}.A", 1, 2}, {"{cmp_test.S}.B[1]", "b", "c"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DiffError.Differences = %v, want %v", got, want)
+	}
+}
+
+func TestEqualE(t *testing.T) {
+	type Inner struct{ a int }
+	type Outer struct{ In Inner }
+	type Nested struct{ A struct{ B struct{ C int } } }
+	equalInts := cmp.Comparer(func(x, y int) bool { return x == y })
+	nan := math.NaN()
+	tests := []struct {
+		label    string
+		x, y     interface{}
+		opts     []cmp.Option
+		wantErr  string // Substring of the error message
+		wantPath string // GoString of the error path, if any
+	}{{
+		label:   "UnfilteredOption",
+		x:       1,
+		y:       1,
+		opts:    []cmp.Option{cmp.Ignore()},
+		wantErr: "cannot use an unfiltered option",
+	}, {
+		label:    "UnexportedField",
+		x:        Outer{Inner{1}},
+		y:        Outer{Inner{1}},
+		wantErr:  "cannot handle unexported field: {cmp_test.Outer}.In.a",
+		wantPath: "{cmp_test.Outer}.In.a",
+	}, {
+		label:    "AmbiguousOptions",
+		x:        []int{1},
+		y:        []int{1},
+		opts:     []cmp.Option{equalInts, cmp.Comparer(func(x, y int) bool { return x == y })},
+		wantErr:  "ambiguous set of options",
+		wantPath: "{[]int}[0]",
+	}, {
+		label: "StrictTransformer",
+		x:     "1",
+		y:     "x",
+		opts: []cmp.Option{cmp.StrictTransformers(), cmp.Transformer("Atoi", func(s string) (int, error) {
+			if s != "1" {
+				return 0, errors.New("invalid")
+			}
+			return 1, nil
+		})},
+		wantErr:  "transformer Atoi failed",
+		wantPath: "{string}",
+	}, {
+		label:    "MaxDepth",
+		x:        Nested{},
+		y:        Nested{},
+		opts:     []cmp.Option{cmp.MaxDepth(2)},
+		wantErr:  "maximum depth of 2 exceeded",
+		wantPath: "{cmp_test.Nested}.A.B.C",
+	}, {
+		label:    "RecursiveTransformers",
+		x:        []int{1},
+		y:        []int{1},
+		opts:     []cmp.Option{cmp.Transformer("Copy", func(x []int) []int { return x })},
+		wantErr:  "recursive set of Transformers",
+		wantPath: "Copy(Copy({[]int}))",
+	}, {
+		label:    "NonSymmetricFunction",
+		x:        1,
+		y:        2,
+		opts:     []cmp.Option{cmp.Comparer(func(x, y int) bool { return x < y })},
+		wantErr:  "non-deterministic or non-symmetric function detected",
+		wantPath: "{int}",
+	}, {
+		label:    "MapKeyNaN",
+		x:        map[float64]int{nan: 1},
+		y:        map[float64]int{nan: 1},
+		wantErr:  "has map key with NaNs",
+		wantPath: "{map[float64]int}[NaN]",
+	}, {
+		label:    "MarshalText",
+		x:        &ipv4{},
+		y:        &ipv4{},
+		opts:     []cmp.Option{cmp.CompareViaTextMarshaler()},
+		wantErr:  "MarshalText failed",
+		wantPath: "*{*cmp_test.ipv4}",
+	}, {
+		label:   "VacuousComparison",
+		x:       1,
+		y:       1,
+		opts:    []cmp.Option{cmp.RequireComparisons(5)},
+		wantErr: "vacuous comparison",
+	}, {
+		label:   "NilProbe",
+		x:       new(int),
+		y:       new(int),
+		opts:    []cmp.Option{cmp.Comparer(func(x, y *int) bool { return *x == *y })},
+		wantErr: "panicked when called with nil pointers",
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.label, func(t *testing.T) {
+			eq, err := cmp.EqualE(tt.x, tt.y, tt.opts...)
+			if eq || err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("EqualE() = (%v, %v), want (false, %q)", eq, err, tt.wantErr)
+			}
+			e, ok := err.(*cmp.Error)
+			if !ok {
+				t.Fatalf("EqualE() error is %T, want *cmp.Error", err)
+			}
+			if got := e.Path.GoString(); e.Path == nil && tt.wantPath != "" || got != tt.wantPath {
+				t.Errorf("Error.Path = %q, want %q", got, tt.wantPath)
+			}
+
+			d, err2 := cmp.DiffE(tt.x, tt.y, tt.opts...)
+			if d != "" || err2 == nil || err2.Error() != err.Error() {
+				t.Errorf("DiffE() = (%q, %v), want (\"\", %v)", d, err2, err)
+			}
+
+			// Equal panics with the message alone.
+			func() {
+				defer func() {
+					if got, _ := recover().(string); got != err.Error() {
+						t.Errorf("Equal() panic = %q, want %q", got, err.Error())
+					}
+				}()
+				cmp.Equal(tt.x, tt.y, tt.opts...)
+			}()
+		})
+	}
+
+	// Values that can be compared report no error.
+	if eq, err := cmp.EqualE(1, 2); eq || err != nil {
+		t.Errorf("EqualE(1, 2) = (%v, %v), want (false, <nil>)", eq, err)
+	}
+	if d, err := cmp.DiffE(1, 1); d != "" || err != nil {
+		t.Errorf("DiffE(1, 1) = (%q, %v), want (\"\", <nil>)", d, err)
+	}
+
+	// Panics in user provided functions are not misuse of cmp.
+	defer func() {
+		if got, _ := recover().(string); !strings.Contains(got, "panic: boom") {
+			t.Errorf("EqualE() panic = %q, want annotated panic", got)
+		}
+	}()
+	cmp.EqualE(1, 2, cmp.Comparer(func(x, y int) bool { panic("boom") }))
+	t.Errorf("EqualE() did not panic")
+}
+
+func TestValidate(t *testing.T) {
+	equalInts := cmp.Comparer(func(x, y int) bool { return true })
+	toFloat := cmp.Transformer("", func(x int) float64 { return float64(x) })
+	tests := []struct {
+		label   string
+		opts    []cmp.Option
+		wantErr string // Substring of the error message, if any
+	}{{
+		label: "Empty",
+	}, {
+		label:   "UnfilteredIgnore",
+		opts:    []cmp.Option{cmp.Ignore()},
+		wantErr: "cannot use an unfiltered option",
+	}, {
+		label:   "UnfilteredComparer",
+		opts:    []cmp.Option{cmp.Comparer(func(_, _ interface{}) bool { return true })},
+		wantErr: "cannot use an unfiltered option",
+	}, {
+		label:   "UnfilteredTransformer",
+		opts:    []cmp.Option{cmp.Options{cmp.Transformer("", func(x interface{}) interface{} { return x })}},
+		wantErr: "cannot use an unfiltered option",
+	}, {
+		label:   "UnknownOption",
+		opts:    []cmp.Option{struct{ cmp.Option }{}},
+		wantErr: "unknown option",
+	}, {
+		label:   "ComparerAndTransformer",
+		opts:    []cmp.Option{equalInts, toFloat},
+		wantErr: "ambiguous set of options for type int:\n\tComparer(",
+	}, {
+		label: "Transformers",
 		opts: []cmp.Option{
-			cmp.AllowUnexported(ts.ParentStructB{}, ts.PublicStruct{}),
+			cmp.Transformer("Halve", func(in int) int { return in / 2 }),
+			cmp.Transformer("Identity", func(in int) int { return in }),
 		},
-		wantDiff: `
-{teststructs.ParentStructB}.PublicStruct.Public:
-	-: 1
-	+: 2
-{teststructs.ParentStructB}.PublicStruct.private:
-	-: 2
-	+: 3`,
+		wantErr: "ambiguous set of options for type int:\n\tTransformer(Halve, ",
 	}, {
-		label:     label + "ParentStructC",
-		x:         ts.ParentStructC{},
-		y:         ts.ParentStructC{},
+		label: "SamePriority",
+		opts: []cmp.Option{
+			cmp.Prioritized(1, cmp.Transformer("Unix", func(t time.Time) int64 { return t.Unix() })),
+			cmp.Prioritized(1, cmp.Comparer(time.Time.Equal)),
+		},
+		wantErr: "ambiguous set of options",
+	}, {
+		label: "DifferentPriority",
+		opts:  []cmp.Option{cmp.Prioritized(1, equalInts), toFloat},
+	}, {
+		label: "FilteredTransformer",
+		opts: []cmp.Option{equalInts, cmp.FilterPath(func(p cmp.Path) bool {
+			return len(p) > 1
+		}, toFloat)},
+	}, {
+		label: "FallibleTransformers",
+		opts: []cmp.Option{
+			cmp.Transformer("", func(s string) (int, error) { return strconv.Atoi(s) }),
+			cmp.Transformer("", func(s string) (float64, error) { return strconv.ParseFloat(s, 64) }),
+		},
+	}, {
+		label: "MaybeIgnored",
+		opts: []cmp.Option{
+			cmp.FilterPath(func(p cmp.Path) bool {
+				return len(p) > 0 && p[len(p)-1].Type().Kind() == reflect.Int
+			}, cmp.Ignore()),
+			equalInts,
+			toFloat,
+		},
+	}, {
+		label:   "NilProbe",
+		opts:    []cmp.Option{cmp.Comparer(func(x, y *int) bool { return *x == *y })},
+		wantErr: "panicked when called with nil pointers",
+	}, {
+		label: "NilProbeDisabled",
+		opts:  []cmp.Option{cmp.Comparer(func(x, y *int) bool { return *x == *y }), cmp.DisableNilProbe()},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.label, func(t *testing.T) {
+			err := cmp.Validate(tt.opts...)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() = %v, want error containing %q", err, tt.wantErr)
+			}
+			if _, ok := err.(*cmp.Error); !ok {
+				t.Errorf("Validate() error is %T, want *cmp.Error", err)
+			}
+		})
+	}
+}
+
+func TestOptionsString(t *testing.T) {
+	allowVisibility, ignoreLocker, transformProtos, equalTable := project3Options()
+	opts := cmp.Options{
+		allowVisibility,
+		transformProtos,
+		ignoreLocker,
+		cmp.Comparer(pb.Equal),
+		cmp.Prioritized(1, cmp.FilterValues(func(x, y ts.Table) bool { return x != nil }, equalTable)),
+	}
+	got := opts.String()
+	for _, want := range []string{
+		"Options{AllowUnexported(teststructs.Dirt), ",
+		"Transformer(cmp_test.project3Options.func1, cmp_test.project3Options.func1 func(testprotos.Dirt) *testprotos.Dirt), ",
+		"FilterPath(cmp_test.isLocker func(cmp.Path) bool, Ignore()), ",
+		"Comparer(testprotos.Equal func(testprotos.Message, testprotos.Message) bool), ",
+		"Prioritized(1, FilterValues(cmp_test.TestOptionsString.func1 func(teststructs.Table, teststructs.Table) bool, " +
+			"Comparer(cmp_test.project3Options.func2 func(teststructs.Table, teststructs.Table) bool)))}",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Options.String() does not contain %q:\n%s", want, got)
+		}
+	}
+	if got2 := opts.String(); got2 != got {
+		t.Errorf("Options.String() is not stable:\ngot:  %s\nwant: %s", got2, got)
+	}
+}
+
+func TestExplain(t *testing.T) {
+	type S struct {
+		A ts.StructB
+		B float64
+		C string
+		D int
+	}
+	x := S{A: ts.StructB{"NotEqual"}, B: 1.0, C: "skip", D: 1}
+	y := S{A: ts.StructB{"not_equal"}, B: 1.1, C: "me", D: 2}
+	opts := []cmp.Option{
+		cmp.FilterPath(func(p cmp.Path) bool { return p.String() == "A" },
+			cmp.Transformer("Ref", func(x ts.StructB) *ts.StructB { return &x })),
+		cmp.Comparer(approxFloat),
+		cmp.FilterPath(func(p cmp.Path) bool { return p.String() == "C" }, cmp.Ignore()),
+	}
+
+	e := cmp.Explain(x, y, opts...)
+	if e.Equal != cmp.Equal(x, y, opts...) {
+		t.Errorf("Explain(...).Equal = %v, want %v", e.Equal, !e.Equal)
+	}
+	want := []struct {
+		path      string
+		equal     bool
+		mechanism cmp.Mechanism
+	}{
+		{"A", true, cmp.ByEqualMethod},
+		{"B", true, cmp.ByComparer},
+		{"C", true, cmp.ByIgnore},
+		{"D", false, cmp.ByDefaultRules},
+	}
+	if len(e.Decisions) != len(want) {
+		t.Fatalf("got %d decisions, want %d:\n%v", len(e.Decisions), len(want), e)
+	}
+	for i, d := range e.Decisions {
+		w := want[i]
+		if got := d.Path.String(); got != w.path || d.Equal != w.equal || d.Mechanism != w.mechanism {
+			t.Errorf("Decisions[%d] = {%s, %v, %v}, want {%s, %v, %v}", i, got, d.Equal, d.Mechanism, w.path, w.equal, w.mechanism)
+		}
+	}
+	if got := e.Decisions[0].Transforms(); len(got) != 1 || got[0] != "Ref" {
+		t.Errorf("Decisions[0].Transforms() = %q, want [Ref]", got)
+	}
+
+	got := e.String()
+	for _, want := range []string{
+		"Ref({cmp_test.S}.A): equal by Equal method teststructs.(*StructB).Equal (via Ref)\n",
+		"{cmp_test.S}.B: equal by Comparer cmp_test.approxFloat\n",
+		"{cmp_test.S}.C: equal by Ignore FilterPath(cmp_test.TestExplain.func3 func(cmp.Path) bool, Ignore())\n",
+		"{cmp_test.S}.D: unequal by default rules\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Explanation.String() does not contain %q:\n%s", want, got)
+		}
+	}
+
+	// Values that Equal compares in one shot are still decided individually.
+	type Flat struct{ A, B int }
+	for _, tt := range []struct {
+		x, y interface{}
+		want []string
+	}{
+		{Flat{1, 2}, Flat{1, 2}, []string{"{cmp_test.Flat}.A: equal by default rules\n", "{cmp_test.Flat}.B: equal by default rules\n"}},
+		{[]int{1, 2}, []int{1, 2}, []string{"{[]int}[0]: equal by default rules\n", "{[]int}[1]: equal by default rules\n"}},
+		{[]byte{1, 2}, []byte{1, 3}, []string{"{[]uint8}[0]: equal by default rules\n", "{[]uint8}[1]: unequal by default rules\n"}},
+	} {
+		e := cmp.Explain(tt.x, tt.y)
+		if len(e.Decisions) != len(tt.want) {
+			t.Errorf("Explain(%v, %v) got %d decisions, want %d:\n%v", tt.x, tt.y, len(e.Decisions), len(tt.want), e)
+			continue
+		}
+		if got := e.String(); got != strings.Join(tt.want, "") {
+			t.Errorf("Explain(%v, %v) = %q, want %q", tt.x, tt.y, got, strings.Join(tt.want, ""))
+		}
+	}
+}
+
+func approxFloat(x, y float64) bool { return math.Abs(x-y) < 0.5 }
+
+func TestAssertionFunc(t *testing.T) {
+	assert := cmp.AssertionFunc(cmp.Comparer(approxFloat))
+	if eq, msg := assert([]float64{1, 2}, []float64{1.1, 2.1}); !eq || msg != "" {
+		t.Errorf("assert(approximately equal) = (%v, %q), want (true, \"\")", eq, msg)
+	}
+
+	x, y := []float64{1, 2}, []float64{1.1, 3}
+	eq, msg := assert(x, y)
+	if eq {
+		t.Errorf("assert(unequal) reported equal")
+	}
+	if d := cmp.Diff(x, y, cmp.Comparer(approxFloat)); d == "" || !strings.Contains(msg, d) {
+		t.Errorf("assert(unequal) message does not contain the diff:\ngot:\n%s\nwant:\n%s", msg, d)
+	}
+
+	type private struct{ f float64 }
+	eq, msg = assert(private{1}, private{1})
+	if eq || !strings.Contains(msg, "cannot handle unexported field") {
+		t.Errorf("assert(unexported) = (%v, %q), want misuse reported as failure", eq, msg)
+	}
+}
+
+// registeredDecimal is a type with default options registered by
+// registerDecimal, which compares values by their normalized form.
+type registeredDecimal struct{ Units, Exp int }
+
+var registerDecimalOnce sync.Once
+
+func registerDecimal() {
+	registerDecimalOnce.Do(func() {
+		cmp.RegisterDefaultOptions(registeredDecimal{}, cmp.Comparer(func(x, y registeredDecimal) bool {
+			for _, d := range []*registeredDecimal{&x, &y} {
+				for d.Units != 0 && d.Units%10 == 0 {
+					d.Units, d.Exp = d.Units/10, d.Exp+1
+				}
+			}
+			return x == y
+		}))
+	})
+}
+
+func TestRegisterDefaultOptions(t *testing.T) {
+	type price struct {
+		Amount   registeredDecimal
+		Currency string
+	}
+	x := price{registeredDecimal{150, -2}, "USD"}
+	y := price{registeredDecimal{15, -1}, "USD"}
+
+	// Populate the cache of plain types before using the defaults.
+	if cmp.Equal(x, y, cmp.WithoutDefaults()) {
+		t.Errorf("Equal(WithoutDefaults) = true, want false")
+	}
+	registerDecimal()
+	if !cmp.Equal(x, y) {
+		t.Errorf("Equal() = false, want true by the registered Comparer")
+	}
+	if d := cmp.Diff(x, y); d != "" {
+		t.Errorf("Diff() = %s, want empty", d)
+	}
+	if cmp.Equal(x, y, cmp.WithoutDefaults()) {
+		t.Errorf("Equal(WithoutDefaults) = true, want false")
+	}
+
+	// Explicit options win over the registered ones.
+	strict := cmp.Comparer(func(x, y registeredDecimal) bool { return x == y })
+	if cmp.Equal(x, y, strict) {
+		t.Errorf("Equal(strict) = true, want false")
+	}
+	if !cmp.Equal(x, price{y.Amount, "EUR"}, cmp.FilterPath(func(p cmp.Path) bool {
+		return p.String() == "Currency"
+	}, cmp.Ignore())) {
+		t.Errorf("Equal(ignore Currency) = false, want true")
+	}
+	if !cmp.Equal(x, price{registeredDecimal{1, 0}, "USD"}, cmp.FilterPath(func(p cmp.Path) bool {
+		return p.String() == "Amount"
+	}, cmp.Ignore())) {
+		t.Errorf("Equal(ignore Amount) = false, want true")
+	}
+
+	for _, tt := range []struct {
+		label string
+		typ   interface{}
+		opts  []cmp.Option
+		want  string
+	}{
+		{"Duplicate", registeredDecimal{}, []cmp.Option{strict}, "default options already registered for type cmp_test.registeredDecimal"},
+		{"Nil", nil, nil, "cannot register default options for a nil type"},
+		{"NotApplicable", price{}, []cmp.Option{strict}, "default option does not apply to type cmp_test.price"},
+		{"Global", price{}, []cmp.Option{cmp.MaxDepth(3)}, "cannot register default option of type cmp.maxDepth"},
+	} {
+		t.Run(tt.label, func(t *testing.T) {
+			defer func() {
+				if got := fmt.Sprint(recover()); !strings.Contains(got, tt.want) {
+					t.Errorf("RegisterDefaultOptions() panic = %q, want %q", got, tt.want)
+				}
+			}()
+			cmp.RegisterDefaultOptions(tt.typ, tt.opts...)
+		})
+	}
+}
+
+// optionerEvent is an Optioner whose Timestamp field is ignored.
+type optionerEvent struct {
+	Name      string
+	Timestamp time.Time
+}
+
+func (optionerEvent) CmpOptions() []cmp.Option {
+	return []cmp.Option{cmp.FilterPath(func(p cmp.Path) bool {
+		sf, ok := p[len(p)-1].(cmp.StructField)
+		return ok && sf.Name() == "Timestamp"
+	}, cmp.Ignore())}
+}
+
+// optionerNode is an Optioner whose Stamp field is ignored.
+type optionerNode struct {
+	Name     string
+	Stamp    int
+	Children []optionerNode
+}
+
+var optionerNodeCalls int
+
+func (*optionerNode) CmpOptions() []cmp.Option {
+	optionerNodeCalls++
+	return []cmp.Option{cmp.FilterPath(func(p cmp.Path) bool {
+		sf, ok := p[len(p)-1].(cmp.StructField)
+		return ok && sf.Name() == "Stamp"
+	}, cmp.Ignore())}
+}
+
+// optionerPtr is an Optioner whose Name field is ignored if N is positive.
+type optionerPtr struct {
+	N    int
+	Name string
+}
+
+func (p *optionerPtr) CmpOptions() []cmp.Option {
+	if p.N <= 0 {
+		return nil
+	}
+	return []cmp.Option{cmp.FilterPath(func(p cmp.Path) bool {
+		sf, ok := p[len(p)-1].(cmp.StructField)
+		return ok && sf.Name() == "Name"
+	}, cmp.Ignore())}
+}
+
+func TestOptioner(t *testing.T) {
+	// CmpOptions is called on the compared value, even through a pointer.
+	if !cmp.Equal(&optionerPtr{1, "a"}, &optionerPtr{1, "b"}) {
+		t.Errorf("Equal(positive N) = false, want true")
+	}
+	if cmp.Equal(&optionerPtr{0, "a"}, &optionerPtr{0, "b"}) {
+		t.Errorf("Equal(zero N) = true, want false")
+	}
+	if !cmp.Equal(optionerPtr{1, "a"}, optionerPtr{1, "b"}) {
+		t.Errorf("Equal(value of positive N) = false, want true")
+	}
+	if !cmp.Equal((*optionerPtr)(nil), (*optionerPtr)(nil)) {
+		t.Errorf("Equal(nil, nil) = false, want true")
+	}
+
+	// The options of nested values of the same type are only added once.
+	optionerNodeCalls = 0
+	nx := optionerNode{"root", 1, []optionerNode{{"a", 2, nil}, {"b", 3, []optionerNode{{"c", 4, nil}}}}}
+	ny := optionerNode{"root", 5, []optionerNode{{"a", 6, nil}, {"b", 7, []optionerNode{{"c", 8, nil}}}}}
+	if !cmp.Equal(nx, ny) {
+		t.Errorf("Equal(tree) = false, want true:\n%s", cmp.Diff(nx, ny))
+	}
+	if optionerNodeCalls != 1 {
+		t.Errorf("CmpOptions called %d times, want 1", optionerNodeCalls)
+	}
+}
+
+func TestCompareFieldsByNameTransform(t *testing.T) {
+	// The Transform step is named like the other built-in transformers,
+	// and its Func presents the struct as-is.
+	var got cmp.Transform
+	opts := []cmp.Option{
+		cmp.CompareFieldsByName(userV1{}, userV3{}),
+		cmp.FilterPath(func(p cmp.Path) bool {
+			for _, ps := range p {
+				if tr, ok := ps.(cmp.Transform); ok {
+					got = tr
+				}
+			}
+			return false
+		}, cmp.Ignore()),
+	}
+	x := userV1{Name: "ann", Email: "ann@example.com"}
+	cmp.Equal(x, userV3{Name: "ann", Email: "ann@example.com"}, opts...)
+	if got == nil {
+		t.Fatalf("no Transform step was recorded")
+	}
+	if got.Name() != "λbyName" {
+		t.Errorf("Transform.Name() = %q, want %q", got.Name(), "λbyName")
+	}
+	out := got.Func().Call([]reflect.Value{reflect.ValueOf(x)})[0].Interface()
+	if out != x {
+		t.Errorf("Transform.Func()(x) = %v, want %v", out, x)
+	}
+}
+
+func TestCheckInvariants(t *testing.T) {
+	type pair struct {
+		A, B int
+	}
+	gen := func(r *rand.Rand) interface{} {
+		return []pair{{r.Intn(3), r.Intn(3)}, {r.Intn(3), r.Intn(3)}}
+	}
+	if err := cmp.CheckInvariants(gen); err != nil {
+		t.Errorf("CheckInvariants(no options) = %v, want nil", err)
+	}
+	sumEqual := cmp.Comparer(func(x, y pair) bool { return x.A+x.B == y.A+y.B })
+	if err := cmp.CheckInvariants(gen, sumEqual); err != nil {
+		t.Errorf("CheckInvariants(sumEqual) = %v, want nil", err)
+	}
+
+	tests := []struct {
+		label string
+		opts  []cmp.Option
+		want  string // Substring of the error
+	}{{
+		label: "Irreflexive",
+		opts:  []cmp.Option{cmp.Comparer(func(x, y pair) bool { return x.A < y.A })},
+		want:  "Equal(x, x) is false for:\n\tx: []cmp_test.pair{{",
+	}, {
+		label: "Asymmetric",
+		opts:  []cmp.Option{cmp.Comparer(func(x, y pair) bool { return x.A <= y.A })},
+		want:  "\npanic: non-deterministic or non-symmetric function detected",
+	}, {
+		label: "Misuse",
+		opts:  []cmp.Option{sumEqual, cmp.Comparer(func(x, y pair) bool { return x == y })},
+		want:  "\npanic: ambiguous set of options",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.label, func(t *testing.T) {
+			err := cmp.CheckInvariants(gen, tt.opts...)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("CheckInvariants() = %v, want error containing %q", err, tt.want)
+			}
+			if ie, ok := err.(*cmp.InvariantError); !ok || ie.X == nil || ie.Y == nil {
+				t.Errorf("InvariantError does not include the values: %#v", ie)
+			}
+		})
+	}
+}
+
+func TestTransformChannelContents(t *testing.T) {
+	makeChan := func(closed bool, vs ...int) chan int {
+		c := make(chan int, 5)
+		for _, v := range vs {
+			c <- v
+		}
+		if closed {
+			close(c)
+		}
+		return c
+	}
+	type pipeline struct {
+		Out    chan int // Closed once the pipeline is done
+		Events chan int
+	}
+	isOut := func(p cmp.Path) bool { return p[len(p)-1].String() == ".Out" }
+	opt := cmp.FilterPath(isOut, cmp.TransformChannelContents())
+
+	x, y := pipeline{Out: makeChan(true, 1, 2, 3)}, pipeline{Out: makeChan(true, 1, 2, 3)}
+	if d := cmp.Diff(x, y, opt); d != "" {
+		t.Errorf("Diff(equal contents) = %s, want empty", d)
+	}
+	if !cmp.Equal(x, y, opt) || len(x.Out)+len(y.Out) > 0 {
+		t.Errorf("Equal(drained channels) = false or not empty, want true since both were drained by Diff")
+	}
+
+	// Channels that are not selected are never received from,
+	// even while another goroutine is blocked sending on them.
+	events := makeChan(false, 1, 2, 3, 4, 5)
+	sent := make(chan bool)
+	go func() {
+		events <- 6
+		close(sent)
+	}()
+	x = pipeline{makeChan(true, 1), events}
+	y = pipeline{makeChan(true, 1), events}
+	if !cmp.Equal(x, y, opt) {
+		t.Errorf("Equal(same events) = false, want true")
+	}
+	if d := cmp.Diff(pipeline{Events: events}, pipeline{Events: makeChan(true)}, opt); !strings.Contains(d, "only equal if they are the same channel") {
+		t.Errorf("Diff(unselected) does not fall back to identity:\n%s", d)
+	}
+	var got []int
+	for len(got) < 6 {
+		got = append(got, <-events)
+	}
+	<-sent
+	if want := []int{1, 2, 3, 4, 5, 6}; !reflect.DeepEqual(got, want) {
+		t.Errorf("events received = %v, want %v", got, want)
+	}
+
+	all := cmp.FilterPath(func(cmp.Path) bool { return true }, cmp.TransformChannelContents())
+	if cmp.Equal((<-chan int)(makeChan(true, 1)), (<-chan int)(makeChan(true, 1)), all) {
+		t.Errorf("Equal(receive-only channels) = true, want false")
+	}
+}
+
+// loader is a wrapper with a Load method, as used by transformLoadsTests.
+type loader struct{ v string }
+
+func (l *loader) Load() interface{} { return l.v }
+
+func TestTransformReaders(t *testing.T) {
+	type message struct {
+		Body    *bytes.Buffer
+		Trailer io.Reader
+	}
+	opt := cmp.TransformReaders()
+	x := message{bytes.NewBufferString("hello"), strings.NewReader("done")}
+	y := message{bytes.NewBufferString("hello"), bytes.NewReader([]byte("done"))}
+	if d := cmp.Diff(x, y, opt); d != "" {
+		t.Errorf("Diff(same contents) = %s, want empty", d)
+	}
+	if n := x.Trailer.(*strings.Reader).Len(); n != 4 {
+		t.Errorf("strings.Reader.Len() = %d after comparison, want 4", n)
+	}
+
+	y.Body = bytes.NewBufferString("help")
+	if cmp.Equal(x, y, opt) {
+		t.Errorf("Equal(different contents) = true, want false")
+	}
+	if y.Body.String() != "help" {
+		t.Errorf("bytes.Buffer contents = %q after comparison, want %q", y.Body.String(), "help")
+	}
+
+	// A plain reader cannot be rewound, so it is compared as usual.
+	r := &onceReader{s: "stream"}
+	if !cmp.Equal(io.Reader(r), io.Reader(r), opt, cmp.AllowUnexported(onceReader{})) {
+		t.Errorf("Equal(same reader) = false, want true")
+	}
+	if r.s != "stream" {
+		t.Errorf("onceReader was consumed: %q remains", r.s)
+	}
+}
+
+// onceReader is a reader that cannot be rewound, as used by TestTransformReaders.
+type onceReader struct{ s string }
+
+func (r *onceReader) Read(b []byte) (int, error) {
+	if r.s == "" {
+		return 0, io.EOF
+	}
+	n := copy(b, r.s)
+	r.s = r.s[n:]
+	return n, nil
+}
+
+func TestNormalizeLineEndings(t *testing.T) {
+	// Binary data is compared exactly and left unmodified.
+	x, y := []byte{0x00, 0x0d, 0x0a, 0xff}, []byte{0x00, 0x0a, 0xff}
+	if cmp.Equal(x, y, cmp.NormalizeLineEndings()) {
+		t.Errorf("Equal(binary) = true, want false")
+	}
+	if want := []byte{0x00, 0x0d, 0x0a, 0xff}; !bytes.Equal(x, want) {
+		t.Errorf("binary input modified: got %x, want %x", x, want)
+	}
+}
+
+func TestAnyOf(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("AnyOf(Transformer) did not panic")
+		}
+	}()
+	cmp.AnyOf(cmp.Transformer("T", strings.TrimSpace))
+}
+
+func TestAllOf(t *testing.T) {
+	// The report names the source line of the comparer,
+	// so it is not covered by allOfTests.
+	opt := cmp.AllOf(cmp.Comparer(pb.Equal), cmp.Comparer(equalNoteStrings))
+	x := &note{Text: "hello", Lang: "en"}
+	y := &note{Text: "hello", Lang: "fr"}
+	if !pb.Equal(x, y) {
+		t.Fatalf("pb.Equal(x, y) = false, want true")
+	}
+	d := cmp.Diff(x, y, opt)
+	for _, want := range []string{
+		"Comparer(cmp_test.equalNoteStrings func(*cmp_test.note, *cmp_test.note) bool) reported a difference",
+		"created at compare_test.go:",
+	} {
+		if !strings.Contains(d, want) {
+			t.Errorf("Diff() does not contain %q:\n%s", want, d)
+		}
+	}
+	e := cmp.Explain(x, y, opt)
+	if e.Equal || len(e.Decisions) != 1 || e.Decisions[0].Name != "cmp_test.equalNoteStrings" {
+		t.Errorf("Explain() = %+v, want unequal by cmp_test.equalNoteStrings", e)
+	}
+}
+
+// note is a message whose String method only formats some of its fields,
+// as used by TestAllOf and allOfTests.
+type note struct{ Text, Lang string }
+
+func (*note) Proto()           {}
+func (n *note) String() string { return n.Text }
+
+func equalNoteStrings(x, y *note) bool { return fmt.Sprintf("%+v", *x) == fmt.Sprintf("%+v", *y) }
+
+func TestNot(t *testing.T) {
+	type record struct{ CreatedAt time.Time }
+	isCreatedAt := func(p cmp.Path) bool {
+		sf, ok := p[len(p)-1].(cmp.StructField)
+		return ok && sf.Name() == "CreatedAt"
+	}
+	if got, want := cmp.Not(cmp.FilterPath(isCreatedAt, cmp.Ignore())).(fmt.Stringer).String(), "Not(FilterPath("; !strings.HasPrefix(got, want) {
+		t.Errorf("String() = %q, want prefix %q", got, want)
+	}
+	for _, opt := range []cmp.Option{cmp.Ignore(), cmp.Comparer(func(x, y int) bool { return true }), cmp.AllowUnexported(record{})} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Not(%v) did not panic", opt)
+				}
+			}()
+			cmp.Not(opt)
+		}()
+	}
+}
+
+func TestOptionsFlatten(t *testing.T) {
+	// The options used by project1Tests, along with some nesting.
+	opts := cmp.Options{
+		IgnoreUnexported(ts.EagleImmutable{}, ts.SlapImmutable{}),
+		cmp.Options{
+			cmp.Comparer(pb.Equal),
+			cmp.Prioritized(1, cmp.Within("Slaps[0].Immutable")),
+		},
+		cmp.AllowUnexported(ts.Dirt{}),
+	}
+	got := opts.Flatten()
+	want := []cmp.OptionDescription{{
+		Kind:    "Ignore",
+		Filters: []string{"FilterPath(cmp_test.IgnoreUnexported.func1 func(cmp.Path) bool)"},
+	}, {
+		Kind: "Comparer",
+		Func: "testprotos.Equal func(testprotos.Message, testprotos.Message) bool",
+	}, {
+		Kind:    "Ignore",
+		Filters: []string{"Prioritized(1)", "FilterPath(cmp.Within.func1 func(cmp.Path) bool)"},
+	}, {
+		Kind: "AllowUnexported",
+	}}
+	if diff := cmp.Diff(want, got, cmp.FilterPath(func(p cmp.Path) bool {
+		sf, ok := p[len(p)-1].(cmp.StructField)
+		return ok && sf.Name() == "Option"
+	}, cmp.Ignore())); diff != "" {
+		t.Errorf("Flatten() mismatch:\n%s", diff)
+	}
+	if got[1].Option.(fmt.Stringer).String() != "Comparer(testprotos.Equal func(testprotos.Message, testprotos.Message) bool)" {
+		t.Errorf("Flatten()[1].Option = %v, want the Comparer", got[1].Option)
+	}
+}
+
+func TestFilterPathValues(t *testing.T) {
+	type entry struct {
+		Amount  int
+		Balance int
+	}
+	var calls int
+	isAmount := func(p cmp.Path) bool {
+		sf, ok := p[len(p)-1].(cmp.StructField)
+		return ok && sf.Name() == "Amount"
+	}
+	bothNegative := func(x, y int) bool {
+		calls++
+		return x < 0 && y < 0
+	}
+	opt := cmp.FilterPathValues(isAmount, bothNegative, cmp.Ignore())
+
+	// The values filter is never called for the Balance field.
+	calls = 0
+	cmp.Equal(entry{-1, -1}, entry{-1, -1}, opt, cmp.DisableFunctionChecks())
+	if calls != 1 {
+		t.Errorf("values filter called %d times, want 1", calls)
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("FilterPathValues with an invalid values filter did not panic")
+			}
+		}()
+		cmp.FilterPathValues(isAmount, func(x int) bool { return true }, cmp.Ignore())
+	}()
+}
+
+func TestDecidingComparer(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("DecidingComparer(func(x, y int) bool) did not panic")
+		}
+	}()
+	cmp.DecidingComparer(func(x, y int) bool { return true })
+}
+
+func TestNamedComparer(t *testing.T) {
+	approx := cmp.NamedComparer("Approx", func(x, y float64) bool { return math.Abs(x-y) < 0.1 })
+	exact := cmp.NamedComparer("mypkg.Exact", func(x, y float64) bool { return x == y })
+
+	if got, want := approx.(fmt.Stringer).String(), "NamedComparer(Approx, cmp_test.TestNamedComparer.func1 func(float64, float64) bool)"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	e := cmp.Explain(1.0, 1.5, approx)
+	if e.Equal || len(e.Decisions) != 1 || e.Decisions[0].Name != "Approx" {
+		t.Errorf("Explain() = %+v, want unequal by Approx", e)
+	}
+
+	func() {
+		defer func() {
+			msg := fmt.Sprint(recover())
+			for _, want := range []string{"ambiguous set of options", "NamedComparer(Approx, ", "NamedComparer(mypkg.Exact, "} {
+				if !strings.Contains(msg, want) {
+					t.Errorf("panic message does not contain %q:\n%s", want, msg)
+				}
+			}
+		}()
+		cmp.Equal(1.0, 1.05, approx, exact)
+	}()
+}
+
+func TestFilterValuesInterface(t *testing.T) {
+	type S struct{ A interface{} }
+	tests := []struct {
+		label     string
+		x, y      interface{}
+		wantCalls []string // Formatted inputs of each call to the filter
+	}{{
+		label:     "UntypedNil",
+		x:         nil,
+		y:         1,
+		wantCalls: []string{"<nil> 1"},
+	}, {
+		label:     "BothUntypedNil",
+		x:         nil,
+		y:         nil,
+		wantCalls: []string{"<nil> <nil>"},
+	}, {
+		label:     "TypedNilPointer",
+		x:         (*int)(nil),
+		y:         1,
+		wantCalls: []string{"(*int)(nil) 1"},
+	}, {
+		label:     "MismatchedTypes",
+		x:         1,
+		y:         "a",
+		wantCalls: []string{`1 "a"`},
+	}, {
+		label:     "NilInterfaceField",
+		x:         S{nil},
+		y:         S{1},
+		wantCalls: []string{"cmp_test.S{A:interface {}(nil)} cmp_test.S{A:1}", "<nil> 1"},
+	}, {
+		label:     "TypedNilPointerField",
+		x:         S{(*int)(nil)},
+		y:         S{nil},
+		wantCalls: []string{"cmp_test.S{A:(*int)(nil)} cmp_test.S{A:interface {}(nil)}", "(*int)(nil) <nil>"},
+	}, {
+		label:     "MismatchedTypesField",
+		x:         S{1},
+		y:         S{"a"},
+		wantCalls: []string{`cmp_test.S{A:1} cmp_test.S{A:"a"}`, `1 "a"`},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.label, func(t *testing.T) {
+			var calls []string
+			isNil := func(x, y interface{}) bool {
+				calls = append(calls, fmt.Sprintf("%#v %#v", x, y))
+				return x == nil || y == nil || reflect.ValueOf(x).Kind() == reflect.Ptr
+			}
+			alwaysEqual := cmp.Comparer(func(_, _ interface{}) bool { return true })
+			got := cmp.Equal(tt.x, tt.y, cmp.FilterValues(isNil, alwaysEqual), cmp.DisableFunctionChecks())
+			if diff := cmp.Diff(tt.wantCalls, calls); diff != "" {
+				t.Errorf("filter calls mismatch (-want +got):\n%s", diff)
+			}
+			if want := tt.label != "MismatchedTypes" && tt.label != "MismatchedTypesField"; got != want {
+				t.Errorf("Equal() = %v, want %v", got, want)
+			}
+		})
+	}
+
+	// A panicking filter is attributed to the filter and the path.
+	defer func() {
+		msg := fmt.Sprint(recover())
+		for _, want := range []string{"function cmp_test.TestFilterValuesInterface.func", "panicked at {cmp_test.S}.A"} {
+			if !strings.Contains(msg, want) {
+				t.Errorf("panic message does not contain %q:\n%s", want, msg)
+			}
+		}
+	}()
+	cmp.Equal(S{nil}, S{1}, cmp.FilterValues(func(x, y interface{}) bool {
+		if x == nil {
+			panic("nil input")
+		}
+		return false
+	}, cmp.Ignore()))
+}
+
+func TestOptionsDedup(t *testing.T) {
+	var calls int
+	equal := cmp.Comparer(func(x, y int) bool { calls++; return x == y })
+	opts := cmp.Options{cmp.Options{equal}, cmp.Options{equal, cmp.Options{equal}}}
+	if !cmp.Equal(1, 1, opts, cmp.DisableFunctionChecks()) {
+		t.Errorf("Equal() = false, want true")
+	}
+	if calls != 1 {
+		t.Errorf("comparer called %d times, want 1", calls)
+	}
+	if got := len(opts.Flatten()); got != 1 {
+		t.Errorf("len(Flatten()) = %d, want 1", got)
+	}
+}
+
+type celsius float64
+
+func (c celsius) String() string { return fmt.Sprintf("%.0f°C", float64(c)) }
+
+func TestExactComparer(t *testing.T) {
+	exact := cmp.ExactComparer(func(x, y fmt.Stringer) bool { return x.String() == y.String() })
+	if s := fmt.Sprint(exact); !strings.HasPrefix(s, "ExactComparer(") {
+		t.Errorf("String() = %q, want prefix %q", s, "ExactComparer(")
+	}
+}
+
+func TestFilterMapKeys(t *testing.T) {
+	foldKeys := cmp.FilterMapKeys(cmp.ApplyOnce(cmp.Transformer("ToLower", strings.ToLower)))
+	if !strings.HasPrefix(fmt.Sprint(foldKeys), "FilterMapKeys(ApplyOnce(Transformer(ToLower, ") {
+		t.Errorf("String() = %q", fmt.Sprint(foldKeys))
+	}
+}
+
+func TestDiffAt(t *testing.T) {
+	type Envelope struct {
+		ID      string
+		Payload *struct{ Records map[string][]int }
+	}
+	newEnvelope := func(id string, recs map[string][]int) Envelope {
+		e := Envelope{ID: id}
+		if recs != nil {
+			e.Payload = &struct{ Records map[string][]int }{recs}
+		}
+		return e
+	}
+	x := newEnvelope("x", map[string][]int{"a": {1, 2}, "b": {3}})
+	y := newEnvelope("y", map[string][]int{"a": {1, 2}, "b": {4}})
+
+	tests := []struct {
+		path     string
+		x, y     interface{}
+		wantDiff string
+		wantErr  string
+	}{{
+		path: "Payload.Records[\"a\"]",
+		x:    x,
+		y:    y,
+	}, {
+		path:     "Payload.Records",
+		x:        x,
+		y:        &y,
+		wantDiff: "{cmp_test.Envelope}.Payload.Records[\"b\"][0]:\n\t-: 3\n\t+: 4\n",
+	}, {
+		path:     ".Payload.Records[\"b\"]",
+		x:        x,
+		y:        y,
+		wantDiff: "{cmp_test.Envelope}.Payload.Records[\"b\"][0]:\n\t-: 3\n\t+: 4\n",
+	}, {
+		path:    "Payload.Records[\"c\"]",
+		x:       x,
+		y:       y,
+		wantErr: `cannot resolve "Payload.Records[\"c\"]" on x: missing key c`,
+	}, {
+		path:    "Payload.Records",
+		x:       x,
+		y:       newEnvelope("y", nil),
+		wantErr: "cannot resolve \"Payload.Records\" on y: nil ptr at {cmp_test.Envelope}.Payload",
+	}, {
+		path:    "Payload.Records[\"a\"][2]",
+		x:       x,
+		y:       y,
+		wantErr: "index 2 out of range",
+	}, {
+		path:    "Payload.Missing",
+		x:       x,
+		y:       y,
+		wantErr: "no field Missing",
+	}, {
+		path:    "Payload[",
+		x:       x,
+		y:       y,
+		wantErr: "invalid path expression",
+	}}
+	for _, tt := range tests {
+		gotDiff, err := cmp.DiffAt(tt.path, tt.x, tt.y)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("DiffAt(%q) error = %v, want %q", tt.path, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("DiffAt(%q) unexpected error: %v", tt.path, err)
+			continue
+		}
+		if gotDiff != tt.wantDiff {
+			t.Errorf("DiffAt(%q):\ngot:\n%s\nwant:\n%s", tt.path, gotDiff, tt.wantDiff)
+		}
+		gotEqual, err := cmp.EqualAt(tt.path, tt.x, tt.y)
+		if err != nil || gotEqual != (tt.wantDiff == "") {
+			t.Errorf("EqualAt(%q) = (%v, %v), want (%v, <nil>)", tt.path, gotEqual, err, tt.wantDiff == "")
+		}
+	}
+}
+
+func TestAuditIgnores(t *testing.T) {
+	type audit struct {
+		path string
+		diff string
+	}
+	ignoreB := cmp.FilterPath(func(p cmp.Path) bool { return p.String() == "B" }, cmp.Ignore())
+	tests := []struct {
+		x, y interface{}
+		opts []cmp.Option
+		want []audit
+	}{{
+		x:    struct{ A, B int }{1, 2},
+		y:    struct{ A, B int }{1, 3},
+		opts: []cmp.Option{ignoreB},
+		want: []audit{{"root.B", "root.B:\n\t-: 2\n\t+: 3\n"}},
+	}, {
+		x:    struct{ A, B int }{1, 2},
+		y:    struct{ A, B int }{1, 2},
+		opts: []cmp.Option{ignoreB},
+	}, {
+		x:    struct{ A, B int }{1, 2},
+		y:    struct{ A, B int }{1, 3},
+		opts: []cmp.Option{cmp.FilterValues(func(x, y int) bool { return x > 1 && y > 1 }, cmp.Ignore())},
+		want: []audit{{"root.B", "root.B:\n\t-: 2\n\t+: 3\n"}},
+	}, {
+		x:    ts.ParentStructA{},
+		y:    ts.ParentStructA{},
+		opts: []cmp.Option{IgnoreUnexported(ts.ParentStructA{})},
+		want: []audit{{"{teststructs.ParentStructA}.privateStruct", "(ignored values could not be evaluated: cannot handle unexported field: {teststructs.ParentStructA}.privateStruct.private)"}},
+	}, {
+		x: func() ts.ParentStructA {
+			var v ts.ParentStructA
+			v.PrivateStruct().Public = 1
+			return v
+		}(),
+		y:    ts.ParentStructA{},
+		opts: []cmp.Option{IgnoreUnexported(ts.ParentStructA{})},
+		want: []audit{{"{teststructs.ParentStructA}.privateStruct", "(ignored values could not be evaluated: cannot handle unexported field: {teststructs.ParentStructA}.privateStruct.private)"}},
+	}, {
+		x: func() ts.ParentStructA {
+			var v ts.ParentStructA
+			v.PrivateStruct().Public = 1
+			return v
+		}(),
+		y:    ts.ParentStructA{},
+		opts: []cmp.Option{IgnoreUnexported(ts.ParentStructA{}), cmp.AllowUnexported(*new(ts.ParentStructA).PrivateStruct())},
+		want: []audit{{"{teststructs.ParentStructA}.privateStruct", "{teststructs.ParentStructA}.privateStruct.Public:\n\t-: 1\n\t+: 0\n"}},
+	}}
+	for i, tt := range tests {
+		var got []audit
+		opts := append(tt.opts, cmp.AuditIgnores(func(p cmp.Path, diff string) {
+			got = append(got, audit{fmt.Sprintf("%#v", p), diff})
+		}))
+		if !cmp.Equal(tt.x, tt.y, opts...) {
+			t.Errorf("test %d: Equal() = false, want true", i)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("test %d: audits mismatch:\ngot:  %q\nwant: %q", i, got, tt.want)
+		}
+	}
+}
+
+func TestOptionsFromRules(t *testing.T) {
+	const spec = `[
+		{"path": "Slaps[*].Immutable.Started", "rule": "ignore"},
+		{"path": "Immutable", "type": "time.Time", "rule": "ignore"},
+		{"type": "float64", "rule": "approx", "fraction": 0.001}
+	]`
+	var rules []cmp.Rule
+	if err := json.Unmarshal([]byte(spec), &rules); err != nil {
+		t.Fatalf("json.Unmarshal error: %v", err)
+	}
+	got, err := cmp.OptionsFromRules(rules)
+	if err != nil {
+		t.Fatalf("OptionsFromRules error: %v", err)
+	}
+	want := cmp.Options{
+		cmp.FilterPath(func(p cmp.Path) bool {
+			return len(p) > 5 && p[1].String() == ".Slaps" && p[3].String() == ".Immutable" && p[5].String() == ".Started"
+		}, cmp.Ignore()),
+		cmp.FilterPath(func(p cmp.Path) bool {
+			return len(p) > 1 && p[1].String() == ".Immutable" && p[len(p)-1].Type() == reflect.TypeOf(time.Time{})
+		}, cmp.Ignore()),
+		cmp.EquateApprox(0.001, 0),
+	}
+	ignoreUnexported := IgnoreUnexported(ts.EagleImmutable{}, ts.DreamerImmutable{}, ts.SlapImmutable{})
+
+	createEagle := func() ts.Eagle {
+		return ts.Eagle{
+			Name:      "eagle",
+			Dreamers:  []ts.Dreamer{{Name: "dreamer", Immutable: &ts.DreamerImmutable{Started: now}}},
+			Slaps:     []ts.Slap{{Name: "slap1", Immutable: &ts.SlapImmutable{Started: now}}, {Name: "slap2"}},
+			Immutable: &ts.EagleImmutable{ID: "eagle", Birthday: now, Started: now},
+		}
+	}
+	mutations := []func(*ts.Eagle){
+		func(eg *ts.Eagle) {},
+		func(eg *ts.Eagle) { eg.Slaps[0].Immutable.Started = now.Add(time.Hour) },
+		func(eg *ts.Eagle) { eg.Slaps[0].Immutable.ID = "slap" },
+		func(eg *ts.Eagle) { eg.Immutable.Birthday = now.Add(time.Hour) },
+		func(eg *ts.Eagle) { eg.Immutable.ID = "eagle2" },
+		func(eg *ts.Eagle) { eg.Dreamers[0].Immutable.Started = now.Add(time.Hour) },
+	}
+	for i, mutate := range mutations {
+		x, y := createEagle(), createEagle()
+		mutate(&y)
+		gotDiff := cmp.Diff(x, y, got, ignoreUnexported)
+		wantDiff := cmp.Diff(x, y, want, ignoreUnexported)
+		if gotDiff != wantDiff {
+			t.Errorf("mutation %d: Diff mismatch:\ngot:\n%s\nwant:\n%s", i, gotDiff, wantDiff)
+		}
+	}
+
+	x, y := []float64{1000, 2000}, []float64{1000.5, 2003}
+	if gotDiff, wantDiff := cmp.Diff(x, y, got), cmp.Diff(x, y, want); gotDiff != wantDiff || gotDiff == "" {
+		t.Errorf("Diff mismatch:\ngot:\n%s\nwant:\n%s", gotDiff, wantDiff)
+	}
+
+	for _, rules := range [][]cmp.Rule{
+		{{Rule: "approximately"}},
+		{{Path: "Slaps[0", Rule: "ignore"}},
+		{{Rule: "ignore"}},
+		{{Type: "float64", Rule: "approx", Fraction: -1}},
+	} {
+		if _, err := cmp.OptionsFromRules(rules); err == nil {
+			t.Errorf("OptionsFromRules(%+v) succeeded, want error", rules)
+		}
+	}
+}
+
+func TestRandomFunctionChecks(t *testing.T) {
+	// The comparer is non-symmetric only for a single element, such that
+	// whether the failure is detected depends on which calls are checked.
+	x, y := make([]int, 1000), make([]int, 1000)
+	x[500] = 1
+	run := func(seed int64) (ncalls int, panicked bool) {
+		defer func() { panicked = recover() != nil }()
+		cmp.Equal(x, y, cmp.RandomFunctionChecks(seed), cmp.Comparer(func(x, y int) bool {
+			ncalls++
+			return x >= y
+		}))
+		return ncalls, false
+	}
+	for seed := int64(0); seed < 10; seed++ {
+		n1, p1 := run(seed)
+		n2, p2 := run(seed)
+		if n1 != n2 || p1 != p2 {
+			t.Errorf("seed %d, mismatching results: (%d, %v) != (%d, %v)", seed, n1, p1, n2, p2)
+		}
+	}
+}
+
+// Test that paths passed to ReportAliases may be retained, even though Equal
+// reuses the steps for other nodes.
+func TestReportAliasesRetained(t *testing.T) {
+	type Pair struct{ A, B []int }
+	a, b := []int{1}, []int{2}
+	x := []Pair{{a, b}, {b, a}}
+	y := []Pair{{a, b}, {b, a}}
+
+	var got []cmp.Path
+	cmp.Equal(&x, &y, cmp.ReportAliases(func(p cmp.Path, _ uintptr) { got = append(got, p) }))
+	var gotStrs []string
+	for _, p := range got {
+		gotStrs = append(gotStrs, fmt.Sprintf("%#v", p))
+	}
+	want := []string{"(*{*[]cmp_test.Pair})[0].A", "(*{*[]cmp_test.Pair})[0].B", "(*{*[]cmp_test.Pair})[1].A", "(*{*[]cmp_test.Pair})[1].B"}
+	if !reflect.DeepEqual(gotStrs, want) {
+		t.Errorf("retained paths mismatch:\ngot:  %q\nwant: %q", gotStrs, want)
+	}
+}
+
+// Test that paths passed to FilterPath may be retained after Equal returns,
+// even though Equal reuses its state for later calls.
+func TestFilterPathRetained(t *testing.T) {
+	type Inner struct{ V *int }
+	type Outer struct{ In Inner }
+	one := 1
+	var saved cmp.Path
+	var want string
+	filter := cmp.FilterPath(func(p cmp.Path) bool {
+		if _, ok := p[len(p)-1].(cmp.Indirect); ok {
+			saved, want = p, fmt.Sprintf("%#v", p)
+		}
+		return false
+	}, cmp.Ignore())
+	cmp.Equal(Outer{Inner{&one}}, Outer{Inner{&one}}, filter)
+	if got := fmt.Sprintf("%#v", saved); got != want {
+		t.Errorf("retained path = %q, want %q", got, want)
+	}
+	cmp.Equal([][]int{{1, 2}}, [][]int{{1, 2}})
+	if got := fmt.Sprintf("%#v", saved); got != want {
+		t.Errorf("retained path after another Equal = %q, want %q", got, want)
+	}
+}
+
+func TestReportAliases(t *testing.T) {
+	slaps := []ts.Slap{{Name: "slap1"}, {Name: "slap2"}}
+	x := ts.Eagle{Name: "eagle", Slaps: slaps}
+	y := ts.Eagle{Name: "eagle", Slaps: slaps}
+
+	var got []string
+	opt := cmp.ReportAliases(func(p cmp.Path, addr uintptr) {
+		if addr != reflect.ValueOf(slaps).Pointer() {
+			t.Errorf("%#v: got address %#x, want %#x", p, addr, reflect.ValueOf(slaps).Pointer())
+		}
+		got = append(got, fmt.Sprintf("%#v", p))
+	})
+	if !cmp.Equal(x, y, opt) {
+		t.Errorf("Equal(x, y) = false, want true")
+	}
+	if want := []string{"{teststructs.Eagle}.Slaps"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("aliases mismatch:\ngot  %v\nwant %v", got, want)
+	}
+
+	// An equal copy of the slice is not an alias.
+	got = nil
+	y.Slaps = append([]ts.Slap(nil), slaps...)
+	if !cmp.Equal(x, y, opt) {
+		t.Errorf("Equal(x, y) = false, want true")
+	}
+	if len(got) > 0 {
+		t.Errorf("unexpected aliases: %v", got)
+	}
+
+	// Aliasing does not affect the result.
+	got = nil
+	x.Name = "eagle2"
+	y.Slaps = slaps
+	if cmp.Equal(x, y, opt) {
+		t.Errorf("Equal(x, y) = true, want false")
+	}
+	if len(got) != 1 {
+		t.Errorf("got %d aliases, want 1", len(got))
+	}
+}
+
+// Equaler is an interface with an Equal method, which is promoted to
+// any struct that embeds it.
+type Equaler interface {
+	Equal(Equaler) bool
+	Residue() int
+}
+
+type embedsEqualer struct{ Equaler }
+
+// mod10 is equal to another Equaler if they are congruent modulo 10.
+type mod10 int
+
+func (x mod10) Equal(y Equaler) bool { return y != nil && x.Residue() == y.Residue() }
+func (x mod10) Residue() int         { return int(x) % 10 }
+
+func comparerTests() []test {
+	const label = "Comparer"
+
+	return []test{{
+		label:    label,
+		x:        1,
+		y:        1,
+		wantDiff: "",
+	}, {
+		label:     label,
+		x:         1,
+		y:         1,
+		opts:      []cmp.Option{cmp.Ignore()},
+		wantPanic: "cannot use an unfiltered option",
+	}, {
+		label:     label,
+		x:         1,
+		y:         1,
+		opts:      []cmp.Option{cmp.Comparer(func(_, _ interface{}) bool { return true })},
+		wantPanic: "cannot use an unfiltered option",
+	}, {
+		label:     label,
+		x:         1,
+		y:         1,
+		opts:      []cmp.Option{cmp.Transformer("", func(x interface{}) interface{} { return x })},
+		wantPanic: "cannot use an unfiltered option",
+	}, {
+		label: label,
+		x:     1,
+		y:     1,
+		opts: []cmp.Option{
+			cmp.Comparer(func(x, y int) bool { return true }),
+			cmp.Transformer("", func(x int) float64 { return float64(x) }),
+		},
+		wantPanic: "ambiguous set of options",
+	}, {
+		label: label,
+		x:     1,
+		y:     1,
+		opts: []cmp.Option{
+			cmp.FilterPath(func(p cmp.Path) bool {
+				return len(p) > 0 && p[len(p)-1].Type().Kind() == reflect.Int
+			}, cmp.Options{cmp.Ignore(), cmp.Ignore(), cmp.Ignore()}),
+			cmp.Comparer(func(x, y int) bool { return true }),
+			cmp.Transformer("", func(x int) float64 { return float64(x) }),
+		},
+	}, {
+		label:     label,
+		opts:      []cmp.Option{struct{ cmp.Option }{}},
+		wantPanic: "unknown option",
+	}, {
+		label:    label,
+		x:        struct{ A, B, C int }{1, 2, 3},
+		y:        struct{ A, B, C int }{1, 2, 3},
+		wantDiff: "",
+	}, {
+		label:    label,
+		x:        struct{ A, B, C int }{1, 2, 3},
+		y:        struct{ A, B, C int }{1, 2, 4},
+		wantDiff: "root.C:\n\t-: 3\n\t+: 4\n",
+	}, {
+		label:     label,
+		x:         struct{ a, b, c int }{1, 2, 3},
+		y:         struct{ a, b, c int }{1, 2, 4},
+		wantPanic: "cannot handle unexported field",
+	}, {
+		label: label,
+		x:     &struct{ A *int }{intPtr(4)},
+		y:     &struct{ A *int }{intPtr(4)},
+	}, {
+		label:    label,
+		x:        &struct{ A *int }{intPtr(4)},
+		y:        &struct{ A *int }{intPtr(5)},
+		wantDiff: "*root.A:\n\t-: 4\n\t+: 5\n",
+	}, {
+		label: label,
+		x:     &struct{ A *int }{intPtr(4)},
+		y:     &struct{ A *int }{intPtr(5)},
+		opts: []cmp.Option{
+			cmp.Comparer(func(x, y int) bool { return true }),
+		},
+	}, {
+		label: label,
+		x:     &struct{ A *int }{intPtr(4)},
+		y:     &struct{ A *int }{intPtr(5)},
+		opts: []cmp.Option{
+			cmp.Comparer(func(x, y *int) bool { return x != nil && y != nil }),
+		},
+	}, {
+		label: label,
+		x:     &struct{ R *bytes.Buffer }{},
+		y:     &struct{ R *bytes.Buffer }{},
+	}, {
+		label:    label,
+		x:        &struct{ R *bytes.Buffer }{new(bytes.Buffer)},
+		y:        &struct{ R *bytes.Buffer }{},
+		wantDiff: "root.R:\n\t-: \"\"\n\t+: <nil>\n",
+	}, {
+		label: label,
+		x:     &struct{ R *bytes.Buffer }{new(bytes.Buffer)},
+		y:     &struct{ R *bytes.Buffer }{},
+		opts: []cmp.Option{
+			cmp.Comparer(func(x, y io.Reader) bool { return true }),
+		},
+	}, {
+		label:     label,
+		x:         &struct{ R bytes.Buffer }{},
+		y:         &struct{ R bytes.Buffer }{},
+		wantPanic: "cannot handle unexported field",
+	}, {
+		label: label,
+		x:     &struct{ R bytes.Buffer }{},
+		y:     &struct{ R bytes.Buffer }{},
+		opts: []cmp.Option{
+			cmp.Comparer(func(x, y io.Reader) bool { return true }),
+		},
+		wantPanic: "cannot handle unexported field",
+	}, {
+		label: label,
+		x:     &struct{ R bytes.Buffer }{},
+		y:     &struct{ R bytes.Buffer }{},
+		opts: []cmp.Option{
+			cmp.Transformer("Ref", func(x bytes.Buffer) *bytes.Buffer { return &x }),
+			cmp.Comparer(func(x, y io.Reader) bool { return true }),
+		},
+	}, {
+		label:     label,
+		x:         []*regexp.Regexp{nil, regexp.MustCompile("a*b*c*")},
+		y:         []*regexp.Regexp{nil, regexp.MustCompile("a*b*c*")},
+		wantPanic: "cannot handle unexported field",
+	}, {
+		label:    label,
+		x:        []*regexp.Regexp{nil, regexp.MustCompile("a*b*c*")},
+		y:        []*regexp.Regexp{nil, regexp.MustCompile("a*b*c*")},
+		opts:     []cmp.Option{cmp.Comparer(equalRegexp)},
+		wantDiff: "",
+	}, {
+		label:    label,
+		x:        []*regexp.Regexp{nil, regexp.MustCompile("a*b*c*")},
+		y:        []*regexp.Regexp{nil, regexp.MustCompile("a*b*d*")},
+		opts:     []cmp.Option{cmp.Comparer(equalRegexp)},
+		wantDiff: "{[]*regexp.Regexp}[1]:\n\t-: \"a*b*c*\"\n\t+: \"a*b*d*\"\n",
+	}, {
+		label: label,
+		x: func() ***int {
+			a := 0
+			b := &a
+			c := &b
+			return &c
+		}(),
+		y: func() ***int {
+			a := 0
+			b := &a
+			c := &b
+			return &c
+		}(),
+	}, {
+		label: label,
+		x: func() ***int {
+			a := 0
+			b := &a
+			c := &b
+			return &c
+		}(),
+		y: func() ***int {
+			a := 1
+			b := &a
+			c := &b
+			return &c
+		}(),
+		wantDiff: `
+***{***int}:
+	-: 0
+	+: 1`,
+	}, {
+		label: label,
+		x:     []int{1, 2, 3, 4, 5}[:3],
+		y:     []int{1, 2, 3},
+	}, {
+		label: label,
+		x:     struct{ fmt.Stringer }{bytes.NewBufferString("hello")},
+		y:     struct{ fmt.Stringer }{regexp.MustCompile("hello")},
+		opts:  []cmp.Option{cmp.Comparer(func(x, y fmt.Stringer) bool { return x.String() == y.String() })},
+	}, {
+		label: label,
+		x:     struct{ fmt.Stringer }{bytes.NewBufferString("hello")},
+		y:     struct{ fmt.Stringer }{regexp.MustCompile("hello2")},
+		opts:  []cmp.Option{cmp.Comparer(func(x, y fmt.Stringer) bool { return x.String() == y.String() })},
+		wantDiff: `
+root:
+	-: "hello"
+	+: "hello2"`,
+	}, {
+		label: label,
+		x:     struct{ fmt.Stringer }{},
+		y:     struct{ fmt.Stringer }{},
+	}, {
+		label: label,
+		x:     struct{ fmt.Stringer }{},
+		y:     struct{ fmt.Stringer }{bytes.NewBufferString("hello")},
+		wantDiff: `
+root.Stringer:
+	-: fmt.Stringer(nil)
+	+: (*bytes.Buffer)("hello")`,
+	}, {
+		label: label,
+		x:     embedsEqualer{mod10(1)},
+		y:     embedsEqualer{mod10(11)},
+	}, {
+		label: label,
+		x:     embedsEqualer{mod10(1)},
+		y:     embedsEqualer{mod10(2)},
+		wantDiff: `
+{cmp_test.embedsEqualer}:
+	-: cmp_test.embedsEqualer{Equaler: 1}
+	+: cmp_test.embedsEqualer{Equaler: 2}`,
+	}, {
+		label: label,
+		x:     embedsEqualer{},
+		y:     embedsEqualer{},
+	}, {
+		label: label,
+		x:     embedsEqualer{mod10(1)},
+		y:     embedsEqualer{},
+		wantDiff: `
+{cmp_test.embedsEqualer}.Equaler:
+	-: cmp_test.mod10(1)
+	+: cmp_test.Equaler(nil)`,
+	}, {
+		label: label,
+		x:     make([]int, 1000),
+		y:     make([]int, 1000),
+		opts: []cmp.Option{
+			cmp.Comparer(func(_, _ int) bool {
+				return rand.Intn(2) == 0
+			}),
+		},
+		wantPanic: "non-deterministic or non-symmetric function detected",
+	}, {
+		label: label,
+		x:     make([]int, 1000),
+		y:     make([]int, 1000),
+		opts: []cmp.Option{
+			cmp.FilterValues(func(_, _ int) bool {
+				return rand.Intn(2) == 0
+			}, cmp.Ignore()),
+		},
+		wantPanic: "non-deterministic or non-symmetric function detected",
+	}, {
+		label: label,
+		x:     make([]int, 1000),
+		y:     make([]int, 1000),
+		opts: []cmp.Option{
+			cmp.Comparer(func(_, _ int) bool {
+				return rand.Intn(2) == 0
+			}),
+			cmp.AlwaysCheckFunctions(),
+		},
+		wantPanic: "non-deterministic or non-symmetric function detected",
+	}, {
+		label: label,
+		x:     make([]int, 1000),
+		y:     make([]int, 1000),
+		opts: []cmp.Option{
+			cmp.FilterValues(func(_, _ int) bool {
+				return rand.Intn(2) == 0
+			}, cmp.Ignore()),
+			cmp.AlwaysCheckFunctions(),
+		},
+		wantPanic: "non-deterministic or non-symmetric function detected",
+	}, {
+		label: label,
+		x:     make([]int, 1000),
+		y:     make([]int, 1000),
+		opts: []cmp.Option{
+			cmp.Comparer(func(_, _ int) bool { return true }),
+			cmp.AlwaysCheckFunctions(),
+		},
+	}, {
+		label: label,
+		x:     rand.Perm(1000),
+		y:     make([]int, 1000),
+		opts: []cmp.Option{
+			cmp.Comparer(func(x, y int) bool { return x >= y }),
+		},
+		wantPanic: "non-deterministic or non-symmetric function detected",
+	}, {
+		label: label,
+		x:     rand.Perm(1000),
+		y:     make([]int, 1000),
+		opts: []cmp.Option{
+			cmp.Comparer(func(x, y int) bool { return x >= y }),
+			cmp.DisableFunctionChecks(),
+		},
+	}, {
+		label: label,
+		x:     struct{ A, B *int }{intPtr(1), nil},
+		y:     struct{ A, B *int }{intPtr(1), intPtr(2)},
+		opts: []cmp.Option{
+			cmp.Comparer(func(x, y *int) bool {
+				if x == nil && y == nil {
+					return true
+				}
+				return *x == *y
+			}),
+		},
+		wantPanic: "panicked at root.B with inputs",
+	}, {
+		label: label,
+		x:     struct{ A, B float64 }{1, 2},
+		y:     struct{ A, B float64 }{1, 2},
+		opts: []cmp.Option{
+			cmp.Comparer(func(x, y float64) bool {
+				if x == 2 {
+					panic("two")
+				}
+				return x == y
+			}),
+		},
+		wantPanic: "panicked at root.B with inputs:\n\targ0: 2\n\targ1: 2\npanic: two",
+	}, {
+		label: label,
+		x:     struct{ A *int }{intPtr(1)},
+		y:     struct{ A *int }{intPtr(1)},
+		opts: []cmp.Option{
+			cmp.Comparer(func(x, y *int) bool { return *x == *y }),
+		},
+		wantPanic: "panicked when called with nil pointers",
+	}, {
+		label: label,
+		x:     struct{ A *int }{intPtr(1)},
+		y:     struct{ A *int }{intPtr(1)},
+		opts: []cmp.Option{
+			cmp.Comparer(func(x, y *int) bool { return *x == *y }),
+			cmp.DisableNilProbe(),
+		},
+	}}
+}
+
+func wordCount(s string) int { return len(strings.Fields(s)) }
+
+func transformerTests() []test {
+	const label = "Transformer/"
+
+	decodeBase64 := cmp.Transformer("Base64", func(s string) ([]byte, error) {
+		return base64.StdEncoding.DecodeString(s)
+	})
+
+	return []test{{
+		label: label,
+		x:     uint8(0),
+		y:     uint8(1),
+		opts: []cmp.Option{
+			cmp.Transformer("", func(in uint8) uint16 { return uint16(in) }),
+			cmp.Transformer("", func(in uint16) uint32 { return uint32(in) }),
+			cmp.Transformer("", func(in uint32) uint64 { return uint64(in) }),
+		},
+		wantDiff: `
+cmp_test.transformerTests.func4(cmp_test.transformerTests.func3(cmp_test.transformerTests.func2({uint8}))):
+	-: 0x00
+	+: 0x01`,
+	}, {
+		label: label,
+		x:     0,
+		y:     1,
+		opts: []cmp.Option{
+			cmp.Transformer("Halve", func(in int) int { return in / 2 }),
+			cmp.Transformer("Identity", func(in int) int { return in }),
+		},
+		wantPanic: "ambiguous set of options at {int} for type int:\n\tTransformer(Halve, ",
+	}, {
+		label: label,
+		x:     []int{0, -5, 0, -1},
+		y:     []int{1, 3, 0, -5},
+		opts: []cmp.Option{
+			cmp.FilterValues(
+				func(x, y int) bool { return x+y >= 0 },
+				cmp.Transformer("", func(in int) int64 { return int64(in / 2) }),
+			),
+			cmp.FilterValues(
+				func(x, y int) bool { return x+y < 0 },
+				cmp.Transformer("", func(in int) int64 { return int64(in) }),
+			),
+		},
+		wantDiff: `
+cmp_test.transformerTests.func10({[]int}[1]):
+	-: -5
+	+: 3
+cmp_test.transformerTests.func10({[]int}[3]):
+	-: -1
+	+: -5`,
+	}, {
+		label: label,
+		x:     0,
+		y:     1,
+		opts: []cmp.Option{
+			cmp.Transformer("", func(in int) interface{} {
+				if in == 0 {
+					return "string"
+				}
+				return in
+			}),
+		},
+		wantDiff: `
+cmp_test.transformerTests.func11({int}):
+	-: "string"
+	+: 1`,
+	}, {
+		label: label,
+		x:     "hello",
+		y:     "hello",
+		opts: []cmp.Option{
+			cmp.Transformer("ToBytes", func(in string) []byte { return []byte(in) }),
+			cmp.Transformer("ToString", func(in []byte) string { return string(in) }),
+		},
+		wantPanic: `recursive set of Transformers detected at ToBytes(ToString(ToBytes({string}))):
+	ToBytes: string => []uint8
+	ToString: []uint8 => string
+	ToBytes: string => []uint8
+`,
+	}, {
+		label: label,
+		x:     []int{3, 2, 1},
+		y:     []int{1, 2, 3},
+		opts: []cmp.Option{
+			cmp.Transformer("Sort", func(in []int) []int {
+				out := append([]int(nil), in...)
+				sort.Ints(out)
+				return out
+			}),
+		},
+		wantPanic: "recursive set of Transformers detected at Sort(Sort({[]int}))",
+	}, {
+		label: label,
+		x:     0,
+		y:     0,
+		opts: []cmp.Option{
+			cmp.Transformer("", func(in int) interface{} { return in }),
+		},
+		wantPanic: "recursive set of Transformers detected at cmp_test.transformerTests.func",
+	}, {
+		label: label + "ApplyOnce",
+		x:     []int{3, 2, 1},
+		y:     []int{1, 2, 3},
+		opts: []cmp.Option{
+			cmp.ApplyOnce(cmp.Transformer("Sort", func(in []int) []int {
+				out := append([]int(nil), in...)
+				sort.Ints(out)
+				return out
+			})),
+		},
+	}, {
+		label: label + "ApplyOnce",
+		x:     map[string][]int{"a": {3, 2, 1}, "b": {1}},
+		y:     map[string][]int{"a": {1, 2, 4}, "b": {1}},
+		opts: []cmp.Option{
+			cmp.FilterPath(func(p cmp.Path) bool { return len(p) > 1 },
+				cmp.ApplyOnce(cmp.Transformer("Sort", func(in []int) []int {
+					out := append([]int(nil), in...)
+					sort.Ints(out)
+					return out
+				}))),
+		},
+		wantDiff: `
+Sort({map[string][]int}["a"])[2]:
+	-: 3
+	+: 4`,
+	}, {
+		label: label + "ApplyOnce",
+		x:     "hello",
+		y:     "world",
+		opts: []cmp.Option{
+			cmp.ApplyOnce(cmp.Transformer("ToBytes", func(in string) []byte { return []byte(in) })),
+			cmp.Transformer("ToString", func(in []byte) string { return string(in) }),
+		},
+		wantDiff: `
+ToString(ToBytes({string})):
+	-: "hello"
+	+: "world"`,
+	}, {
+		label: label + "QualifiedName",
+		x:     []int{3, 2, 1},
+		y:     []int{1, 2, 4},
+		opts: []cmp.Option{
+			cmp.FilterPath(func(p cmp.Path) bool { return len(p) == 1 },
+				cmp.Transformer("mypkg.Sort", func(in []int) []int {
+					out := append([]int(nil), in...)
+					sort.Ints(out)
+					return out
+				})),
+		},
+		wantDiff: `
+mypkg.Sort({[]int})[2]:
+	-: 3
+	+: 4`,
+	}, {
+		label: label + "FuncName",
+		x:     "hello world",
+		y:     "hello there, world",
+		opts:  []cmp.Option{cmp.Transformer("", wordCount)},
+		wantDiff: `
+cmp_test.wordCount({string}):
+	-: 2
+	+: 3`,
+	}, {
+		label: label + "Fallible",
+		x:     struct{ Data string }{"aGVsbG8="},
+		y:     struct{ Data string }{"aGVsbG8h"},
+		opts:  []cmp.Option{decodeBase64},
+		wantDiff: `
+Base64(root.Data)[5]:
+	-: <non-existent>
+	+: 0x21`,
+	}, {
+		label: label + "Fallible",
+		x:     struct{ Data string }{"not base64!"},
+		y:     struct{ Data string }{"not base64?"},
+		opts:  []cmp.Option{decodeBase64},
+		wantDiff: `
+root.Data:
+	-: "not base64!"
+	+: "not base64?"`,
+	}, {
+		label: label + "Fallible",
+		x:     struct{ Data string }{"aGVsbG8="},
+		y:     struct{ Data string }{"aGVsbG8="},
+		opts:  []cmp.Option{decodeBase64, cmp.StrictTransformers()},
+	}, {
+		label:     label + "Fallible",
+		x:         struct{ Data string }{"aGVsbG8="},
+		y:         struct{ Data string }{"not base64?"},
+		opts:      []cmp.Option{decodeBase64, cmp.StrictTransformers()},
+		wantPanic: "transformer Base64 failed at root.Data: illegal base64 data",
+	}}
+}
+
+func embeddedTests() []test {
+	const label = "EmbeddedStruct/"
+
+	privateStruct := *new(ts.ParentStructA).PrivateStruct()
+
+	createStructA := func(i int) ts.ParentStructA {
+		s := ts.ParentStructA{}
+		s.PrivateStruct().Public = 1 + i
+		s.PrivateStruct().SetPrivate(2 + i)
+		return s
+	}
+
+	createStructB := func(i int) ts.ParentStructB {
+		s := ts.ParentStructB{}
+		s.PublicStruct.Public = 1 + i
+		s.PublicStruct.SetPrivate(2 + i)
+		return s
+	}
+
+	createStructC := func(i int) ts.ParentStructC {
+		s := ts.ParentStructC{}
+		s.PrivateStruct().Public = 1 + i
+		s.PrivateStruct().SetPrivate(2 + i)
+		s.Public = 3 + i
+		s.SetPrivate(4 + i)
+		return s
+	}
+
+	createStructD := func(i int) ts.ParentStructD {
+		s := ts.ParentStructD{}
+		s.PublicStruct.Public = 1 + i
+		s.PublicStruct.SetPrivate(2 + i)
+		s.Public = 3 + i
+		s.SetPrivate(4 + i)
+		return s
+	}
+
+	createStructE := func(i int) ts.ParentStructE {
+		s := ts.ParentStructE{}
+		s.PrivateStruct().Public = 1 + i
+		s.PrivateStruct().SetPrivate(2 + i)
+		s.PublicStruct.Public = 3 + i
+		s.PublicStruct.SetPrivate(4 + i)
+		return s
+	}
+
+	createStructF := func(i int) ts.ParentStructF {
+		s := ts.ParentStructF{}
+		s.PrivateStruct().Public = 1 + i
+		s.PrivateStruct().SetPrivate(2 + i)
+		s.PublicStruct.Public = 3 + i
+		s.PublicStruct.SetPrivate(4 + i)
+		s.Public = 5 + i
+		s.SetPrivate(6 + i)
+		return s
+	}
+
+	createStructG := func(i int) *ts.ParentStructG {
+		s := ts.NewParentStructG()
+		s.PrivateStruct().Public = 1 + i
+		s.PrivateStruct().SetPrivate(2 + i)
+		return s
+	}
+
+	createStructH := func(i int) *ts.ParentStructH {
+		s := ts.NewParentStructH()
+		s.PublicStruct.Public = 1 + i
+		s.PublicStruct.SetPrivate(2 + i)
+		return s
+	}
+
+	createStructI := func(i int) *ts.ParentStructI {
+		s := ts.NewParentStructI()
+		s.PrivateStruct().Public = 1 + i
+		s.PrivateStruct().SetPrivate(2 + i)
+		s.PublicStruct.Public = 3 + i
+		s.PublicStruct.SetPrivate(4 + i)
+		return s
+	}
+
+	createStructJ := func(i int) *ts.ParentStructJ {
+		s := ts.NewParentStructJ()
+		s.PrivateStruct().Public = 1 + i
+		s.PrivateStruct().SetPrivate(2 + i)
+		s.PublicStruct.Public = 3 + i
+		s.PublicStruct.SetPrivate(4 + i)
+		s.Private().Public = 5 + i
+		s.Private().SetPrivate(6 + i)
+		s.Public.Public = 7 + i
+		s.Public.SetPrivate(8 + i)
+		return s
+	}
+
+	return []test{{
+		label:     label + "ParentStructA",
+		x:         ts.ParentStructA{},
+		y:         ts.ParentStructA{},
+		wantPanic: "cannot handle unexported field",
+	}, {
+		label: label + "ParentStructA",
+		x:     ts.ParentStructA{},
+		y:     ts.ParentStructA{},
+		opts: []cmp.Option{
+			IgnoreUnexported(ts.ParentStructA{}),
+		},
+	}, {
+		label: label + "ParentStructA",
+		x:     createStructA(0),
+		y:     createStructA(0),
+		opts: []cmp.Option{
+			cmp.AllowUnexported(ts.ParentStructA{}),
+		},
+		wantPanic: "cannot handle unexported field",
+	}, {
+		label: label + "ParentStructA",
+		x:     createStructA(0),
+		y:     createStructA(0),
+		opts: []cmp.Option{
+			cmp.AllowUnexported(ts.ParentStructA{}, privateStruct),
+		},
+	}, {
+		label: label + "ParentStructA",
+		x:     createStructA(0),
+		y:     createStructA(1),
+		opts: []cmp.Option{
+			cmp.AllowUnexported(ts.ParentStructA{}, privateStruct),
+		},
+		wantDiff: `
+{teststructs.ParentStructA}.privateStruct.Public:
+	-: 1
+	+: 2
+{teststructs.ParentStructA}.privateStruct.private:
+	-: 2
+	+: 3`,
+	}, {
+		label: label + "ParentStructA",
+		x:     createStructA(0),
+		y:     createStructA(1),
+		opts: []cmp.Option{
+			cmp.AllowUnexported(ts.ParentStructA{}, reflect.TypeOf(privateStruct)),
+		},
+		wantDiff: `
+{teststructs.ParentStructA}.privateStruct.Public:
+	-: 1
+	+: 2
+{teststructs.ParentStructA}.privateStruct.private:
+	-: 2
+	+: 3`,
+	}, {
+		label: label + "ParentStructA",
+		x:     createStructA(0),
+		y:     createStructA(1),
+		opts: []cmp.Option{
+			cmp.AllowUnexported(reflect.TypeOf(ts.ParentStructA{})),
+			IgnoreUnexported(reflect.TypeOf(privateStruct)),
+		},
+		wantDiff: `
+{teststructs.ParentStructA}.privateStruct.Public:
+	-: 1
+	+: 2`,
+	}, {
+		label: label + "ParentStructB",
+		x:     ts.ParentStructB{},
+		y:     ts.ParentStructB{},
+		opts: []cmp.Option{
+			IgnoreUnexported(ts.ParentStructB{}),
+		},
+		wantPanic: "cannot handle unexported field",
+	}, {
+		label: label + "ParentStructB",
+		x:     ts.ParentStructB{},
+		y:     ts.ParentStructB{},
+		opts: []cmp.Option{
+			IgnoreUnexported(ts.ParentStructB{}),
+			IgnoreUnexported(ts.PublicStruct{}),
+		},
+	}, {
+		label: label + "ParentStructB",
+		x:     createStructB(0),
+		y:     createStructB(0),
+		opts: []cmp.Option{
+			cmp.AllowUnexported(ts.ParentStructB{}),
+		},
+		wantPanic: "cannot handle unexported field",
+	}, {
+		label: label + "ParentStructB",
+		x:     createStructB(0),
+		y:     createStructB(0),
+		opts: []cmp.Option{
+			cmp.AllowUnexported(ts.ParentStructB{}, ts.PublicStruct{}),
+		},
+	}, {
+		label: label + "ParentStructB",
+		x:     createStructB(0),
+		y:     createStructB(1),
+		opts: []cmp.Option{
+			cmp.AllowUnexported(ts.ParentStructB{}, ts.PublicStruct{}),
+		},
+		wantDiff: `
+{teststructs.ParentStructB}.PublicStruct.Public:
+	-: 1
+	+: 2
+{teststructs.ParentStructB}.PublicStruct.private:
+	-: 2
+	+: 3`,
+	}, {
+		label:     label + "ParentStructC",
+		x:         ts.ParentStructC{},
+		y:         ts.ParentStructC{},
+		wantPanic: "cannot handle unexported field",
+	}, {
+		label: label + "ParentStructC",
+		x:     ts.ParentStructC{},
+		y:     ts.ParentStructC{},
+		opts: []cmp.Option{
+			IgnoreUnexported(ts.ParentStructC{}),
+		},
+	}, {
+		label: label + "ParentStructC",
+		x:     createStructC(0),
+		y:     createStructC(0),
+		opts: []cmp.Option{
+			cmp.AllowUnexported(ts.ParentStructC{}),
+		},
+		wantPanic: "cannot handle unexported field",
+	}, {
+		label: label + "ParentStructC",
+		x:     createStructC(0),
+		y:     createStructC(0),
+		opts: []cmp.Option{
+			cmp.AllowUnexported(ts.ParentStructC{}, privateStruct),
+		},
+	}, {
+		label: label + "ParentStructC",
+		x:     createStructC(0),
+		y:     createStructC(1),
+		opts: []cmp.Option{
+			cmp.AllowUnexported(ts.ParentStructC{}, privateStruct),
+		},
+		wantDiff: `
+{teststructs.ParentStructC}.privateStruct.Public:
+	-: 1
+	+: 2
+{teststructs.ParentStructC}.privateStruct.private:
+	-: 2
+	+: 3
+{teststructs.ParentStructC}.Public:
+	-: 3
+	+: 4
+{teststructs.ParentStructC}.private:
+	-: 4
+	+: 5`,
+	}, {
+		label: label + "ParentStructD",
+		x:     ts.ParentStructD{},
+		y:     ts.ParentStructD{},
+		opts: []cmp.Option{
+			IgnoreUnexported(ts.ParentStructD{}),
+		},
+		wantPanic: "cannot handle unexported field",
+	}, {
+		label: label + "ParentStructD",
+		x:     ts.ParentStructD{},
+		y:     ts.ParentStructD{},
+		opts: []cmp.Option{
+			IgnoreUnexported(ts.ParentStructD{}),
+			IgnoreUnexported(ts.PublicStruct{}),
+		},
+	}, {
+		label: label + "ParentStructD",
+		x:     createStructD(0),
+		y:     createStructD(0),
+		opts: []cmp.Option{
+			cmp.AllowUnexported(ts.ParentStructD{}),
+		},
+		wantPanic: "cannot handle unexported field",
+	}, {
+		label: label + "ParentStructD",
+		x:     createStructD(0),
+		y:     createStructD(0),
+		opts: []cmp.Option{
+			cmp.AllowUnexported(ts.ParentStructD{}, ts.PublicStruct{}),
+		},
+	}, {
+		label: label + "ParentStructD",
+		x:     createStructD(0),
+		y:     createStructD(1),
+		opts: []cmp.Option{
+			cmp.AllowUnexported(ts.ParentStructD{}, ts.PublicStruct{}),
+		},
+		wantDiff: `
+{teststructs.ParentStructD}.PublicStruct.Public:
+	-: 1
+	+: 2
+{teststructs.ParentStructD}.PublicStruct.private:
+	-: 2
+	+: 3
+{teststructs.ParentStructD}.Public:
+	-: 3
+	+: 4
+{teststructs.ParentStructD}.private:
+	-: 4
+	+: 5`,
+	}, {
+		label: label + "ParentStructE",
+		x:     ts.ParentStructE{},
+		y:     ts.ParentStructE{},
+		opts: []cmp.Option{
+			IgnoreUnexported(ts.ParentStructE{}),
+		},
+		wantPanic: "cannot handle unexported field",
+	}, {
+		label: label + "ParentStructE",
+		x:     ts.ParentStructE{},
+		y:     ts.ParentStructE{},
+		opts: []cmp.Option{
+			IgnoreUnexported(ts.ParentStructE{}),
+			IgnoreUnexported(ts.PublicStruct{}),
+		},
+	}, {
+		label: label + "ParentStructE",
+		x:     createStructE(0),
+		y:     createStructE(0),
+		opts: []cmp.Option{
+			cmp.AllowUnexported(ts.ParentStructE{}),
+		},
+		wantPanic: "cannot handle unexported field",
+	}, {
+		label: label + "ParentStructE",
+		x:     createStructE(0),
+		y:     createStructE(0),
+		opts: []cmp.Option{
+			cmp.AllowUnexported(ts.ParentStructE{}, ts.PublicStruct{}),
+		},
+		wantPanic: "cannot handle unexported field",
+	}, {
+		label: label + "ParentStructE",
+		x:     createStructE(0),
+		y:     createStructE(0),
+		opts: []cmp.Option{
+			cmp.AllowUnexported(ts.ParentStructE{}, ts.PublicStruct{}, privateStruct),
+		},
+	}, {
+		label: label + "ParentStructE",
+		x:     createStructE(0),
+		y:     createStructE(1),
+		opts: []cmp.Option{
+			cmp.AllowUnexported(ts.ParentStructE{}, ts.PublicStruct{}, privateStruct),
+		},
+		wantDiff: `
+{teststructs.ParentStructE}.privateStruct.Public:
+	-: 1
+	+: 2
+{teststructs.ParentStructE}.privateStruct.private:
+	-: 2
+	+: 3
+{teststructs.ParentStructE}.PublicStruct.Public:
+	-: 3
+	+: 4
+{teststructs.ParentStructE}.PublicStruct.private:
+	-: 4
+	+: 5`,
+	}, {
+		label: label + "ParentStructF",
+		x:     ts.ParentStructF{},
+		y:     ts.ParentStructF{},
+		opts: []cmp.Option{
+			IgnoreUnexported(ts.ParentStructF{}),
+		},
+		wantPanic: "cannot handle unexported field",
+	}, {
+		label: label + "ParentStructF",
+		x:     ts.ParentStructF{},
+		y:     ts.ParentStructF{},
+		opts: []cmp.Option{
+			IgnoreUnexported(ts.ParentStructF{}),
+			IgnoreUnexported(ts.PublicStruct{}),
+		},
+	}, {
+		label: label + "ParentStructF",
+		x:     createStructF(0),
+		y:     createStructF(0),
+		opts: []cmp.Option{
+			cmp.AllowUnexported(ts.ParentStructF{}),
+		},
+		wantPanic: "cannot handle unexported field",
+	}, {
+		label: label + "ParentStructF",
+		x:     createStructF(0),
+		y:     createStructF(0),
+		opts: []cmp.Option{
+			cmp.AllowUnexported(ts.ParentStructF{}, ts.PublicStruct{}),
+		},
+		wantPanic: "cannot handle unexported field",
+	}, {
+		label: label + "ParentStructF",
+		x:     createStructF(0),
+		y:     createStructF(0),
+		opts: []cmp.Option{
+			cmp.AllowUnexported(ts.ParentStructF{}, ts.PublicStruct{}, privateStruct),
+		},
+	}, {
+		label: label + "ParentStructF",
+		x:     createStructF(0),
+		y:     createStructF(1),
+		opts: []cmp.Option{
+			cmp.AllowUnexported(ts.ParentStructF{}, ts.PublicStruct{}, privateStruct),
+		},
+		wantDiff: `
+{teststructs.ParentStructF}.privateStruct.Public:
+	-: 1
+	+: 2
+{teststructs.ParentStructF}.privateStruct.private:
+	-: 2
+	+: 3
+{teststructs.ParentStructF}.PublicStruct.Public:
+	-: 3
+	+: 4
+{teststructs.ParentStructF}.PublicStruct.private:
+	-: 4
+	+: 5
+{teststructs.ParentStructF}.Public:
+	-: 5
+	+: 6
+{teststructs.ParentStructF}.private:
+	-: 6
+	+: 7`,
+	}, {
+		label:     label + "ParentStructG",
+		x:         ts.ParentStructG{},
+		y:         ts.ParentStructG{},
+		wantPanic: "cannot handle unexported field",
+	}, {
+		label: label + "ParentStructG",
+		x:     ts.ParentStructG{},
+		y:     ts.ParentStructG{},
+		opts: []cmp.Option{
+			IgnoreUnexported(ts.ParentStructG{}),
+		},
+	}, {
+		label: label + "ParentStructG",
+		x:     createStructG(0),
+		y:     createStructG(0),
+		opts: []cmp.Option{
+			cmp.AllowUnexported(ts.ParentStructG{}),
+		},
+		wantPanic: "cannot handle unexported field",
+	}, {
+		label: label + "ParentStructG",
+		x:     createStructG(0),
+		y:     createStructG(0),
+		opts: []cmp.Option{
+			cmp.AllowUnexported(ts.ParentStructG{}, privateStruct),
+		},
+	}, {
+		label: label + "ParentStructG",
+		x:     createStructG(0),
+		y:     createStructG(1),
+		opts: []cmp.Option{
+			cmp.AllowUnexported(ts.ParentStructG{}, privateStruct),
+		},
+		wantDiff: `
+{*teststructs.ParentStructG}.privateStruct.Public:
+	-: 1
+	+: 2
+{*teststructs.ParentStructG}.privateStruct.private:
+	-: 2
+	+: 3`,
+	}, {
+		label: label + "ParentStructA",
+		x:     createStructA(0),
+		y:     createStructA(0),
+		opts: []cmp.Option{
+			cmp.AccessVia(ts.ParentStructA{}, "PrivateStruct"),
+		},
+		wantPanic: "cannot handle unexported field",
+	}, {
+		label: label + "ParentStructA",
+		x:     createStructA(0),
+		y:     createStructA(1),
+		opts: []cmp.Option{
+			cmp.AccessVia(ts.ParentStructA{}, "PrivateStruct"),
+			IgnoreUnexported(privateStruct),
+		},
+		wantDiff: `
+PrivateStruct({teststructs.ParentStructA}).Public:
+	-: 1
+	+: 2`,
+	}, {
+		label: label + "ParentStructG",
+		x:     createStructG(0),
+		y:     createStructG(0),
+		opts: []cmp.Option{
+			cmp.AccessVia(createStructG(0), "PrivateStruct"),
+			IgnoreUnexported(privateStruct),
+		},
+	}, {
+		label: label + "ParentStructG",
+		x:     createStructG(0),
+		y:     createStructG(1),
+		opts: []cmp.Option{
+			cmp.AccessVia(reflect.TypeOf(ts.ParentStructG{}), "PrivateStruct"),
+			IgnoreUnexported(privateStruct),
+		},
+		wantDiff: `
+PrivateStruct((*{*teststructs.ParentStructG})).Public:
+	-: 1
+	+: 2`,
+	}, {
+		label: label + "ParentStructH",
+		x:     ts.ParentStructH{},
+		y:     ts.ParentStructH{},
+	}, {
+		label:     label + "ParentStructH",
+		x:         createStructH(0),
+		y:         createStructH(0),
+		wantPanic: "cannot handle unexported field",
+	}, {
+		label: label + "ParentStructH",
+		x:     ts.ParentStructH{},
+		y:     ts.ParentStructH{},
+		opts: []cmp.Option{
+			IgnoreUnexported(ts.ParentStructH{}),
+		},
+	}, {
+		label: label + "ParentStructH",
+		x:     createStructH(0),
+		y:     createStructH(0),
+		opts: []cmp.Option{
+			cmp.AllowUnexported(ts.ParentStructH{}),
+		},
+		wantPanic: "cannot handle unexported field",
+	}, {
+		label: label + "ParentStructH",
+		x:     createStructH(0),
+		y:     createStructH(0),
+		opts: []cmp.Option{
+			cmp.AllowUnexported(ts.ParentStructH{}, ts.PublicStruct{}),
+		},
+	}, {
+		label: label + "ParentStructH",
+		x:     createStructH(0),
+		y:     createStructH(1),
+		opts: []cmp.Option{
+			cmp.AllowUnexported(ts.ParentStructH{}, ts.PublicStruct{}),
+		},
+		wantDiff: `
+{*teststructs.ParentStructH}.PublicStruct.Public:
+	-: 1
+	+: 2
+{*teststructs.ParentStructH}.PublicStruct.private:
+	-: 2
+	+: 3`,
+	}, {
+		label:     label + "ParentStructI",
+		x:         ts.ParentStructI{},
+		y:         ts.ParentStructI{},
+		wantPanic: "cannot handle unexported field",
+	}, {
+		label: label + "ParentStructI",
+		x:     ts.ParentStructI{},
+		y:     ts.ParentStructI{},
+		opts: []cmp.Option{
+			IgnoreUnexported(ts.ParentStructI{}),
+		},
+	}, {
+		label: label + "ParentStructI",
+		x:     createStructI(0),
+		y:     createStructI(0),
+		opts: []cmp.Option{
+			IgnoreUnexported(ts.ParentStructI{}),
+		},
+		wantPanic: "cannot handle unexported field",
+	}, {
+		label: label + "ParentStructI",
+		x:     createStructI(0),
+		y:     createStructI(0),
+		opts: []cmp.Option{
+			IgnoreUnexported(ts.ParentStructI{}, ts.PublicStruct{}),
+		},
+	}, {
+		label: label + "ParentStructI",
+		x:     createStructI(0),
+		y:     createStructI(0),
+		opts: []cmp.Option{
+			cmp.AllowUnexported(ts.ParentStructI{}),
+		},
+		wantPanic: "cannot handle unexported field",
+	}, {
+		label: label + "ParentStructI",
+		x:     createStructI(0),
+		y:     createStructI(0),
+		opts: []cmp.Option{
+			cmp.AllowUnexported(ts.ParentStructI{}, ts.PublicStruct{}, privateStruct),
+		},
+	}, {
+		label: label + "ParentStructI",
+		x:     createStructI(0),
+		y:     createStructI(1),
+		opts: []cmp.Option{
+			cmp.AllowUnexported(ts.ParentStructI{}, ts.PublicStruct{}, privateStruct),
+		},
+		wantDiff: `
+{*teststructs.ParentStructI}.privateStruct.Public:
+	-: 1
+	+: 2
+{*teststructs.ParentStructI}.privateStruct.private:
+	-: 2
+	+: 3
+{*teststructs.ParentStructI}.PublicStruct.Public:
+	-: 3
+	+: 4
+{*teststructs.ParentStructI}.PublicStruct.private:
+	-: 4
+	+: 5`,
+	}, {
+		label:     label + "ParentStructJ",
+		x:         ts.ParentStructJ{},
+		y:         ts.ParentStructJ{},
+		wantPanic: "cannot handle unexported field",
+	}, {
+		label: label + "ParentStructJ",
+		x:     ts.ParentStructJ{},
+		y:     ts.ParentStructJ{},
+		opts: []cmp.Option{
+			IgnoreUnexported(ts.ParentStructJ{}),
+		},
+		wantPanic: "cannot handle unexported field",
+	}, {
+		label: label + "ParentStructJ",
+		x:     ts.ParentStructJ{},
+		y:     ts.ParentStructJ{},
+		opts: []cmp.Option{
+			IgnoreUnexported(ts.ParentStructJ{}, ts.PublicStruct{}),
+		},
+	}, {
+		label: label + "ParentStructJ",
+		x:     createStructJ(0),
+		y:     createStructJ(0),
+		opts: []cmp.Option{
+			cmp.AllowUnexported(ts.ParentStructJ{}, ts.PublicStruct{}),
+		},
+		wantPanic: "cannot handle unexported field",
+	}, {
+		label: label + "ParentStructJ",
+		x:     createStructJ(0),
+		y:     createStructJ(0),
+		opts: []cmp.Option{
+			cmp.AllowUnexported(ts.ParentStructJ{}, ts.PublicStruct{}, privateStruct),
+		},
+	}, {
+		label: label + "ParentStructJ",
+		x:     createStructJ(0),
+		y:     createStructJ(1),
+		opts: []cmp.Option{
+			cmp.AllowUnexported(ts.ParentStructJ{}, ts.PublicStruct{}, privateStruct),
+		},
+		wantDiff: `
+{*teststructs.ParentStructJ}.privateStruct.Public:
+	-: 1
+	+: 2
+{*teststructs.ParentStructJ}.privateStruct.private:
+	-: 2
+	+: 3
+{*teststructs.ParentStructJ}.PublicStruct.Public:
+	-: 3
+	+: 4
+{*teststructs.ParentStructJ}.PublicStruct.private:
+	-: 4
+	+: 5
+{*teststructs.ParentStructJ}.Public.Public:
+	-: 7
+	+: 8
+{*teststructs.ParentStructJ}.Public.private:
+	-: 8
+	+: 9
+{*teststructs.ParentStructJ}.private.Public:
+	-: 5
+	+: 6
+{*teststructs.ParentStructJ}.private.private:
+	-: 6
+	+: 7`,
+	}}
+}
+
+func methodTests() []test {
+	const label = "EqualMethod/"
+
+	// A common mistake that the Equal method is on a pointer receiver,
+	// but only a non-pointer value is present in the struct.
+	// A transform can be used to forcibly reference the value.
+	derefTransform := cmp.FilterPath(func(p cmp.Path) bool {
+		if len(p) == 0 {
+			return false
+		}
+		t := p[len(p)-1].Type()
+		if _, ok := t.MethodByName("Equal"); ok || t.Kind() == reflect.Ptr {
+			return false
+		}
+		if m, ok := reflect.PtrTo(t).MethodByName("Equal"); ok {
+			tf := m.Func.Type()
+			return !tf.IsVariadic() && tf.NumIn() == 2 && tf.NumOut() == 1 &&
+				tf.In(0).AssignableTo(tf.In(1)) && tf.Out(0) == boolType
+		}
+		return false
+	}, cmp.Transformer("Ref", func(x interface{}) interface{} {
+		v := reflect.ValueOf(x)
+		vp := reflect.New(v.Type())
+		vp.Elem().Set(v)
+		return vp.Interface()
+	}))
+
+	// For each of these types, there is an Equal method defined, which always
+	// returns true, while the underlying data are fundamentally different.
+	// Since the method should be called, these are expected to be equal.
+	return []test{{
+		label: label + "StructA",
+		x:     ts.StructA{"NotEqual"},
+		y:     ts.StructA{"not_equal"},
+	}, {
+		label: label + "StructA",
+		x:     &ts.StructA{"NotEqual"},
+		y:     &ts.StructA{"not_equal"},
+	}, {
+		label: label + "StructB",
+		x:     ts.StructB{"NotEqual"},
+		y:     ts.StructB{"not_equal"},
+		wantDiff: `
+{teststructs.StructB}.X:
+	-: "NotEqual"
+	+: "not_equal"`,
+	}, {
+		label: label + "StructB",
+		x:     ts.StructB{"NotEqual"},
+		y:     ts.StructB{"not_equal"},
+		opts:  []cmp.Option{derefTransform},
+	}, {
+		label: label + "StructB",
+		x:     &ts.StructB{"NotEqual"},
+		y:     &ts.StructB{"not_equal"},
+	}, {
+		label: label + "StructC",
+		x:     ts.StructC{"NotEqual"},
+		y:     ts.StructC{"not_equal"},
+	}, {
+		label: label + "StructC",
+		x:     &ts.StructC{"NotEqual"},
+		y:     &ts.StructC{"not_equal"},
+	}, {
+		label: label + "StructD",
+		x:     ts.StructD{"NotEqual"},
+		y:     ts.StructD{"not_equal"},
+		wantDiff: `
+{teststructs.StructD}.X:
+	-: "NotEqual"
+	+: "not_equal"`,
+	}, {
+		label: label + "StructD",
+		x:     ts.StructD{"NotEqual"},
+		y:     ts.StructD{"not_equal"},
+		opts:  []cmp.Option{derefTransform},
+	}, {
+		label: label + "StructD",
+		x:     &ts.StructD{"NotEqual"},
+		y:     &ts.StructD{"not_equal"},
+	}, {
+		label: label + "StructE",
+		x:     ts.StructE{"NotEqual"},
+		y:     ts.StructE{"not_equal"},
+		wantDiff: `
+{teststructs.StructE}.X:
+	-: "NotEqual"
+	+: "not_equal"`,
+	}, {
+		label: label + "StructE",
+		x:     ts.StructE{"NotEqual"},
+		y:     ts.StructE{"not_equal"},
+		opts:  []cmp.Option{derefTransform},
+	}, {
+		label: label + "StructE",
+		x:     &ts.StructE{"NotEqual"},
+		y:     &ts.StructE{"not_equal"},
+	}, {
+		label: label + "StructF",
+		x:     ts.StructF{"NotEqual"},
+		y:     ts.StructF{"not_equal"},
+		wantDiff: `
+{teststructs.StructF}.X:
+	-: "NotEqual"
+	+: "not_equal"`,
+	}, {
+		label: label + "StructF",
+		x:     &ts.StructF{"NotEqual"},
+		y:     &ts.StructF{"not_equal"},
+	}, {
+		label: label + "StructA1",
+		x:     ts.StructA1{ts.StructA{"NotEqual"}, "equal"},
+		y:     ts.StructA1{ts.StructA{"not_equal"}, "equal"},
+	}, {
+		label:    label + "StructA1",
+		x:        ts.StructA1{ts.StructA{"NotEqual"}, "NotEqual"},
+		y:        ts.StructA1{ts.StructA{"not_equal"}, "not_equal"},
+		wantDiff: "{teststructs.StructA1}.X:\n\t-: \"NotEqual\"\n\t+: \"not_equal\"\n",
+	}, {
+		label: label + "StructA1",
+		x:     &ts.StructA1{ts.StructA{"NotEqual"}, "equal"},
+		y:     &ts.StructA1{ts.StructA{"not_equal"}, "equal"},
+	}, {
+		label:    label + "StructA1",
+		x:        &ts.StructA1{ts.StructA{"NotEqual"}, "NotEqual"},
+		y:        &ts.StructA1{ts.StructA{"not_equal"}, "not_equal"},
+		wantDiff: "{*teststructs.StructA1}.X:\n\t-: \"NotEqual\"\n\t+: \"not_equal\"\n",
+	}, {
+		label: label + "StructB1",
+		x:     ts.StructB1{ts.StructB{"NotEqual"}, "equal"},
+		y:     ts.StructB1{ts.StructB{"not_equal"}, "equal"},
+		opts:  []cmp.Option{derefTransform},
+	}, {
+		label:    label + "StructB1",
+		x:        ts.StructB1{ts.StructB{"NotEqual"}, "NotEqual"},
+		y:        ts.StructB1{ts.StructB{"not_equal"}, "not_equal"},
+		opts:     []cmp.Option{derefTransform},
+		wantDiff: "{teststructs.StructB1}.X:\n\t-: \"NotEqual\"\n\t+: \"not_equal\"\n",
+	}, {
+		label: label + "StructB1",
+		x:     &ts.StructB1{ts.StructB{"NotEqual"}, "equal"},
+		y:     &ts.StructB1{ts.StructB{"not_equal"}, "equal"},
+		opts:  []cmp.Option{derefTransform},
+	}, {
+		label:    label + "StructB1",
+		x:        &ts.StructB1{ts.StructB{"NotEqual"}, "NotEqual"},
+		y:        &ts.StructB1{ts.StructB{"not_equal"}, "not_equal"},
+		opts:     []cmp.Option{derefTransform},
+		wantDiff: "{*teststructs.StructB1}.X:\n\t-: \"NotEqual\"\n\t+: \"not_equal\"\n",
+	}, {
+		label: label + "StructC1",
+		x:     ts.StructC1{ts.StructC{"NotEqual"}, "NotEqual"},
+		y:     ts.StructC1{ts.StructC{"not_equal"}, "not_equal"},
+	}, {
+		label: label + "StructC1",
+		x:     &ts.StructC1{ts.StructC{"NotEqual"}, "NotEqual"},
+		y:     &ts.StructC1{ts.StructC{"not_equal"}, "not_equal"},
+	}, {
+		label: label + "StructD1",
+		x:     ts.StructD1{ts.StructD{"NotEqual"}, "NotEqual"},
+		y:     ts.StructD1{ts.StructD{"not_equal"}, "not_equal"},
+		wantDiff: `
+{teststructs.StructD1}.StructD.X:
+	-: "NotEqual"
+	+: "not_equal"
+{teststructs.StructD1}.X:
+	-: "NotEqual"
+	+: "not_equal"`,
+	}, {
+		label: label + "StructD1",
+		x:     ts.StructD1{ts.StructD{"NotEqual"}, "NotEqual"},
+		y:     ts.StructD1{ts.StructD{"not_equal"}, "not_equal"},
+		opts:  []cmp.Option{derefTransform},
+	}, {
+		label: label + "StructD1",
+		x:     &ts.StructD1{ts.StructD{"NotEqual"}, "NotEqual"},
+		y:     &ts.StructD1{ts.StructD{"not_equal"}, "not_equal"},
+	}, {
+		label: label + "StructE1",
+		x:     ts.StructE1{ts.StructE{"NotEqual"}, "NotEqual"},
+		y:     ts.StructE1{ts.StructE{"not_equal"}, "not_equal"},
+		wantDiff: `
+{teststructs.StructE1}.StructE.X:
+	-: "NotEqual"
+	+: "not_equal"
+{teststructs.StructE1}.X:
+	-: "NotEqual"
+	+: "not_equal"`,
+	}, {
+		label: label + "StructE1",
+		x:     ts.StructE1{ts.StructE{"NotEqual"}, "NotEqual"},
+		y:     ts.StructE1{ts.StructE{"not_equal"}, "not_equal"},
+		opts:  []cmp.Option{derefTransform},
+	}, {
+		label: label + "StructE1",
+		x:     &ts.StructE1{ts.StructE{"NotEqual"}, "NotEqual"},
+		y:     &ts.StructE1{ts.StructE{"not_equal"}, "not_equal"},
+	}, {
+		label: label + "StructF1",
+		x:     ts.StructF1{ts.StructF{"NotEqual"}, "NotEqual"},
+		y:     ts.StructF1{ts.StructF{"not_equal"}, "not_equal"},
+		wantDiff: `
+{teststructs.StructF1}.StructF.X:
+	-: "NotEqual"
+	+: "not_equal"
+{teststructs.StructF1}.X:
+	-: "NotEqual"
+	+: "not_equal"`,
+	}, {
+		label: label + "StructF1",
+		x:     &ts.StructF1{ts.StructF{"NotEqual"}, "NotEqual"},
+		y:     &ts.StructF1{ts.StructF{"not_equal"}, "not_equal"},
+	}, {
+		label: label + "StructA2",
+		x:     ts.StructA2{&ts.StructA{"NotEqual"}, "equal"},
+		y:     ts.StructA2{&ts.StructA{"not_equal"}, "equal"},
+	}, {
+		label:    label + "StructA2",
+		x:        ts.StructA2{&ts.StructA{"NotEqual"}, "NotEqual"},
+		y:        ts.StructA2{&ts.StructA{"not_equal"}, "not_equal"},
+		wantDiff: "{teststructs.StructA2}.X:\n\t-: \"NotEqual\"\n\t+: \"not_equal\"\n",
+	}, {
+		label: label + "StructA2",
+		x:     &ts.StructA2{&ts.StructA{"NotEqual"}, "equal"},
+		y:     &ts.StructA2{&ts.StructA{"not_equal"}, "equal"},
+	}, {
+		label:    label + "StructA2",
+		x:        &ts.StructA2{&ts.StructA{"NotEqual"}, "NotEqual"},
+		y:        &ts.StructA2{&ts.StructA{"not_equal"}, "not_equal"},
+		wantDiff: "{*teststructs.StructA2}.X:\n\t-: \"NotEqual\"\n\t+: \"not_equal\"\n",
+	}, {
+		label: label + "StructB2",
+		x:     ts.StructB2{&ts.StructB{"NotEqual"}, "equal"},
+		y:     ts.StructB2{&ts.StructB{"not_equal"}, "equal"},
+	}, {
+		label:    label + "StructB2",
+		x:        ts.StructB2{&ts.StructB{"NotEqual"}, "NotEqual"},
+		y:        ts.StructB2{&ts.StructB{"not_equal"}, "not_equal"},
+		wantDiff: "{teststructs.StructB2}.X:\n\t-: \"NotEqual\"\n\t+: \"not_equal\"\n",
+	}, {
+		label: label + "StructB2",
+		x:     &ts.StructB2{&ts.StructB{"NotEqual"}, "equal"},
+		y:     &ts.StructB2{&ts.StructB{"not_equal"}, "equal"},
+	}, {
+		label:    label + "StructB2",
+		x:        &ts.StructB2{&ts.StructB{"NotEqual"}, "NotEqual"},
+		y:        &ts.StructB2{&ts.StructB{"not_equal"}, "not_equal"},
+		wantDiff: "{*teststructs.StructB2}.X:\n\t-: \"NotEqual\"\n\t+: \"not_equal\"\n",
+	}, {
+		label: label + "StructC2",
+		x:     ts.StructC2{&ts.StructC{"NotEqual"}, "NotEqual"},
+		y:     ts.StructC2{&ts.StructC{"not_equal"}, "not_equal"},
+	}, {
+		label: label + "StructC2",
+		x:     &ts.StructC2{&ts.StructC{"NotEqual"}, "NotEqual"},
+		y:     &ts.StructC2{&ts.StructC{"not_equal"}, "not_equal"},
+	}, {
+		label: label + "StructD2",
+		x:     ts.StructD2{&ts.StructD{"NotEqual"}, "NotEqual"},
+		y:     ts.StructD2{&ts.StructD{"not_equal"}, "not_equal"},
+	}, {
+		label: label + "StructD2",
+		x:     &ts.StructD2{&ts.StructD{"NotEqual"}, "NotEqual"},
+		y:     &ts.StructD2{&ts.StructD{"not_equal"}, "not_equal"},
+	}, {
+		label: label + "StructE2",
+		x:     ts.StructE2{&ts.StructE{"NotEqual"}, "NotEqual"},
+		y:     ts.StructE2{&ts.StructE{"not_equal"}, "not_equal"},
+	}, {
+		label: label + "StructE2",
+		x:     &ts.StructE2{&ts.StructE{"NotEqual"}, "NotEqual"},
+		y:     &ts.StructE2{&ts.StructE{"not_equal"}, "not_equal"},
+	}, {
+		label: label + "StructF2",
+		x:     ts.StructF2{&ts.StructF{"NotEqual"}, "NotEqual"},
+		y:     ts.StructF2{&ts.StructF{"not_equal"}, "not_equal"},
+	}, {
+		label: label + "StructF2",
+		x:     &ts.StructF2{&ts.StructF{"NotEqual"}, "NotEqual"},
+		y:     &ts.StructF2{&ts.StructF{"not_equal"}, "not_equal"},
+	}, {
+		label:    label + "StructNo",
+		x:        ts.StructNo{"NotEqual"},
+		y:        ts.StructNo{"not_equal"},
+		wantDiff: "{teststructs.StructNo}.X:\n\t-: \"NotEqual\"\n\t+: \"not_equal\"\n",
+	}, {
+		label: label + "AssignA",
+		x:     ts.AssignA(func() int { return 0 }),
+		y:     ts.AssignA(func() int { return 1 }),
+	}, {
+		label: label + "AssignB",
+		x:     ts.AssignB(struct{ A int }{0}),
+		y:     ts.AssignB(struct{ A int }{1}),
+	}, {
+		label: label + "AssignC",
+		x:     ts.AssignC(make(chan bool)),
+		y:     ts.AssignC(make(chan bool)),
+	}, {
+		label: label + "AssignD",
+		x:     ts.AssignD(make(chan bool)),
+		y:     ts.AssignD(make(chan bool)),
+	}, {
+		label: label + "UnexportedValue",
+		x:     struct{ C ts.Comparable }{ts.NewComparableValue("hello")},
+		y:     struct{ C ts.Comparable }{ts.NewComparableValue("HELLO")},
+	}, {
+		label: label + "UnexportedValue",
+		x:     struct{ C ts.Comparable }{ts.NewComparableValue("hello")},
+		y:     struct{ C ts.Comparable }{ts.NewComparableValue("goodbye")},
+		wantDiff: `
+root.C.(teststructs.comparableValue):
+	-: teststructs.comparableValue{s: "hello"}
+	+: teststructs.comparableValue{s: "goodbye"}`,
+	}, {
+		label: label + "UnexportedPointer",
+		x:     struct{ C ts.Comparable }{ts.NewComparablePointer("hello")},
+		y:     struct{ C ts.Comparable }{ts.NewComparablePointer("HELLO")},
+	}, {
+		label: label + "UnexportedPointer",
+		x:     struct{ C ts.Comparable }{ts.NewComparablePointer("hello")},
+		y:     struct{ C ts.Comparable }{ts.NewComparablePointer("goodbye")},
+		wantDiff: `
+root.C.(teststructs.comparablePointer):
+	-: teststructs.comparablePointer{s: "hello"}
+	+: teststructs.comparablePointer{s: "goodbye"}`,
+	}, {
+		label: label + "UnexportedPointer",
+		x:     []ts.Comparable{ts.NewComparablePointer("hello"), ts.NewComparableValue("hello")},
+		y:     []ts.Comparable{ts.NewComparablePointer("Hello"), ts.NewComparableValue("Hello")},
+	}}
+}
+
+func project1Tests() []test {
+	const label = "Project1"
+
+	ignoreUnexported := IgnoreUnexported(
+		ts.EagleImmutable{},
+		ts.DreamerImmutable{},
+		ts.SlapImmutable{},
+		ts.GoatImmutable{},
+		ts.DonkeyImmutable{},
+		ts.LoveRadius{},
+		ts.SummerLove{},
+		ts.SummerLoveSummary{},
+	)
+
+	createEagle := func() ts.Eagle {
+		return ts.Eagle{
+			Name:   "eagle",
+			Hounds: []string{"buford", "tannen"},
+			Desc:   "some description",
+			Dreamers: []ts.Dreamer{{}, {
+				Name: "dreamer2",
+				Animal: []interface{}{
+					ts.Goat{
+						Target: "corporation",
+						Immutable: &ts.GoatImmutable{
+							ID:      "southbay",
+							State:   (*pb.Goat_States)(intPtr(5)),
+							Started: now,
+						},
+					},
+					ts.Donkey{},
+				},
+				Amoeba: 53,
+			}},
+			Slaps: []ts.Slap{{
+				Name: "slapID",
+				Args: &pb.MetaData{Stringer: pb.Stringer{"metadata"}},
+				Immutable: &ts.SlapImmutable{
+					ID:       "immutableSlap",
+					MildSlap: true,
+					Started:  now,
+					LoveRadius: &ts.LoveRadius{
+						Summer: &ts.SummerLove{
+							Summary: &ts.SummerLoveSummary{
+								Devices:    []string{"foo", "bar", "baz"},
+								ChangeType: []pb.SummerType{1, 2, 3},
+							},
+						},
+					},
+				},
+			}},
+			Immutable: &ts.EagleImmutable{
+				ID:          "eagleID",
+				Birthday:    now,
+				MissingCall: (*pb.Eagle_MissingCalls)(intPtr(55)),
+			},
+		}
+	}
+
+	return []test{{
+		label: label,
+		x: ts.Eagle{Slaps: []ts.Slap{{
+			Args: &pb.MetaData{Stringer: pb.Stringer{"metadata"}},
+		}}},
+		y: ts.Eagle{Slaps: []ts.Slap{{
+			Args: &pb.MetaData{Stringer: pb.Stringer{"metadata"}},
+		}}},
+		wantPanic: "cannot handle unexported field",
+	}, {
+		label: label,
+		x: ts.Eagle{Slaps: []ts.Slap{{
+			Args: &pb.MetaData{Stringer: pb.Stringer{"metadata"}},
+		}}},
+		y: ts.Eagle{Slaps: []ts.Slap{{
+			Args: &pb.MetaData{Stringer: pb.Stringer{"metadata"}},
+		}}},
+		opts:     []cmp.Option{cmp.Comparer(pb.Equal)},
+		wantDiff: "",
+	}, {
+		label: label,
+		x: ts.Eagle{Slaps: []ts.Slap{{}, {}, {}, {}, {
+			Args: &pb.MetaData{Stringer: pb.Stringer{"metadata"}},
+		}}},
+		y: ts.Eagle{Slaps: []ts.Slap{{}, {}, {}, {}, {
+			Args: &pb.MetaData{Stringer: pb.Stringer{"metadata2"}},
+		}}},
+		opts:     []cmp.Option{cmp.Comparer(pb.Equal)},
+		wantDiff: "{teststructs.Eagle}.Slaps[4].Args:\n\t-: \"metadata\"\n\t+: \"metadata2\"\n",
+	}, {
+		label: label,
+		x:     createEagle(),
+		y:     createEagle(),
+		opts:  []cmp.Option{ignoreUnexported, cmp.Comparer(pb.Equal)},
+	}, {
+		label: label,
+		x: func() ts.Eagle {
+			eg := createEagle()
+			eg.Dreamers[1].Animal[0].(ts.Goat).Immutable.ID = "southbay2"
+			eg.Dreamers[1].Animal[0].(ts.Goat).Immutable.State = (*pb.Goat_States)(intPtr(6))
+			eg.Slaps[0].Immutable.MildSlap = false
+			return eg
+		}(),
+		y: func() ts.Eagle {
+			eg := createEagle()
+			devs := eg.Slaps[0].Immutable.LoveRadius.Summer.Summary.Devices
+			eg.Slaps[0].Immutable.LoveRadius.Summer.Summary.Devices = devs[:1]
+			return eg
+		}(),
+		opts: []cmp.Option{ignoreUnexported, cmp.Comparer(pb.Equal)},
+		wantDiff: `
+{teststructs.Eagle}.Dreamers[1].Animal[0].(teststructs.Goat).Immutable.ID:
+	-: "southbay2"
+	+: "southbay"
+*{teststructs.Eagle}.Dreamers[1].Animal[0].(teststructs.Goat).Immutable.State:
+	-: 6
+	+: 5
+{teststructs.Eagle}.Slaps[0].Immutable.MildSlap:
+	-: false
+	+: true
+{teststructs.Eagle}.Slaps[0].Immutable.LoveRadius.Summer.Summary.Devices[1]:
+	-: "bar"
+	+: <non-existent>
+{teststructs.Eagle}.Slaps[0].Immutable.LoveRadius.Summer.Summary.Devices[2]:
+	-: "baz"
+	+: <non-existent>`,
+	}, {
+		label: label,
+		x: func() ts.Eagle {
+			eg := createEagle()
+			eg.Dreamers[1].Animal[0].(ts.Goat).Immutable.ID = "southbay2"
+			eg.Slaps[0].Immutable.MildSlap = false
+			return eg
+		}(),
+		y:    createEagle(),
+		opts: []cmp.Option{ignoreUnexported, cmp.Comparer(pb.Equal), cmp.Within("Slaps[0].Immutable")},
+		wantDiff: `
+{teststructs.Eagle}.Slaps[0].Immutable.MildSlap:
+	-: false
+	+: true`,
+	}, {
+		label: label,
+		x: func() ts.Eagle {
+			eg := createEagle()
+			eg.Dreamers[1].Animal[0].(ts.Goat).Immutable.ID = "southbay2"
+			eg.Slaps[0].Immutable.MildSlap = false
+			return eg
+		}(),
+		y:    createEagle(),
+		opts: []cmp.Option{cmp.Within(".Dreamers[1].Animal[0].Immutable.ID", "Slaps[1]")},
+		wantDiff: `
+{teststructs.Eagle}.Dreamers[1].Animal[0].(teststructs.Goat).Immutable.ID:
+	-: "southbay2"
+	+: "southbay"`,
+	}}
+}
+
+func project2Tests() []test {
+	const label = "Project2"
+
+	sortGerms := cmp.FilterValues(func(x, y []*pb.Germ) bool {
+		ok1 := sort.SliceIsSorted(x, func(i, j int) bool {
+			return x[i].String() < x[j].String()
+		})
+		ok2 := sort.SliceIsSorted(y, func(i, j int) bool {
+			return y[i].String() < y[j].String()
+		})
+		return !ok1 || !ok2
+	}, cmp.Transformer("Sort", func(in []*pb.Germ) []*pb.Germ {
+		out := append([]*pb.Germ(nil), in...) // Make copy
+		sort.Slice(out, func(i, j int) bool {
+			return out[i].String() < out[j].String()
+		})
+		return out
+	}))
+
+	equalDish := cmp.Comparer(func(x, y *ts.Dish) bool {
+		if x == nil || y == nil {
+			return x == nil && y == nil
+		}
+		px, err1 := x.Proto()
+		py, err2 := y.Proto()
+		if err1 != nil || err2 != nil {
+			return err1 == err2
+		}
+		return pb.Equal(px, py)
+	})
+
+	createBatch := func() ts.GermBatch {
+		return ts.GermBatch{
+			DirtyGerms: map[int32][]*pb.Germ{
+				17: []*pb.Germ{
+					&pb.Germ{Stringer: pb.Stringer{"germ1"}},
+				},
+				18: []*pb.Germ{
+					&pb.Germ{Stringer: pb.Stringer{"germ2"}},
+					&pb.Germ{Stringer: pb.Stringer{"germ3"}},
+					&pb.Germ{Stringer: pb.Stringer{"germ4"}},
+				},
+			},
+			GermMap: map[int32]*pb.Germ{
+				13: &pb.Germ{Stringer: pb.Stringer{"germ13"}},
+				21: &pb.Germ{Stringer: pb.Stringer{"germ21"}},
+			},
+			DishMap: map[int32]*ts.Dish{
+				0: ts.CreateDish(nil, io.EOF),
+				1: ts.CreateDish(nil, io.ErrUnexpectedEOF),
+				2: ts.CreateDish(&pb.Dish{Stringer: pb.Stringer{"dish"}}, nil),
+			},
+			HasPreviousResult: true,
+			DirtyID:           10,
+			GermStrain:        421,
+			InfectedAt:        now,
+		}
+	}
+
+	return []test{{
+		label:     label,
+		x:         createBatch(),
+		y:         createBatch(),
+		wantPanic: "cannot handle unexported field",
+	}, {
+		label: label,
+		x:     createBatch(),
+		y:     createBatch(),
+		opts:  []cmp.Option{cmp.Comparer(pb.Equal), sortGerms, equalDish},
+	}, {
+		label: label,
+		x:     createBatch(),
+		y: func() ts.GermBatch {
+			gb := createBatch()
+			s := gb.DirtyGerms[18]
+			s[0], s[1], s[2] = s[1], s[2], s[0]
+			return gb
+		}(),
+		opts: []cmp.Option{cmp.Comparer(pb.Equal), equalDish},
+		wantDiff: `
+{teststructs.GermBatch}.DirtyGerms[18][0]:
+	-: "germ2"
+	+: "germ3"
+{teststructs.GermBatch}.DirtyGerms[18][1]:
+	-: "germ3"
+	+: "germ4"
+{teststructs.GermBatch}.DirtyGerms[18][2]:
+	-: "germ4"
+	+: "germ2"`,
+	}, {
+		label: label,
+		x:     createBatch(),
+		y: func() ts.GermBatch {
+			gb := createBatch()
+			s := gb.DirtyGerms[18]
+			s[0], s[1], s[2] = s[1], s[2], s[0]
+			return gb
+		}(),
+		opts: []cmp.Option{cmp.Comparer(pb.Equal), sortGerms, equalDish},
+	}, {
+		label: label,
+		x: func() ts.GermBatch {
+			gb := createBatch()
+			delete(gb.DirtyGerms, 17)
+			gb.DishMap[1] = nil
+			return gb
+		}(),
+		y: func() ts.GermBatch {
+			gb := createBatch()
+			gb.DirtyGerms[18] = gb.DirtyGerms[18][:2]
+			gb.GermStrain = 22
+			return gb
+		}(),
+		opts: []cmp.Option{cmp.Comparer(pb.Equal), sortGerms, equalDish},
+		wantDiff: `
+{teststructs.GermBatch}.DirtyGerms[17]:
+	-: <non-existent>
+	+: []*testprotos.Germ{"germ1"}
+{teststructs.GermBatch}.DirtyGerms[18][2]:
+	-: "germ4"
+	+: <non-existent>
+{teststructs.GermBatch}.DishMap[1]:
+	-: (*teststructs.Dish)(nil)
+	+: &teststructs.Dish{err: &errors.errorString{s: "unexpected EOF"}}
+{teststructs.GermBatch}.GermStrain:
+	-: 421
+	+: 22`,
+	}, {
+		label: label,
+		x: func() ts.GermBatch {
+			gb := createBatch()
+			delete(gb.DirtyGerms, 17)
+			return gb
+		}(),
+		y:    createBatch(),
+		opts: []cmp.Option{cmp.Comparer(pb.Equal), sortGerms, equalDish, cmp.CompareSharedMapKeys()},
+	}, {
+		label: label,
+		x: func() ts.GermBatch {
+			gb := createBatch()
+			delete(gb.DirtyGerms, 17)
+			return gb
+		}(),
+		y: func() ts.GermBatch {
+			gb := createBatch()
+			gb.DirtyGerms[18] = gb.DirtyGerms[18][:2]
+			gb.DirtyGerms[19] = nil
+			delete(gb.DishMap, 0)
+			return gb
+		}(),
+		opts: []cmp.Option{cmp.Comparer(pb.Equal), sortGerms, equalDish, cmp.CompareSharedMapKeys()},
+		wantDiff: `
+{teststructs.GermBatch}.DirtyGerms[18][2]:
+	-: "germ4"
+	+: <non-existent>
+	(disregarded 0 map keys only in x and 2 map keys only in y)`,
+	}}
+}
+
+// isLocker reports whether the last step of p is a sync.Mutex.
+func isLocker(p cmp.Path) bool {
+	return len(p) > 0 && p[len(p)-1].Type() == mutexType
+}
+
+// project3Options returns the options used to compare ts.Dirt values.
+func project3Options() (allowVisibility, ignoreLocker, transformProtos, equalTable cmp.Option) {
+	allowVisibility = cmp.AllowUnexported(ts.Dirt{})
+
+	ignoreLocker = cmp.FilterPath(isLocker, cmp.Ignore())
+
+	transformProtos = cmp.Transformer("", func(x pb.Dirt) *pb.Dirt {
+		return &x
+	})
+
+	equalTable = cmp.Comparer(func(x, y ts.Table) bool {
+		tx, ok1 := x.(*ts.MockTable)
+		ty, ok2 := y.(*ts.MockTable)
+		if !ok1 || !ok2 {
+			panic("table type must be MockTable")
+		}
+		return cmp.Equal(tx.State(), ty.State())
+	})
+	return allowVisibility, ignoreLocker, transformProtos, equalTable
+}
+
+func project3Tests() []test {
+	const label = "Project3"
+
+	allowVisibility, ignoreLocker, transformProtos, equalTable := project3Options()
+
+	createDirt := func() (d ts.Dirt) {
+		d.SetTable(ts.CreateMockTable([]string{"a", "b", "c"}))
+		d.SetTimestamp(12345)
+		d.Discord = 554
+		d.Proto = pb.Dirt{Stringer: pb.Stringer{"proto"}}
+		d.SetWizard(map[string]*pb.Wizard{
+			"harry": &pb.Wizard{Stringer: pb.Stringer{"potter"}},
+			"albus": &pb.Wizard{Stringer: pb.Stringer{"dumbledore"}},
+		})
+		d.SetLastTime(54321)
+		return d
+	}
+
+	tests := []test{{
+		label:     label,
+		x:         createDirt(),
+		y:         createDirt(),
 		wantPanic: "cannot handle unexported field",
 	}, {
-		label: label + "ParentStructC",
-		x:     ts.ParentStructC{},
-		y:     ts.ParentStructC{},
-		opts: []cmp.Option{
-			IgnoreUnexported(ts.ParentStructC{}),
-		},
-	}, {
-		label: label + "ParentStructC",
-		x:     createStructC(0),
-		y:     createStructC(0),
-		opts: []cmp.Option{
-			cmp.AllowUnexported(ts.ParentStructC{}),
-		},
+		label:     label,
+		x:         createDirt(),
+		y:         createDirt(),
+		opts:      []cmp.Option{allowVisibility, ignoreLocker, cmp.Comparer(pb.Equal), equalTable},
 		wantPanic: "cannot handle unexported field",
 	}, {
-		label: label + "ParentStructC",
-		x:     createStructC(0),
-		y:     createStructC(0),
-		opts: []cmp.Option{
-			cmp.AllowUnexported(ts.ParentStructC{}, privateStruct),
-		},
+		label: label,
+		x:     createDirt(),
+		y:     createDirt(),
+		opts:  []cmp.Option{allowVisibility, transformProtos, ignoreLocker, cmp.Comparer(pb.Equal), equalTable},
 	}, {
-		label: label + "ParentStructC",
-		x:     createStructC(0),
-		y:     createStructC(1),
-		opts: []cmp.Option{
-			cmp.AllowUnexported(ts.ParentStructC{}, privateStruct),
-		},
+		label: label,
+		x: func() ts.Dirt {
+			d := createDirt()
+			d.SetTable(ts.CreateMockTable([]string{"a", "c"}))
+			d.Proto = pb.Dirt{Stringer: pb.Stringer{"blah"}}
+			return d
+		}(),
+		y: func() ts.Dirt {
+			d := createDirt()
+			d.Discord = 500
+			d.SetWizard(map[string]*pb.Wizard{
+				"harry": &pb.Wizard{Stringer: pb.Stringer{"otter"}},
+			})
+			return d
+		}(),
+		opts: []cmp.Option{allowVisibility, transformProtos, ignoreLocker, cmp.Comparer(pb.Equal), equalTable},
 		wantDiff: `
-{teststructs.ParentStructC}.privateStruct.Public:
-	-: 1
-	+: 2
-{teststructs.ParentStructC}.privateStruct.private:
-	-: 2
-	+: 3
-{teststructs.ParentStructC}.Public:
-	-: 3
-	+: 4
-{teststructs.ParentStructC}.private:
-	-: 4
-	+: 5`,
-	}, {
-		label: label + "ParentStructD",
-		x:     ts.ParentStructD{},
-		y:     ts.ParentStructD{},
-		opts: []cmp.Option{
-			IgnoreUnexported(ts.ParentStructD{}),
-		},
+{teststructs.Dirt}.table:
+	-: &teststructs.MockTable{state: []string{"a", "c"}}
+	+: &teststructs.MockTable{state: []string{"a", "b", "c"}}
+{teststructs.Dirt}.Discord:
+	-: 554
+	+: 500
+cmp_test.project3Options.func1({teststructs.Dirt}.Proto):
+	-: "blah"
+	+: "proto"
+{teststructs.Dirt}.wizard["albus"]:
+	-: "dumbledore"
+	+: <non-existent>
+{teststructs.Dirt}.wizard["harry"]:
+	-: "potter"
+	+: "otter"`,
+	}}
+
+	// Without package unsafe, the values of unexported fields that are passed
+	// to the options are read-only, which the options cannot be called with.
+	for i := 1; i < len(tests); i++ {
+		tests[i] = readOnly(tests[i], "{teststructs.Dirt}.table")
+	}
+	return tests
+}
+
+func project4Tests() []test {
+	const label = "Project4"
+
+	allowVisibility := cmp.AllowUnexported(
+		ts.Cartel{},
+		ts.Headquarter{},
+		ts.Poison{},
+	)
+
+	transformProtos := cmp.Transformer("", func(x pb.Restrictions) *pb.Restrictions {
+		return &x
+	})
+
+	createCartel := func() ts.Cartel {
+		var p ts.Poison
+		p.SetPoisonType(5)
+		p.SetExpiration(now)
+		p.SetManufactuer("acme")
+
+		var hq ts.Headquarter
+		hq.SetID(5)
+		hq.SetLocation("moon")
+		hq.SetSubDivisions([]string{"alpha", "bravo", "charlie"})
+		hq.SetMetaData(&pb.MetaData{Stringer: pb.Stringer{"metadata"}})
+		hq.SetPublicMessage([]byte{1, 2, 3, 4, 5})
+		hq.SetHorseBack("abcdef")
+		hq.SetStatus(44)
+
+		var c ts.Cartel
+		c.Headquarter = hq
+		c.SetSource("mars")
+		c.SetCreationTime(now)
+		c.SetBoss("al capone")
+		c.SetPoisons([]*ts.Poison{&p})
+
+		return c
+	}
+
+	tests := []test{{
+		label:     label,
+		x:         createCartel(),
+		y:         createCartel(),
 		wantPanic: "cannot handle unexported field",
 	}, {
-		label: label + "ParentStructD",
-		x:     ts.ParentStructD{},
-		y:     ts.ParentStructD{},
-		opts: []cmp.Option{
-			IgnoreUnexported(ts.ParentStructD{}),
-			IgnoreUnexported(ts.PublicStruct{}),
-		},
-	}, {
-		label: label + "ParentStructD",
-		x:     createStructD(0),
-		y:     createStructD(0),
-		opts: []cmp.Option{
-			cmp.AllowUnexported(ts.ParentStructD{}),
-		},
+		label:     label,
+		x:         createCartel(),
+		y:         createCartel(),
+		opts:      []cmp.Option{allowVisibility, cmp.Comparer(pb.Equal)},
 		wantPanic: "cannot handle unexported field",
 	}, {
-		label: label + "ParentStructD",
-		x:     createStructD(0),
-		y:     createStructD(0),
-		opts: []cmp.Option{
-			cmp.AllowUnexported(ts.ParentStructD{}, ts.PublicStruct{}),
-		},
+		label: label,
+		x:     createCartel(),
+		y:     createCartel(),
+		opts:  []cmp.Option{allowVisibility, transformProtos, cmp.Comparer(pb.Equal)},
 	}, {
-		label: label + "ParentStructD",
-		x:     createStructD(0),
-		y:     createStructD(1),
-		opts: []cmp.Option{
-			cmp.AllowUnexported(ts.ParentStructD{}, ts.PublicStruct{}),
-		},
+		label: label,
+		x: func() ts.Cartel {
+			d := createCartel()
+			var p1, p2 ts.Poison
+			p1.SetPoisonType(1)
+			p1.SetExpiration(now)
+			p1.SetManufactuer("acme")
+			p2.SetPoisonType(2)
+			p2.SetManufactuer("acme2")
+			d.SetPoisons([]*ts.Poison{&p1, &p2})
+			return d
+		}(),
+		y: func() ts.Cartel {
+			d := createCartel()
+			d.SetSubDivisions([]string{"bravo", "charlie"})
+			d.SetPublicMessage([]byte{1, 2, 4, 3, 5})
+			return d
+		}(),
+		opts: []cmp.Option{allowVisibility, transformProtos, cmp.Comparer(pb.Equal)},
+		wantDiff: `
+{teststructs.Cartel}.Headquarter.subDivisions[0]:
+	-: "alpha"
+	+: "bravo"
+{teststructs.Cartel}.Headquarter.subDivisions[1]:
+	-: "bravo"
+	+: "charlie"
+{teststructs.Cartel}.Headquarter.subDivisions[2]:
+	-: "charlie"
+	+: <non-existent>
+{teststructs.Cartel}.Headquarter.publicMessage[2]:
+	-: 0x03
+	+: 0x04
+{teststructs.Cartel}.Headquarter.publicMessage[3]:
+	-: 0x04
+	+: 0x03
+{teststructs.Cartel}.poisons[0].poisonType:
+	-: 1
+	+: 5
+{teststructs.Cartel}.poisons[1]:
+	-: &teststructs.Poison{poisonType: 2, manufactuer: "acme2"}
+	+: <non-existent>`,
+	}}
+
+	// Without package unsafe, the values of unexported fields that are passed
+	// to the options are read-only, which the options cannot be called with.
+	for i := 1; i < len(tests); i++ {
+		tests[i] = readOnly(tests[i], "{teststructs.Cartel}.Headquarter.incorporatedDate")
+	}
+	return tests
+}
+
+func comparerDetailsTests() []test {
+	const label = "ComparerDetails/"
+
+	type pair struct{ A, B int }
+	equalPair := cmp.Comparer(func(x, y pair) bool { return x == y })
+
+	return []test{{
+		label:    label + "Detailed",
+		x:        []pair{{1, 2}, {3, 4}},
+		y:        []pair{{1, 2}, {3, 5}},
+		opts:     []cmp.Option{equalPair, cmp.ReportComparerDetails()},
+		wantDiff: "{[]cmp_test.pair}[1]:\n\t-: cmp_test.pair{A: 3, B: 4}\n\t+: cmp_test.pair{A: 3, B: 5}\n\t(supplemental report with Comparer(cmp_test.comparerDetailsTests.func1) suppressed)\n\t{[]cmp_test.pair}[1].B:\n\t\t-: 4\n\t\t+: 5\n",
+	}, {
+		label:    label + "Disabled",
+		x:        []pair{{1, 2}, {3, 4}},
+		y:        []pair{{1, 2}, {3, 5}},
+		opts:     []cmp.Option{equalPair},
+		wantDiff: "{[]cmp_test.pair}[1]:\n\t-: cmp_test.pair{A: 3, B: 4}\n\t+: cmp_test.pair{A: 3, B: 5}\n",
+	}, {
+		label:    label + "Equal",
+		x:        []pair{{1, 2}, {3, 4}},
+		y:        []pair{{1, 2}, {3, 4}},
+		opts:     []cmp.Option{equalPair, cmp.ReportComparerDetails()},
+		wantDiff: "",
+	}, {
+		label: label + "Fallback",
+		x: ts.Eagle{Slaps: []ts.Slap{{
+			Args: &pb.MetaData{Stringer: pb.Stringer{"metadata"}},
+		}}},
+		y: ts.Eagle{Slaps: []ts.Slap{{
+			Args: &pb.MetaData{Stringer: pb.Stringer{"metadata2"}},
+		}}},
+		opts:     []cmp.Option{cmp.Comparer(pb.Equal), cmp.ReportComparerDetails()},
+		wantDiff: "{teststructs.Eagle}.Slaps[0].Args:\n\t-: \"metadata\"\n\t+: \"metadata2\"\n",
+	}}
+}
+
+func maxDepthTests() []test {
+	const label = "MaxDepth/"
+
+	// Each pointer in the chain adds a single Indirect step to the Path.
+	type ptr *ptr
+	makeChain := func(n int) ptr {
+		var p ptr
+		for i := 0; i < n; i++ {
+			pp := new(ptr)
+			*pp = p
+			p = pp
+		}
+		return p
+	}
+
+	type node struct {
+		Next *node
+		V    int
+	}
+	makeList := func(n, last int) *node {
+		l := &node{V: last}
+		for i := 1; i < n; i++ {
+			l = &node{Next: l}
+		}
+		return l
+	}
+
+	return []test{{
+		label: label + "UnderLimit",
+		x:     makeChain(100),
+		y:     makeChain(100),
+		opts:  []cmp.Option{cmp.MaxDepth(100)},
+	}, {
+		label:     label + "OverLimit",
+		x:         makeChain(101),
+		y:         makeChain(101),
+		opts:      []cmp.Option{cmp.MaxDepth(100)},
+		wantPanic: "maximum depth of 100 exceeded at *****",
+	}, {
+		label: label + "List",
+		x:     makeList(3, 1),
+		y:     makeList(3, 2),
+		opts:  []cmp.Option{cmp.MaxDepth(6)},
 		wantDiff: `
-{teststructs.ParentStructD}.PublicStruct.Public:
+{*cmp_test.node}.Next.Next.V:
 	-: 1
-	+: 2
-{teststructs.ParentStructD}.PublicStruct.private:
-	-: 2
-	+: 3
-{teststructs.ParentStructD}.Public:
-	-: 3
-	+: 4
-{teststructs.ParentStructD}.private:
-	-: 4
-	+: 5`,
-	}, {
-		label: label + "ParentStructE",
-		x:     ts.ParentStructE{},
-		y:     ts.ParentStructE{},
-		opts: []cmp.Option{
-			IgnoreUnexported(ts.ParentStructE{}),
-		},
-		wantPanic: "cannot handle unexported field",
-	}, {
-		label: label + "ParentStructE",
-		x:     ts.ParentStructE{},
-		y:     ts.ParentStructE{},
-		opts: []cmp.Option{
-			IgnoreUnexported(ts.ParentStructE{}),
-			IgnoreUnexported(ts.PublicStruct{}),
-		},
+	+: 2`,
+	}, {
+		label:     label + "List",
+		x:         makeList(3, 1),
+		y:         makeList(3, 2),
+		opts:      []cmp.Option{cmp.MaxDepth(5)},
+		wantPanic: "maximum depth of 5 exceeded at {*cmp_test.node}.Next.Next",
+	}, {
+		label:     label + "Default",
+		x:         makeList(100000, 1),
+		y:         makeList(100000, 1),
+		wantPanic: "use MaxDepth to raise the limit",
+	}}
+}
+
+func nilInterfaceTests() []test {
+	const label = "NilInterface/"
+
+	return []test{{
+		label: label,
+		x:     map[string]io.Reader{"a": (*bytes.Buffer)(nil)},
+		y:     map[string]io.Reader{"a": nil},
+		wantDiff: `
+{map[string]io.Reader}["a"]:
+	-: typed nil (*bytes.Buffer)(nil)
+	+: untyped nil`,
 	}, {
-		label: label + "ParentStructE",
-		x:     createStructE(0),
-		y:     createStructE(0),
-		opts: []cmp.Option{
-			cmp.AllowUnexported(ts.ParentStructE{}),
-		},
-		wantPanic: "cannot handle unexported field",
+		label: label,
+		x:     map[string]io.Reader{"a": nil},
+		y:     map[string]io.Reader{"a": (*bytes.Buffer)(nil)},
+		wantDiff: `
+{map[string]io.Reader}["a"]:
+	-: untyped nil
+	+: typed nil (*bytes.Buffer)(nil)`,
 	}, {
-		label: label + "ParentStructE",
-		x:     createStructE(0),
-		y:     createStructE(0),
-		opts: []cmp.Option{
-			cmp.AllowUnexported(ts.ParentStructE{}, ts.PublicStruct{}),
-		},
-		wantPanic: "cannot handle unexported field",
+		label: label,
+		x:     map[string]io.Reader{"a": (*bytes.Buffer)(nil)},
+		y:     map[string]io.Reader{"a": nil},
+		opts:  []cmp.Option{cmp.EquateNilInterfaces()},
 	}, {
-		label: label + "ParentStructE",
-		x:     createStructE(0),
-		y:     createStructE(0),
-		opts: []cmp.Option{
-			cmp.AllowUnexported(ts.ParentStructE{}, ts.PublicStruct{}, privateStruct),
-		},
+		label: label,
+		x:     map[string]io.Reader{"a": nil},
+		y:     map[string]io.Reader{"a": (*bytes.Buffer)(nil)},
+		opts:  []cmp.Option{cmp.EquateNilInterfaces()},
 	}, {
-		label: label + "ParentStructE",
-		x:     createStructE(0),
-		y:     createStructE(1),
-		opts: []cmp.Option{
-			cmp.AllowUnexported(ts.ParentStructE{}, ts.PublicStruct{}, privateStruct),
-		},
+		label: label,
+		x:     map[string]io.Reader{"a": (*bytes.Buffer)(nil)},
+		y:     map[string]io.Reader{"a": (*strings.Reader)(nil)},
 		wantDiff: `
-{teststructs.ParentStructE}.privateStruct.Public:
-	-: 1
-	+: 2
-{teststructs.ParentStructE}.privateStruct.private:
-	-: 2
-	+: 3
-{teststructs.ParentStructE}.PublicStruct.Public:
-	-: 3
-	+: 4
-{teststructs.ParentStructE}.PublicStruct.private:
-	-: 4
-	+: 5`,
-	}, {
-		label: label + "ParentStructF",
-		x:     ts.ParentStructF{},
-		y:     ts.ParentStructF{},
-		opts: []cmp.Option{
-			IgnoreUnexported(ts.ParentStructF{}),
-		},
-		wantPanic: "cannot handle unexported field",
+{map[string]io.Reader}["a"]:
+	-: <nil>
+	+: (*strings.Reader)(nil)`,
 	}, {
-		label: label + "ParentStructF",
-		x:     ts.ParentStructF{},
-		y:     ts.ParentStructF{},
-		opts: []cmp.Option{
-			IgnoreUnexported(ts.ParentStructF{}),
-			IgnoreUnexported(ts.PublicStruct{}),
-		},
+		label: label,
+		x:     map[string]io.Reader{"a": (*bytes.Buffer)(nil)},
+		y:     map[string]io.Reader{"a": (*strings.Reader)(nil)},
+		opts:  []cmp.Option{cmp.EquateNilInterfaces()},
 	}, {
-		label: label + "ParentStructF",
-		x:     createStructF(0),
-		y:     createStructF(0),
-		opts: []cmp.Option{
-			cmp.AllowUnexported(ts.ParentStructF{}),
-		},
-		wantPanic: "cannot handle unexported field",
+		label: label,
+		x:     []interface{}{[]int(nil), map[int]int(nil)},
+		y:     []interface{}{nil, nil},
+		opts:  []cmp.Option{cmp.EquateNilInterfaces()},
 	}, {
-		label: label + "ParentStructF",
-		x:     createStructF(0),
-		y:     createStructF(0),
-		opts: []cmp.Option{
-			cmp.AllowUnexported(ts.ParentStructF{}, ts.PublicStruct{}),
-		},
-		wantPanic: "cannot handle unexported field",
+		label: label,
+		x:     map[string]io.Reader{"a": new(bytes.Buffer)},
+		y:     map[string]io.Reader{"a": nil},
+		opts:  []cmp.Option{cmp.EquateNilInterfaces()},
+		wantDiff: `
+{map[string]io.Reader}["a"]:
+	-: (*bytes.Buffer)("")
+	+: io.Reader(nil)`,
+	}}
+}
+
+func priorityTests() []test {
+	const label = "Prioritized/"
+
+	type Record struct {
+		Name      string
+		CreatedAt time.Time
+		UpdatedAt time.Time
+	}
+	t0 := time.Date(2009, time.November, 10, 23, 0, 0, 0, time.UTC)
+	x := Record{"foo", t0, t0}
+	y := Record{"foo", t0.Add(time.Hour), t0.Add(time.Hour)}
+	isTime := func(p cmp.Path) bool { return p[len(p)-1].Type() == reflect.TypeOf(time.Time{}) }
+	isCreatedAt := func(p cmp.Path) bool { return p[len(p)-1].String() == ".CreatedAt" }
+
+	return []test{{
+		label: label,
+		x:     x,
+		y:     y,
+		opts:  []cmp.Option{cmp.FilterPath(isTime, cmp.Ignore())},
 	}, {
-		label: label + "ParentStructF",
-		x:     createStructF(0),
-		y:     createStructF(0),
+		label: label,
+		x:     x,
+		y:     y,
 		opts: []cmp.Option{
-			cmp.AllowUnexported(ts.ParentStructF{}, ts.PublicStruct{}, privateStruct),
+			cmp.FilterPath(isTime, cmp.Ignore()),
+			cmp.Prioritized(1, cmp.FilterPath(isCreatedAt, cmp.Comparer(time.Time.Equal))),
 		},
+		wantDiff: `
+{cmp_test.Record}.CreatedAt:
+	-: "2009-11-10 23:00:00 +0000 UTC"
+	+: "2009-11-11 00:00:00 +0000 UTC"`,
 	}, {
-		label: label + "ParentStructF",
-		x:     createStructF(0),
-		y:     createStructF(1),
+		label: label,
+		x:     x,
+		y:     y,
 		opts: []cmp.Option{
-			cmp.AllowUnexported(ts.ParentStructF{}, ts.PublicStruct{}, privateStruct),
+			cmp.FilterValues(func(x, y time.Time) bool { return true }, cmp.Ignore()),
+			cmp.Prioritized(1, cmp.FilterPath(isCreatedAt, cmp.Comparer(time.Time.Equal))),
 		},
 		wantDiff: `
-{teststructs.ParentStructF}.privateStruct.Public:
-	-: 1
-	+: 2
-{teststructs.ParentStructF}.privateStruct.private:
-	-: 2
-	+: 3
-{teststructs.ParentStructF}.PublicStruct.Public:
-	-: 3
-	+: 4
-{teststructs.ParentStructF}.PublicStruct.private:
-	-: 4
-	+: 5
-{teststructs.ParentStructF}.Public:
-	-: 5
-	+: 6
-{teststructs.ParentStructF}.private:
-	-: 6
-	+: 7`,
-	}, {
-		label:     label + "ParentStructG",
-		x:         ts.ParentStructG{},
-		y:         ts.ParentStructG{},
-		wantPanic: "cannot handle unexported field",
+{cmp_test.Record}.CreatedAt:
+	-: "2009-11-10 23:00:00 +0000 UTC"
+	+: "2009-11-11 00:00:00 +0000 UTC"`,
 	}, {
-		label: label + "ParentStructG",
-		x:     ts.ParentStructG{},
-		y:     ts.ParentStructG{},
+		label: label,
+		x:     x,
+		y:     y,
 		opts: []cmp.Option{
-			IgnoreUnexported(ts.ParentStructG{}),
+			cmp.Prioritized(1, cmp.FilterPath(isTime, cmp.Ignore())),
+			cmp.FilterPath(isCreatedAt, cmp.Comparer(time.Time.Equal)),
 		},
 	}, {
-		label: label + "ParentStructG",
-		x:     createStructG(0),
-		y:     createStructG(0),
+		label: label,
+		x:     x,
+		y:     y,
 		opts: []cmp.Option{
-			cmp.AllowUnexported(ts.ParentStructG{}),
+			cmp.Transformer("Unix", func(t time.Time) int64 { return t.Unix() / 86400 }),
+			cmp.Prioritized(1, cmp.Comparer(time.Time.Equal)),
 		},
-		wantPanic: "cannot handle unexported field",
+		wantDiff: `
+{cmp_test.Record}.CreatedAt:
+	-: "2009-11-10 23:00:00 +0000 UTC"
+	+: "2009-11-11 00:00:00 +0000 UTC"
+{cmp_test.Record}.UpdatedAt:
+	-: "2009-11-10 23:00:00 +0000 UTC"
+	+: "2009-11-11 00:00:00 +0000 UTC"`,
 	}, {
-		label: label + "ParentStructG",
-		x:     createStructG(0),
-		y:     createStructG(0),
+		label: label,
+		x:     x,
+		y:     y,
 		opts: []cmp.Option{
-			cmp.AllowUnexported(ts.ParentStructG{}, privateStruct),
+			cmp.Prioritized(1, cmp.Transformer("Unix", func(t time.Time) int64 { return t.Unix() / 86400 })),
+			cmp.Comparer(time.Time.Equal),
 		},
+		wantDiff: `
+Unix({cmp_test.Record}.CreatedAt):
+	-: 14558
+	+: 14559
+Unix({cmp_test.Record}.UpdatedAt):
+	-: 14558
+	+: 14559`,
 	}, {
-		label: label + "ParentStructG",
-		x:     createStructG(0),
-		y:     createStructG(1),
+		label: label,
+		x:     x,
+		y:     y,
 		opts: []cmp.Option{
-			cmp.AllowUnexported(ts.ParentStructG{}, privateStruct),
+			cmp.Prioritized(1, cmp.Transformer("Unix", func(t time.Time) int64 { return t.Unix() })),
+			cmp.Prioritized(1, cmp.Comparer(time.Time.Equal)),
 		},
+		wantPanic: "ambiguous set of options",
+	}}
+}
+
+func mapKeyTests() []test {
+	const label = "MapKey/"
+
+	type key struct{ A interface{} }
+	return []test{{
+		label: label,
+		x:     map[interface{}]int{1: 1, 2: 2},
+		y:     map[interface{}]int{int64(1): 1, 2: 2},
 		wantDiff: `
-{*teststructs.ParentStructG}.privateStruct.Public:
+root[1]:
 	-: 1
-	+: 2
-{*teststructs.ParentStructG}.privateStruct.private:
-	-: 2
-	+: 3`,
+	+: 1
+	(map keys are equal except for their dynamic types: int and int64)`,
 	}, {
-		label: label + "ParentStructH",
-		x:     ts.ParentStructH{},
-		y:     ts.ParentStructH{},
+		label: label,
+		x:     map[interface{}]int{1: 1, "1": 2},
+		y:     map[interface{}]int{int64(1): 1, "1": 2},
+		wantDiff: `
+root[1]:
+	-: 1
+	+: 1
+	(map keys are equal except for their dynamic types: int and int64)`,
 	}, {
-		label:     label + "ParentStructH",
-		x:         createStructH(0),
-		y:         createStructH(0),
-		wantPanic: "cannot handle unexported field",
+		label: label,
+		x:     map[interface{}]int{1: 1},
+		y:     map[interface{}]int{2: 1},
+		wantDiff: `
+root[1]:
+	-: 1
+	+: <non-existent>
+root[2]:
+	-: <non-existent>
+	+: 1`,
 	}, {
-		label: label + "ParentStructH",
-		x:     ts.ParentStructH{},
-		y:     ts.ParentStructH{},
-		opts: []cmp.Option{
-			IgnoreUnexported(ts.ParentStructH{}),
-		},
+		label: label,
+		x:     map[interface{}]int{key{[2]int{1, 2}}: 1},
+		y:     map[interface{}]int{key{"12"}: 1},
+		wantDiff: `
+root[cmp_test.key{A:[2]int{1, 2}}]:
+	-: 1
+	+: <non-existent>
+root[cmp_test.key{A:"12"}]:
+	-: <non-existent>
+	+: 1`,
+	}}
+}
+
+func makeCounter(n int) func() int {
+	return func() int { n++; return n }
+}
+
+func funcChanTests() []test {
+	const label = "FuncChan/"
+
+	type Handler struct {
+		Name string
+		Next func() int
+		Done chan bool
+	}
+	next := makeCounter(0)
+	done := make(chan bool)
+	h := Handler{"foo", next, done}
+	tests := []test{{
+		label: label,
+		x:     Handler{"foo", nil, nil},
+		y:     Handler{"foo", nil, nil},
 	}, {
-		label: label + "ParentStructH",
-		x:     createStructH(0),
-		y:     createStructH(0),
-		opts: []cmp.Option{
-			cmp.AllowUnexported(ts.ParentStructH{}),
-		},
-		wantPanic: "cannot handle unexported field",
+		label: label,
+		x:     h,
+		y:     Handler{"foo", makeCounter(0), done},
+		wantDiff: `
+{cmp_test.Handler}.Next:
+	-: (func() int)(cmp_test.makeCounter.func1)
+	+: (func() int)(cmp_test.makeCounter.func1)
+	(functions are only equal if they are both nil or the same function value; consider using an Ignore or Comparer option for this value)`,
 	}, {
-		label: label + "ParentStructH",
-		x:     createStructH(0),
-		y:     createStructH(0),
-		opts: []cmp.Option{
-			cmp.AllowUnexported(ts.ParentStructH{}, ts.PublicStruct{}),
-		},
+		label: label,
+		x:     h,
+		y:     Handler{"foo", nil, done},
+		wantDiff: `
+{cmp_test.Handler}.Next:
+	-: (func() int)(cmp_test.makeCounter.func1)
+	+: (func() int)(nil)`,
 	}, {
-		label: label + "ParentStructH",
-		x:     createStructH(0),
-		y:     createStructH(1),
+		label: label,
+		x:     h,
+		y:     Handler{"foo", makeCounter(0), make(chan bool)},
 		opts: []cmp.Option{
-			cmp.AllowUnexported(ts.ParentStructH{}, ts.PublicStruct{}),
+			cmp.FilterPath(func(p cmp.Path) bool {
+				t := p[len(p)-1].Type()
+				return t.Kind() == reflect.Func || t.Kind() == reflect.Chan
+			}, cmp.Ignore()),
 		},
+	}}
+
+	// The same function is only equal to itself with package unsafe.
+	if canCompareFuncs {
+		tests = append(tests, test{
+			label: label,
+			x:     h,
+			y:     h,
+		})
+	}
+	return tests
+}
+
+func untypedNilTests() []test {
+	const label = "UntypedNil/"
+
+	type Struct struct{ A int }
+	return []test{{
+		label: label,
+		x:     nil,
+		y:     nil,
+	}, {
+		label: label,
+		x:     nil,
+		y:     io.Reader(nil),
+	}, {
+		label: label,
+		x:     error(nil),
+		y:     io.Reader(nil),
+	}, {
+		label: label,
+		x:     nil,
+		y:     (*int)(nil),
 		wantDiff: `
-{*teststructs.ParentStructH}.PublicStruct.Public:
-	-: 1
-	+: 2
-{*teststructs.ParentStructH}.PublicStruct.private:
-	-: 2
-	+: 3`,
+root:
+	-: <untyped nil>
+	+: (*int)(nil)`,
 	}, {
-		label:     label + "ParentStructI",
-		x:         ts.ParentStructI{},
-		y:         ts.ParentStructI{},
-		wantPanic: "cannot handle unexported field",
+		label: label,
+		x:     []int(nil),
+		y:     nil,
+		wantDiff: `
+root:
+	-: []int(nil)
+	+: <untyped nil>`,
 	}, {
-		label: label + "ParentStructI",
-		x:     ts.ParentStructI{},
-		y:     ts.ParentStructI{},
-		opts: []cmp.Option{
-			IgnoreUnexported(ts.ParentStructI{}),
-		},
+		label: label,
+		x:     nil,
+		y:     io.Reader((*bytes.Buffer)(nil)),
+		wantDiff: `
+root:
+	-: <untyped nil>
+	+: (*bytes.Buffer)(nil)`,
 	}, {
-		label: label + "ParentStructI",
-		x:     createStructI(0),
-		y:     createStructI(0),
-		opts: []cmp.Option{
-			IgnoreUnexported(ts.ParentStructI{}),
-		},
-		wantPanic: "cannot handle unexported field",
+		label: label,
+		x:     nil,
+		y:     Struct{1},
+		wantDiff: `
+root:
+	-: <untyped nil>
+	+: cmp_test.Struct{A: 1}`,
 	}, {
-		label: label + "ParentStructI",
-		x:     createStructI(0),
-		y:     createStructI(0),
-		opts: []cmp.Option{
-			IgnoreUnexported(ts.ParentStructI{}, ts.PublicStruct{}),
-		},
+		label: label,
+		x:     Struct{},
+		y:     nil,
+		wantDiff: `
+root:
+	-: cmp_test.Struct{}
+	+: <untyped nil>`,
 	}, {
-		label: label + "ParentStructI",
-		x:     createStructI(0),
-		y:     createStructI(0),
-		opts: []cmp.Option{
-			cmp.AllowUnexported(ts.ParentStructI{}),
-		},
-		wantPanic: "cannot handle unexported field",
+		label: label,
+		x:     nil,
+		y:     "",
+		wantDiff: `
+root:
+	-: <untyped nil>
+	+: string("")`,
 	}, {
-		label: label + "ParentStructI",
-		x:     createStructI(0),
-		y:     createStructI(0),
-		opts: []cmp.Option{
-			cmp.AllowUnexported(ts.ParentStructI{}, ts.PublicStruct{}, privateStruct),
-		},
+		label: label,
+		x:     1,
+		y:     int64(1),
+		wantDiff: `
+root:
+	-: int(1)
+	+: int64(1)`,
+	}}
+}
+
+func strictIgnoreTests() []test {
+	const label = "StrictIgnores/"
+
+	ignoreB := cmp.FilterPath(func(p cmp.Path) bool { return p.String() == "B" }, cmp.Ignore())
+	return []test{{
+		label: label,
+		x:     struct{ A, B int }{1, 2},
+		y:     struct{ A, B int }{1, 2},
+		opts:  []cmp.Option{ignoreB, cmp.StrictIgnores()},
 	}, {
-		label: label + "ParentStructI",
-		x:     createStructI(0),
-		y:     createStructI(1),
-		opts: []cmp.Option{
-			cmp.AllowUnexported(ts.ParentStructI{}, ts.PublicStruct{}, privateStruct),
-		},
+		label: label,
+		x:     struct{ A, B int }{1, 2},
+		y:     struct{ A, B int }{1, 3},
+		opts:  []cmp.Option{ignoreB},
+	}, {
+		label: label,
+		x:     struct{ A, B int }{1, 2},
+		y:     struct{ A, B int }{1, 3},
+		opts:  []cmp.Option{ignoreB, cmp.StrictIgnores()},
 		wantDiff: `
-{*teststructs.ParentStructI}.privateStruct.Public:
-	-: 1
-	+: 2
-{*teststructs.ParentStructI}.privateStruct.private:
+root.B:
 	-: 2
 	+: 3
-{*teststructs.ParentStructI}.PublicStruct.Public:
-	-: 3
-	+: 4
-{*teststructs.ParentStructI}.PublicStruct.private:
-	-: 4
-	+: 5`,
+	(differences at root.B are suppressed by an Ignore option)`,
+	}}
+}
+
+func equateApproxTests() []test {
+	const label = "EquateApprox/"
+
+	return []test{{
+		label: label,
+		x:     []float64{1000, 2000, 0, math.NaN()},
+		y:     []float64{1000.5, 2001, 0.001, math.NaN()},
+		opts:  []cmp.Option{cmp.EquateApprox(0.001, 0)},
+		wantDiff: `
+{[]float64}[2]:
+	-: 0
+	+: 0.001
+{[]float64}[3]:
+	-: NaN
+	+: NaN`,
 	}, {
-		label:     label + "ParentStructJ",
-		x:         ts.ParentStructJ{},
-		y:         ts.ParentStructJ{},
-		wantPanic: "cannot handle unexported field",
+		label: label,
+		x:     []float32{1000, 2000, 0},
+		y:     []float32{1000.5, 2001, 0.001},
+		opts:  []cmp.Option{cmp.EquateApprox(0.001, 0.01)},
 	}, {
-		label: label + "ParentStructJ",
-		x:     ts.ParentStructJ{},
-		y:     ts.ParentStructJ{},
-		opts: []cmp.Option{
-			IgnoreUnexported(ts.ParentStructJ{}),
-		},
-		wantPanic: "cannot handle unexported field",
+		label: label,
+		x:     struct{ A, B float64 }{1, math.Inf(+1)},
+		y:     struct{ A, B float64 }{2, math.Inf(+1)},
+		opts:  []cmp.Option{cmp.EquateApprox(0, 0.5)},
+		wantDiff: `
+root.A:
+	-: 1
+	+: 2`,
+	}}
+}
+
+func equateApproxComplexTests() []test {
+	const label = "EquateApproxComplex/"
+
+	// These mirror the values in ExampleOption_transformComplex, which
+	// need a stack of three Transformers to achieve the same effect.
+	x := []interface{}{
+		complex128(3.0), complex64(5.1 + 2.9i), float32(-1.2), float64(12.3),
+	}
+	y := []interface{}{
+		complex128(3.1), complex64(4.9 + 3.1i), float32(-1.3), float64(11.7),
+	}
+	z := []interface{}{
+		complex128(3.8), complex64(4.9 + 3.1i), float32(-1.3), float64(11.7),
+	}
+	opts := []cmp.Option{cmp.EquateApproxComplex(0, 0.65), cmp.EquateApprox(0, 0.65)}
+	return []test{{
+		label: label,
+		x:     x,
+		y:     y,
+		opts:  opts,
 	}, {
-		label: label + "ParentStructJ",
-		x:     ts.ParentStructJ{},
-		y:     ts.ParentStructJ{},
-		opts: []cmp.Option{
-			IgnoreUnexported(ts.ParentStructJ{}, ts.PublicStruct{}),
-		},
+		label: label,
+		x:     y,
+		y:     z,
+		opts:  opts,
+		wantDiff: `
+root[0].(complex128):
+	-: (3.1+0i)
+	+: (3.8+0i)
+	(absolute error is 0.6999999999999997)`,
 	}, {
-		label: label + "ParentStructJ",
-		x:     createStructJ(0),
-		y:     createStructJ(0),
-		opts: []cmp.Option{
-			cmp.AllowUnexported(ts.ParentStructJ{}, ts.PublicStruct{}),
-		},
+		label: label,
+		x:     z,
+		y:     x,
+		opts:  opts,
+		wantDiff: `
+root[0].(complex128):
+	-: (3.8+0i)
+	+: (3+0i)
+	(absolute error is 0.7999999999999998)`,
+	}, {
+		label: label,
+		x:     []complex128{1000 + 1000i, complex(math.NaN(), 0)},
+		y:     []complex128{1001 + 999i, complex(math.NaN(), 0)},
+		opts:  []cmp.Option{cmp.EquateApproxComplex(0.001, 0)},
+		wantDiff: `
+{[]complex128}[1]:
+	-: (NaN+0i)
+	+: (NaN+0i)`,
+	}}
+}
+
+func equateBigTests() []test {
+	const label = "EquateBig/"
+
+	type Ledger struct {
+		Balance *big.Int
+		Rate    *big.Float
+		Share   *big.Rat
+		Total   big.Int
+	}
+	newFloat := func(prec uint, x float64) *big.Float {
+		return new(big.Float).SetPrec(prec).SetFloat64(x)
+	}
+	return []test{{
+		label:     label,
+		x:         Ledger{Balance: big.NewInt(5)},
+		y:         Ledger{Balance: big.NewInt(5)},
 		wantPanic: "cannot handle unexported field",
 	}, {
-		label: label + "ParentStructJ",
-		x:     createStructJ(0),
-		y:     createStructJ(0),
-		opts: []cmp.Option{
-			cmp.AllowUnexported(ts.ParentStructJ{}, ts.PublicStruct{}, privateStruct),
-		},
+		label: label,
+		x:     Ledger{big.NewInt(5), newFloat(24, 0.5), big.NewRat(1, 2), *big.NewInt(7)},
+		y:     Ledger{big.NewInt(5), newFloat(200, 0.5), big.NewRat(2, 4), *big.NewInt(7)},
+		opts:  []cmp.Option{cmp.EquateBig()},
 	}, {
-		label: label + "ParentStructJ",
-		x:     createStructJ(0),
-		y:     createStructJ(1),
-		opts: []cmp.Option{
-			cmp.AllowUnexported(ts.ParentStructJ{}, ts.PublicStruct{}, privateStruct),
-		},
+		label: label,
+		x:     Ledger{},
+		y:     Ledger{},
+		opts:  []cmp.Option{cmp.EquateBig()},
+	}, {
+		label: label,
+		x:     Ledger{Balance: nil, Share: big.NewRat(1, 3)},
+		y:     Ledger{Balance: new(big.Int), Share: big.NewRat(1, 2)},
+		opts:  []cmp.Option{cmp.EquateBig()},
 		wantDiff: `
-{*teststructs.ParentStructJ}.privateStruct.Public:
-	-: 1
-	+: 2
-{*teststructs.ParentStructJ}.privateStruct.private:
-	-: 2
-	+: 3
-{*teststructs.ParentStructJ}.PublicStruct.Public:
-	-: 3
-	+: 4
-{*teststructs.ParentStructJ}.PublicStruct.private:
-	-: 4
-	+: 5
-{*teststructs.ParentStructJ}.Public.Public:
-	-: 7
-	+: 8
-{*teststructs.ParentStructJ}.Public.private:
-	-: 8
-	+: 9
-{*teststructs.ParentStructJ}.private.Public:
-	-: 5
-	+: 6
-{*teststructs.ParentStructJ}.private.private:
-	-: 6
-	+: 7`,
+{cmp_test.Ledger}.Balance:
+	-: <nil>
+	+: "0"
+{cmp_test.Ledger}.Share:
+	-: "1/3"
+	+: "1/2"`,
+	}, {
+		label: label,
+		x:     []*big.Float{newFloat(53, 1000), newFloat(53, 0), big.NewFloat(math.Inf(+1))},
+		y:     []*big.Float{newFloat(100, 1000.5), newFloat(53, 0.001), big.NewFloat(math.Inf(+1))},
+		opts:  []cmp.Option{cmp.EquateApproxBigFloat(0.001, 0)},
+		wantDiff: `
+{[]*big.Float}[1]:
+	-: "0"
+	+: "0.001"`,
 	}}
 }
 
-func methodTests() []test {
-	const label = "EqualMethod/"
-
-	// A common mistake that the Equal method is on a pointer receiver,
-	// but only a non-pointer value is present in the struct.
-	// A transform can be used to forcibly reference the value.
-	derefTransform := cmp.FilterPath(func(p cmp.Path) bool {
-		if len(p) == 0 {
-			return false
-		}
-		t := p[len(p)-1].Type()
-		if _, ok := t.MethodByName("Equal"); ok || t.Kind() == reflect.Ptr {
-			return false
-		}
-		if m, ok := reflect.PtrTo(t).MethodByName("Equal"); ok {
-			tf := m.Func.Type()
-			return !tf.IsVariadic() && tf.NumIn() == 2 && tf.NumOut() == 1 &&
-				tf.In(0).AssignableTo(tf.In(1)) && tf.Out(0) == boolType
-		}
-		return false
-	}, cmp.Transformer("Ref", func(x interface{}) interface{} {
-		v := reflect.ValueOf(x)
-		vp := reflect.New(v.Type())
-		vp.Elem().Set(v)
-		return vp.Interface()
-	}))
+func bitwiseFloatTests() []test {
+	const label = "EquateFloatsBitwise/"
 
-	// For each of these types, there is an Equal method defined, which always
-	// returns true, while the underlying data are fundamentally different.
-	// Since the method should be called, these are expected to be equal.
+	type Sample struct {
+		Gain   float64
+		Offset float32
+		Tol    float64
+	}
+	negZero := math.Copysign(0, -1)
+	nan1 := math.Float64frombits(0x7ff8000000000001)
+	nan2 := math.Float64frombits(0x7ff8000000000002)
+	isGain := func(p cmp.Path) bool {
+		sf, ok := p[len(p)-1].(cmp.StructField)
+		return ok && sf.Name() != "Tol"
+	}
 	return []test{{
-		label: label + "StructA",
-		x:     ts.StructA{"NotEqual"},
-		y:     ts.StructA{"not_equal"},
+		label: label,
+		x:     Sample{Gain: negZero, Offset: float32(negZero)},
+		y:     Sample{Gain: 0, Offset: 0},
 	}, {
-		label: label + "StructA",
-		x:     &ts.StructA{"NotEqual"},
-		y:     &ts.StructA{"not_equal"},
+		label: label,
+		x:     Sample{Gain: negZero, Offset: float32(negZero)},
+		y:     Sample{Gain: 0, Offset: 0},
+		opts:  []cmp.Option{cmp.EquateFloatsBitwise()},
+		wantDiff: `
+{cmp_test.Sample}.Gain:
+	-: -0
+	+: 0
+	(bit patterns are 0x8000000000000000 and 0x0000000000000000)
+{cmp_test.Sample}.Offset:
+	-: -0
+	+: 0
+	(bit patterns are 0x80000000 and 0x00000000)`,
+	}, {
+		label: label,
+		x:     []float64{nan1, nan1},
+		y:     []float64{nan1, nan2},
+		opts:  []cmp.Option{cmp.EquateFloatsBitwise()},
+		wantDiff: `
+{[]float64}[1]:
+	-: NaN
+	+: NaN
+	(bit patterns are 0x7ff8000000000001 and 0x7ff8000000000002)`,
 	}, {
-		label: label + "StructB",
-		x:     ts.StructB{"NotEqual"},
-		y:     ts.StructB{"not_equal"},
+		label: label,
+		x:     Sample{Gain: 1, Tol: negZero},
+		y:     Sample{Gain: 1, Tol: 0},
+		opts:  []cmp.Option{cmp.FilterPath(isGain, cmp.EquateFloatsBitwise())},
+	}}
+}
+
+func compareByStringTests() []test {
+	const label = "CompareByString/"
+
+	type Link struct {
+		Href  *url.URL
+		Label fmt.Stringer
+	}
+	mustParse := func(s string) *url.URL {
+		u, err := url.Parse(s)
+		if err != nil {
+			panic(err)
+		}
+		return u
+	}
+	// Both render as "http://example.com/a%20b", but only one has a RawPath.
+	u1 := &url.URL{Scheme: "http", Host: "example.com", Path: "/a b", RawPath: "/a%20b"}
+	u2 := mustParse("http://example.com/a%20b")
+	return []test{{
+		label: label,
+		x:     Link{Href: u1},
+		y:     Link{Href: u2},
 		wantDiff: `
-{teststructs.StructB}.X:
-	-: "NotEqual"
-	+: "not_equal"`,
+{cmp_test.Link}.Href.RawPath:
+	-: "/a%20b"
+	+: ""`,
 	}, {
-		label: label + "StructB",
-		x:     ts.StructB{"NotEqual"},
-		y:     ts.StructB{"not_equal"},
-		opts:  []cmp.Option{derefTransform},
+		label: label,
+		x:     Link{Href: u1},
+		y:     Link{Href: u2},
+		opts:  []cmp.Option{cmp.CompareByString((*url.URL)(nil))},
 	}, {
-		label: label + "StructB",
-		x:     &ts.StructB{"NotEqual"},
-		y:     &ts.StructB{"not_equal"},
+		label: label,
+		x:     []*url.URL{nil, u1, u1},
+		y:     []*url.URL{nil, nil, mustParse("http://example.com/a%2Fb")},
+		opts:  []cmp.Option{cmp.CompareByString(reflect.TypeOf(u1))},
+		wantDiff: `
+{[]*url.URL}[1]:
+	-: "http://example.com/a%20b"
+	+: <nil>
+{[]*url.URL}[2]:
+	-: "http://example.com/a%20b"
+	+: "http://example.com/a%2Fb"`,
 	}, {
-		label: label + "StructC",
-		x:     ts.StructC{"NotEqual"},
-		y:     ts.StructC{"not_equal"},
+		label: label,
+		x:     Link{Label: u1, Href: u1},
+		y:     Link{Label: (*url.URL)(nil), Href: u2},
+		opts:  []cmp.Option{cmp.CompareByString()},
+		wantDiff: `
+{cmp_test.Link}.Label:
+	-: "http://example.com/a%20b"
+	+: <nil>`,
+	}}
+}
+
+type pathError struct{ path string }
+
+func (e *pathError) Error() string { return "bad path: " + e.path }
+
+func compareErrorsTests() []test {
+	const label = "CompareErrorsByMessage/"
+
+	type Result struct {
+		Value int
+		Err   error
+	}
+	return []test{{
+		label:     label,
+		x:         Result{Err: errors.New("bad path: /tmp")},
+		y:         Result{Err: errors.New("bad path: /tmp")},
+		wantPanic: "cannot handle unexported field",
 	}, {
-		label: label + "StructC",
-		x:     &ts.StructC{"NotEqual"},
-		y:     &ts.StructC{"not_equal"},
+		label: label,
+		x:     []Result{{Err: nil}, {Err: errors.New("bad path: /tmp")}, {Err: (*pathError)(nil)}},
+		y:     []Result{{Err: nil}, {Err: &pathError{"/tmp"}}, {Err: nil}},
+		opts:  []cmp.Option{cmp.CompareErrorsByMessage()},
 	}, {
-		label: label + "StructD",
-		x:     ts.StructD{"NotEqual"},
-		y:     ts.StructD{"not_equal"},
+		label: label,
+		x:     []Result{{Err: fmt.Errorf("open: %v", &pathError{"/tmp"})}, {Err: io.EOF}},
+		y:     []Result{{Err: fmt.Errorf("open: %v", &pathError{"/var"})}, {Err: nil}},
+		opts:  []cmp.Option{cmp.CompareErrorsByMessage()},
 		wantDiff: `
-{teststructs.StructD}.X:
-	-: "NotEqual"
-	+: "not_equal"`,
+{[]cmp_test.Result}[0].Err:
+	-: &errors.errorString{s: "open: bad path: /tmp"}
+	+: &errors.errorString{s: "open: bad path: /var"}
+	(error messages are "open: bad path: /tmp" and "open: bad path: /var")
+{[]cmp_test.Result}[1].Err:
+	-: (*errors.errorString)(&errors.errorString{s: "EOF"})
+	+: error(nil)
+	(error messages are "EOF" and <nil>)`,
 	}, {
-		label: label + "StructD",
-		x:     ts.StructD{"NotEqual"},
-		y:     ts.StructD{"not_equal"},
-		opts:  []cmp.Option{derefTransform},
+		label: label,
+		x:     &pathError{"/tmp"},
+		y:     &pathError{"/var"},
+		opts:  []cmp.Option{cmp.CompareErrorsByMessage()},
+		wantDiff: `
+{*cmp_test.pathError}:
+	-: &cmp_test.pathError{path: "/tmp"}
+	+: &cmp_test.pathError{path: "/var"}
+	(error messages are "bad path: /tmp" and "bad path: /var")`,
+	}}
+}
+
+func multisetTests() []test {
+	const label = "EquateMultisets/"
+
+	type Point struct{ X, Y float64 }
+	type Shape struct {
+		Name   string
+		Points []Point
+		Tags   []string
+	}
+	return []test{{
+		label: label,
+		x:     Shape{Points: []Point{{0, 0}, {1, 1}, {0, 0}}},
+		y:     Shape{Points: []Point{{0, 0}, {0, 0}, {1, 1}}},
+		wantDiff: `
+{cmp_test.Shape}.Points[1].X:
+	-: 1
+	+: 0
+{cmp_test.Shape}.Points[1].Y:
+	-: 1
+	+: 0
+{cmp_test.Shape}.Points[2].X:
+	-: 0
+	+: 1
+{cmp_test.Shape}.Points[2].Y:
+	-: 0
+	+: 1`,
 	}, {
-		label: label + "StructD",
-		x:     &ts.StructD{"NotEqual"},
-		y:     &ts.StructD{"not_equal"},
+		label: label,
+		x:     Shape{Points: []Point{{0, 0}, {1, 1}, {0, 0}}, Tags: []string{"a", "b"}},
+		y:     Shape{Points: []Point{{0, 0}, {0, 0}, {1, 1}}, Tags: []string{"b", "a"}},
+		opts:  []cmp.Option{cmp.EquateMultisets()},
 	}, {
-		label: label + "StructE",
-		x:     ts.StructE{"NotEqual"},
-		y:     ts.StructE{"not_equal"},
+		label: label,
+		x:     Shape{Points: []Point{{0, 0}, {1, 1}, {0, 0}}, Tags: []string{"a", "a", "b"}},
+		y:     Shape{Points: []Point{{1, 1}, {0, 0}, {1, 1}}, Tags: []string{"b", "a", "c"}},
+		opts:  []cmp.Option{cmp.EquateMultisets()},
 		wantDiff: `
-{teststructs.StructE}.X:
-	-: "NotEqual"
-	+: "not_equal"`,
+{cmp_test.Shape}.Points[2]:
+	-: cmp_test.Point{}
+	+: <non-existent>
+{cmp_test.Shape}.Points[2]:
+	-: <non-existent>
+	+: cmp_test.Point{X: 1, Y: 1}
+{cmp_test.Shape}.Tags[1]:
+	-: "a"
+	+: <non-existent>
+{cmp_test.Shape}.Tags[2]:
+	-: <non-existent>
+	+: "c"`,
 	}, {
-		label: label + "StructE",
-		x:     ts.StructE{"NotEqual"},
-		y:     ts.StructE{"not_equal"},
-		opts:  []cmp.Option{derefTransform},
+		// Approximate equality is not transitive, so a greedy matching of
+		// 1.5 with 1.0 would leave no match for the other 1.0.
+		label: label,
+		x:     []float64{1.5, 1.0},
+		y:     []float64{1.0, 2.0},
+		opts:  []cmp.Option{cmp.EquateMultisets(), cmp.EquateApprox(0, 0.5)},
 	}, {
-		label: label + "StructE",
-		x:     &ts.StructE{"NotEqual"},
-		y:     &ts.StructE{"not_equal"},
+		label: label,
+		x:     Shape{Name: "a", Tags: []string{"x", "y"}},
+		y:     Shape{Name: "a", Tags: []string{"y", "x", "x"}},
+		opts: []cmp.Option{
+			cmp.FilterPath(func(p cmp.Path) bool { return p[len(p)-1].String() == ".Tags" }, cmp.EquateMultisets()),
+		},
+		wantDiff: `
+{cmp_test.Shape}.Tags[2]:
+	-: <non-existent>
+	+: "x"`,
 	}, {
-		label: label + "StructF",
-		x:     ts.StructF{"NotEqual"},
-		y:     ts.StructF{"not_equal"},
+		label: label,
+		x:     Shape{Tags: []string{}},
+		y:     Shape{Tags: nil},
+		opts:  []cmp.Option{cmp.EquateMultisets()},
 		wantDiff: `
-{teststructs.StructF}.X:
-	-: "NotEqual"
-	+: "not_equal"`,
+{cmp_test.Shape}.Tags:
+	-: []string{}
+	+: []string(nil)`,
+	}}
+}
+
+func setTests() []test {
+	const label = "EquateSets/"
+
+	return []test{{
+		label: label,
+		x:     []string{"a", "b", "b"},
+		y:     []string{"b", "a"},
+		wantDiff: `
+{[]string}[0]:
+	-: "a"
+	+: "b"
+{[]string}[1]:
+	-: "b"
+	+: "a"
+{[]string}[2]:
+	-: "b"
+	+: <non-existent>`,
 	}, {
-		label: label + "StructF",
-		x:     &ts.StructF{"NotEqual"},
-		y:     &ts.StructF{"not_equal"},
+		label: label,
+		x:     []string{"a", "b", "b"},
+		y:     []string{"b", "a"},
+		opts:  []cmp.Option{cmp.EquateSets()},
 	}, {
-		label: label + "StructA1",
-		x:     ts.StructA1{ts.StructA{"NotEqual"}, "equal"},
-		y:     ts.StructA1{ts.StructA{"not_equal"}, "equal"},
+		label: label,
+		x:     []string{"a", "b", "b", "c"},
+		y:     []string{"d", "b", "a", "d"},
+		opts:  []cmp.Option{cmp.EquateSets()},
+		wantDiff: `
+{[]string}[3]:
+	-: "c"
+	+: <non-existent>
+{[]string}[0]:
+	-: <non-existent>
+	+: "d"
+{[]string}[3]:
+	-: <non-existent>
+	+: "d"`,
 	}, {
-		label:    label + "StructA1",
-		x:        ts.StructA1{ts.StructA{"NotEqual"}, "NotEqual"},
-		y:        ts.StructA1{ts.StructA{"not_equal"}, "not_equal"},
-		wantDiff: "{teststructs.StructA1}.X:\n\t-: \"NotEqual\"\n\t+: \"not_equal\"\n",
+		// Both 1.0 and 2.0 are within the margin of 1.5 and each other.
+		label: label,
+		x:     []float64{1.5},
+		y:     []float64{1.0, 2.0, 1.5},
+		opts:  []cmp.Option{cmp.EquateSets(), cmp.EquateApprox(0, 0.5)},
 	}, {
-		label: label + "StructA1",
-		x:     &ts.StructA1{ts.StructA{"NotEqual"}, "equal"},
-		y:     &ts.StructA1{ts.StructA{"not_equal"}, "equal"},
+		label: label,
+		x:     [][]string{{}, nil},
+		y:     [][]string{nil, {}},
+		opts:  []cmp.Option{cmp.EquateSets()},
 	}, {
-		label:    label + "StructA1",
-		x:        &ts.StructA1{ts.StructA{"NotEqual"}, "NotEqual"},
-		y:        &ts.StructA1{ts.StructA{"not_equal"}, "not_equal"},
-		wantDiff: "{*teststructs.StructA1}.X:\n\t-: \"NotEqual\"\n\t+: \"not_equal\"\n",
+		label: label,
+		x:     map[string][]string{"a": {}},
+		y:     map[string][]string{"a": nil},
+		opts:  []cmp.Option{cmp.EquateSets()},
+		wantDiff: `
+{map[string][]string}["a"]:
+	-: []string{}
+	+: []string(nil)`,
+	}}
+}
+
+type (
+	userV1 struct {
+		Name  string
+		Age   int
+		Email string
+		notes string
+	}
+	userV2 struct {
+		Name    string
+		Age     int64
+		Email   string
+		Aliases []string
+	}
+	accountV1 struct {
+		Owner *userV1
+	}
+	accountV2 struct {
+		Owner *userV2
+	}
+	userV3 struct {
+		Email string
+		Name  string
+		Age   int
+	}
+)
+
+func fieldsByNameTests() []test {
+	const label = "CompareFieldsByName/"
+
+	return []test{{
+		label: label,
+		x:     userV1{Name: "ann", Email: "ann@example.com"},
+		y:     userV3{Name: "ann", Email: "ann@example.com"},
+		wantDiff: `
+root:
+	-: cmp_test.userV1{Name: "ann", Email: "ann@example.com"}
+	+: cmp_test.userV3{Email: "ann@example.com", Name: "ann"}`,
 	}, {
-		label: label + "StructB1",
-		x:     ts.StructB1{ts.StructB{"NotEqual"}, "equal"},
-		y:     ts.StructB1{ts.StructB{"not_equal"}, "equal"},
-		opts:  []cmp.Option{derefTransform},
+		label: label,
+		x:     userV1{Name: "ann", Email: "ann@example.com", notes: "ignored"},
+		y:     userV3{Name: "ann", Email: "ann@example.com"},
+		opts:  []cmp.Option{cmp.CompareFieldsByName(userV1{}, userV3{})},
 	}, {
-		label:    label + "StructB1",
-		x:        ts.StructB1{ts.StructB{"NotEqual"}, "NotEqual"},
-		y:        ts.StructB1{ts.StructB{"not_equal"}, "not_equal"},
-		opts:     []cmp.Option{derefTransform},
-		wantDiff: "{teststructs.StructB1}.X:\n\t-: \"NotEqual\"\n\t+: \"not_equal\"\n",
+		label: label,
+		x:     &userV3{Name: "ann", Email: "ann@example.com"},
+		y:     &userV1{Name: "ann", Email: "ann@example.org"},
+		opts:  []cmp.Option{cmp.CompareFieldsByName(userV1{}, userV3{})},
+		wantDiff: `
+λbyName(root).Email:
+	-: "ann@example.com"
+	+: "ann@example.org"`,
 	}, {
-		label: label + "StructB1",
-		x:     &ts.StructB1{ts.StructB{"NotEqual"}, "equal"},
-		y:     &ts.StructB1{ts.StructB{"not_equal"}, "equal"},
-		opts:  []cmp.Option{derefTransform},
+		label: label,
+		x:     accountV1{&userV1{Name: "ann", Age: 30}},
+		y:     accountV2{&userV2{Name: "ann", Age: 30, Aliases: []string{"annie"}}},
+		opts: []cmp.Option{
+			cmp.CompareFieldsByName(accountV1{}, accountV2{}),
+			cmp.CompareFieldsByName(reflect.TypeOf(userV1{}), (*userV2)(nil)),
+		},
+		wantDiff: `
+λbyName(λbyName(root).Owner).Age:
+	-: 30
+	+: 30
+	(field Age has type int in cmp_test.userV1, but int64 in cmp_test.userV2)
+λbyName(λbyName(root).Owner).Aliases:
+	-: <non-existent>
+	+: []string{"annie"}
+	(field Aliases does not exist in cmp_test.userV1)`,
 	}, {
-		label:    label + "StructB1",
-		x:        &ts.StructB1{ts.StructB{"NotEqual"}, "NotEqual"},
-		y:        &ts.StructB1{ts.StructB{"not_equal"}, "not_equal"},
-		opts:     []cmp.Option{derefTransform},
-		wantDiff: "{*teststructs.StructB1}.X:\n\t-: \"NotEqual\"\n\t+: \"not_equal\"\n",
+		label: label,
+		x:     []interface{}{userV1{Name: "ann"}, userV3{Name: "bob"}},
+		y:     []interface{}{userV3{Name: "ann"}, userV1{Name: "bob"}},
+		opts:  []cmp.Option{cmp.CompareFieldsByName(userV1{}, userV3{})},
+	}}
+}
+
+func pointersToValuesTests() []test {
+	const label = "EquatePointersToValues/"
+
+	type Config struct {
+		Name  string
+		Ports []int
+	}
+	return []test{{
+		label: label,
+		x:     []interface{}{&Config{Name: "a"}, Config{Name: "b"}},
+		y:     []interface{}{Config{Name: "a"}, &Config{Name: "b"}},
+		wantDiff: `
+root[0]:
+	-: &cmp_test.Config{Name: "a"}
+	+: cmp_test.Config{Name: "a"}
+root[1]:
+	-: cmp_test.Config{Name: "b"}
+	+: &cmp_test.Config{Name: "b"}`,
 	}, {
-		label: label + "StructC1",
-		x:     ts.StructC1{ts.StructC{"NotEqual"}, "NotEqual"},
-		y:     ts.StructC1{ts.StructC{"not_equal"}, "not_equal"},
+		label: label,
+		x:     []interface{}{&Config{Name: "a"}, Config{Name: "b"}},
+		y:     []interface{}{Config{Name: "a"}, &Config{Name: "b"}},
+		opts:  []cmp.Option{cmp.EquatePointersToValues()},
 	}, {
-		label: label + "StructC1",
-		x:     &ts.StructC1{ts.StructC{"NotEqual"}, "NotEqual"},
-		y:     &ts.StructC1{ts.StructC{"not_equal"}, "not_equal"},
+		label: label,
+		x:     &Config{Name: "a", Ports: []int{80}},
+		y:     Config{Name: "a", Ports: []int{443}},
+		opts:  []cmp.Option{cmp.EquatePointersToValues()},
+		wantDiff: `
+root.Ports[0]:
+	-: 80
+	+: 443`,
 	}, {
-		label: label + "StructD1",
-		x:     ts.StructD1{ts.StructD{"NotEqual"}, "NotEqual"},
-		y:     ts.StructD1{ts.StructD{"not_equal"}, "not_equal"},
+		label: label,
+		x:     []interface{}{Config{Name: "a"}},
+		y:     []interface{}{(*Config)(nil)},
+		opts:  []cmp.Option{cmp.EquatePointersToValues()},
 		wantDiff: `
-{teststructs.StructD1}.StructD.X:
-	-: "NotEqual"
-	+: "not_equal"
-{teststructs.StructD1}.X:
-	-: "NotEqual"
-	+: "not_equal"`,
+root[0]:
+	-: cmp_test.Config{Name: "a"}
+	+: (*cmp_test.Config)(nil)
+	(nil *cmp_test.Config cannot be dereferenced to compare with cmp_test.Config)`,
 	}, {
-		label: label + "StructD1",
-		x:     ts.StructD1{ts.StructD{"NotEqual"}, "NotEqual"},
-		y:     ts.StructD1{ts.StructD{"not_equal"}, "not_equal"},
-		opts:  []cmp.Option{derefTransform},
+		label: label,
+		x:     []interface{}{Config{Name: "a"}, new(*Config)},
+		y:     []interface{}{new(*Config), Config{Name: "a"}},
+		opts:  []cmp.Option{cmp.EquatePointersToValues()},
+		wantDiff: `
+root[0]:
+	-: cmp_test.Config{Name: "a"}
+	+: &(*cmp_test.Config)(nil)
+root[1]:
+	-: &(*cmp_test.Config)(nil)
+	+: cmp_test.Config{Name: "a"}`,
+	}}
+}
+
+func bytesAndStringsTests() []test {
+	const label = "EquateBytesAndStrings/"
+
+	type Message struct {
+		Body interface{}
+	}
+	type Text string
+	return []test{{
+		label: label,
+		x:     []interface{}{[]byte("hello"), Text("hi")},
+		y:     []interface{}{"hello", []byte("hi")},
+		wantDiff: `
+root[0]:
+	-: []uint8{0x68, 0x65, 0x6c, 0x6c, 0x6f}
+	+: "hello"
+root[1]:
+	-: "hi"
+	+: []uint8{0x68, 0x69}`,
 	}, {
-		label: label + "StructD1",
-		x:     &ts.StructD1{ts.StructD{"NotEqual"}, "NotEqual"},
-		y:     &ts.StructD1{ts.StructD{"not_equal"}, "not_equal"},
+		label: label,
+		x:     []interface{}{[]byte("hello"), `{"a":1}`, []byte("same"), "", Text("hi")},
+		y:     []interface{}{"hello", json.RawMessage(`{"a":1}`), []byte("same"), []byte(nil), []byte("hi")},
+		opts:  []cmp.Option{cmp.EquateBytesAndStrings()},
 	}, {
-		label: label + "StructE1",
-		x:     ts.StructE1{ts.StructE{"NotEqual"}, "NotEqual"},
-		y:     ts.StructE1{ts.StructE{"not_equal"}, "not_equal"},
+		label: label,
+		x:     Message{Body: json.RawMessage(`{"a":1}`)},
+		y:     Message{Body: `{"a":2}`},
+		opts:  []cmp.Option{cmp.EquateBytesAndStrings()},
 		wantDiff: `
-{teststructs.StructE1}.StructE.X:
-	-: "NotEqual"
-	+: "not_equal"
-{teststructs.StructE1}.X:
-	-: "NotEqual"
-	+: "not_equal"`,
-	}, {
-		label: label + "StructE1",
-		x:     ts.StructE1{ts.StructE{"NotEqual"}, "NotEqual"},
-		y:     ts.StructE1{ts.StructE{"not_equal"}, "not_equal"},
-		opts:  []cmp.Option{derefTransform},
+{cmp_test.Message}.Body:
+	-: "{\"a\":1}"
+	+: "{\"a\":2}"`,
 	}, {
-		label: label + "StructE1",
-		x:     &ts.StructE1{ts.StructE{"NotEqual"}, "NotEqual"},
-		y:     &ts.StructE1{ts.StructE{"not_equal"}, "not_equal"},
+		label: label,
+		x:     []byte("hello"),
+		y:     "world",
+		opts:  []cmp.Option{cmp.EquateBytesAndStrings()},
+		wantDiff: `
+root:
+	-: "hello"
+	+: "world"`,
+	}}
+}
+
+func runesAndStringsTests() []test {
+	const label = "EquateRunesAndStrings/"
+
+	type Runes []rune
+	return []test{{
+		label: label,
+		x:     []interface{}{[]rune("héllo"), "hi"},
+		y:     []interface{}{"héllo", []rune("hi")},
+		wantDiff: `
+root[0]:
+	-: []int32{104, 233, 108, 108, 111}
+	+: "héllo"
+root[1]:
+	-: "hi"
+	+: []int32{104, 105}`,
 	}, {
-		label: label + "StructF1",
-		x:     ts.StructF1{ts.StructF{"NotEqual"}, "NotEqual"},
-		y:     ts.StructF1{ts.StructF{"not_equal"}, "not_equal"},
+		label: label,
+		x:     []interface{}{[]rune("héllo"), "hi", Runes("ok"), []rune(nil), []rune("same")},
+		y:     []interface{}{"héllo", Runes("hi"), "ok", "", []rune("same")},
+		opts:  []cmp.Option{cmp.EquateRunesAndStrings()},
+	}, {
+		label: label,
+		x:     []rune("hello"),
+		y:     "world",
+		opts:  []cmp.Option{cmp.EquateRunesAndStrings()},
 		wantDiff: `
-{teststructs.StructF1}.StructF.X:
-	-: "NotEqual"
-	+: "not_equal"
-{teststructs.StructF1}.X:
-	-: "NotEqual"
-	+: "not_equal"`,
+root:
+	-: "hello"
+	+: "world"`,
 	}, {
-		label: label + "StructF1",
-		x:     &ts.StructF1{ts.StructF{"NotEqual"}, "NotEqual"},
-		y:     &ts.StructF1{ts.StructF{"not_equal"}, "not_equal"},
+		label: label,
+		x:     []rune{'a', 0xd800},
+		y:     "a\ufffd",
+		opts:  []cmp.Option{cmp.EquateRunesAndStrings()},
+		wantDiff: "\nroot:\n\t-: \"a\ufffd\"\n\t+: \"a\ufffd\"\n" +
+			"\t([]rune contains invalid code point U+D800 at index 1)",
 	}, {
-		label: label + "StructA2",
-		x:     ts.StructA2{&ts.StructA{"NotEqual"}, "equal"},
-		y:     ts.StructA2{&ts.StructA{"not_equal"}, "equal"},
+		label: label,
+		x:     []rune("abc"),
+		y:     []rune("abd"),
+		opts:  []cmp.Option{cmp.EquateRunesAndStrings()},
+		wantDiff: `
+{[]int32}[2]:
+	-: 99
+	+: 100`,
+	}}
+}
+
+func numbersInInterfacesTests() []test {
+	const label = "EquateNumbersInInterfaces/"
+
+	var decoded interface{}
+	if err := json.Unmarshal([]byte(`{"n":1,"f":2.5}`), &decoded); err != nil {
+		panic(err)
+	}
+	type Counts struct {
+		A int
+		B float64
+	}
+	return []test{{
+		label: label,
+		x:     decoded,
+		y:     map[string]interface{}{"n": 1, "f": 2.5},
+		wantDiff: `
+root["n"]:
+	-: 1
+	+: 1`,
 	}, {
-		label:    label + "StructA2",
-		x:        ts.StructA2{&ts.StructA{"NotEqual"}, "NotEqual"},
-		y:        ts.StructA2{&ts.StructA{"not_equal"}, "not_equal"},
-		wantDiff: "{teststructs.StructA2}.X:\n\t-: \"NotEqual\"\n\t+: \"not_equal\"\n",
+		label: label,
+		x:     decoded,
+		y:     map[string]interface{}{"n": 1, "f": 2.5},
+		opts:  []cmp.Option{cmp.EquateNumbersInInterfaces()},
 	}, {
-		label: label + "StructA2",
-		x:     &ts.StructA2{&ts.StructA{"NotEqual"}, "equal"},
-		y:     &ts.StructA2{&ts.StructA{"not_equal"}, "equal"},
+		label: label,
+		x:     []interface{}{int8(-1), uint64(1 << 63), 1 << 53, float32(0.5), 3.0},
+		y:     []interface{}{int64(-1), float64(1 << 63), float64(1 << 53), 0.5, uint8(3)},
+		opts:  []cmp.Option{cmp.EquateNumbersInInterfaces()},
 	}, {
-		label:    label + "StructA2",
-		x:        &ts.StructA2{&ts.StructA{"NotEqual"}, "NotEqual"},
-		y:        &ts.StructA2{&ts.StructA{"not_equal"}, "not_equal"},
-		wantDiff: "{*teststructs.StructA2}.X:\n\t-: \"NotEqual\"\n\t+: \"not_equal\"\n",
+		label: label,
+		x:     []interface{}{-1, 2.5, math.NaN(), uint64(math.MaxUint64), "1"},
+		y:     []interface{}{uint(math.MaxUint64), 2, math.NaN(), float64(math.MaxUint64), 1},
+		opts:  []cmp.Option{cmp.EquateNumbersInInterfaces()},
+		wantDiff: `
+root[0]:
+	-: -1
+	+: 0xffffffffffffffff
+	(compared int and uint by their numeric values)
+root[1]:
+	-: 2.5
+	+: 2
+	(compared float64 and int by their numeric values)
+root[2].(float64):
+	-: NaN
+	+: NaN
+root[3]:
+	-: 0xffffffffffffffff
+	+: 1.8446744073709552e+19
+	(compared uint64 and float64 by their numeric values)
+root[4]:
+	-: "1"
+	+: 1`,
 	}, {
-		label: label + "StructB2",
-		x:     ts.StructB2{&ts.StructB{"NotEqual"}, "equal"},
-		y:     ts.StructB2{&ts.StructB{"not_equal"}, "equal"},
+		label: label,
+		x:     Counts{A: 1, B: 1},
+		y:     Counts{A: 2, B: 1},
+		opts:  []cmp.Option{cmp.EquateNumbersInInterfaces()},
+		wantDiff: `
+{cmp_test.Counts}.A:
+	-: 1
+	+: 2`,
 	}, {
-		label:    label + "StructB2",
-		x:        ts.StructB2{&ts.StructB{"NotEqual"}, "NotEqual"},
-		y:        ts.StructB2{&ts.StructB{"not_equal"}, "not_equal"},
-		wantDiff: "{teststructs.StructB2}.X:\n\t-: \"NotEqual\"\n\t+: \"not_equal\"\n",
+		label: label,
+		x:     1,
+		y:     1.0,
+		opts:  []cmp.Option{cmp.EquateNumbersInInterfaces()},
+	}}
+}
+
+func structsToMapsTests() []test {
+	const label = "EquateStructsToMaps/"
+
+	type Address struct {
+		City string `json:"city"`
+		Zip  string `json:"zip,omitempty"`
+	}
+	type Person struct {
+		Name     string            `json:"name"`
+		Age      int               `json:"age"`
+		Home     *Address          `json:"home"`
+		Work     *Address          `json:"work"`
+		Previous []Address         `json:"previous,omitempty"`
+		Labels   map[string]string `json:"labels"`
+		Secret   string            `json:"-"`
+		Nickname string
+		internal int
+	}
+	decode := func(v interface{}) map[string]interface{} {
+		b, err := json.Marshal(v)
+		if err != nil {
+			panic(err)
+		}
+		var m map[string]interface{}
+		if err := json.Unmarshal(b, &m); err != nil {
+			panic(err)
+		}
+		return m
+	}
+	p := Person{
+		Name:     "Ann",
+		Age:      30,
+		Home:     &Address{City: "Oslo", Zip: "0150"},
+		Previous: []Address{{City: "Bergen"}},
+		Labels:   map[string]string{"team": "core"},
+		Secret:   "hunter2",
+		Nickname: "annie",
+		internal: 1,
+	}
+	opts := []cmp.Option{cmp.EquateStructsToMaps(false), cmp.EquateNumbersInInterfaces()}
+	return []test{{
+		label: label,
+		x:     p,
+		y:     decode(p),
+		opts:  opts,
 	}, {
-		label: label + "StructB2",
-		x:     &ts.StructB2{&ts.StructB{"NotEqual"}, "equal"},
-		y:     &ts.StructB2{&ts.StructB{"not_equal"}, "equal"},
+		label: label,
+		x:     decode(&p),
+		y:     &p,
+		opts:  opts,
 	}, {
-		label:    label + "StructB2",
-		x:        &ts.StructB2{&ts.StructB{"NotEqual"}, "NotEqual"},
-		y:        &ts.StructB2{&ts.StructB{"not_equal"}, "not_equal"},
-		wantDiff: "{*teststructs.StructB2}.X:\n\t-: \"NotEqual\"\n\t+: \"not_equal\"\n",
+		label: label,
+		x:     p,
+		y: func() map[string]interface{} {
+			m := decode(p)
+			delete(m, "work")
+			return m
+		}(),
+		opts: []cmp.Option{cmp.EquateStructsToMaps(true), cmp.EquateNumbersInInterfaces()},
 	}, {
-		label: label + "StructC2",
-		x:     ts.StructC2{&ts.StructC{"NotEqual"}, "NotEqual"},
-		y:     ts.StructC2{&ts.StructC{"not_equal"}, "not_equal"},
+		label: label,
+		x:     p,
+		y: func() map[string]interface{} {
+			m := decode(p)
+			m["home"].(map[string]interface{})["city"] = "Bergen"
+			delete(m, "work")
+			return m
+		}(),
+		opts: opts,
+		wantDiff: `
+λtoMap(root)["home"].(map[string]interface {})["city"].(string):
+	-: "Oslo"
+	+: "Bergen"
+λtoMap(root)["work"]:
+	-: interface {}(nil)
+	+: <non-existent>`,
 	}, {
-		label: label + "StructC2",
-		x:     &ts.StructC2{&ts.StructC{"NotEqual"}, "NotEqual"},
-		y:     &ts.StructC2{&ts.StructC{"not_equal"}, "not_equal"},
+		label: label,
+		x:     p,
+		y:     decode(p),
+		wantDiff: `
+root:
+	-: cmp_test.Person{Name: "Ann", Age: 30, Home: &cmp_test.Address{City: "Oslo", Zip: "0150"}, Previous: []cmp_test.Address{{City: "Bergen"}}, Labels: map[string]string{"team": "core"}, Secret: "hunter2", Nickname: "annie", internal: 1}
+	+: map[string]interface {}{"Nickname": "annie", "age": 30, "home": map[string]interface {}{"city": "Oslo", "zip": "0150"}, "labels": map[string]interface {}{"team": "core"}, "name": "Ann", "previous": []interface {}{map[string]interface {}{"city": "Bergen"}}, "work": interface {}(nil)}`,
+	}}
+}
+
+// ipv4 is an IPv4 address that implements encoding.TextMarshaler,
+// but has unexported fields and no Equal method.
+type ipv4 struct{ b [4]byte }
+
+func (ip *ipv4) MarshalText() ([]byte, error) {
+	if ip.b[0] == 0 {
+		return nil, errors.New("unspecified address")
+	}
+	return []byte(fmt.Sprintf("%d.%d.%d.%d", ip.b[0], ip.b[1], ip.b[2], ip.b[3])), nil
+}
+
+func textMarshalerTests() []test {
+	const label = "TextMarshaler/"
+
+	type Host struct {
+		Addr    ipv4
+		Started time.Time
+	}
+	t0 := time.Date(2009, time.November, 10, 23, 0, 0, 0, time.UTC)
+	t1 := t0.In(time.FixedZone("EST", -5*60*60)) // Same instant, different text
+	return []test{{
+		label:     label,
+		x:         Host{Addr: ipv4{[4]byte{127, 0, 0, 1}}},
+		y:         Host{Addr: ipv4{[4]byte{127, 0, 0, 1}}},
+		wantPanic: "cannot handle unexported field",
 	}, {
-		label: label + "StructD2",
-		x:     ts.StructD2{&ts.StructD{"NotEqual"}, "NotEqual"},
-		y:     ts.StructD2{&ts.StructD{"not_equal"}, "not_equal"},
+		label: label,
+		x:     Host{ipv4{[4]byte{127, 0, 0, 1}}, t0},
+		y:     Host{ipv4{[4]byte{127, 0, 0, 1}}, t1},
+		opts:  []cmp.Option{cmp.CompareViaTextMarshaler()},
 	}, {
-		label: label + "StructD2",
-		x:     &ts.StructD2{&ts.StructD{"NotEqual"}, "NotEqual"},
-		y:     &ts.StructD2{&ts.StructD{"not_equal"}, "not_equal"},
+		label: label,
+		x:     []*ipv4{{[4]byte{127, 0, 0, 1}}, {[4]byte{10, 0, 0, 1}}},
+		y:     []*ipv4{{[4]byte{127, 0, 0, 1}}, {[4]byte{10, 0, 0, 2}}},
+		opts:  []cmp.Option{cmp.CompareViaTextMarshaler()},
+		wantDiff: `
+*{[]*cmp_test.ipv4}[1]:
+	-: "10.0.0.1"
+	+: "10.0.0.2"`,
 	}, {
-		label: label + "StructE2",
-		x:     ts.StructE2{&ts.StructE{"NotEqual"}, "NotEqual"},
-		y:     ts.StructE2{&ts.StructE{"not_equal"}, "not_equal"},
+		label: label,
+		x:     Host{Addr: ipv4{[4]byte{127, 0, 0, 1}}},
+		y:     Host{Addr: ipv4{[4]byte{127, 0, 0, 2}}},
+		opts: []cmp.Option{
+			cmp.CompareViaTextMarshaler(),
+			cmp.Comparer(func(x, y ipv4) bool { return x.b[0] == y.b[0] }),
+		},
 	}, {
-		label: label + "StructE2",
-		x:     &ts.StructE2{&ts.StructE{"NotEqual"}, "NotEqual"},
-		y:     &ts.StructE2{&ts.StructE{"not_equal"}, "not_equal"},
+		label:     label,
+		x:         Host{Addr: ipv4{[4]byte{127, 0, 0, 1}}},
+		y:         Host{},
+		opts:      []cmp.Option{cmp.CompareViaTextMarshaler()},
+		wantPanic: "MarshalText failed at {cmp_test.Host}.Addr: unspecified address",
+	}}
+}
+
+func reflectTypeTests() []test {
+	const label = "ReflectType/"
+
+	type Codec struct {
+		Name string
+		Type reflect.Type
+	}
+	type MyInt int
+	return []test{{
+		label: label,
+		x:     Codec{"int", reflect.TypeOf(0)},
+		y:     Codec{"int", reflect.TypeOf(0)},
 	}, {
-		label: label + "StructF2",
-		x:     ts.StructF2{&ts.StructF{"NotEqual"}, "NotEqual"},
-		y:     ts.StructF2{&ts.StructF{"not_equal"}, "not_equal"},
+		label: label,
+		x:     Codec{"int", reflect.TypeOf(0)},
+		y:     Codec{"int", reflect.TypeOf(MyInt(0))},
+		wantDiff: `
+{cmp_test.Codec}.Type:
+	-: int
+	+: cmp_test.MyInt`,
 	}, {
-		label: label + "StructF2",
-		x:     &ts.StructF2{&ts.StructF{"NotEqual"}, "NotEqual"},
-		y:     &ts.StructF2{&ts.StructF{"not_equal"}, "not_equal"},
+		label: label,
+		x:     Codec{"int", reflect.TypeOf(0)},
+		y:     Codec{"int", nil},
+		wantDiff: `
+{cmp_test.Codec}.Type:
+	-: int
+	+: reflect.Type(nil)`,
 	}, {
-		label:    label + "StructNo",
-		x:        ts.StructNo{"NotEqual"},
-		y:        ts.StructNo{"not_equal"},
-		wantDiff: "{teststructs.StructNo}.X:\n\t-: \"NotEqual\"\n\t+: \"not_equal\"\n",
+		label: label,
+		x:     map[reflect.Type]string{reflect.TypeOf(""): "string"},
+		y:     map[reflect.Type]string{reflect.TypeOf(""): "string"},
 	}, {
-		label: label + "AssignA",
-		x:     ts.AssignA(func() int { return 0 }),
-		y:     ts.AssignA(func() int { return 1 }),
+		label: label,
+		x:     reflect.TypeOf(MyInt(0)),
+		y:     reflect.TypeOf(0),
+		wantDiff: `
+{*reflect.rtype}:
+	-: cmp_test.MyInt
+	+: int`,
+	}}
+}
+
+func comparePointersByIdentityTests() []test {
+	const label = "ComparePointersByIdentity/"
+
+	type Node struct {
+		Name string
+		Next *Node
+	}
+	type Route struct {
+		From, To *Node
+	}
+	a1, a2 := &Node{Name: "a"}, &Node{Name: "a"}
+	cyclic := &Node{Name: "b"}
+	cyclic.Next = cyclic
+	opt := cmp.ComparePointersByIdentity((*Node)(nil))
+	return []test{{
+		label: label,
+		x:     Route{a1, a2},
+		y:     Route{a2, a1},
 	}, {
-		label: label + "AssignB",
-		x:     ts.AssignB(struct{ A int }{0}),
-		y:     ts.AssignB(struct{ A int }{1}),
+		label: label,
+		x:     Route{a1, a2},
+		y:     Route{a1, &Node{Name: "b"}},
+		wantDiff: `
+{cmp_test.Route}.To.Name:
+	-: "a"
+	+: "b"`,
 	}, {
-		label: label + "AssignC",
-		x:     ts.AssignC(make(chan bool)),
-		y:     ts.AssignC(make(chan bool)),
+		label: label,
+		x:     Route{a1, cyclic},
+		y:     Route{a1, cyclic},
+		opts:  []cmp.Option{opt},
 	}, {
-		label: label + "AssignD",
-		x:     ts.AssignD(make(chan bool)),
-		y:     ts.AssignD(make(chan bool)),
+		label: label,
+		x:     Route{},
+		y:     Route{},
+		opts:  []cmp.Option{opt},
 	}}
 }
 
-func project1Tests() []test {
-	const label = "Project1"
-
-	ignoreUnexported := IgnoreUnexported(
-		ts.EagleImmutable{},
-		ts.DreamerImmutable{},
-		ts.SlapImmutable{},
-		ts.GoatImmutable{},
-		ts.DonkeyImmutable{},
-		ts.LoveRadius{},
-		ts.SummerLove{},
-		ts.SummerLoveSummary{},
-	)
+func shallowTests() []test {
+	const label = "Shallow/"
 
-	createEagle := func() ts.Eagle {
-		return ts.Eagle{
-			Name:   "eagle",
-			Hounds: []string{"buford", "tannen"},
-			Desc:   "some description",
-			Dreamers: []ts.Dreamer{{}, {
-				Name: "dreamer2",
-				Animal: []interface{}{
-					ts.Goat{
-						Target: "corporation",
-						Immutable: &ts.GoatImmutable{
-							ID:      "southbay",
-							State:   (*pb.Goat_States)(intPtr(5)),
-							Started: now,
-						},
-					},
-					ts.Donkey{},
-				},
-				Amoeba: 53,
-			}},
-			Slaps: []ts.Slap{{
-				Name: "slapID",
-				Args: &pb.MetaData{Stringer: pb.Stringer{"metadata"}},
-				Immutable: &ts.SlapImmutable{
-					ID:       "immutableSlap",
-					MildSlap: true,
-					Started:  now,
-					LoveRadius: &ts.LoveRadius{
-						Summer: &ts.SummerLove{
-							Summary: &ts.SummerLoveSummary{
-								Devices:    []string{"foo", "bar", "baz"},
-								ChangeType: []pb.SummerType{1, 2, 3},
-							},
-						},
-					},
-				},
-			}},
-			Immutable: &ts.EagleImmutable{
-				ID:          "eagleID",
-				Birthday:    now,
-				MissingCall: (*pb.Eagle_MissingCalls)(intPtr(55)),
-			},
-		}
+	type Buffer struct {
+		Name  string
+		Data  []byte
+		Index map[string]int
+		Next  *Buffer
+	}
+	data := []byte("hello")
+	index := map[string]int{"h": 0}
+	next := &Buffer{Name: "next"}
+	x := &Buffer{"a", data, index, next}
+	return []test{{
+		label: label,
+		x:     x,
+		y:     &Buffer{"a", data, index, next},
+		opts:  []cmp.Option{cmp.Shallow()},
+	}, {
+		label: label,
+		x:     x,
+		y:     &Buffer{"a", data, index, next},
+	}, {
+		label: label,
+		x:     x,
+		y:     &Buffer{"a", []byte("hello"), index, next},
+	}, {
+		label: label,
+		x:     x,
+		y:     &Buffer{"a", data[:4], index, next},
+		wantDiff: `
+{*cmp_test.Buffer}.Data[4]:
+	-: 0x6f
+	+: <non-existent>`,
+	}, {
+		label: label,
+		x:     x,
+		y:     &Buffer{"a", data, map[string]int{"h": 0}, next},
+	}, {
+		label: label,
+		x:     x,
+		y:     &Buffer{"a", data, index, &Buffer{Name: "next"}},
+	}, {
+		label: label,
+		x:     x,
+		y:     &Buffer{"b", data, index, next},
+		opts:  []cmp.Option{cmp.Shallow()},
+		wantDiff: `
+{*cmp_test.Buffer}.Name:
+	-: "a"
+	+: "b"`,
+	}, {
+		label: label,
+		x:     x,
+		y:     &Buffer{"b", data, index, next},
+		wantDiff: `
+{*cmp_test.Buffer}.Name:
+	-: "a"
+	+: "b"`,
+	}}
+}
+
+func requireComparisonsTests() []test {
+	const label = "RequireComparisons/"
+
+	type Record struct {
+		ID    int
+		Tags  []string
+		notes string
 	}
+	ignoreAll := cmp.Options{
+		IgnoreUnexported(Record{}),
+		cmp.FilterPath(func(p cmp.Path) bool { return len(p) > 1 }, cmp.Ignore()),
+	}
+	return []test{{
+		label:     label,
+		x:         Record{1, []string{"a", "b"}, "x"},
+		y:         Record{2, []string{"c"}, "y"},
+		opts:      []cmp.Option{ignoreAll, cmp.RequireComparisons(1)},
+		wantPanic: "vacuous comparison: 0 values were compared, but at least 1 are required",
+	}, {
+		label: label,
+		x:     Record{1, []string{"a", "b"}, "x"},
+		y:     Record{2, []string{"c"}, "y"},
+		opts:  []cmp.Option{IgnoreUnexported(Record{}), cmp.RequireComparisons(2)},
+		wantDiff: `
+{cmp_test.Record}.ID:
+	-: 1
+	+: 2
+{cmp_test.Record}.Tags[0]:
+	-: "a"
+	+: "c"
+{cmp_test.Record}.Tags[1]:
+	-: "b"
+	+: <non-existent>`,
+	}}
+}
+
+func transformChannelContentsTests() []test {
+	const label = "TransformChannelContents/"
 
+	makeChan := func(closed bool, vs ...int) chan int {
+		c := make(chan int, 5)
+		for _, v := range vs {
+			c <- v
+		}
+		if closed {
+			close(c)
+		}
+		return c
+	}
+	type pipeline struct{ Out chan int }
+	all := func(cmp.Path) bool { return true }
+	opt := cmp.FilterPath(all, cmp.TransformChannelContents())
 	return []test{{
+		label:     label,
+		x:         makeChan(true, 1),
+		y:         makeChan(true, 1),
+		opts:      []cmp.Option{cmp.TransformChannelContents()},
+		wantPanic: "cannot use an unfiltered option",
+	}, {
 		label: label,
-		x: ts.Eagle{Slaps: []ts.Slap{{
-			Args: &pb.MetaData{Stringer: pb.Stringer{"metadata"}},
-		}}},
-		y: ts.Eagle{Slaps: []ts.Slap{{
-			Args: &pb.MetaData{Stringer: pb.Stringer{"metadata"}},
-		}}},
-		wantPanic: "cannot handle unexported field",
+		x:     pipeline{makeChan(true, 1, 2, 3)},
+		y:     pipeline{makeChan(true, 1, 2, 3)},
+		opts:  []cmp.Option{opt},
 	}, {
 		label: label,
-		x: ts.Eagle{Slaps: []ts.Slap{{
-			Args: &pb.MetaData{Stringer: pb.Stringer{"metadata"}},
-		}}},
-		y: ts.Eagle{Slaps: []ts.Slap{{
-			Args: &pb.MetaData{Stringer: pb.Stringer{"metadata"}},
-		}}},
-		opts:     []cmp.Option{cmp.Comparer(pb.Equal)},
-		wantDiff: "",
+		x:     makeChan(true, 1, 2, 3),
+		y:     makeChan(true, 3, 2, 1),
+		opts:  []cmp.Option{opt},
+		wantDiff: `
+λdrain({chan int})[0]:
+	-: 1
+	+: 3
+λdrain({chan int})[2]:
+	-: 3
+	+: 1`,
 	}, {
 		label: label,
-		x: ts.Eagle{Slaps: []ts.Slap{{}, {}, {}, {}, {
-			Args: &pb.MetaData{Stringer: pb.Stringer{"metadata"}},
-		}}},
-		y: ts.Eagle{Slaps: []ts.Slap{{}, {}, {}, {}, {
-			Args: &pb.MetaData{Stringer: pb.Stringer{"metadata2"}},
-		}}},
-		opts:     []cmp.Option{cmp.Comparer(pb.Equal)},
-		wantDiff: "{teststructs.Eagle}.Slaps[4].Args:\n\t-: \"metadata\"\n\t+: \"metadata2\"\n",
+		x:     pipeline{makeChan(true, 1, 2)},
+		y:     pipeline{makeChan(true, 1, 2, 3)},
+		opts:  []cmp.Option{opt},
+		wantDiff: `
+λdrain({cmp_test.pipeline}.Out)[2]:
+	-: <non-existent>
+	+: 3`,
+	}}
+}
+
+func transformSyncMapsTests() []test {
+	const label = "TransformSyncMaps/"
+
+	type cache struct {
+		Entries sync.Map
+		Shared  *sync.Map
+	}
+	makeCache := func(keys ...string) *cache {
+		c := &cache{Shared: new(sync.Map)}
+		for _, k := range keys {
+			c.Entries.Store(k, len(k))
+			c.Shared.Store(k, k+k)
+		}
+		return c
+	}
+	changed := makeCache("c", "a", "b")
+	changed.Shared.Store("b", "bbb")
+	opt := cmp.TransformSyncMaps()
+	return []test{{
+		label: label,
+		x:     makeCache("a", "b", "c"),
+		y:     makeCache("c", "a", "b"),
+		opts:  []cmp.Option{opt},
 	}, {
 		label: label,
-		x:     createEagle(),
-		y:     createEagle(),
-		opts:  []cmp.Option{ignoreUnexported, cmp.Comparer(pb.Equal)},
+		x:     makeCache("a", "b", "c"),
+		y:     changed,
+		opts:  []cmp.Option{opt},
+		wantDiff: `
+λsnapshot({*cmp_test.cache}.Shared)["b"].(string):
+	-: "bb"
+	+: "bbb"`,
 	}, {
 		label: label,
-		x: func() ts.Eagle {
-			eg := createEagle()
-			eg.Dreamers[1].Animal[0].(ts.Goat).Immutable.ID = "southbay2"
-			eg.Dreamers[1].Animal[0].(ts.Goat).Immutable.State = (*pb.Goat_States)(intPtr(6))
-			eg.Slaps[0].Immutable.MildSlap = false
-			return eg
-		}(),
-		y: func() ts.Eagle {
-			eg := createEagle()
-			devs := eg.Slaps[0].Immutable.LoveRadius.Summer.Summary.Devices
-			eg.Slaps[0].Immutable.LoveRadius.Summer.Summary.Devices = devs[:1]
-			return eg
-		}(),
-		opts: []cmp.Option{ignoreUnexported, cmp.Comparer(pb.Equal)},
+		x:     (*sync.Map)(nil),
+		y:     (*sync.Map)(nil),
+		opts:  []cmp.Option{opt},
+	}, {
+		label: label,
+		x:     (*sync.Map)(nil),
+		y:     makeCache("a").Shared,
+		opts:  []cmp.Option{opt},
 		wantDiff: `
-{teststructs.Eagle}.Dreamers[1].Animal[0].(teststructs.Goat).Immutable.ID:
-	-: "southbay2"
-	+: "southbay"
-*{teststructs.Eagle}.Dreamers[1].Animal[0].(teststructs.Goat).Immutable.State:
-	-: 6
-	+: 5
-{teststructs.Eagle}.Slaps[0].Immutable.MildSlap:
-	-: false
-	+: true
-{teststructs.Eagle}.Slaps[0].Immutable.LoveRadius.Summer.Summary.Devices[1]:
-	-: "bar"
-	+: <non-existent>
-{teststructs.Eagle}.Slaps[0].Immutable.LoveRadius.Summer.Summary.Devices[2]:
-	-: "baz"
-	+: <non-existent>`,
+λsnapshot({*sync.Map}):
+	-: map[interface {}]interface {}(nil)
+	+: map[interface {}]interface {}{"a": "aa"}`,
 	}}
 }
 
-func project2Tests() []test {
-	const label = "Project2"
+func transformLoadsTests() []test {
+	const label = "TransformLoads/"
 
-	sortGerms := cmp.FilterValues(func(x, y []*pb.Germ) bool {
-		ok1 := sort.SliceIsSorted(x, func(i, j int) bool {
-			return x[i].String() < x[j].String()
-		})
-		ok2 := sort.SliceIsSorted(y, func(i, j int) bool {
-			return y[i].String() < y[j].String()
-		})
-		return !ok1 || !ok2
-	}, cmp.Transformer("Sort", func(in []*pb.Germ) []*pb.Germ {
-		out := append([]*pb.Germ(nil), in...) // Make copy
-		sort.Slice(out, func(i, j int) bool {
-			return out[i].String() < out[j].String()
-		})
-		return out
-	}))
+	type point struct{ X, Y int }
+	type config struct {
+		Current atomic.Value
+		Backup  *atomic.Value
+		Custom  loader
+	}
+	makeConfig := func(p point, custom string) *config {
+		c := &config{Custom: loader{custom}}
+		c.Current.Store(p)
+		return c
+	}
+	stored := new(atomic.Value)
+	stored.Store(point{})
+	opt := cmp.TransformLoads()
+	return []test{{
+		label: label,
+		x:     makeConfig(point{1, 2}, "a"),
+		y:     makeConfig(point{1, 2}, "a"),
+		opts:  []cmp.Option{opt},
+	}, {
+		label: label,
+		x:     makeConfig(point{1, 2}, "a"),
+		y:     makeConfig(point{1, 3}, "a"),
+		opts:  []cmp.Option{opt},
+		wantDiff: `
+λload({*cmp_test.config}.Current).(cmp_test.point).Y:
+	-: 2
+	+: 3`,
+	}, {
+		label: label,
+		x:     loader{"a"},
+		y:     loader{"b"},
+		opts:  []cmp.Option{opt},
+		wantDiff: `
+λload({cmp_test.loader}).(string):
+	-: "a"
+	+: "b"`,
+	}, {
+		label: label,
+		x:     new(atomic.Value),
+		y:     new(atomic.Value),
+		opts:  []cmp.Option{opt},
+	}, {
+		label: label,
+		x:     (*atomic.Value)(nil),
+		y:     new(atomic.Value),
+		opts:  []cmp.Option{opt},
+	}, {
+		label: label,
+		x:     new(atomic.Value),
+		y:     stored,
+		opts:  []cmp.Option{opt},
+		wantDiff: `
+λload({*atomic.Value}):
+	-: interface {}(nil)
+	+: cmp_test.point{}`,
+	}}
+}
 
-	equalDish := cmp.Comparer(func(x, y *ts.Dish) bool {
-		if x == nil || y == nil {
-			return x == nil && y == nil
-		}
-		px, err1 := x.Proto()
-		py, err2 := y.Proto()
-		if err1 != nil || err2 != nil {
-			return err1 == err2
-		}
-		return pb.Equal(px, py)
-	})
+func transformReadersTests() []test {
+	const label = "TransformReaders/"
 
-	createBatch := func() ts.GermBatch {
-		return ts.GermBatch{
-			DirtyGerms: map[int32][]*pb.Germ{
-				17: []*pb.Germ{
-					&pb.Germ{Stringer: pb.Stringer{"germ1"}},
-				},
-				18: []*pb.Germ{
-					&pb.Germ{Stringer: pb.Stringer{"germ2"}},
-					&pb.Germ{Stringer: pb.Stringer{"germ3"}},
-					&pb.Germ{Stringer: pb.Stringer{"germ4"}},
-				},
-			},
-			GermMap: map[int32]*pb.Germ{
-				13: &pb.Germ{Stringer: pb.Stringer{"germ13"}},
-				21: &pb.Germ{Stringer: pb.Stringer{"germ21"}},
-			},
-			DishMap: map[int32]*ts.Dish{
-				0: ts.CreateDish(nil, io.EOF),
-				1: ts.CreateDish(nil, io.ErrUnexpectedEOF),
-				2: ts.CreateDish(&pb.Dish{Stringer: pb.Stringer{"dish"}}, nil),
-			},
-			HasPreviousResult: true,
-			DirtyID:           10,
-			GermStrain:        421,
-			InfectedAt:        now,
-		}
+	type message struct {
+		Body    *bytes.Buffer
+		Trailer io.Reader
 	}
+	opt := cmp.TransformReaders()
+	return []test{{
+		label: label,
+		x:     message{bytes.NewBufferString("hello"), strings.NewReader("done")},
+		y:     message{bytes.NewBufferString("hello"), bytes.NewReader([]byte("done"))},
+		opts:  []cmp.Option{opt},
+	}, {
+		label: label,
+		x:     message{bytes.NewBufferString("hello"), strings.NewReader("done")},
+		y:     message{bytes.NewBufferString("help"), strings.NewReader("done")},
+		opts:  []cmp.Option{opt},
+		wantDiff: `
+λread({cmp_test.message}.Body)[3]:
+	-: 0x6c
+	+: 0x70
+λread({cmp_test.message}.Body)[4]:
+	-: 0x6f
+	+: <non-existent>`,
+	}, {
+		label: label,
+		x:     (*bytes.Buffer)(nil),
+		y:     new(bytes.Buffer),
+		opts:  []cmp.Option{opt},
+		wantDiff: `
+λread({*bytes.Buffer}):
+	-: []uint8(nil)
+	+: []uint8{}`,
+	}}
+}
 
+func normalizeLineEndingsTests() []test {
+	const label = "NormalizeLineEndings/"
+
+	opt := cmp.NormalizeLineEndings()
 	return []test{{
-		label:     label,
-		x:         createBatch(),
-		y:         createBatch(),
-		wantPanic: "cannot handle unexported field",
+		label: label,
+		x:     "a\r\nb",
+		y:     "a\nb",
+		opts:  []cmp.Option{opt},
 	}, {
 		label: label,
-		x:     createBatch(),
-		y:     createBatch(),
-		opts:  []cmp.Option{cmp.Comparer(pb.Equal), sortGerms, equalDish},
+		x:     []byte("a\rb\r\n"),
+		y:     []byte("a\nb\n"),
+		opts:  []cmp.Option{opt},
 	}, {
 		label: label,
-		x:     createBatch(),
-		y: func() ts.GermBatch {
-			gb := createBatch()
-			s := gb.DirtyGerms[18]
-			s[0], s[1], s[2] = s[1], s[2], s[0]
-			return gb
-		}(),
-		opts: []cmp.Option{cmp.Comparer(pb.Equal), equalDish},
+		x:     "a\r\nb",
+		y:     "a\nb",
 		wantDiff: `
-{teststructs.GermBatch}.DirtyGerms[18][0]:
-	-: "germ2"
-	+: "germ3"
-{teststructs.GermBatch}.DirtyGerms[18][1]:
-	-: "germ3"
-	+: "germ4"
-{teststructs.GermBatch}.DirtyGerms[18][2]:
-	-: "germ4"
-	+: "germ2"`,
+{string}:
+	-: "a\r\nb"
+	+: "a\nb"`,
 	}, {
 		label: label,
-		x:     createBatch(),
-		y: func() ts.GermBatch {
-			gb := createBatch()
-			s := gb.DirtyGerms[18]
-			s[0], s[1], s[2] = s[1], s[2], s[0]
-			return gb
-		}(),
-		opts: []cmp.Option{cmp.Comparer(pb.Equal), sortGerms, equalDish},
+		x:     "a\r\nb",
+		y:     "a\nc",
+		opts:  []cmp.Option{opt},
+		wantDiff: `
+λeol({string}):
+	-: "a\nb"
+	+: "a\nc"`,
 	}, {
 		label: label,
-		x: func() ts.GermBatch {
-			gb := createBatch()
-			delete(gb.DirtyGerms, 17)
-			gb.DishMap[1] = nil
-			return gb
-		}(),
-		y: func() ts.GermBatch {
-			gb := createBatch()
-			gb.DirtyGerms[18] = gb.DirtyGerms[18][:2]
-			gb.GermStrain = 22
-			return gb
-		}(),
-		opts: []cmp.Option{cmp.Comparer(pb.Equal), sortGerms, equalDish},
+		x:     []byte{0x00, 0x0d, 0x0a, 0xff},
+		y:     []byte{0x00, 0x0a, 0xff},
+		opts:  []cmp.Option{opt},
 		wantDiff: `
-{teststructs.GermBatch}.DirtyGerms[17]:
-	-: <non-existent>
-	+: []*testprotos.Germ{"germ1"}
-{teststructs.GermBatch}.DirtyGerms[18][2]:
-	-: "germ4"
-	+: <non-existent>
-{teststructs.GermBatch}.DishMap[1]:
-	-: (*teststructs.Dish)(nil)
-	+: &teststructs.Dish{err: &errors.errorString{s: "unexpected EOF"}}
-{teststructs.GermBatch}.GermStrain:
-	-: 421
-	+: 22`,
+{[]uint8}[1]:
+	-: 0x0d
+	+: 0x0a
+{[]uint8}[2]:
+	-: 0x0a
+	+: 0xff
+{[]uint8}[3]:
+	-: 0xff
+	+: <non-existent>`,
+	}}
+}
+
+func collapseWhitespaceTests() []test {
+	const label = "CollapseWhitespace/"
+
+	type query struct {
+		Name string
+		SQL  string
+	}
+	opt := cmp.FilterPath(func(p cmp.Path) bool {
+		return p[len(p)-1].String() == ".SQL"
+	}, cmp.CollapseWhitespace())
+	return []test{{
+		label: label,
+		x:     query{"q", "SELECT a, b\n  FROM t\n  WHERE a = 1\n"},
+		y:     query{"q", "  SELECT a, b FROM t\tWHERE a = 1"},
+		opts:  []cmp.Option{opt},
+	}, {
+		label: label,
+		x:     query{Name: "a b"},
+		y:     query{Name: "a  b"},
+		opts:  []cmp.Option{opt},
+		wantDiff: `
+{cmp_test.query}.Name:
+	-: "a b"
+	+: "a  b"`,
+	}, {
+		label: label,
+		x:     query{"q", "SELECT a, b\n  FROM t\n  WHERE a = 1\n"},
+		y:     query{"q", "SELECT a, c\nFROM t WHERE a = 1"},
+		opts:  []cmp.Option{opt},
+		wantDiff: `
+λspace({cmp_test.query}.SQL):
+	-: "SELECT a, b FROM t WHERE a = 1"
+	+: "SELECT a, c FROM t WHERE a = 1"`,
 	}}
 }
 
-func project3Tests() []test {
-	const label = "Project3"
+func anyOfTests() []test {
+	const label = "AnyOf/"
 
-	allowVisibility := cmp.AllowUnexported(ts.Dirt{})
+	type result struct {
+		Name  string
+		Score float64
+		Count int
+	}
+	approx := cmp.EquateApprox(0, 0.01)
+	eitherZero := cmp.Comparer(func(x, y float64) bool { return x == 0 || y == 0 })
+	opt := cmp.FilterPath(func(p cmp.Path) bool {
+		return p[len(p)-1].String() == ".Score"
+	}, cmp.AnyOf(
+		cmp.Comparer(func(x, y float64) bool { return x == y }),
+		approx,
+		eitherZero,
+	))
+	return []test{{
+		label: label,
+		x:     result{"a", 1.5, 3},
+		y:     result{"a", 1.5, 3},
+		opts:  []cmp.Option{opt},
+	}, {
+		label: label,
+		x:     result{"a", 1.5, 3},
+		y:     result{"a", 1.505, 3},
+		opts:  []cmp.Option{opt},
+	}, {
+		label: label,
+		x:     result{"a", 1.5, 3},
+		y:     result{"a", 0, 3},
+		opts:  []cmp.Option{opt},
+	}, {
+		label: label,
+		x:     result{"a", 1.5, 3},
+		y:     result{"a", 1.6, 4},
+		opts:  []cmp.Option{opt},
+		wantDiff: `
+{cmp_test.result}.Score:
+	-: 1.5
+	+: 1.6
+{cmp_test.result}.Count:
+	-: 3
+	+: 4`,
+	}, {
+		label:     label,
+		x:         result{"a", 1.5, 3},
+		y:         result{"a", 1.6, 4},
+		opts:      []cmp.Option{approx, eitherZero},
+		wantPanic: "ambiguous set of options",
+	}}
+}
 
-	ignoreLocker := cmp.FilterPath(func(p cmp.Path) bool {
-		return len(p) > 0 && p[len(p)-1].Type() == mutexType
-	}, cmp.Ignore())
+func allOfTests() []test {
+	const label = "AllOf/"
 
-	transformProtos := cmp.Transformer("", func(x pb.Dirt) *pb.Dirt {
-		return &x
-	})
+	opt := cmp.AllOf(cmp.Comparer(pb.Equal), cmp.Comparer(equalNoteStrings))
+	return []test{{
+		label: label,
+		x:     &note{Text: "hello", Lang: "en"},
+		y:     &note{Text: "hello", Lang: "en"},
+		opts:  []cmp.Option{opt},
+	}, {
+		label: label,
+		x:     &pb.Germ{},
+		y:     &pb.Germ{},
+		opts:  []cmp.Option{opt},
+	}}
+}
 
-	equalTable := cmp.Comparer(func(x, y ts.Table) bool {
-		tx, ok1 := x.(*ts.MockTable)
-		ty, ok2 := y.(*ts.MockTable)
-		if !ok1 || !ok2 {
-			panic("table type must be MockTable")
-		}
-		return cmp.Equal(tx.State(), ty.State())
-	})
+func notTests() []test {
+	const label = "Not/"
 
-	createDirt := func() (d ts.Dirt) {
-		d.SetTable(ts.CreateMockTable([]string{"a", "b", "c"}))
-		d.SetTimestamp(12345)
-		d.Discord = 554
-		d.Proto = pb.Dirt{Stringer: pb.Stringer{"proto"}}
-		d.SetWizard(map[string]*pb.Wizard{
-			"harry": &pb.Wizard{Stringer: pb.Stringer{"potter"}},
-			"albus": &pb.Wizard{Stringer: pb.Stringer{"dumbledore"}},
-		})
-		d.SetLastTime(54321)
-		return d
+	type record struct {
+		Name      string
+		CreatedAt time.Time
+		UpdatedAt time.Time
+		Expires   []time.Time
+	}
+	isCreatedAt := func(p cmp.Path) bool {
+		sf, ok := p[len(p)-1].(cmp.StructField)
+		return ok && sf.Name() == "CreatedAt"
 	}
+	opt := cmp.FilterValues(func(x, y time.Time) bool { return true },
+		cmp.Not(cmp.FilterPath(isCreatedAt, cmp.Ignore())))
 
+	t0 := time.Date(2009, 11, 10, 23, 0, 0, 0, time.UTC)
 	return []test{{
-		label:     label,
-		x:         createDirt(),
-		y:         createDirt(),
-		wantPanic: "cannot handle unexported field",
+		label: label,
+		x:     record{"a", t0, t0, []time.Time{t0}},
+		y:     record{"a", t0, t0.Add(time.Hour), []time.Time{t0.Add(time.Minute)}},
+		opts:  []cmp.Option{opt},
 	}, {
-		label:     label,
-		x:         createDirt(),
-		y:         createDirt(),
-		opts:      []cmp.Option{allowVisibility, ignoreLocker, cmp.Comparer(pb.Equal), equalTable},
-		wantPanic: "cannot handle unexported field",
+		label: label,
+		x:     record{"a", t0, t0, []time.Time{t0}},
+		y:     record{"b", t0.Add(time.Second), t0.Add(time.Hour), []time.Time{t0.Add(time.Minute)}},
+		opts:  []cmp.Option{opt},
+		wantDiff: `
+{cmp_test.record}.Name:
+	-: "a"
+	+: "b"
+{cmp_test.record}.CreatedAt:
+	-: "2009-11-10 23:00:00 +0000 UTC"
+	+: "2009-11-10 23:00:01 +0000 UTC"`,
+	}}
+}
+
+func filterPathValuesTests() []test {
+	const label = "FilterPathValues/"
+
+	type entry struct {
+		Amount  int
+		Balance int
+	}
+	isAmount := func(p cmp.Path) bool {
+		sf, ok := p[len(p)-1].(cmp.StructField)
+		return ok && sf.Name() == "Amount"
+	}
+	bothNegative := func(x, y int) bool { return x < 0 && y < 0 }
+	opt := cmp.FilterPathValues(isAmount, bothNegative, cmp.Ignore())
+	return []test{{
+		label: label,
+		x:     entry{-5, 1},
+		y:     entry{-7, 1},
+		opts:  []cmp.Option{opt},
 	}, {
 		label: label,
-		x:     createDirt(),
-		y:     createDirt(),
-		opts:  []cmp.Option{allowVisibility, transformProtos, ignoreLocker, cmp.Comparer(pb.Equal), equalTable},
+		x:     entry{5, -1},
+		y:     entry{7, -2},
+		opts:  []cmp.Option{opt},
+		wantDiff: `
+{cmp_test.entry}.Amount:
+	-: 5
+	+: 7
+{cmp_test.entry}.Balance:
+	-: -1
+	+: -2`,
 	}, {
 		label: label,
-		x: func() ts.Dirt {
-			d := createDirt()
-			d.SetTable(ts.CreateMockTable([]string{"a", "c"}))
-			d.Proto = pb.Dirt{Stringer: pb.Stringer{"blah"}}
-			return d
-		}(),
-		y: func() ts.Dirt {
-			d := createDirt()
-			d.Discord = 500
-			d.SetWizard(map[string]*pb.Wizard{
-				"harry": &pb.Wizard{Stringer: pb.Stringer{"otter"}},
-			})
-			return d
-		}(),
-		opts: []cmp.Option{allowVisibility, transformProtos, ignoreLocker, cmp.Comparer(pb.Equal), equalTable},
+		x:     entry{-5, 1},
+		y:     entry{7, 1},
+		opts:  []cmp.Option{opt},
 		wantDiff: `
-{teststructs.Dirt}.table:
-	-: &teststructs.MockTable{state: []string{"a", "c"}}
-	+: &teststructs.MockTable{state: []string{"a", "b", "c"}}
-{teststructs.Dirt}.Discord:
-	-: 554
-	+: 500
-λ({teststructs.Dirt}.Proto):
-	-: "blah"
-	+: "proto"
-{teststructs.Dirt}.wizard["albus"]:
-	-: "dumbledore"
-	+: <non-existent>
-{teststructs.Dirt}.wizard["harry"]:
-	-: "potter"
-	+: "otter"`,
+{cmp_test.entry}.Amount:
+	-: -5
+	+: 7`,
 	}}
 }
 
-func project4Tests() []test {
-	const label = "Project4"
-
-	allowVisibility := cmp.AllowUnexported(
-		ts.Cartel{},
-		ts.Headquarter{},
-		ts.Poison{},
-	)
+func decidingComparerTests() []test {
+	const label = "DecidingComparer/"
 
-	transformProtos := cmp.Transformer("", func(x pb.Restrictions) *pb.Restrictions {
-		return &x
+	type blob struct {
+		Data []byte
+		Sum  uint32 // Zero if unknown
+	}
+	bySum := cmp.DecidingComparer(func(x, y blob) cmp.Verdict {
+		switch {
+		case x.Sum == 0 || y.Sum == 0:
+			return cmp.Undecided
+		case x.Sum == y.Sum:
+			return cmp.Same
+		default:
+			return cmp.Different
+		}
 	})
+	byData := cmp.Comparer(func(x, y blob) bool { return bytes.Equal(x.Data, y.Data) })
+	return []test{{
+		label: label + "Same",
+		x:     blob{[]byte("a"), 1},
+		y:     blob{[]byte("b"), 1},
+		opts:  []cmp.Option{bySum},
+	}, {
+		label: label + "Different",
+		x:     blob{[]byte("a"), 1},
+		y:     blob{[]byte("a"), 2},
+		opts:  []cmp.Option{bySum},
+		wantDiff: `
+{cmp_test.blob}:
+	-: cmp_test.blob{Data: []uint8{0x61}, Sum: 0x01}
+	+: cmp_test.blob{Data: []uint8{0x61}, Sum: 0x02}`,
+	}, {
+		label: label + "Undecided",
+		x:     blob{[]byte("a"), 0},
+		y:     blob{[]byte("a"), 0},
+		opts:  []cmp.Option{bySum},
+	}, {
+		label: label + "Undecided",
+		x:     blob{[]byte("a"), 0},
+		y:     blob{[]byte("b"), 1},
+		opts:  []cmp.Option{bySum},
+		wantDiff: `
+{cmp_test.blob}.Data[0]:
+	-: 0x61
+	+: 0x62
+{cmp_test.blob}.Sum:
+	-: 0x00
+	+: 0x01`,
+	}, {
+		label: label + "Undecided",
+		x:     blob{[]byte("a"), 0},
+		y:     blob{[]byte("a"), 2},
+		opts:  []cmp.Option{bySum, byData},
+	}, {
+		label:     label + "Ambiguous",
+		x:         blob{[]byte("a"), 1},
+		y:         blob{[]byte("a"), 2},
+		opts:      []cmp.Option{bySum, byData},
+		wantPanic: "ambiguous set of options",
+	}}
+}
 
-	createCartel := func() ts.Cartel {
-		var p ts.Poison
-		p.SetPoisonType(5)
-		p.SetExpiration(now)
-		p.SetManufactuer("acme")
+func optionsDedupTests() []test {
+	const label = "OptionsDedup/"
 
-		var hq ts.Headquarter
-		hq.SetID(5)
-		hq.SetLocation("moon")
-		hq.SetSubDivisions([]string{"alpha", "bravo", "charlie"})
-		hq.SetMetaData(&pb.MetaData{Stringer: pb.Stringer{"metadata"}})
-		hq.SetPublicMessage([]byte{1, 2, 3, 4, 5})
-		hq.SetHorseBack("abcdef")
-		hq.SetStatus(44)
+	equal := cmp.Comparer(func(x, y int) bool { return x == y })
+	f := func(x, y int) bool { return x == y }
+	return []test{{
+		label: label,
+		x:     1,
+		y:     2,
+		opts:  []cmp.Option{cmp.Options{equal}, cmp.Options{equal, cmp.Options{equal}}},
+		wantDiff: `
+{int}:
+	-: 1
+	+: 2`,
+	}, {
+		// Separate calls with the same function are distinct options.
+		label:     label,
+		x:         1,
+		y:         1,
+		opts:      []cmp.Option{cmp.Comparer(f), cmp.Comparer(f)},
+		wantPanic: "ambiguous set of options",
+	}}
+}
 
-		var c ts.Cartel
-		c.Headquarter = hq
-		c.SetSource("mars")
-		c.SetCreationTime(now)
-		c.SetBoss("al capone")
-		c.SetPoisons([]*ts.Poison{&p})
+func exactComparerTests() []test {
+	const label = "ExactComparer/"
 
-		return c
-	}
+	type reading struct{ S fmt.Stringer }
+	equalStrings := func(x, y fmt.Stringer) bool { return x.String() == y.String() }
+	return []test{{
+		label: label,
+		x:     celsius(20.1),
+		y:     celsius(20.4),
+		opts:  []cmp.Option{cmp.Comparer(equalStrings)},
+	}, {
+		label: label,
+		x:     celsius(20.1),
+		y:     celsius(20.4),
+		opts:  []cmp.Option{cmp.ExactComparer(equalStrings)},
+		wantDiff: `
+{cmp_test.celsius}:
+	-: 20.1
+	+: 20.4`,
+	}, {
+		label: label,
+		x:     reading{celsius(20.1)},
+		y:     reading{celsius(20.4)},
+		opts:  []cmp.Option{cmp.ExactComparer(equalStrings)},
+	}}
+}
+
+func filterMapKeysTests() []test {
+	const label = "FilterMapKeys/"
 
+	foldKeys := cmp.FilterMapKeys(cmp.ApplyOnce(cmp.Transformer("ToLower", strings.ToLower)))
+	foldValues := cmp.FilterMapValues(cmp.ApplyOnce(cmp.Transformer("ToLower", strings.ToLower)))
 	return []test{{
-		label:     label,
-		x:         createCartel(),
-		y:         createCartel(),
-		wantPanic: "cannot handle unexported field",
+		label: label,
+		x:     map[string]int{"Alpha": 1, "beta": 2},
+		y:     map[string]int{"alpha": 1, "BETA": 2},
+		wantDiff: `
+{map[string]int}["Alpha"]:
+	-: 1
+	+: <non-existent>
+{map[string]int}["BETA"]:
+	-: <non-existent>
+	+: 2
+{map[string]int}["alpha"]:
+	-: <non-existent>
+	+: 1
+{map[string]int}["beta"]:
+	-: 2
+	+: <non-existent>`,
 	}, {
-		label:     label,
-		x:         createCartel(),
-		y:         createCartel(),
-		opts:      []cmp.Option{allowVisibility, cmp.Comparer(pb.Equal)},
-		wantPanic: "cannot handle unexported field",
+		label: label,
+		x:     map[string]int{"Alpha": 1, "beta": 2},
+		y:     map[string]int{"alpha": 1, "BETA": 2},
+		opts:  []cmp.Option{foldKeys},
 	}, {
 		label: label,
-		x:     createCartel(),
-		y:     createCartel(),
-		opts:  []cmp.Option{allowVisibility, transformProtos, cmp.Comparer(pb.Equal)},
+		x:     map[string]int{"Alpha": 1, "beta": 2},
+		y:     map[string]int{"alpha": 1, "BETA": 3},
+		opts:  []cmp.Option{foldKeys},
+		wantDiff: `
+{map[string]int}["beta"]:
+	-: 2
+	+: 3`,
 	}, {
+		// Values are still compared exactly.
 		label: label,
-		x: func() ts.Cartel {
-			d := createCartel()
-			var p1, p2 ts.Poison
-			p1.SetPoisonType(1)
-			p1.SetExpiration(now)
-			p1.SetManufactuer("acme")
-			p2.SetPoisonType(2)
-			p2.SetManufactuer("acme2")
-			d.SetPoisons([]*ts.Poison{&p1, &p2})
-			return d
-		}(),
-		y: func() ts.Cartel {
-			d := createCartel()
-			d.SetSubDivisions([]string{"bravo", "charlie"})
-			d.SetPublicMessage([]byte{1, 2, 4, 3, 5})
-			return d
-		}(),
-		opts: []cmp.Option{allowVisibility, transformProtos, cmp.Comparer(pb.Equal)},
+		x:     map[string]string{"A": "x"},
+		y:     map[string]string{"a": "X"},
+		opts:  []cmp.Option{foldKeys},
 		wantDiff: `
-{teststructs.Cartel}.Headquarter.subDivisions[0]:
-	-: "alpha"
-	+: "bravo"
-{teststructs.Cartel}.Headquarter.subDivisions[1]:
-	-: "bravo"
-	+: "charlie"
-{teststructs.Cartel}.Headquarter.subDivisions[2]:
-	-: "charlie"
+{map[string]string}["A"]:
+	-: "x"
+	+: "X"`,
+	}, {
+		label: label,
+		x:     map[string]string{"a": "X"},
+		y:     map[string]string{"a": "x"},
+		opts:  []cmp.Option{foldValues},
+	}, {
+		label: label,
+		x:     map[string]string{"A": "x"},
+		y:     map[string]string{"a": "x"},
+		opts:  []cmp.Option{foldValues},
+		wantDiff: `
+{map[string]string}["A"]:
+	-: "x"
 	+: <non-existent>
-{teststructs.Cartel}.Headquarter.publicMessage[2]:
-	-: 0x03
-	+: 0x04
-{teststructs.Cartel}.Headquarter.publicMessage[3]:
-	-: 0x04
-	+: 0x03
-{teststructs.Cartel}.poisons[0].poisonType:
-	-: 1
-	+: 5
-{teststructs.Cartel}.poisons[1]:
-	-: &teststructs.Poison{poisonType: 2, manufactuer: "acme2"}
-	+: <non-existent>`,
+{map[string]string}["a"]:
+	-: <non-existent>
+	+: "x"`,
+	}}
+}
+
+func optionerTests() []test {
+	const label = "Optioner/"
+
+	// The options do not apply outside of the values of the type.
+	type record struct {
+		Event     optionerEvent
+		Timestamp time.Time
+	}
+	now := time.Date(2009, 11, 10, 23, 0, 0, 0, time.UTC)
+	return []test{{
+		label: label,
+		x:     []optionerEvent{{"start", now}, {"stop", now}},
+		y:     []optionerEvent{{"start", now.Add(time.Second)}, {"stop", now.Add(time.Minute)}},
+	}, {
+		label: label,
+		x:     []optionerEvent{{"start", now}, {"stop", now}},
+		y:     []optionerEvent{{"start", now.Add(time.Second)}, {"halt", now.Add(time.Minute)}},
+		wantDiff: `
+{[]cmp_test.optionerEvent}[1].Name:
+	-: "stop"
+	+: "halt"`,
+	}, {
+		label: label,
+		x:     record{optionerEvent{"start", now}, now},
+		y:     record{optionerEvent{"start", now}, now.Add(time.Hour)},
+		wantDiff: `
+{cmp_test.record}.Timestamp:
+	-: "2009-11-10 23:00:00 +0000 UTC"
+	+: "2009-11-11 00:00:00 +0000 UTC"`,
 	}}
 }
@@ -258,7 +258,8 @@ func roundF64(z float64) float64 {
 // into a pair of float32 or float64 values. It would be convenient to be able
 // define only a single comparator on float64 and have float32, complex64, and
 // complex128 all be able to use that comparator. Transformations can be used
-// to handle this.
+// to handle this. (For comparisons within a tolerance, the EquateApprox and
+// EquateApproxComplex options already cover all four types.)
 func ExampleOption_transformComplex() {
 	opts := []cmp.Option{
 		// This transformer decomposes complex128 into a pair of float64s.
@@ -0,0 +1,138 @@
+// Copyright 2017, The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+// Package golden compares values computed by tests with golden files.
+//
+// The golden file holds the encoded form of the expected value, which is
+// decoded and compared with the computed value using cmp, such that a
+// mismatch is reported as a structural difference rather than as differing
+// bytes. Snapshots are golden files that are recorded by the first run of
+// a test. Running the tests with the -update flag rewrites the golden files
+// with the computed values instead:
+//
+//	go test -update
+package golden
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files with the values computed by tests")
+
+// Codec encodes and decodes the values stored in golden files.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(b []byte, v interface{}) error
+}
+
+// JSON is the Codec used by Diff, which stores values as indented JSON.
+var JSON Codec = jsonCodec{}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	b, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+func (jsonCodec) Unmarshal(b []byte, v interface{}) error {
+	return json.Unmarshal(b, v)
+}
+
+// Diff reports the differences between got and the value stored as JSON in
+// the golden file at path, as determined by cmp.Diff with the given options,
+// as an error of the test t. It reports whether the values are equal.
+//
+// If the -update flag is set, the golden file is instead rewritten with got,
+// and Diff reports true. Otherwise, a missing or undecodable golden file,
+// or invalid options, stop the test by t.Fatal.
+func Diff(t testing.TB, path string, got interface{}, opts ...cmp.Option) bool {
+	t.Helper()
+	return DiffCodec(t, path, got, JSON, opts...)
+}
+
+// DiffCodec is like Diff, but stores the value in the golden file using c.
+func DiffCodec(t testing.TB, path string, got interface{}, c Codec, opts ...cmp.Option) bool {
+	t.Helper()
+	if got == nil {
+		t.Fatalf("cannot compare nil with golden file %s", path)
+		return false
+	}
+	if *update {
+		return write(t, path, got, c)
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		t.Fatalf("golden file %s does not exist; run the test with -update to create it", path)
+		return false
+	} else if err != nil {
+		t.Fatalf("cannot read golden file: %v", err)
+		return false
+	}
+	want := reflect.New(reflect.TypeOf(got))
+	if err := c.Unmarshal(b, want.Interface()); err != nil {
+		t.Fatalf("cannot decode golden file %s: %v", path, err)
+		return false
+	}
+	d, err := cmp.DiffE(got, want.Elem().Interface(), opts...)
+	if err != nil {
+		t.Fatalf("cannot compare with golden file %s: %v", path, err)
+		return false
+	}
+	if d != "" {
+		t.Errorf("mismatch with golden file %s (-got +want):\n%s", path, d)
+		return false
+	}
+	return true
+}
+
+// Snapshot is like Diff, but compares got with the snapshot called name,
+// which is stored as JSON in the file testdata/name.json relative to the
+// current directory, which is the directory of the package being tested.
+// Unlike Diff, if the snapshot does not exist, it is recorded from got
+// and Snapshot reports true. Map keys are sorted in the recorded JSON,
+// such that snapshots of equal values are identical.
+func Snapshot(t testing.TB, name string, got interface{}, opts ...cmp.Option) bool {
+	t.Helper()
+	path := filepath.Join("testdata", name+".json")
+	if _, err := os.Stat(path); os.IsNotExist(err) && !*update && got != nil {
+		if !write(t, path, got, JSON) {
+			return false
+		}
+		t.Logf("recorded snapshot %s", path)
+		return true
+	}
+	return Diff(t, path, got, opts...)
+}
+
+// write writes the golden file at path with got encoded by c.
+func write(t testing.TB, path string, got interface{}, c Codec) bool {
+	t.Helper()
+	b, err := c.Marshal(got)
+	if err != nil {
+		t.Fatalf("cannot encode golden file %s: %v", path, err)
+		return false
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		t.Fatalf("cannot update golden file: %v", err)
+		return false
+	}
+	if err := ioutil.WriteFile(path, b, 0666); err != nil {
+		t.Fatalf("cannot update golden file: %v", err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,202 @@
+// Copyright 2017, The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package golden_test
+
+import (
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/golden"
+)
+
+// fakeTB records the failures reported to it. Unlike a real testing.TB,
+// Fatalf does not stop the calling goroutine.
+type fakeTB struct {
+	testing.TB
+	logs   []string
+	errors []string
+	fatals []string
+}
+
+func (t *fakeTB) Helper() {}
+
+func (t *fakeTB) Logf(f string, args ...interface{}) {
+	t.logs = append(t.logs, fmt.Sprintf(f, args...))
+}
+
+func (t *fakeTB) Errorf(f string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(f, args...))
+}
+
+func (t *fakeTB) Fatalf(f string, args ...interface{}) {
+	t.fatals = append(t.fatals, fmt.Sprintf(f, args...))
+}
+
+type config struct {
+	Name    string
+	Weights []float64
+}
+
+// setUpdate sets the -update flag to v.
+func setUpdate(t *testing.T, v bool) {
+	if err := flag.Set("update", fmt.Sprint(v)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	dir, err := ioutil.TempDir("", "golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer setUpdate(t, false)
+	path := filepath.Join(dir, "testdata", "config.golden")
+	got := config{Name: "model", Weights: []float64{0.25, 0.5}}
+
+	// A missing golden file is a fatal error, unless updating.
+	ft := new(fakeTB)
+	if golden.Diff(ft, path, got) {
+		t.Errorf("Diff(missing) = true, want false")
+	}
+	if len(ft.fatals) != 1 || !strings.Contains(ft.fatals[0], "does not exist; run the test with -update") {
+		t.Errorf("Diff(missing) fatal errors = %q, want one about -update", ft.fatals)
+	}
+
+	setUpdate(t, true)
+	ft = new(fakeTB)
+	if !golden.Diff(ft, path, got) || len(ft.errors)+len(ft.fatals) > 0 {
+		t.Fatalf("Diff(update) failed: %q %q", ft.errors, ft.fatals)
+	}
+	setUpdate(t, false)
+	ft = new(fakeTB)
+	if !golden.Diff(ft, path, got) || len(ft.errors)+len(ft.fatals) > 0 {
+		t.Errorf("Diff(updated) failed: %q %q", ft.errors, ft.fatals)
+	}
+
+	// A mismatch reports the structural difference.
+	changed := config{Name: "model", Weights: []float64{0.25, 0.75}}
+	ft = new(fakeTB)
+	if golden.Diff(ft, path, changed) {
+		t.Errorf("Diff(mismatch) = true, want false")
+	}
+	want := cmp.Diff(changed, got)
+	if len(ft.errors) != 1 || !strings.Contains(ft.errors[0], "(-got +want):\n"+want) {
+		t.Errorf("Diff(mismatch) errors = %q, want the diff:\n%s", ft.errors, want)
+	}
+	approx := cmp.Comparer(func(x, y float64) bool { return x-y < 0.5 && y-x < 0.5 })
+	ft = new(fakeTB)
+	if !golden.Diff(ft, path, changed, approx) {
+		t.Errorf("Diff(mismatch, approx) failed: %q", ft.errors)
+	}
+
+	ft = new(fakeTB)
+	if golden.Diff(ft, path, changed, approx, cmp.Comparer(func(x, y float64) bool { return x == y })) || len(ft.fatals) != 1 {
+		t.Errorf("Diff(ambiguous) fatal errors = %q, want one", ft.fatals)
+	}
+}
+
+type xmlCodec struct{}
+
+func (xmlCodec) Marshal(v interface{}) ([]byte, error)   { return xml.Marshal(v) }
+func (xmlCodec) Unmarshal(b []byte, v interface{}) error { return xml.Unmarshal(b, v) }
+
+func TestDiffCodec(t *testing.T) {
+	dir, err := ioutil.TempDir("", "golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer setUpdate(t, false)
+	path := filepath.Join(dir, "config.xml")
+	got := config{Name: "model", Weights: []float64{0.25, 0.5}}
+
+	setUpdate(t, true)
+	if ft := new(fakeTB); !golden.DiffCodec(ft, path, got, xmlCodec{}) {
+		t.Fatalf("DiffCodec(update) failed: %q", ft.fatals)
+	}
+	setUpdate(t, false)
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(b), "<config>") {
+		t.Errorf("golden file is not XML:\n%s", b)
+	}
+	ft := new(fakeTB)
+	if golden.DiffCodec(ft, path, config{Name: "other", Weights: got.Weights}, xmlCodec{}) || len(ft.errors) != 1 {
+		t.Errorf("DiffCodec(mismatch) errors = %q, want one", ft.errors)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	defer setUpdate(t, false)
+
+	got := map[string]config{
+		"b": {Name: "second", Weights: []float64{2}},
+		"a": {Name: "first", Weights: []float64{1}},
+	}
+
+	// The first run records the snapshot.
+	ft := new(fakeTB)
+	if !golden.Snapshot(ft, "models", got) || len(ft.logs) != 1 {
+		t.Fatalf("Snapshot(record) failed: logs %q, errors %q %q", ft.logs, ft.errors, ft.fatals)
+	}
+	b, err := ioutil.ReadFile(filepath.Join("testdata", "models.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i, j := strings.Index(string(b), `"a"`), strings.Index(string(b), `"b"`); i < 0 || j < i {
+		t.Errorf("snapshot keys are not sorted:\n%s", b)
+	}
+
+	// Later runs compare with the snapshot.
+	ft = new(fakeTB)
+	if !golden.Snapshot(ft, "models", got) || len(ft.logs)+len(ft.errors)+len(ft.fatals) > 0 {
+		t.Errorf("Snapshot(match) failed: logs %q, errors %q %q", ft.logs, ft.errors, ft.fatals)
+	}
+	changed := map[string]config{
+		"a": {Name: "first", Weights: []float64{1}},
+		"b": {Name: "second", Weights: []float64{3}},
+	}
+	ft = new(fakeTB)
+	if golden.Snapshot(ft, "models", changed) {
+		t.Errorf("Snapshot(mismatch) = true, want false")
+	}
+	want := cmp.Diff(changed, got)
+	if len(ft.errors) != 1 || !strings.Contains(ft.errors[0], want) || !strings.Contains(want, `["b"].Weights[0]`) {
+		t.Errorf("Snapshot(mismatch) errors = %q, want the diff:\n%s", ft.errors, want)
+	}
+
+	// The -update flag rewrites the snapshot.
+	setUpdate(t, true)
+	if ft := new(fakeTB); !golden.Snapshot(ft, "models", changed) {
+		t.Fatalf("Snapshot(update) failed: %q", ft.fatals)
+	}
+	setUpdate(t, false)
+	if ft := new(fakeTB); !golden.Snapshot(ft, "models", changed) || len(ft.errors) > 0 {
+		t.Errorf("Snapshot(updated) failed: %q", ft.errors)
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2017, The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+// Package gomegacmp provides a Gomega matcher that compares values with cmp.
+//
+// The matcher implements the methods of the GomegaMatcher interface without
+// this package importing Gomega, such that it may be used as:
+//
+//	Expect(got).To(gomegacmp.MatchCmp(want, opts...))
+package gomegacmp
+
+import (
+	"fmt"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// Matcher matches values that are equal to an expected value,
+// as determined by cmp.Equal with the given options.
+type Matcher struct {
+	want interface{}
+	opts []cmp.Option
+}
+
+// MatchCmp returns a Matcher that succeeds if the actual value is equal to
+// want, according to cmp.Equal with the given options.
+func MatchCmp(want interface{}, opts ...cmp.Option) *Matcher {
+	return &Matcher{want, opts}
+}
+
+// Match reports whether actual is equal to the expected value.
+// It returns an error rather than panicking if the options are misused,
+// as by cmp.EqualE.
+func (m *Matcher) Match(actual interface{}) (bool, error) {
+	return cmp.EqualE(actual, m.want, m.opts...)
+}
+
+// FailureMessage returns the message for a failed match, which reports
+// the differences between actual and the expected value, as by cmp.Diff.
+func (m *Matcher) FailureMessage(actual interface{}) string {
+	d, err := cmp.DiffE(actual, m.want, m.opts...)
+	if err != nil {
+		return fmt.Sprintf("cannot compare values: %v", err)
+	}
+	return "Expected values to be equal (-actual +expected):\n" + d
+}
+
+// NegatedFailureMessage returns the message for a failed negated match,
+// where actual was unexpectedly equal to the expected value.
+func (m *Matcher) NegatedFailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected\n\t%v\nnot to equal\n\t%v", actual, m.want)
+}
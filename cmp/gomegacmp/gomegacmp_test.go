@@ -0,0 +1,57 @@
+// Copyright 2017, The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package gomegacmp_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/gomegacmp"
+)
+
+// gomegaMatcher is the shape of the GomegaMatcher interface.
+type gomegaMatcher interface {
+	Match(actual interface{}) (success bool, err error)
+	FailureMessage(actual interface{}) (message string)
+	NegatedFailureMessage(actual interface{}) (message string)
+}
+
+var _ gomegaMatcher = gomegacmp.MatchCmp(nil)
+
+func TestMatchCmp(t *testing.T) {
+	type user struct {
+		Name  string
+		Score float64
+	}
+	approx := cmp.Comparer(func(x, y float64) bool { return x-y < 0.1 && y-x < 0.1 })
+	m := gomegacmp.MatchCmp(user{"gopher", 1.0}, approx)
+
+	if ok, err := m.Match(user{"gopher", 1.05}); !ok || err != nil {
+		t.Errorf("Match(approximately equal) = (%v, %v), want (true, nil)", ok, err)
+	}
+	if got, want := m.NegatedFailureMessage(user{"gopher", 1.05}), "Expected\n\t{gopher 1.05}\nnot to equal\n\t{gopher 1}"; got != want {
+		t.Errorf("NegatedFailureMessage() = %q, want %q", got, want)
+	}
+
+	actual := user{"badger", 1.0}
+	if ok, err := m.Match(actual); ok || err != nil {
+		t.Errorf("Match(unequal) = (%v, %v), want (false, nil)", ok, err)
+	}
+	msg := m.FailureMessage(actual)
+	want := "Expected values to be equal (-actual +expected):\n" + cmp.Diff(actual, user{"gopher", 1.0}, approx)
+	if msg != want || !strings.Contains(msg, `"badger"`) {
+		t.Errorf("FailureMessage() = %q, want %q", msg, want)
+	}
+
+	// Misuse of the options is an error of the matcher.
+	m = gomegacmp.MatchCmp(user{"gopher", 1.0}, approx, cmp.Comparer(func(x, y float64) bool { return x == y }))
+	if ok, err := m.Match(actual); ok || err == nil || !strings.Contains(err.Error(), "ambiguous") {
+		t.Errorf("Match(ambiguous) = (%v, %v), want an ambiguity error", ok, err)
+	}
+	if msg := m.FailureMessage(actual); !strings.Contains(msg, "cannot compare values: ambiguous") {
+		t.Errorf("FailureMessage(ambiguous) = %q, want the error", msg)
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2017, The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+// Package httpcmp provides options for comparing HTTP headers and URL query
+// values according to their multi-value semantics.
+package httpcmp
+
+import (
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"reflect"
+	"sort"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// EquateHeaders returns an Option that compares http.Header values by their
+// canonical keys, as by textproto.CanonicalMIMEHeaderKey, such that
+// "content-type" and "Content-Type" are the same key. The values of keys that
+// differ only by canonicalization are combined, in the sorted order of the
+// original keys.
+//
+// The values of each key are compared in order, except for the keys in
+// unordered, whose values are compared regardless of their order (e.g.,
+// "Accept"). A nil Header is equal to an empty one.
+//
+// Differences are reported per canonical key, within a Transformer named
+// "λheader".
+func EquateHeaders(unordered ...string) cmp.Option {
+	keys := make(map[string]bool)
+	for _, k := range unordered {
+		keys[textproto.CanonicalMIMEHeaderKey(k)] = true
+	}
+	return cmp.FilterPath(isType(reflect.TypeOf(http.Header{})),
+		cmp.Transformer("λheader", func(h http.Header) map[string][]string {
+			return normalize(h, textproto.CanonicalMIMEHeaderKey, keys)
+		}))
+}
+
+// EquateURLValues returns an Option that compares url.Values with the values
+// of each key compared in order, except for the keys in unordered, whose
+// values are compared regardless of their order. Unlike header keys, query
+// keys are case-sensitive. A nil Values is equal to an empty one.
+//
+// Differences are reported per key, within a Transformer named "λquery".
+func EquateURLValues(unordered ...string) cmp.Option {
+	keys := make(map[string]bool)
+	for _, k := range unordered {
+		keys[k] = true
+	}
+	return cmp.FilterPath(isType(reflect.TypeOf(url.Values{})),
+		cmp.Transformer("λquery", func(v url.Values) map[string][]string {
+			return normalize(v, func(k string) string { return k }, keys)
+		}))
+}
+
+// isType returns a path filter for values of exactly type t, which excludes
+// the unnamed map[string][]string produced by the Transformers.
+func isType(t reflect.Type) func(cmp.Path) bool {
+	return func(p cmp.Path) bool { return p[len(p)-1].Type() == t }
+}
+
+// normalize returns a copy of m with every key canonicalized by canon and the
+// values of the unordered keys sorted.
+func normalize(m map[string][]string, canon func(string) string, unordered map[string]bool) map[string][]string {
+	// Combine the values of equivalent keys in a deterministic order.
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	n := make(map[string][]string, len(m))
+	for _, k := range keys {
+		n[canon(k)] = append(n[canon(k)], m[k]...)
+	}
+	for k, vs := range n {
+		if unordered[k] {
+			sort.Strings(vs)
+		}
+	}
+	return n
+}
@@ -0,0 +1,68 @@
+// Copyright 2017, The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package httpcmp_test
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/httpcmp"
+)
+
+func TestEquateHeaders(t *testing.T) {
+	x := http.Header{
+		"content-type": {"application/json"},
+		"Accept":       {"text/html", "application/json"},
+		"X-Trace":      {"a", "b"},
+	}
+	y := http.Header{
+		"Content-Type": {"application/json"},
+		"accept":       {"application/json", "text/html"},
+		"X-Trace":      {"a", "b"},
+	}
+	if cmp.Equal(x, y, httpcmp.EquateHeaders()) {
+		t.Errorf("Equal(reordered Accept) = true, want false when ordered")
+	}
+	if !cmp.Equal(x, y, httpcmp.EquateHeaders("accept")) {
+		t.Errorf("Equal() = false, want true:\n%s", cmp.Diff(x, y, httpcmp.EquateHeaders("accept")))
+	}
+	if !cmp.Equal(http.Header(nil), http.Header{}, httpcmp.EquateHeaders()) {
+		t.Errorf("Equal(nil, empty) = false, want true")
+	}
+
+	y["X-Trace"] = []string{"b", "a"}
+	y["content-type"] = []string{"text/plain"}
+	d := cmp.Diff(x, y, httpcmp.EquateHeaders("Accept"))
+	for _, want := range []string{
+		`λheader({http.Header})["Content-Type"][1]:`,
+		`"text/plain"`,
+		`λheader({http.Header})["X-Trace"][0]:`,
+	} {
+		if !strings.Contains(d, want) {
+			t.Errorf("Diff() does not contain %q:\n%s", want, d)
+		}
+	}
+	if strings.Contains(d, "Accept") {
+		t.Errorf("Diff() reports the unordered Accept values:\n%s", d)
+	}
+}
+
+func TestEquateURLValues(t *testing.T) {
+	x := url.Values{"tag": {"a", "b"}, "page": {"1", "2"}}
+	y := url.Values{"tag": {"b", "a"}, "page": {"2", "1"}}
+	d := cmp.Diff(x, y, httpcmp.EquateURLValues("tag"))
+	if !strings.Contains(d, `λquery({url.Values})["page"][0]:`) || strings.Contains(d, "tag") {
+		t.Errorf("Diff() reports the wrong keys:\n%s", d)
+	}
+	if !cmp.Equal(x, y, httpcmp.EquateURLValues("tag", "page")) {
+		t.Errorf("Equal() = false, want true")
+	}
+	if cmp.Equal(url.Values{"Tag": {"a"}}, url.Values{"tag": {"a"}}, httpcmp.EquateURLValues()) {
+		t.Errorf("Equal() = true, want false since keys are case-sensitive")
+	}
+}
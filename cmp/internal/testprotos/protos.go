@@ -27,6 +27,8 @@ type notComparable struct {
 type Stringer struct{ X string }
 
 func (s *Stringer) String() string { return s.X }
+func (s *Stringer) Reset()         { *s = Stringer{} }
+func (s *Stringer) ProtoMessage()  {}
 
 // Project1 protocol buffers
 type (
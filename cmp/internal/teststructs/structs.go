@@ -4,6 +4,8 @@
 
 package teststructs
 
+import "strings"
+
 type InterfaceA interface {
 	InterfaceA()
 }
@@ -195,3 +197,27 @@ func (s *ParentStructG) PrivateStruct() *privateStruct { return s.privateStruct
 func (s *ParentStructI) PrivateStruct() *privateStruct { return s.privateStruct }
 func (s *ParentStructJ) PrivateStruct() *privateStruct { return s.privateStruct }
 func (s *ParentStructJ) Private() *privateStruct       { return &s.private }
+
+// Comparable is an exported interface whose implementations are unexported,
+// as is common for values returned by constructors.
+type Comparable interface {
+	Comparable()
+}
+
+type (
+	comparableValue   struct{ s string } // Equal method on value receiver
+	comparablePointer struct{ s string } // Equal method on pointer receiver
+)
+
+func NewComparableValue(s string) Comparable   { return comparableValue{s} }
+func NewComparablePointer(s string) Comparable { return comparablePointer{s} }
+
+func (comparableValue) Comparable()   {}
+func (comparablePointer) Comparable() {}
+
+func (x comparableValue) Equal(y comparableValue) bool {
+	return strings.EqualFold(x.s, y.s)
+}
+func (x *comparablePointer) Equal(y *comparablePointer) bool {
+	return strings.EqualFold(x.s, y.s)
+}
@@ -0,0 +1,127 @@
+// Copyright 2017, The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+// Package jsoncmp reports the differences between JSON documents.
+package jsoncmp
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// Diff returns a human-readable report of the differences between the JSON
+// documents x and y, which is empty if and only if they are equal.
+//
+// Both documents are decoded as by json.Unmarshal into an interface{},
+// such that objects are map[string]interface{}, arrays are []interface{},
+// and numbers are float64, and are then compared by cmp.Equal with the given
+// options (e.g., a Comparer of float64 values for a numeric tolerance).
+// Each difference is reported at its JSON Pointer (RFC 6901), such as
+// "/items/3/name", with the JSON encoding of the value in x marked by "-"
+// and that in y marked by "+". A value that only exists in one of the
+// documents is shown as <non-existent> in the other.
+//
+// It returns an error if either document is not valid JSON.
+// Like cmp.Diff, it panics if the options are misused.
+func Diff(x, y []byte, opts ...cmp.Option) (string, error) {
+	var vx, vy interface{}
+	if err := json.Unmarshal(x, &vx); err != nil {
+		return "", fmt.Errorf("invalid JSON in x: %v", err)
+	}
+	if err := json.Unmarshal(y, &vy); err != nil {
+		return "", fmt.Errorf("invalid JSON in y: %v", err)
+	}
+
+	var b strings.Builder
+	for _, d := range differences(vx, vy, opts) {
+		fmt.Fprintf(&b, "%s:\n\t-: %s\n\t+: %s\n", pointer(d.Path), formatValue(d.X), formatValue(d.Y))
+	}
+	return b.String(), nil
+}
+
+// CompareProjection returns an Option that compares the JSON projection
+// of the values passed to cmp.Equal rather than the values themselves.
+// Both values are marshaled with encoding/json and unmarshaled into an
+// interface{}, such that struct tags are respected and unexported fields and
+// empty fields tagged with omitempty are disregarded. The resulting trees are
+// then compared with the other options, which therefore apply to the decoded
+// maps, slices, strings, float64s, and bools rather than the original types.
+//
+// The projection is recorded in the Path as a Transform named
+// "λjson". Equal panics if either value cannot be marshaled.
+func CompareProjection() cmp.Option {
+	return cmp.FilterPath(isRoot, cmp.Transformer("λjson", project))
+}
+
+// isRoot reports whether p is the path to the values passed to cmp.Equal.
+func isRoot(p cmp.Path) bool {
+	return len(p) == 1
+}
+
+// project marshals v with encoding/json and unmarshals the result into
+// an interface{} tree of maps, slices, strings, float64s, and bools.
+func project(v interface{}) interface{} {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("cannot project %T to JSON: %v", v, err))
+	}
+	var p interface{}
+	if err := json.Unmarshal(b, &p); err != nil {
+		panic(fmt.Sprintf("cannot project %T to JSON: %v", v, err))
+	}
+	return p
+}
+
+// differences returns the differences between x and y, as by cmp.MustEqual.
+func differences(x, y interface{}, opts []cmp.Option) (diffs []cmp.Difference) {
+	defer func() {
+		if ex := recover(); ex != nil {
+			e, ok := ex.(*cmp.DiffError)
+			if !ok {
+				panic(ex)
+			}
+			diffs = e.Differences
+		}
+	}()
+	cmp.MustEqual(x, y, opts...)
+	return nil
+}
+
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// pointer returns the JSON Pointer of p, which is quoted if empty,
+// since the empty pointer refers to the whole document.
+func pointer(p cmp.Path) string {
+	var b strings.Builder
+	for _, ps := range p {
+		switch ps := ps.(type) {
+		case cmp.MapIndex:
+			b.WriteString("/" + pointerEscaper.Replace(ps.Key().String()))
+		case cmp.SliceIndex:
+			b.WriteString("/" + strconv.Itoa(ps.Key()))
+		}
+	}
+	if b.Len() == 0 {
+		return `""`
+	}
+	return b.String()
+}
+
+// formatValue returns the JSON encoding of v, which is a value decoded by
+// json.Unmarshal, or <non-existent> if v is invalid.
+func formatValue(v reflect.Value) string {
+	if !v.IsValid() {
+		return "<non-existent>"
+	}
+	b, err := json.Marshal(v.Interface())
+	if err != nil {
+		return fmt.Sprintf("%v", v) // Should never happen for decoded values
+	}
+	return string(b)
+}
@@ -0,0 +1,187 @@
+// Copyright 2017, The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package jsoncmp_test
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/jsoncmp"
+)
+
+func TestDiff(t *testing.T) {
+	tolerance := cmp.Comparer(func(x, y float64) bool { return math.Abs(x-y) < 0.01 })
+	tests := []struct {
+		label string
+		x, y  string
+		opts  []cmp.Option
+		want  string
+	}{{
+		label: "Equal",
+		x:     `{"a": [1, {"b": null}], "c": "d"}`,
+		y:     `{"c":"d","a":[1,{"b":null}]}`,
+		want:  "",
+	}, {
+		label: "NestedArrays",
+		x:     `{"items": [{"name": "a", "tags": ["x", "y"]}, {"name": "b"}]}`,
+		y:     `{"items": [{"name": "a", "tags": ["x", "z"]}, {"name": "c"}, {"name": "d"}]}`,
+		want: `/items/0/tags/1:
+	-: "y"
+	+: "z"
+/items/1/name:
+	-: "b"
+	+: "c"
+/items/2:
+	-: <non-existent>
+	+: {"name":"d"}
+`,
+	}, {
+		label: "MissingKeys",
+		x:     `{"a": 1, "b/c": {"d~e": true}}`,
+		y:     `{"a": 1, "b/c": {}, "f": [null]}`,
+		want: `/b~1c/d~0e:
+	-: true
+	+: <non-existent>
+/f:
+	-: <non-existent>
+	+: [null]
+`,
+	}, {
+		label: "TypeChanges",
+		x:     `{"id": 1, "values": [1.5, "2", false]}`,
+		y:     `{"id": "1", "values": ["1.5", 2, null]}`,
+		want: `/id:
+	-: 1
+	+: "1"
+/values/0:
+	-: 1.5
+	+: "1.5"
+/values/1:
+	-: "2"
+	+: 2
+/values/2:
+	-: false
+	+: null
+`,
+	}, {
+		label: "Root",
+		x:     `[1, 2]`,
+		y:     `{"a": 1}`,
+		want: `"":
+	-: [1,2]
+	+: {"a":1}
+`,
+	}, {
+		label: "Tolerance",
+		x:     `{"a": [1.001, 2], "b": 3}`,
+		y:     `{"a": [1.002, 2], "b": 3.5}`,
+		opts:  []cmp.Option{tolerance},
+		want: `/b:
+	-: 3
+	+: 3.5
+`,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.label, func(t *testing.T) {
+			got, err := jsoncmp.Diff([]byte(tt.x), []byte(tt.y), tt.opts...)
+			if err != nil {
+				t.Fatalf("Diff() error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Diff() mismatch:\ngot:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffInvalid(t *testing.T) {
+	if _, err := jsoncmp.Diff([]byte(`{"a":`), []byte(`{}`)); err == nil || !strings.Contains(err.Error(), "invalid JSON in x") {
+		t.Errorf("Diff(invalid x) error = %v, want one identifying x", err)
+	}
+	if _, err := jsoncmp.Diff([]byte(`{}`), []byte(`[1,]`)); err == nil || !strings.Contains(err.Error(), "invalid JSON in y") {
+		t.Errorf("Diff(invalid y) error = %v, want one identifying y", err)
+	}
+}
+
+func TestCompareProjection(t *testing.T) {
+	type User struct {
+		Name     string            `json:"name"`
+		Email    string            `json:"email,omitempty"`
+		Tags     []string          `json:"tags"`
+		Password string            `json:"-"`
+		Extra    map[string]string `json:"extra,omitempty"`
+		session  int
+	}
+	type Login struct {
+		Name string   `json:"name"`
+		Tags []string `json:"tags"`
+	}
+	tests := []struct {
+		label     string
+		x, y      interface{}
+		opts      []cmp.Option
+		wantDiff  string
+		wantPanic string
+	}{{
+		label: "IgnoredFields",
+		x:     User{Name: "gopher", Password: "hunter2", session: 1},
+		y:     User{Name: "gopher", Password: "swordfish", session: 2},
+	}, {
+		label: "DifferentTypes",
+		x:     User{Name: "gopher", Tags: []string{"a"}, Extra: map[string]string{}},
+		y:     Login{Name: "gopher", Tags: []string{"a"}},
+	}, {
+		label: "Difference",
+		x:     User{Name: "gopher", Tags: []string{"a", "b"}},
+		y:     User{Name: "gopher", Tags: []string{"a", "c"}},
+		wantDiff: `
+λjson({jsoncmp_test.User}).(map[string]interface {})["tags"].([]interface {})[1].(string):
+	-: "b"
+	+: "c"`,
+	}, {
+		label: "FilteredKey",
+		x:     User{Name: "gopher", Email: "gopher@example.com"},
+		y:     User{Name: "gopher", Email: "gopher@example.org"},
+		opts: []cmp.Option{cmp.FilterPath(func(p cmp.Path) bool {
+			mi, ok := p[len(p)-1].(cmp.MapIndex)
+			return ok && mi.Key().String() == "email"
+		}, cmp.Ignore())},
+	}, {
+		label:     "Unmarshalable",
+		x:         map[string]interface{}{"f": func() {}},
+		y:         map[string]interface{}{},
+		wantPanic: "cannot project map[string]interface {} to JSON: json: unsupported type: func()",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.label, func(t *testing.T) {
+			opts := append([]cmp.Option{jsoncmp.CompareProjection()}, tt.opts...)
+			var gotDiff, gotPanic string
+			func() {
+				defer func() {
+					if ex := recover(); ex != nil {
+						gotPanic = fmt.Sprint(ex)
+					}
+				}()
+				gotDiff = cmp.Diff(tt.x, tt.y, opts...)
+			}()
+			if tt.wantPanic != "" {
+				if !strings.Contains(gotPanic, tt.wantPanic) {
+					t.Fatalf("panic message:\ngot:  %s\nwant: %s", gotPanic, tt.wantPanic)
+				}
+				return
+			}
+			if gotPanic != "" {
+				t.Fatalf("unexpected panic message: %s", gotPanic)
+			}
+			if got, want := strings.TrimSpace(gotDiff), strings.TrimSpace(tt.wantDiff); got != want {
+				t.Fatalf("difference message:\ngot:\n%s\n\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+// Copyright 2017, The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+// Package netcmp provides options for comparing network addresses according
+// to their semantics rather than their encoding.
+package netcmp
+
+import (
+	"net"
+	"reflect"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// EquateIPs returns an Option that compares net.IP values as by net.IP.Equal,
+// such that the 4-byte and 16-byte encodings of an IPv4 address are equal.
+// It also compares net.IPNet values by their addresses and masks in the same
+// way, such that "192.0.2.0/24" is equal regardless of how it is encoded,
+// which the Equal method of net.IP alone does not cover for net.IPMask.
+// A nil IP is equal to an empty one.
+//
+// Addresses are reported in their String form, within a Transformer named
+// "λip" or "λipNet".
+func EquateIPs() cmp.Option {
+	return cmp.Options{
+		cmp.FilterPath(isType(reflect.TypeOf(net.IP{})),
+			cmp.Transformer("λip", net.IP.String)),
+		cmp.FilterPath(isType(reflect.TypeOf(net.IPNet{})),
+			cmp.Transformer("λipNet", func(n net.IPNet) string { return n.String() })),
+	}
+}
+
+// isType returns a path filter for values of exactly type t, which excludes
+// the unnamed []byte values that are assignable to net.IP.
+func isType(t reflect.Type) func(cmp.Path) bool {
+	return func(p cmp.Path) bool { return p[len(p)-1].Type() == t }
+}
@@ -0,0 +1,49 @@
+// Copyright 2017, The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package netcmp_test
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/netcmp"
+)
+
+func TestEquateIPs(t *testing.T) {
+	ip := net.ParseIP("192.0.2.1")
+	x, y := ip.To4(), ip.To16()
+	if !cmp.Equal(x, y, netcmp.EquateIPs()) {
+		t.Errorf("Equal(4-byte, 16-byte) = false, want true:\n%s", cmp.Diff(x, y, netcmp.EquateIPs()))
+	}
+
+	d := cmp.Diff(x, net.ParseIP("192.0.2.2"), netcmp.EquateIPs())
+	if want := "-: \"192.0.2.1\"\n\t+: \"192.0.2.2\""; !strings.Contains(d, want) {
+		t.Errorf("Diff() does not contain %q:\n%s", want, d)
+	}
+	if cmp.Diff([]byte{1, 2}, []byte{1, 2}, netcmp.EquateIPs()) != "" {
+		t.Errorf("Diff(equal []byte) is not empty")
+	}
+
+	type route struct {
+		Dest *net.IPNet
+		Via  net.IP
+	}
+	_, n4, _ := net.ParseCIDR("192.0.2.0/24")
+	n16 := &net.IPNet{IP: n4.IP.To16(), Mask: net.CIDRMask(24+96, 128)}
+	rx, ry := route{n4, x}, route{n16, y}
+	if cmp.Equal(rx, ry) {
+		t.Errorf("Equal(routes) = true, want false without EquateIPs")
+	}
+	if !cmp.Equal(rx, ry, netcmp.EquateIPs()) {
+		t.Errorf("Equal(routes) = false, want true:\n%s", cmp.Diff(rx, ry, netcmp.EquateIPs()))
+	}
+	ry.Dest = &net.IPNet{IP: n4.IP, Mask: net.CIDRMask(16, 32)}
+	d = cmp.Diff(rx, ry, netcmp.EquateIPs())
+	if want := "λipNet((*{netcmp_test.route}.Dest)):"; !strings.Contains(d, want) {
+		t.Errorf("Diff(routes) does not contain %q:\n%s", want, d)
+	}
+}
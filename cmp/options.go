@@ -5,10 +5,21 @@
 package cmp
 
 import (
+	"bytes"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/big"
+	"math/cmplx"
+	"path/filepath"
 	"reflect"
 	"runtime"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode/utf8"
 )
 
 // Option configures for specific behavior of Diff and Equal. In particular,
@@ -30,6 +41,12 @@ type Option interface {
 //
 // Applying a filter on an Options is equivalent to applying that same filter
 // on all individual options held within.
+//
+// Copies of the same option (e.g., a Comparer included by several helper
+// bundles) are only evaluated once and are never ambiguous with each other.
+// Options are only copies of each other if they derive from the same call to
+// Ignore, Transformer, Comparer, or the like, with the same filters applied
+// by the same calls; separate calls with the same function are distinct.
 type Options []Option
 
 func (Options) option() {}
@@ -37,8 +54,9 @@ func (Options) option() {}
 type (
 	pathFilter  func(Path) bool
 	valueFilter struct {
-		in  reflect.Type  // T
-		fnc reflect.Value // func(T, T) bool
+		in     reflect.Type  // T
+		fnc    reflect.Value // func(T, T) bool
+		direct directFunc    // Optional equivalent of fnc
 	}
 )
 
@@ -46,38 +64,261 @@ type option struct {
 	typeFilter   reflect.Type
 	pathFilters  []pathFilter
 	valueFilters []valueFilter
+	inverted     []filters // Filters that must not all pass (see Not)
 
 	// op is the operation to perform. If nil, then this acts as an ignore.
-	op interface{} // nil | *transformer | *comparer
+	op interface{} // nil | *transformer | *comparer | *combination | *multiset | *sharedKeys | *channelContents
+
+	// src is the source location (e.g., "file.go:123") where op was created.
+	src string
+
+	// priority is the precedence of this option over other options that
+	// apply to the same values (see Prioritized).
+	priority int
 }
 
 func (option) option() {}
 
-func (o option) String() string {
-	// TODO: Add information about the caller?
-	// TODO: Maintain the order that filters were added?
+// sameOption reports whether a and b are copies of the same option.
+func sameOption(a, b option) bool {
+	return a.op == b.op && a.typeFilter == b.typeFilter && a.priority == b.priority && a.src == b.src &&
+		sameSlice(len(a.pathFilters), len(b.pathFilters), func() bool { return &a.pathFilters[0] == &b.pathFilters[0] }) &&
+		sameSlice(len(a.valueFilters), len(b.valueFilters), func() bool { return &a.valueFilters[0] == &b.valueFilters[0] }) &&
+		sameSlice(len(a.inverted), len(b.inverted), func() bool { return &a.inverted[0] == &b.inverted[0] })
+}
+
+// sameSlice reports whether two slices of lengths n1 and n2 are the same,
+// where same reports whether their non-empty backing arrays are the same.
+func sameSlice(n1, n2 int, same func() bool) bool {
+	return n1 == n2 && (n1 == 0 || same())
+}
+
+// filters is the set of path and value filters of an option.
+type filters struct {
+	pathFilters  []pathFilter
+	valueFilters []valueFilter
+	inverted     []filters
+}
 
+// strings formats each of the filters as the call that would apply it,
+// without the option argument, with the outermost filter first.
+func (fs filters) strings() []string {
 	var ss []string
+	for i := len(fs.pathFilters) - 1; i >= 0; i-- {
+		ss = append(ss, fs.pathFilters[i].wrap(""))
+	}
+	for i := len(fs.valueFilters) - 1; i >= 0; i-- {
+		ss = append(ss, fmt.Sprintf("FilterValues(%s)", funcString(fs.valueFilters[i].fnc)))
+	}
+	for i := len(fs.inverted) - 1; i >= 0; i-- {
+		ss = append(ss, fmt.Sprintf("Not(%s)", strings.Join(fs.inverted[i].strings(), ", ")))
+	}
+	return ss
+}
+
+// wrap formats the filters as the calls that would apply them to the
+// option formatted as s.
+func (fs filters) wrap(s string) string {
+	// Each filter is appended as it wraps the option, so the last filter is
+	// the outermost. The order of path filters relative to value filters
+	// is not retained, but is insignificant since all filters must pass.
+	for _, f := range fs.inverted {
+		s = fmt.Sprintf("Not(%s)", f.wrap(s))
+	}
+	for _, f := range fs.valueFilters {
+		s = fmt.Sprintf("FilterValues(%s, %s)", funcString(f.fnc), s)
+	}
+	for _, f := range fs.pathFilters {
+		s = f.wrap(s)
+	}
+	return s
+}
+
+// wrap formats the path filter as the call that would apply it to the option
+// formatted as s, or without the option argument if s is empty.
+func (f pathFilter) wrap(s string) string {
+	var name string
+	switch fv := reflect.ValueOf((func(Path) bool)(f)); fv.Pointer() {
+	case reflect.ValueOf(inMapKey).Pointer():
+		name = "FilterMapKeys"
+	case reflect.ValueOf(inMapValue).Pointer():
+		name = "FilterMapValues"
+	default:
+		name, s = "FilterPath", strings.TrimSuffix(funcString(fv)+", "+s, ", ")
+	}
+	return fmt.Sprintf("%s(%s)", name, s)
+}
+
+// String formats the option as the calls that would construct it,
+// with user provided functions identified by their names and signatures.
+func (o option) String() string {
+	var s string
 	switch op := o.op.(type) {
 	case *transformer:
-		fn := getFuncName(op.fnc.Pointer())
-		ss = append(ss, fmt.Sprintf("Transformer(%s, %s)", op.name, fn))
+		s = fmt.Sprintf("Transformer(%s, %s)", op.name, funcString(op.fnc))
+		if op.once {
+			s = fmt.Sprintf("ApplyOnce(%s)", s)
+		}
 	case *comparer:
-		fn := getFuncName(op.fnc.Pointer())
-		ss = append(ss, fmt.Sprintf("Comparer(%s)", fn))
+		s = fmt.Sprintf("Comparer(%s)", funcString(op.fnc))
+		if op.name != "" {
+			s = fmt.Sprintf("NamedComparer(%s, %s)", op.name, funcString(op.fnc))
+		}
+		if op.deciding {
+			s = "Deciding" + s
+		}
+		if op.exact {
+			s = "Exact" + s
+		}
+	case *combination:
+		ss := make([]string, len(op.opts))
+		for i, o := range op.opts {
+			ss[i] = o.String()
+		}
+		s = fmt.Sprintf("%s(%s)", op.name(), strings.Join(ss, ", "))
+	case *sharedKeys:
+		s = "CompareSharedMapKeys()"
+	case *channelContents:
+		s = "TransformChannelContents()"
+	case *multiset:
+		if op.set {
+			s = "EquateSets()"
+		} else {
+			s = "EquateMultisets()"
+		}
 	default:
-		ss = append(ss, "Ignore()")
+		s = "Ignore()"
 	}
 
-	for _, f := range o.pathFilters {
-		fn := getFuncName(reflect.ValueOf(f).Pointer())
-		ss = append(ss, fmt.Sprintf("FilterPath(%s)", fn))
+	s = filters{o.pathFilters, o.valueFilters, o.inverted}.wrap(s)
+	if o.priority != 0 {
+		s = fmt.Sprintf("Prioritized(%d, %s)", o.priority, s)
 	}
-	for _, f := range o.valueFilters {
-		fn := getFuncName(f.fnc.Pointer())
-		ss = append(ss, fmt.Sprintf("FilterValues(%s)", fn))
+	return s
+}
+
+// String formats the options as a list of the options within.
+func (opts Options) String() string {
+	var ss []string
+	for _, opt := range opts {
+		if s, ok := opt.(fmt.Stringer); ok {
+			ss = append(ss, s.String())
+		} else {
+			ss = append(ss, fmt.Sprintf("%T", opt))
+		}
+	}
+	return fmt.Sprintf("Options{%s}", strings.Join(ss, ", "))
+}
+
+// OptionDescription describes an individual option within an Options,
+// as returned by Options.Flatten.
+type OptionDescription struct {
+	// Option is the option itself, including the filters that wrap it.
+	Option Option
+
+	// Kind is the name of the function that constructed the option
+	// (e.g., "Comparer", "Transformer", "Ignore", or "AllowUnexported"),
+	// or the Go type of the option if it is unknown.
+	Kind string
+
+	// Func identifies the user provided function of a Comparer or Transformer
+	// by its name and signature (e.g., "mypkg.equalFoo func(mypkg.Foo,
+	// mypkg.Foo) bool"), and is empty for other options.
+	Func string
+
+	// Filters are the filters wrapping the option, outermost first,
+	// formatted as the calls that applied them without the option argument
+	// (e.g., "FilterPath(mypkg.isFoo func(cmp.Path) bool)" or "Prioritized(1)").
+	Filters []string
+}
+
+// Flatten returns a description of each individual option within opts,
+// in order, with nested Options expanded and copies of the same option
+// omitted after the first.
+func (opts Options) Flatten() []OptionDescription {
+	var ds []OptionDescription
+	opts.flatten(func(opt Option) {
+		if o, ok := opt.(option); ok {
+			for _, d := range ds {
+				if do, ok := d.Option.(option); ok && sameOption(o, do) {
+					return
+				}
+			}
+		}
+		ds = append(ds, describe(opt))
+	})
+	return ds
+}
+
+// flatten calls f with each option within opts, with nested Options expanded.
+func (opts Options) flatten(f func(Option)) {
+	for _, opt := range opts {
+		if o, ok := opt.(Options); ok {
+			o.flatten(f)
+		} else {
+			f(opt)
+		}
+	}
+}
+
+// describe returns the description of opt, which must not be an Options.
+func describe(opt Option) OptionDescription {
+	d := OptionDescription{Option: opt, Kind: fmt.Sprintf("%T", opt)}
+	switch o := opt.(type) {
+	case option:
+		switch op := o.op.(type) {
+		case *transformer:
+			d.Kind, d.Func = "Transformer", funcString(op.fnc)
+		case *comparer:
+			d.Kind, d.Func = "Comparer", funcString(op.fnc)
+			if op.name != "" {
+				d.Kind = "NamedComparer"
+			}
+			if op.deciding {
+				d.Kind = "DecidingComparer"
+			}
+			if op.exact {
+				d.Kind = "ExactComparer"
+			}
+		case *combination:
+			d.Kind = op.name()
+		case *sharedKeys:
+			d.Kind = "CompareSharedMapKeys"
+		case *channelContents:
+			d.Kind = "TransformChannelContents"
+		case *multiset:
+			d.Kind = "EquateMultisets"
+			if op.set {
+				d.Kind = "EquateSets"
+			}
+		default:
+			d.Kind = "Ignore"
+		}
+		if o.priority != 0 {
+			d.Filters = append(d.Filters, fmt.Sprintf("Prioritized(%d)", o.priority))
+		}
+		d.Filters = append(d.Filters, filters{o.pathFilters, o.valueFilters, o.inverted}.strings()...)
+	case fmt.Stringer:
+		if s := o.String(); strings.IndexByte(s, '(') > 0 {
+			d.Kind = s[:strings.IndexByte(s, '(')]
+		}
+	}
+	return d
+}
+
+// funcString formats the user provided function f by its name and signature.
+func funcString(f reflect.Value) string {
+	return fmt.Sprintf("%s %v", getFuncName(f.Pointer()), f.Type())
+}
+
+// getCaller returns the source location of the caller of the function
+// that calls getCaller.
+func getCaller() string {
+	_, file, line, ok := runtime.Caller(2)
+	if !ok {
+		return ""
 	}
-	return strings.Join(ss, "\n\t")
+	return fmt.Sprintf("%s:%d", filepath.Base(file), line)
 }
 
 // getFuncName returns a short function name from the pointer.
@@ -132,6 +373,50 @@ func FilterPath(f func(Path) bool, opt Option) Option {
 	}
 }
 
+// FilterMapKeys returns a new Option where opt is only evaluated for the keys
+// of maps, and the values within them, rather than for the values of maps.
+// Whenever such an option may apply, the keys of two maps are no longer
+// only matched up if they are identical: a key only present in one map is
+// matched with a key only present in the other map if the two keys are equal
+// according to the options, in which case their values are compared.
+// The path to a key ends with a MapKey step.
+//
+// For example, FilterMapKeys(Transformer("ToLower", strings.ToLower))
+// matches up map keys regardless of their case, while the values of the maps
+// are still compared exactly.
+func FilterMapKeys(opt Option) Option {
+	return FilterPath(inMapKey, opt)
+}
+
+// FilterMapValues returns a new Option where opt is only evaluated for the
+// values of maps, and the values within them, rather than for their keys.
+func FilterMapValues(opt Option) Option {
+	return FilterPath(inMapValue, opt)
+}
+
+// inMapKey reports whether the nearest map in p is traversed by a MapKey.
+func inMapKey(p Path) bool {
+	_, ok := mapPosition(p).(MapKey)
+	return ok
+}
+
+// inMapValue reports whether the nearest map in p is traversed by a MapIndex.
+func inMapValue(p Path) bool {
+	_, ok := mapPosition(p).(MapIndex)
+	return ok
+}
+
+// mapPosition returns the last MapKey or MapIndex step in p, if any.
+func mapPosition(p Path) PathStep {
+	for i := len(p) - 1; i >= 0; i-- {
+		switch p[i].(type) {
+		case MapKey, MapIndex:
+			return p[i]
+		}
+	}
+	return nil
+}
+
 // FilterValues returns a new Option where opt is only evaluated if filter f,
 // which is a function of the form "func(T, T) bool", returns true for the
 // current pair of values being compared. If the type of the values is not
@@ -143,6 +428,13 @@ func FilterPath(f func(Path) bool, opt Option) Option {
 // If T is an interface, it is possible that f is called with two values with
 // different concrete types that both implement T.
 //
+// More precisely, f is called with whatever both values hold, including nil
+// interfaces and nil pointers within an interface, and f is only skipped if
+// either value is not assignable to T. The inputs to Equal are treated as
+// values of type interface{}, such that f is also called with them if T is
+// the empty interface, even if one is an untyped nil or their types differ.
+// If f panics, then the panic is annotated with f and the current Path.
+//
 // The option passed in may be an Ignore, Transformer, Comparer, Options, or
 // a previously filtered Option.
 func FilterValues(f interface{}, opt Option) Option {
@@ -159,7 +451,7 @@ func FilterValues(f interface{}, opt Option) Option {
 		return Options(opts)
 	case option:
 		n := len(opt.valueFilters)
-		vf := valueFilter{v.Type().In(0), v}
+		vf := valueFilter{v.Type().In(0), v, newDirectFunc(f)}
 		opt.valueFilters = append(opt.valueFilters[:n:n], vf) // Append to copy
 		return opt
 	default:
@@ -167,11 +459,156 @@ func FilterValues(f interface{}, opt Option) Option {
 	}
 }
 
+// Not returns a new Option where the filters of opt are inverted, such that
+// opt is evaluated wherever its path and value filters, taken together,
+// would not have permitted it. For example, the following ignores all
+// time.Time values except for the CreatedAt field:
+//	cmp.FilterValues(func(x, y time.Time) bool { return true },
+//		cmp.Not(cmp.FilterPath(isCreatedAt, cmp.Ignore())))
+//
+// The type restriction of a Transformer or Comparer is not inverted, nor is
+// the implicit restriction of a value filter to the values assignable to
+// its type, which instead makes that filter false and its inversion true.
+//
+// The option passed in must be a previously filtered Option, or an Options
+// of them, each of which is inverted separately.
+func Not(opt Option) Option {
+	switch opt := opt.(type) {
+	case Options:
+		var opts []Option
+		for _, o := range opt {
+			opts = append(opts, Not(o)) // Append to slice copy
+		}
+		return Options(opts)
+	case option:
+		if len(opt.pathFilters)+len(opt.valueFilters)+len(opt.inverted) == 0 {
+			panic(fmt.Sprintf("cannot invert an option without a path or value filter: %v", opt))
+		}
+		f := filters{opt.pathFilters, opt.valueFilters, opt.inverted}
+		opt.pathFilters, opt.valueFilters, opt.inverted = nil, nil, []filters{f}
+		return opt
+	default:
+		panic(fmt.Sprintf("unknown option type: %T", opt))
+	}
+}
+
+// FilterPathValues returns a new Option where opt is only evaluated if both
+// the path filter f returns true for the current Path and the values filter g,
+// which is a function of the form "func(T, T) bool", returns true for the
+// current pair of values. It is equivalent to
+// FilterPath(f, FilterValues(g, opt)), and the same requirements apply to
+// f and g. Since the path filter is evaluated first, g is only called for
+// values at paths that f accepts.
+//
+// For example, the following ignores the Amount field when both amounts are
+// negative:
+//	cmp.FilterPathValues(isAmount, func(x, y int) bool { return x < 0 && y < 0 }, cmp.Ignore())
+func FilterPathValues(f func(Path) bool, g interface{}, opt Option) Option {
+	return FilterPath(f, FilterValues(g, opt))
+}
+
+// Prioritized returns a new Option where opt takes precedence over all other
+// options with a lower priority level that apply to the same values.
+// Options that are not prioritized have a priority level of 0.
+// This replaces any priority level previously assigned to opt.
+//
+// For example, the following ignores all time.Time values except for the
+// CreatedAt field, which is compared using the Equal method:
+//	cmp.Options{
+//		cmp.FilterValues(func(x, y time.Time) bool { return true }, cmp.Ignore()),
+//		cmp.Prioritized(1, cmp.FilterPath(isCreatedAt, cmp.Comparer(time.Time.Equal))),
+//	}
+//
+// Equal still panics if multiple Transformer or Comparer options apply
+// at the same highest priority level.
+//
+// The option passed in may be an Ignore, Transformer, Comparer, Options, or
+// a previously filtered Option.
+func Prioritized(level int, opt Option) Option {
+	switch opt := opt.(type) {
+	case Options:
+		var opts []Option
+		for _, o := range opt {
+			opts = append(opts, Prioritized(level, o)) // Append to slice copy
+		}
+		return Options(opts)
+	case option:
+		opt.priority = level
+		return opt
+	default:
+		panic(fmt.Sprintf("unknown option type: %T", opt))
+	}
+}
+
+// Within returns an Option that ignores all values that are neither within
+// nor along the way to the nodes described by any of the path expressions,
+// such that only those subtrees participate in the comparison.
+// The reported paths remain relative to the original values.
+//
+// A path expression is a sequence of struct field accesses (e.g., ".Field"),
+// slice indexes (e.g., "[2]"), and map indexes (e.g., `["key"]` or "[5]"),
+// where the leading period is optional and pointer indirections and
+// type assertions are implicit. For example:
+//	Within("Slaps[0].Immutable", `Config["storage"]`)
+func Within(exprs ...string) Option {
+	if len(exprs) == 0 {
+		panic("missing path expressions")
+	}
+	var pes []pathExpr
+	for _, s := range exprs {
+		pe, err := parsePathExpr(s)
+		if err != nil {
+			panic(fmt.Sprintf("invalid path expression %q: %v", s, err))
+		}
+		pes = append(pes, pe)
+	}
+	return FilterPath(func(p Path) bool {
+		for _, pe := range pes {
+			if agree, _ := pe.matchPath(p); agree {
+				return false
+			}
+		}
+		return true
+	}, Ignore())
+}
+
+// AuditIgnores returns an Option that calls f whenever an Ignore option
+// suppresses a difference, which is useful for detecting broad Ignore options
+// that hide genuine regressions. The result of Equal is unaffected.
+//
+// The function f is called with the Path to the ignored node and a report of
+// the differences beneath it in the same format as Diff. If the ignored values
+// cannot be evaluated (e.g., because they contain unexported fields),
+// then the report is a note explaining why.
+func AuditIgnores(f func(p Path, diff string)) Option {
+	if f == nil {
+		panic("invalid audit function")
+	}
+	return ignoreAuditor(f)
+}
+
+type ignoreAuditor func(Path, string)
+
+func (ignoreAuditor) option() {}
+
+// StrictIgnores returns an Option that treats differences suppressed by
+// an Ignore option as actual differences, such that Equal reports false.
+// Such differences are reported with a note naming the ignored node.
+// It is a stricter variant of AuditIgnores that is intended for verifying
+// that a set of Ignore options does not hide any differences.
+func StrictIgnores() Option {
+	return strictIgnores{}
+}
+
+type strictIgnores struct{}
+
+func (strictIgnores) option() {}
+
 // Ignore is an Option that causes all comparisons to be ignored.
 // This value is intended to be combined with FilterPath or FilterValues.
 // It is an error to pass an unfiltered Ignore option to Equal.
 func Ignore() Option {
-	return option{}
+	return option{src: getCaller()}
 }
 
 // Transformer returns an Option that applies a transformation function that
@@ -183,105 +620,1653 @@ func Ignore() Option {
 // If T and R are the same type, an additional filter must be applied to
 // act as the base case to prevent an infinite recursion applying the same
 // transform to itself (see the SortedSlice example).
+// Equal panics if a long sequence of transformations is applied to a value
+// without making progress into its sub-values, which indicates that some set
+// of Transformers is recursively being applied forever.
+//
+// The transformer f may also be a function "func(T) (R, error)" for
+// transformations that can fail (e.g., parsing or decoding).
+// If the transformation of either value reports an error, then the
+// Transformer is treated as if it did not apply to those values and evaluation
+// proceeds as if the Transformer were never provided, unless the
+// StrictTransformers option is used.
 //
 // The name is a user provided label that is used as the Transform.Name in the
-// transformation PathStep. If empty, an arbitrary name is used.
+// transformation PathStep, which must be an identifier optionally qualified
+// by other identifiers (e.g., "Sort" or "mypkg.Sort"). If empty, the name of
+// the function f is used (e.g., "mypkg.sortInts" or "mypkg.Func.func1"),
+// or an arbitrary name if that is not such an identifier.
 func Transformer(name string, f interface{}) Option {
 	v := reflect.ValueOf(f)
-	if functionType(v.Type()) != transformFunc || v.IsNil() {
+	if ft := functionType(v.Type()); (ft != transformFunc && ft != transformErrFunc) || v.IsNil() {
 		panic(fmt.Sprintf("invalid transformer function: %T", f))
 	}
 	if name == "" {
-		name = "λ" // Lambda-symbol as place-holder for anonymous transformer
+		name = getFuncName(v.Pointer())
+		if !isQualified(name) {
+			name = "λ" // Lambda-symbol as place-holder for anonymous transformer
+		}
 	}
-	if !isValid(name) {
+	if !isQualified(name) {
 		panic(fmt.Sprintf("invalid name: %q", name))
 	}
-	opt := option{op: &transformer{name, reflect.ValueOf(f)}}
+	opt := option{op: &transformer{name: name, fnc: v}, src: getCaller()}
 	if ti := v.Type().In(0); ti.Kind() != reflect.Interface || ti.NumMethod() > 0 {
 		opt.typeFilter = ti
 	}
 	return opt
 }
 
+// AccessVia returns a Transformer option that compares values of a type T
+// by the result of calling the named method on them, which must take no
+// arguments and return a single value. The method may have either a value
+// or a pointer receiver, in which case it is called on a copy of the value.
+// This allows types that only expose their state through accessor methods
+// to be compared without AllowUnexported, which is unavailable on platforms
+// where package unsafe cannot be used.
+//
+// The type T is specified by passing in a value of type T or its reflect.Type.
+// A single level of pointer indirection is removed, such that a value of
+// type *T may also be passed. The call is recorded in the Path as a Transform
+// named after the method.
+func AccessVia(typ interface{}, method string) Option {
+	t, ok := typ.(reflect.Type)
+	if !ok {
+		t = reflect.TypeOf(typ)
+	}
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil {
+		panic("invalid type: <nil>")
+	}
+	m, ok := reflect.PtrTo(t).MethodByName(method)
+	if !ok || m.Type.NumIn() != 1 || m.Type.NumOut() != 1 {
+		panic(fmt.Sprintf("invalid accessor method: %v.%s", t, method))
+	}
+	_, byValue := t.MethodByName(method)
+	ft := reflect.FuncOf([]reflect.Type{t}, []reflect.Type{m.Type.Out(0)}, false)
+	fn := reflect.MakeFunc(ft, func(args []reflect.Value) []reflect.Value {
+		v := args[0]
+		if !byValue {
+			v = makeAddressable(v).Addr()
+		}
+		return v.MethodByName(method).Call(nil)
+	})
+	return option{typeFilter: t, op: &transformer{name: method, fnc: fn}, src: getCaller()}
+}
+
 type transformer struct {
 	name string
-	fnc  reflect.Value // func(T) R
+	fnc  reflect.Value // func(T) R or func(T) (R, error)
+	once bool          // Whether to apply at most once along a path
+}
+
+// fallible reports whether the transformer may report an error.
+func (tr *transformer) fallible() bool {
+	return tr.fnc.Type().NumOut() == 2
+}
+
+// ApplyOnce returns a new Option where the Transformer opt is applied at most
+// once along any path, such that it does not apply to values beneath a value
+// that it already transformed. This allows a transformer whose output type is
+// the same as its input type (e.g., one that sorts a []int) to be used without
+// a filter to prevent it from applying to its own output. Filters applied to
+// opt, before or after ApplyOnce, still apply.
+//
+// Only the recursive application of the same Transformer is prevented, so a
+// cyclic chain of other Transformers is still reported as recursive.
+//
+// The option passed in must be a Transformer, an Options of them,
+// or a filtered Option of either.
+func ApplyOnce(opt Option) Option {
+	switch opt := opt.(type) {
+	case Options:
+		var opts []Option
+		for _, o := range opt {
+			opts = append(opts, ApplyOnce(o)) // Append to slice copy
+		}
+		return Options(opts)
+	case option:
+		tr, ok := opt.op.(*transformer)
+		if !ok {
+			panic(fmt.Sprintf("ApplyOnce only accepts Transformer options: %v", opt))
+		}
+		tr2 := *tr
+		tr2.once = true
+		opt.op = &tr2
+		return opt
+	default:
+		panic(fmt.Sprintf("unknown option type: %T", opt))
+	}
 }
 
+// StrictTransformers returns an Option that causes Equal to panic with
+// the current Path whenever a Transformer of the form "func(T) (R, error)"
+// reports an error, rather than ignoring the Transformer for those values.
+func StrictTransformers() Option {
+	return strictTransformers{}
+}
+
+type strictTransformers struct{}
+
+func (strictTransformers) option() {}
+
 // Comparer returns an Option that determines whether two values are equal
 // to each other.
 //
 // The comparer f must be a function "func(T, T) bool" and is implicitly
 // filtered to input values assignable to T. If T is an interface, it is
 // possible that f is called with two values of different concrete types that
-// both implement T.
+// both implement T. Use ExactComparer to only apply f to values of type T.
 //
 // The equality function must be:
 //	• Symmetric: equal(x, y) == equal(y, x)
 //	• Deterministic: equal(x, y) == equal(x, y)
 //	• Pure: equal(x, y) does not modify x or y
+//
+// If T is a pointer, the equality function should handle nil inputs.
+// Unless the DisableNilProbe option is used, Equal panics upfront if an
+// unfiltered comparer on pointers panics when called with two nil pointers.
 func Comparer(f interface{}) Option {
 	v := reflect.ValueOf(f)
 	if functionType(v.Type()) != equalFunc || v.IsNil() {
 		panic(fmt.Sprintf("invalid comparer function: %T", f))
 	}
-	opt := option{op: &comparer{v}}
+	opt := option{op: &comparer{fnc: v, direct: newDirectFunc(f)}, src: getCaller()}
 	if ti := v.Type().In(0); ti.Kind() != reflect.Interface || ti.NumMethod() > 0 {
 		opt.typeFilter = ti
 	}
 	return opt
 }
 
+// NamedComparer returns an Option like Comparer, except that the comparer is
+// identified by name in Options.String, in the result of Explain, and in
+// panics about ambiguous options, rather than by the name of its function.
+// The name must be an identifier optionally qualified by other identifiers
+// (e.g., "ApproxTime" or "mypkg.ApproxTime"), as for Transformer.
+func NamedComparer(name string, f interface{}) Option {
+	if !isQualified(name) {
+		panic(fmt.Sprintf("invalid name: %q", name))
+	}
+	opt := Comparer(f).(option)
+	opt.op.(*comparer).name = name
+	opt.src = getCaller()
+	return opt
+}
+
+// ExactComparer returns an Option like Comparer, except that the comparer is
+// only applied to values whose type is identical to T, rather than to all
+// values assignable to T. If T is an interface, the comparer only applies
+// where values are statically typed as T, and not to concrete types that
+// implement T, such that a type that later gains a method of T does not
+// silently change how it is compared.
+func ExactComparer(f interface{}) Option {
+	opt := Comparer(f).(option)
+	opt.op.(*comparer).exact = true
+	opt.typeFilter = opt.op.(*comparer).fnc.Type().In(0)
+	opt.src = getCaller()
+	return opt
+}
+
 type comparer struct {
-	fnc reflect.Value // func(T, T) bool
+	name     string                          // Name from NamedComparer, if any
+	exact    bool                            // Whether T must be identical
+	fnc      reflect.Value                   // func(T, T) bool | func(T, T) Verdict
+	direct   directFunc                      // Optional equivalent of fnc
+	note     func(x, y reflect.Value) string // Optional note for unequal values
+	deciding bool                            // Whether fnc returns a Verdict
+
+	// The result of probing fnc with nil pointers is the same for every
+	// comparison, so it is only probed once per comparer.
+	probe   sync.Once
+	probeEx string // Panic message if the probe panicked
 }
 
-// AllowUnexported returns an Option that forcibly allows operations on
-// unexported fields in certain structs, which are specified by passing in a
-// value of each struct type.
-//
-// Users of this option must understand that comparing on unexported fields
-// from external packages is not safe since changes in the internal
-// implementation of some external package may cause the result of Equal
-// to unexpectedly change. However, it may be valid to use this option on types
-// defined in an internal package where the semantic meaning of an unexported
-// field is in the control of the user.
+// Verdict is the result of a comparer created by DecidingComparer.
+type Verdict int
+
+const (
+	// Undecided indicates that the comparer cannot decide whether the values
+	// are equal, such that they are compared as if it did not apply.
+	Undecided Verdict = iota
+	// Same indicates that the values are equal.
+	Same
+	// Different indicates that the values are not equal.
+	Different
+)
+
+// DecidingComparer returns an Option like Comparer, except that the
+// equality function is of the form "func(T, T) Verdict", which may decline
+// to decide whether two values are equal by returning Undecided. In that case,
+// the values are compared as if the comparer did not apply, either by
+// another option or by the default rules of Equal. An undecided comparer is
+// also never ambiguous with another option that applies to the same values.
 //
-// For most cases, a custom Comparer should be used instead that defines
-// equality as a function of the public API of a type rather than the underlying
-// unexported implementation.
+// For example, a comparer may only decide values that both have a checksum,
+// leaving the remaining values to be compared structurally.
+// The equality function has the same requirements as for Comparer,
+// and may not be used within AnyOf or AllOf.
+func DecidingComparer(f interface{}) Option {
+	v := reflect.ValueOf(f)
+	if t := v.Type(); t.Kind() != reflect.Func || t.IsVariadic() || v.IsNil() ||
+		t.NumIn() != 2 || t.In(0) != t.In(1) || t.NumOut() != 1 || t.Out(0) != verdictType {
+		panic(fmt.Sprintf("invalid deciding comparer function: %T", f))
+	}
+	opt := option{op: &comparer{fnc: v, deciding: true}, src: getCaller()}
+	if ti := v.Type().In(0); ti.Kind() != reflect.Interface || ti.NumMethod() > 0 {
+		opt.typeFilter = ti
+	}
+	return opt
+}
+
+var verdictType = reflect.TypeOf(Undecided)
+
+// DisableNilProbe returns an Option that disables the check performed by
+// Equal that every unfiltered Comparer on a pointer type can be called with
+// two nil pointers without panicking. This is useful for comparers on values
+// that are known to never be nil.
+func DisableNilProbe() Option {
+	return nilProbe{}
+}
+
+type nilProbe struct{}
+
+func (nilProbe) option() {}
+
+// AnyOf returns an Option that determines two values to be equal if any of
+// the given options determines them to be equal, which allows several
+// overlapping comparers to apply to the same values without being ambiguous.
+// For example, a field may be acceptable if it is exactly equal, within some
+// tolerance (e.g., EquateApprox), or zero.
 //
-// For example, the reflect.Type documentation defines equality to be determined
-// by the == operator on the interface (essentially performing a shallow pointer
-// comparison) and most attempts to compare *regexp.Regexp types are interested
-// in only checking that the regular expression strings are equal.
-// Both of these are accomplished using Comparers:
-//	Comparer(func(x, y reflect.Type) bool { return x == y })
-//	Comparer(func(x, y *regexp.Regexp) bool { return x.String() == y.String() })
+// Each option must be a Comparer, an Options of comparers, or a filtered
+// Option of either (including a nested AnyOf or AllOf). The comparers are
+// evaluated in order and only those whose filters apply to the values are
+// considered. If none apply, then AnyOf does not apply either.
+func AnyOf(opts ...Option) Option {
+	return newCombination(opts, false)
+}
+
+// AllOf returns an Option that determines two values to be equal only if
+// every one of the given options that applies determines them to be equal,
+// such that independent definitions of equality (e.g., an Equal function
+// and a comparison of String forms) must all hold.
+// The first comparer to report a difference is identified in the report.
 //
-// NOTE: This feature is experimental and may be removed!
-func AllowUnexported(types ...interface{}) Option {
-	m := make(map[reflect.Type]bool)
-	for _, typ := range types {
-		t := reflect.TypeOf(typ)
-		if t.Kind() != reflect.Struct {
-			panic(fmt.Sprintf("invalid struct type: %T", typ))
+// The options are the same as for AnyOf, and likewise AllOf only applies
+// if at least one of its comparers applies to the values.
+func AllOf(opts ...Option) Option {
+	return newCombination(opts, true)
+}
+
+func newCombination(opts []Option, all bool) option {
+	c := &combination{all: all}
+	for _, opt := range opts {
+		c.add(opt, nil)
+	}
+	if len(c.opts) == 0 {
+		panic(fmt.Sprintf("%s requires at least one Comparer", c.name()))
+	}
+	opt := option{op: c, src: getCaller(), typeFilter: c.opts[0].typeFilter}
+	for _, o := range c.opts[1:] {
+		if o.typeFilter != opt.typeFilter {
+			opt.typeFilter = nil // The children are filtered individually
 		}
-		m[t] = true
 	}
-	return visibleStructs(m)
+	return opt
 }
 
-type visibleStructs map[reflect.Type]bool
+type combination struct {
+	opts []option // Each a Comparer, or a combination with a different all
+	all  bool     // Whether every comparer must report equal, or just one
+}
 
-func (visibleStructs) option() {}
+func (c *combination) name() string {
+	if c.all {
+		return "AllOf"
+	}
+	return "AnyOf"
+}
 
-// reporter is an Option that configures how differences are reported.
+// add flattens opt into the list of comparers, with the filters of the
+// enclosing combination, if any, applied to each of them.
+func (c *combination) add(opt Option, outer *option) {
+	switch opt := opt.(type) {
+	case Options:
+		for _, o := range opt {
+			c.add(o, outer)
+		}
+	case option:
+		if outer != nil {
+			n, m, k := len(outer.pathFilters), len(outer.valueFilters), len(outer.inverted)
+			opt.pathFilters = append(outer.pathFilters[:n:n], opt.pathFilters...)
+			opt.valueFilters = append(outer.valueFilters[:m:m], opt.valueFilters...)
+			opt.inverted = append(outer.inverted[:k:k], opt.inverted...)
+		}
+		switch op := opt.op.(type) {
+		case *comparer:
+			if op.deciding {
+				panic(fmt.Sprintf("%s does not accept a DecidingComparer: %v", c.name(), opt))
+			}
+			c.opts = append(c.opts, opt)
+		case *combination:
+			if op.all != c.all {
+				c.opts = append(c.opts, opt) // Evaluated as a single comparer
+				break
+			}
+			for _, o := range op.opts {
+				c.add(o, &opt)
+			}
+		default:
+			panic(fmt.Sprintf("%s only accepts Comparer options: %v", c.name(), opt))
+		}
+	default:
+		panic(fmt.Sprintf("unknown option type: %T", opt))
+	}
+}
+
+// RegisterDefaultOptions registers options that Equal and Diff use to compare
+// every value of the same type as typ, in addition to the options passed
+// to them, unless the WithoutDefaults option is used. This allows a package
+// that defines a type to declare how values of the type are compared,
+// without every caller having to pass the options.
 //
-// TODO: Not exported yet, see concerns in defaultReporter.Report.
-type reporter interface {
-	Option
+// Each option must be an Ignore, Transformer, Comparer, Options, or a filtered
+// Option that may apply to values of the type, and is restricted to apply to
+// only those values. Registered options have lower priority than any option
+// passed to Equal, such that explicit options always win over them.
+//
+// It is intended to be called from an init function, since comparisons that
+// are in progress may not observe the options. It is safe for concurrent use,
+// but panics if options are already registered for the type.
+func RegisterDefaultOptions(typ interface{}, opts ...Option) {
+	t := reflect.TypeOf(typ)
+	if t == nil {
+		panic("cannot register default options for a nil type")
+	}
+	var flat []option
+	var flatten func(Option)
+	flatten = func(opt Option) {
+		switch opt := opt.(type) {
+		case Options:
+			for _, o := range opt {
+				flatten(o)
+			}
+		case option:
+			if !typeApplies(t, opt) {
+				panic(fmt.Sprintf("default option does not apply to type %v: %v", t, opt))
+			}
+			opt.typeFilter, opt.priority = t, minPriority
+			flat = append(flat, opt)
+		default:
+			panic(fmt.Sprintf("cannot register default option of type %T", opt))
+		}
+	}
+	for _, opt := range opts {
+		flatten(opt)
+	}
+
+	defaultOpts.mu.Lock()
+	defer defaultOpts.mu.Unlock()
+	if defaultOpts.types[t] {
+		panic(fmt.Sprintf("default options already registered for type %v", t))
+	}
+	if defaultOpts.types == nil {
+		defaultOpts.types = make(map[reflect.Type]bool)
+	}
+	defaultOpts.types[t] = true
+	defaultOpts.opts = append(defaultOpts.opts, flat...)
+	atomic.StoreInt32(&defaultOpts.any, 1)
+	plainFastTypes.Range(func(t, _ interface{}) bool {
+		plainFastTypes.Delete(t)
+		return true
+	})
+}
+
+// minPriority is the priority of registered default options,
+// which is lower than that of any explicit option.
+const minPriority = -int(^uint(0)>>1) - 1
+
+// defaultOpts is the registry of options added by RegisterDefaultOptions.
+var defaultOpts struct {
+	mu    sync.RWMutex
+	types map[reflect.Type]bool // Types with registered options
+	opts  []option              // Registered options; only ever appended to
+	any   int32                 // Whether any options are registered; accessed atomically
+}
+
+// registeredOptions returns the options added by RegisterDefaultOptions.
+// The returned slice must not be modified.
+func registeredOptions() []option {
+	if atomic.LoadInt32(&defaultOpts.any) == 0 {
+		return nil
+	}
+	defaultOpts.mu.RLock()
+	defer defaultOpts.mu.RUnlock()
+	return defaultOpts.opts[:len(defaultOpts.opts):len(defaultOpts.opts)]
+}
+
+// WithoutDefaults returns an Option that disables the options registered by
+// RegisterDefaultOptions, such that only the explicitly passed options apply.
+func WithoutDefaults() Option {
+	return withoutDefaults{}
+}
+
+type withoutDefaults struct{}
+
+func (withoutDefaults) option() {}
+
+// Optioner is implemented by types that declare the options used to compare
+// their values, as an alternative to RegisterDefaultOptions.
+//
+// When Equal compares two values of a type T such that T or *T implements
+// Optioner, the options returned by CmpOptions are used in addition to the
+// options passed to Equal, but only to compare those values and the values
+// within them. CmpOptions is called on the x value of the pair being compared,
+// or on a pointer to a copy of it if only *T implements Optioner, so that the
+// options may depend on the value. A nil pointer is replaced with a pointer to
+// the zero value, such that CmpOptions may always read its receiver.
+// Only the options of the outermost value of each such type are used, such
+// that the options may apply to values of the type itself without being
+// added again.
+//
+// The options may only be Ignore, Transformer, Comparer, Options,
+// or filtered Options.
+type Optioner interface {
+	CmpOptions() []Option
+}
+
+var optionerType = reflect.TypeOf((*Optioner)(nil)).Elem()
+
+// optioners records whether each type is an Optioner, as by isOptioner.
+var optioners sync.Map // map[reflect.Type]bool
+
+// isOptioner reports whether t or *t implements Optioner.
+func isOptioner(t reflect.Type) bool {
+	if ok, found := optioners.Load(t); found {
+		return ok.(bool)
+	}
+	ok := t.Kind() != reflect.Interface &&
+		(t.Implements(optionerType) || t.Kind() != reflect.Ptr && reflect.PtrTo(t).Implements(optionerType))
+	optioners.Store(t, ok)
+	return ok
+}
+
+// optionerOptions returns the options of v, whose type must satisfy isOptioner.
+func optionerOptions(v reflect.Value) []Option {
+	switch t := v.Type(); {
+	case t.Kind() == reflect.Ptr && v.IsNil():
+		v = reflect.New(t.Elem())
+	case !t.Implements(optionerType):
+		v = makeAddressable(v).Addr()
+	}
+	return v.Interface().(Optioner).CmpOptions()
+}
+
+// EquateApprox returns a Comparer option that determines float32 or float64
+// values to be equal if they are within a relative fraction or absolute margin.
+// NaN and infinite values are not affected by this option.
+//
+// Let d be the absolute difference between x and y. The values x and y are
+// equal if either d ≤ fraction*min(|x|, |y|) or d ≤ margin.
+// The fraction and margin must be non-negative.
+func EquateApprox(fraction, margin float64) Option {
+	if margin < 0 || fraction < 0 || math.IsNaN(margin) || math.IsNaN(fraction) {
+		panic("margin or fraction must be a non-negative number")
+	}
+	a := approximator{fraction, margin}
+	return Options{
+		FilterValues(areRealF64s, Comparer(a.compareF64)),
+		FilterValues(areRealF32s, Comparer(a.compareF32)),
+	}
+}
+
+type approximator struct{ frac, marg float64 }
+
+func areRealF64s(x, y float64) bool {
+	return !math.IsNaN(x) && !math.IsNaN(y) && !math.IsInf(x, 0) && !math.IsInf(y, 0)
+}
+func areRealF32s(x, y float32) bool {
+	return areRealF64s(float64(x), float64(y))
+}
+func (a approximator) compareF64(x, y float64) bool {
+	relMarg := a.frac * math.Min(math.Abs(x), math.Abs(y))
+	return math.Abs(x-y) <= math.Max(a.marg, relMarg)
+}
+func (a approximator) compareF32(x, y float32) bool {
+	return a.compareF64(float64(x), float64(y))
+}
+
+// EquateApproxComplex returns a Comparer option that determines complex64 or
+// complex128 values to be equal if they are within a relative fraction or
+// absolute margin, where the tolerance is applied to the modulus of the
+// difference. Values with a NaN or infinite component are not affected
+// by this option. The absolute error of unequal values is reported
+// alongside their rectangular forms.
+//
+// Let d be |x-y|. The values x and y are equal if either
+// d ≤ fraction*min(|x|, |y|) or d ≤ margin.
+// The fraction and margin must be non-negative.
+func EquateApproxComplex(fraction, margin float64) Option {
+	if margin < 0 || fraction < 0 || math.IsNaN(margin) || math.IsNaN(fraction) {
+		panic("margin or fraction must be a non-negative number")
+	}
+	a := approximator{fraction, margin}
+	c128 := Comparer(a.compareC128).(option)
+	c128.op.(*comparer).note = noteAbsError
+	c64 := Comparer(a.compareC64).(option)
+	c64.op.(*comparer).note = noteAbsError
+	return Options{
+		FilterValues(areRealC128s, c128),
+		FilterValues(areRealC64s, c64),
+	}
+}
+
+func areRealC128s(x, y complex128) bool {
+	return !cmplx.IsNaN(x) && !cmplx.IsNaN(y) && !cmplx.IsInf(x) && !cmplx.IsInf(y)
+}
+func areRealC64s(x, y complex64) bool {
+	return areRealC128s(complex128(x), complex128(y))
+}
+func (a approximator) compareC128(x, y complex128) bool {
+	relMarg := a.frac * math.Min(cmplx.Abs(x), cmplx.Abs(y))
+	return cmplx.Abs(x-y) <= math.Max(a.marg, relMarg)
+}
+func (a approximator) compareC64(x, y complex64) bool {
+	return a.compareC128(complex128(x), complex128(y))
+}
+func noteAbsError(x, y reflect.Value) string {
+	return fmt.Sprintf("absolute error is %v", cmplx.Abs(x.Complex()-y.Complex()))
+}
+
+// EquateBig returns a set of Comparer options that determine *big.Int,
+// *big.Float, and *big.Rat values (and their non-pointer forms) to be equal
+// if their Cmp method reports zero, regardless of their internal
+// representation (e.g., the precision or rounding mode of a big.Float).
+// Two nil pointers are equal, while a nil pointer is never equal to a
+// non-nil pointer, even if it points to zero.
+//
+// EquateBig must not be combined with EquateApproxBigFloat since both
+// apply to big.Float values.
+func EquateBig() Option {
+	return Options{
+		Comparer(equateBigInt),
+		Comparer(equateBigFloat),
+		Comparer(equateBigRat),
+		Comparer(func(x, y big.Int) bool { return equateBigInt(&x, &y) }),
+		Comparer(func(x, y big.Float) bool { return equateBigFloat(&x, &y) }),
+		Comparer(func(x, y big.Rat) bool { return equateBigRat(&x, &y) }),
+	}
+}
+
+func equateBigInt(x, y *big.Int) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return x.Cmp(y) == 0
+}
+func equateBigFloat(x, y *big.Float) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return x.Cmp(y) == 0
+}
+func equateBigRat(x, y *big.Rat) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return x.Cmp(y) == 0
+}
+
+// EquateApproxBigFloat returns a set of Comparer options that determine
+// *big.Float values (and their non-pointer forms) to be equal if they are
+// within a relative fraction or absolute margin, in the same way as
+// EquateApprox. Infinite values are only equal to themselves and
+// nil pointers are handled as in EquateBig.
+// The fraction and margin must be non-negative.
+func EquateApproxBigFloat(fraction, margin float64) Option {
+	if margin < 0 || fraction < 0 || math.IsNaN(margin) || math.IsNaN(fraction) {
+		panic("margin or fraction must be a non-negative number")
+	}
+	a := approximator{fraction, margin}
+	return Options{
+		Comparer(a.compareBigFloat),
+		Comparer(func(x, y big.Float) bool { return a.compareBigFloat(&x, &y) }),
+	}
+}
+
+func (a approximator) compareBigFloat(x, y *big.Float) bool {
+	if x == nil || y == nil || x.IsInf() || y.IsInf() {
+		return equateBigFloat(x, y)
+	}
+	prec := x.MinPrec()
+	if p := y.MinPrec(); p > prec {
+		prec = p
+	}
+	prec += 64 // Enough headroom for the fraction and margin
+	d := new(big.Float).SetPrec(prec).Sub(x, y)
+	d.Abs(d)
+	ax := new(big.Float).SetPrec(prec).Abs(x)
+	ay := new(big.Float).SetPrec(prec).Abs(y)
+	if ay.Cmp(ax) < 0 {
+		ax = ay
+	}
+	relMarg := ax.Mul(ax, new(big.Float).SetFloat64(a.frac))
+	return d.Cmp(relMarg) <= 0 || d.Cmp(new(big.Float).SetFloat64(a.marg)) <= 0
+}
+
+// EquateFloatsBitwise returns a set of Comparer options that determine
+// float32 or float64 values to be equal only if their IEEE-754 bit patterns
+// are identical. Thus, -0.0 is not equal to +0.0, a NaN is equal to a NaN
+// with the same bit pattern, and NaNs that differ in their sign, quiet bit,
+// or payload are unequal. The bit patterns of unequal values are reported
+// alongside their decimal forms.
+//
+// This is intended to be combined with FilterPath or FilterValues to
+// limit the values that are compared strictly.
+func EquateFloatsBitwise() Option {
+	f64 := Comparer(func(x, y float64) bool {
+		return math.Float64bits(x) == math.Float64bits(y)
+	}).(option)
+	f64.op.(*comparer).note = func(x, y reflect.Value) string {
+		return fmt.Sprintf("bit patterns are 0x%016x and 0x%016x",
+			math.Float64bits(x.Float()), math.Float64bits(y.Float()))
+	}
+	f32 := Comparer(func(x, y float32) bool {
+		return math.Float32bits(x) == math.Float32bits(y)
+	}).(option)
+	f32.op.(*comparer).note = func(x, y reflect.Value) string {
+		return fmt.Sprintf("bit patterns are 0x%08x and 0x%08x",
+			math.Float32bits(float32(x.Float())), math.Float32bits(float32(y.Float())))
+	}
+	return Options{f64, f32}
+}
+
+// ComparePointersByIdentity returns a set of Comparer options that determine
+// pointers of the specified types to be equal only if they are both nil or
+// point to the same object. The pointed-at values are never compared,
+// which is useful for pointers that act as handles to registered objects
+// that may be large or cyclic. The addresses and the types of unequal
+// pointers are reported alongside the pointed-at values.
+//
+// Each type is specified by passing in a pointer value of that type or the
+// reflect.Type of that pointer type.
+func ComparePointersByIdentity(types ...interface{}) Option {
+	var opts Options
+	for _, typ := range types {
+		t, ok := typ.(reflect.Type)
+		if !ok {
+			t = reflect.TypeOf(typ)
+		}
+		if t == nil || t.Kind() != reflect.Ptr {
+			panic(fmt.Sprintf("invalid pointer type: %v", t))
+		}
+		ft := reflect.FuncOf([]reflect.Type{t, t}, []reflect.Type{boolType}, false)
+		fn := reflect.MakeFunc(ft, func(args []reflect.Value) []reflect.Value {
+			return []reflect.Value{reflect.ValueOf(args[0].Pointer() == args[1].Pointer())}
+		})
+		opt := option{typeFilter: t, op: &comparer{fnc: fn, note: notePointers}, src: getCaller()}
+		opts = append(opts, opt)
+	}
+	return opts
+}
+
+func notePointers(x, y reflect.Value) string {
+	return fmt.Sprintf("pointers are compared by identity: (%v)(%#x) and (%v)(%#x)",
+		x.Type(), x.Pointer(), y.Type(), y.Pointer())
+}
+
+// CompareByString returns a set of Comparer options that determine values of
+// the specified types to be equal if their String methods return the same
+// output. Each type must implement fmt.Stringer and is specified by passing
+// in a value of that type or its reflect.Type. Nil pointers, interfaces,
+// maps, and slices are never passed to the String method; instead, they are
+// only equal to other nil values.
+//
+// If no types are specified, then the option applies to all values that
+// implement fmt.Stringer, in which case it should be combined with
+// FilterPath or FilterValues to limit the values that it applies to.
+func CompareByString(types ...interface{}) Option {
+	if len(types) == 0 {
+		return Comparer(func(x, y fmt.Stringer) bool {
+			return equalStrings(reflect.ValueOf(x), reflect.ValueOf(y))
+		})
+	}
+	var opts Options
+	for _, typ := range types {
+		t, ok := typ.(reflect.Type)
+		if !ok {
+			t = reflect.TypeOf(typ)
+		}
+		if t == nil || !t.Implements(stringerIface) {
+			panic(fmt.Sprintf("type does not implement fmt.Stringer: %v", t))
+		}
+		ft := reflect.FuncOf([]reflect.Type{t, t}, []reflect.Type{boolType}, false)
+		fn := reflect.MakeFunc(ft, func(args []reflect.Value) []reflect.Value {
+			return []reflect.Value{reflect.ValueOf(equalStrings(args[0], args[1]))}
+		})
+		opts = append(opts, option{typeFilter: t, op: &comparer{fnc: fn}, src: getCaller()})
+	}
+	return opts
+}
+
+// equalStrings reports whether the String methods of x and y return the same
+// output, where nil values are only equal to other nil values.
+func equalStrings(x, y reflect.Value) bool {
+	if nx, ny := isNilValue(x), isNilValue(y); nx || ny {
+		return nx && ny
+	}
+	return x.Interface().(fmt.Stringer).String() == y.Interface().(fmt.Stringer).String()
+}
+
+func isNilValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return true
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+		return v.IsNil()
+	}
+	return false
+}
+
+// CompareErrorsByMessage returns a Comparer option that determines errors to
+// be equal if they are both nil or if they are both non-nil and their Error
+// methods return the same message. Wrapped errors are compared by their
+// full message. An interface holding a nil pointer is treated as nil.
+// The messages of unequal errors are reported alongside the errors.
+func CompareErrorsByMessage() Option {
+	opt := Comparer(func(x, y error) bool {
+		if nx, ny := isNilValue(reflect.ValueOf(x)), isNilValue(reflect.ValueOf(y)); nx || ny {
+			return nx && ny
+		}
+		return x.Error() == y.Error()
+	}).(option)
+	opt.op.(*comparer).note = noteErrors
+	return opt
+}
+
+func noteErrors(x, y reflect.Value) string {
+	msg := func(v reflect.Value) string {
+		if v.Kind() == reflect.Interface {
+			v = v.Elem()
+		}
+		if isNilValue(v) {
+			return "<nil>"
+		}
+		return fmt.Sprintf("%q", v.Interface().(error).Error())
+	}
+	return fmt.Sprintf("error messages are %s and %s", msg(x), msg(y))
+}
+
+// EquateMultisets returns an Option that compares slices and arrays as
+// multisets, where the order of the elements is disregarded. The values are
+// equal if every element of x can be matched with a distinct element of y
+// that is equal to it according to all other options, and vice versa.
+// Unmatched elements of either value are reported as the differences.
+//
+// Every element of x is compared with every element of y, such that
+// comparing slices of length n and m requires O(n·m) element comparisons.
+// A maximum bipartite matching is then found in O(n·n·m) time, which gives
+// the correct result even if equality is not transitive (e.g., EquateApprox).
+// Thus, this option is only suitable for modestly sized slices.
+// It may be combined with FilterPath or FilterValues to limit the slices
+// that it applies to.
+func EquateMultisets() Option {
+	return FilterValues(areSlices, option{op: &multiset{}, src: getCaller()})
+}
+
+// EquateSets returns an Option that compares slices and arrays as sets,
+// where the order of the elements and any duplicate elements are disregarded.
+// The values are equal if every element of x is equal to at least one element
+// of y according to all other options, and vice versa.
+// Elements of either value without an equal counterpart are reported as the
+// differences. As with other slices, a nil slice is not equal to
+// an empty slice.
+//
+// As with EquateMultisets, comparing slices of length n and m requires
+// O(n·m) element comparisons, so this option is only suitable for modestly
+// sized slices. It may be combined with FilterPath or FilterValues to limit
+// the slices that it applies to.
+func EquateSets() Option {
+	return FilterValues(areSlices, option{op: &multiset{set: true}, src: getCaller()})
+}
+
+type multiset struct {
+	set bool // Whether duplicate elements are disregarded
+}
+
+func areSlices(x, y interface{}) bool {
+	kx, ky := reflect.ValueOf(x).Kind(), reflect.ValueOf(y).Kind()
+	return (kx == reflect.Slice || kx == reflect.Array) && kx == ky
+}
+
+// CompareSharedMapKeys returns an Option that compares maps only by the
+// entries for keys that are present in both maps. Keys that are present in
+// only one of the maps are disregarded, such that a nil map is equal to
+// any other map. If the shared entries differ, then the number of
+// disregarded keys is reported alongside the differences.
+//
+// It may be combined with FilterPath or FilterValues to limit the maps
+// that it applies to.
+func CompareSharedMapKeys() Option {
+	return FilterValues(areMaps, option{op: &sharedKeys{}, src: getCaller()})
+}
+
+type sharedKeys struct{}
+
+func areMaps(x, y interface{}) bool {
+	return reflect.ValueOf(x).Kind() == reflect.Map && reflect.ValueOf(y).Kind() == reflect.Map
+}
+
+// CompareViaTextMarshaler returns an Option that compares values of a type T,
+// where T or *T implements encoding.TextMarshaler, by comparing the text
+// produced by MarshalText. The text forms are reported as the difference.
+// This is useful for types with unexported fields that are not owned by
+// the user (e.g., IP addresses or decimal numbers).
+//
+// It has lower precedence than Comparer, Transformer, and Ignore options and
+// Equal methods, but takes precedence over comparing the underlying kinds.
+// Equal panics with the current Path if MarshalText reports an error.
+func CompareViaTextMarshaler() Option {
+	return textMarshaler{}
+}
+
+type textMarshaler struct{}
+
+func (textMarshaler) option() {}
+
+// CompareFieldsByName returns an Option that allows values of two different
+// struct types to be compared by matching their exported fields by name.
+// The struct types are specified by passing in a value of each type,
+// a pointer to such a value, or the reflect.Type of each type.
+// Pointers to the struct types may also be compared with each other.
+//
+// Fields with the same name are compared according to all other options.
+// A field that only exists in one type, or whose type differs between the
+// two struct types, is reported as a difference. Unexported fields are
+// disregarded. The comparison is recorded in the Path as a Transform named
+// "λbyName". Since the fields are matched up in place rather than converted,
+// the Func of that Transform is the identity function.
+func CompareFieldsByName(x, y interface{}) Option {
+	var ts [2]reflect.Type
+	for i, typ := range []interface{}{x, y} {
+		t, ok := typ.(reflect.Type)
+		if !ok {
+			t = reflect.TypeOf(typ)
+		}
+		if t != nil && t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t == nil || t.Kind() != reflect.Struct {
+			panic(fmt.Sprintf("invalid struct type: %v", t))
+		}
+		ts[i] = t
+	}
+	return fieldsByName(ts)
+}
+
+type fieldsByName [2]reflect.Type
+
+func (fieldsByName) option() {}
+
+// EquatePointersToValues returns an Option that allows a pointer of type *T
+// to be compared against a value of type T, which otherwise are never equal
+// since their types differ. If exactly one of the values is a pointer to
+// the type of the other, then the pointer is dereferenced and the comparison
+// continues with the pointed-at value, which is recorded in the Path as
+// an Indirect step. A nil pointer is never equal to a value.
+func EquatePointersToValues() Option {
+	return pointersToValues{}
+}
+
+type pointersToValues struct{}
+
+func (pointersToValues) option() {}
+
+// EquateBytesAndStrings returns an Option that allows a byte slice to be
+// compared against a string, which otherwise are never equal since their
+// types differ. If one of the values is a string and the other is a []byte
+// (or named types with those underlying types), then they are equal if
+// their contents are identical. Both values are reported as strings.
+// Values of the same type are unaffected by this option.
+func EquateBytesAndStrings() Option {
+	return bytesAndStrings{}
+}
+
+type bytesAndStrings struct{}
+
+func (bytesAndStrings) option() {}
+
+// EquateRunesAndStrings returns an Option that allows a rune slice to be
+// compared against a string, which otherwise are never equal since their
+// types differ. If one of the values is a string and the other is a []rune
+// (or named types with those underlying types), then they are equal if the
+// runes encode to the same string. Both values are reported as strings.
+// A []rune containing an invalid code point (e.g., a surrogate half) is never
+// equal to a string, since encoding it would replace that code point with
+// utf8.RuneError, and the difference is reported with a note.
+// Values of the same type are unaffected by this option.
+func EquateRunesAndStrings() Option {
+	return runesAndStrings{}
+}
+
+type runesAndStrings struct{}
+
+func (runesAndStrings) option() {}
+
+// EquateNumbersInInterfaces returns an Option that allows numbers of
+// different kinds within interfaces (e.g., an int and a float64 within an
+// interface{}) to be compared by their exact numeric values, which otherwise
+// are never equal since their types differ. This is useful for comparing
+// decoded JSON, where all numbers are float64, against handwritten values.
+//
+// Integers are compared exactly, regardless of their signedness or size.
+// An integer is only equal to a floating-point number if that number is
+// integral and within range. NaNs are never equal.
+// Since only values within an interface may be of different types,
+// typed fields and elements (e.g., an int field) are unaffected.
+func EquateNumbersInInterfaces() Option {
+	return numbersInInterfaces{}
+}
+
+type numbersInInterfaces struct{}
+
+func (numbersInInterfaces) option() {}
+
+// EquateStructsToMaps returns an Option that allows a struct (or a pointer
+// to a struct) to be compared against a map with string keys (e.g., a
+// map[string]interface{} decoded from JSON or YAML), which otherwise are never
+// equal since their types differ. The struct is converted into a
+// map[string]interface{}, which is recorded in the Path as a Transform
+// named "λtoMap", and then compared against the other map.
+// The Func of that Transform is the identity function, since the conversion
+// is only performed as part of the comparison.
+//
+// Each exported field becomes an entry keyed by its name, or by the name
+// in its json tag if present. Fields tagged with `json:"-"` and unexported
+// fields are skipped, as are empty fields tagged with omitempty.
+// Nested structs, pointers, interfaces, slices, arrays, and maps with string
+// keys are converted recursively into maps and []interface{}.
+// Nil pointers, interfaces, slices, and maps become nil entries, unless
+// omitNil is true, in which case such fields are omitted from the map.
+//
+// This is often combined with EquateNumbersInInterfaces since the numbers
+// in decoded data usually differ in kind from those in the struct.
+func EquateStructsToMaps(omitNil bool) Option {
+	return structsToMaps{omitNil: omitNil}
+}
+
+type structsToMaps struct {
+	omitNil bool // Whether to omit nil fields
+}
+
+func (structsToMaps) option() {}
+
+// convert converts v into a value composed of map[string]interface{},
+// []interface{}, and the basic values within v.
+func (sm structsToMaps) convert(v reflect.Value) interface{} {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return nil
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return sm.convert(v.Elem())
+	case reflect.Struct:
+		m := make(map[string]interface{})
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !isExported(f.Name) {
+				continue
+			}
+			name, opts := f.Name, ""
+			if tag, ok := f.Tag.Lookup("json"); ok {
+				if tag == "-" {
+					continue
+				}
+				if i := strings.Index(tag, ","); i >= 0 {
+					tag, opts = tag[:i], tag[i:]
+				}
+				if tag != "" {
+					name = tag
+				}
+			}
+			fv := v.Field(i)
+			if strings.Contains(opts+",", ",omitempty,") && isEmptyValue(fv) {
+				continue
+			}
+			if sm.omitNil && isNilValue(fv) {
+				continue
+			}
+			m[name] = sm.convert(fv)
+		}
+		return m
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+		fallthrough
+	case reflect.Array:
+		s := make([]interface{}, v.Len())
+		for i := range s {
+			s[i] = sm.convert(v.Index(i))
+		}
+		return s
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		if v.Type().Key().Kind() != reflect.String {
+			return v.Interface()
+		}
+		m := make(map[string]interface{}, v.Len())
+		for _, k := range v.MapKeys() {
+			m[k.String()] = sm.convert(v.MapIndex(k))
+		}
+		return m
+	default:
+		return v.Interface()
+	}
+}
+
+// isEmptyValue reports whether v is empty according to the omitempty
+// option of encoding/json.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+// TransformChannelContents returns an Option that compares two channels by
+// their buffered contents rather than by identity. Each channel is drained
+// into a slice without blocking, and the slices are compared according to the
+// other options, which appears in the path as a Transform named "λdrain".
+// It only applies to distinct, non-nil, buffered, bidirectional channels.
+//
+// Whether a channel is closed cannot be determined without receiving from it,
+// so every channel that the option applies to is consumed, even if it turns
+// out not to be closed. The option must therefore be combined with FilterPath
+// or FilterValues to select the channels that are known to be closed and no
+// longer in use; it panics as an unfiltered option otherwise. All other
+// channels are compared by identity as usual and are never received from.
+// A drained channel is left empty, although it is only drained once per call
+// to Equal.
+func TransformChannelContents() Option {
+	return option{op: &channelContents{}, src: getCaller()}
+}
+
+type channelContents struct{}
+
+// TransformSyncMaps returns an Option that compares sync.Map and *sync.Map
+// values by the key-value pairs that they currently hold, which are otherwise
+// inaccessible because of unexported fields. Each map is transformed into
+// a map[interface{}]interface{} snapshot by its Range method, which is then
+// compared according to the other options and appears in the path as a
+// Transform named "λsnapshot". A nil *sync.Map is transformed into a nil map,
+// such that two nil pointers are equal. A sync.Map that is not a pointer is
+// copied before taking its snapshot.
+//
+// As with Range, the snapshot is not consistent if a map is concurrently
+// modified during the comparison.
+func TransformSyncMaps() Option {
+	fromValue := reflect.MakeFunc(reflect.FuncOf([]reflect.Type{syncMapType}, []reflect.Type{syncMapSnapshotType}, false),
+		func(args []reflect.Value) []reflect.Value {
+			p := reflect.New(syncMapType)
+			p.Elem().Set(args[0])
+			return []reflect.Value{reflect.ValueOf(snapshotSyncMap(p.Interface().(*sync.Map)))}
+		})
+	return Options{
+		Transformer("λsnapshot", snapshotSyncMap),
+		Transformer("λsnapshot", fromValue.Interface()),
+	}
+}
+
+var (
+	syncMapType         = reflect.TypeOf(sync.Map{})
+	syncMapSnapshotType = reflect.TypeOf(map[interface{}]interface{}(nil))
+)
+
+// snapshotSyncMap returns the key-value pairs held by m, which is nil if m is.
+func snapshotSyncMap(m *sync.Map) map[interface{}]interface{} {
+	if m == nil {
+		return nil
+	}
+	snap := make(map[interface{}]interface{})
+	m.Range(func(k, v interface{}) bool {
+		snap[k] = v
+		return true
+	})
+	return snap
+}
+
+// TransformLoads returns an Option that compares values of types with a Load
+// method by the values that they load, such as atomic.Value and the other
+// types of sync/atomic, whose unexported fields cannot be compared otherwise.
+// It applies to values of a type T or *T where *T has a method of the form
+// "Load() R", including wrappers of the common form "Load() interface{}".
+// The loaded values are compared according to the other options, and the
+// step appears in the path as a Transform named "λload".
+//
+// An atomic.Value with nothing stored loads nil, so it is only equal to
+// another with nothing stored. A nil pointer also loads nil.
+// A value of type T is copied before calling its Load method.
+func TransformLoads() Option {
+	return FilterPath(func(p Path) bool {
+		return hasLoadMethod(p[len(p)-1].Type())
+	}, Transformer("λload", func(x interface{}) interface{} {
+		v := reflect.ValueOf(x)
+		switch {
+		case v.Kind() != reflect.Ptr:
+			p := reflect.New(v.Type())
+			p.Elem().Set(v)
+			v = p
+		case v.IsNil():
+			return nil
+		}
+		return v.MethodByName("Load").Call(nil)[0].Interface()
+	}))
+}
+
+// hasLoadMethod reports whether t is a pointer type with a Load method,
+// or a type whose pointer type has one, as supported by TransformLoads.
+func hasLoadMethod(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Interface:
+		return false // Load the dynamic value instead
+	case reflect.Ptr:
+	default:
+		t = reflect.PtrTo(t)
+	}
+	m, ok := t.MethodByName("Load")
+	return ok && m.Type.NumIn() == 1 && m.Type.NumOut() == 1 && !m.Type.IsVariadic()
+}
+
+// TransformReaders returns an Option that compares readers by their unread
+// contents, which are reported as a []byte in a Transform named "λread".
+// It only applies when both readers are a *bytes.Buffer or implement
+// io.ReadSeeker, such as *bytes.Reader and *strings.Reader, in which case
+// readers of different types may be equal.
+// A *bytes.Buffer is inspected without reading from it, while a seeker is
+// read to the end and then restored to its previous offset.
+// Other readers cannot be rewound and are left to the other options,
+// so that comparing them never consumes a stream.
+//
+// A nil reader has no contents and is only equal to another nil reader.
+func TransformReaders() Option {
+	return FilterValues(func(x, y io.Reader) bool {
+		return isRewindable(x) && isRewindable(y)
+	}, Transformer("λread", readContents))
+}
+
+// isRewindable reports whether r can be read by readContents
+// without losing its contents.
+func isRewindable(r io.Reader) bool {
+	switch r.(type) {
+	case *bytes.Buffer, io.ReadSeeker:
+		return true
+	default:
+		return false
+	}
+}
+
+func readContents(r io.Reader) []byte {
+	if v := reflect.ValueOf(r); v.Kind() == reflect.Ptr && v.IsNil() {
+		return nil
+	}
+	if b, ok := r.(*bytes.Buffer); ok {
+		return append([]byte{}, b.Bytes()...)
+	}
+	rs := r.(io.ReadSeeker)
+	pos, err := rs.Seek(0, io.SeekCurrent)
+	if err == nil {
+		defer rs.Seek(pos, io.SeekStart)
+	}
+	b, err := ioutil.ReadAll(rs)
+	if err != nil {
+		panic(fmt.Sprintf("cannot read %T: %v", r, err))
+	}
+	return append([]byte{}, b...)
+}
+
+// NormalizeLineEndings returns an Option that compares strings and byte
+// slices with every "\r\n" and lone "\r" replaced by "\n", such that text
+// produced on Windows is equal to the same text produced elsewhere.
+// The normalization is recorded in the Path as a Transform named "λeol".
+//
+// It only applies when either value contains a "\r". A []byte is only
+// normalized if both values look like text, being valid UTF-8 without
+// control characters other than tabs and line endings, so that binary
+// data is compared exactly. Use FilterPath to further limit its scope.
+func NormalizeLineEndings() Option {
+	return Options{
+		FilterValues(func(x, y string) bool {
+			return strings.ContainsRune(x, '\r') || strings.ContainsRune(y, '\r')
+		}, Transformer("λeol", func(s string) string {
+			return string(normalizeEOL([]byte(s)))
+		})),
+		FilterValues(func(x, y []byte) bool {
+			return (bytes.IndexByte(x, '\r') >= 0 || bytes.IndexByte(y, '\r') >= 0) &&
+				isText(x) && isText(y)
+		}, Transformer("λeol", normalizeEOL)),
+	}
+}
+
+// normalizeEOL returns a copy of b with every "\r\n" and "\r" replaced by "\n".
+func normalizeEOL(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	n := make([]byte, 0, len(b))
+	for i := 0; i < len(b); i++ {
+		if b[i] == '\r' {
+			if i+1 < len(b) && b[i+1] == '\n' {
+				i++
+			}
+			n = append(n, '\n')
+			continue
+		}
+		n = append(n, b[i])
+	}
+	return n
+}
+
+// isText reports whether b is valid UTF-8 without control characters other
+// than tabs and line endings.
+func isText(b []byte) bool {
+	if !utf8.Valid(b) {
+		return false
+	}
+	for _, c := range b {
+		if (c < 0x20 && c != '\t' && c != '\n' && c != '\r') || c == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// CollapseWhitespace returns an Option that compares strings with leading and
+// trailing whitespace removed and every interior run of whitespace replaced
+// by a single space, such that text differing only in its indentation or
+// wrapping (e.g., generated SQL) is equal. The collapsed strings are recorded
+// in the Path as a Transform named "λspace" and are shown in the report.
+//
+// It only applies when either string is not already collapsed.
+// Use FilterPath to limit it to particular fields.
+func CollapseWhitespace() Option {
+	return FilterValues(func(x, y string) bool {
+		return x != collapseSpace(x) || y != collapseSpace(y)
+	}, Transformer("λspace", collapseSpace))
+}
+
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// Shallow returns an Option that makes Equal perform a shallow comparison,
+// which descends into the root value as usual, but compares any pointer,
+// slice, or map reached through a struct field, a slice or array element, or
+// a map entry by identity rather than by the values it references.
+// Pointers and maps are equal only if they are both nil or point to the same
+// object, and slices are equal only if they have the same underlying array,
+// length, and capacity. Other values below the root (e.g., strings, numbers,
+// and nested struct values) are compared as usual.
+//
+// This is useful for verifying that a function returns the same underlying
+// buffers rather than copies. Comparer, Transformer, and Ignore options still
+// take precedence over the identity comparison.
+func Shallow() Option {
+	return shallowMode{}
+}
+
+type shallowMode struct{}
+
+func (shallowMode) option() {}
+
+// ShortCircuit returns an Option that makes Equal stop traversing the values
+// as soon as they are known to be unequal, rather than visiting every node
+// of the value tree. This is useful for comparing large values in hot paths,
+// where only the result matters. Diff is unaffected by this option since it
+// must find all differences in order to report them.
+//
+// Diagnostic options (e.g., CountComparisons and ReportAliases) only observe
+// the portion of the value tree traversed before Equal stopped.
+// RequireComparisons does not panic if Equal stopped early, since a difference
+// was found. The Comparer or Equal method that found the difference is always
+// verified for symmetry and determinism unless DisableFunctionChecks is used.
+func ShortCircuit() Option {
+	return shortCircuit{}
+}
+
+type shortCircuit struct{}
+
+func (shortCircuit) option() {}
+
+// Parallel returns an Option that allows Equal to compare the elements of
+// large slices, arrays, and maps concurrently using up to the given number
+// of goroutines. Only the outermost such values in the value tree are
+// divided among the goroutines, and differences are reported in the same
+// order as they would be without this option.
+// It panics if workers is less than one.
+//
+// When this option is used, all user provided functions (e.g., Comparers,
+// Transformers, FilterValues, FilterPath, Equal methods, and the callbacks
+// passed to AuditIgnores and ReportAliases) must be safe for concurrent use.
+// This option may not be combined with a reporter that is not safe for
+// concurrent use.
+func Parallel(workers int) Option {
+	if workers < 1 {
+		panic(fmt.Sprintf("invalid number of workers: %d", workers))
+	}
+	return parallelWorkers(workers)
+}
+
+type parallelWorkers int
+
+func (parallelWorkers) option() {}
+
+// MaxReportSize returns an Option that limits the report produced by Diff
+// to at most n bytes. Once the next difference would not fit, Diff stops
+// comparing the values and ends the report with a notice that it was
+// truncated, which states the number of differences reported.
+// Formatted values are elided as needed so that no single enormous value can
+// exceed the limit. By default, a report is not limited to a fixed size,
+// although only the first few kilobytes of differences are formatted.
+// It panics if n is less than 256. This option has no effect on Equal.
+func MaxReportSize(n int) Option {
+	if n < minReportLimit {
+		panic(fmt.Sprintf("invalid maximum report size: %d", n))
+	}
+	return reportLimit(n)
+}
+
+type reportLimit int
+
+func (reportLimit) option() {}
+
+// AllowUnexported returns an Option that forcibly allows operations on
+// unexported fields in certain structs, which are specified by passing in a
+// value of each struct type or the reflect.Type of each struct type.
+// A single level of pointer indirection is automatically removed, such that
+// passing in a *T is equivalent to passing in a T.
+// It panics if any argument is not a struct type.
+//
+// Users of this option must understand that comparing on unexported fields
+// from external packages is not safe since changes in the internal
+// implementation of some external package may cause the result of Equal
+// to unexpectedly change. However, it may be valid to use this option on types
+// defined in an internal package where the semantic meaning of an unexported
+// field is in the control of the user.
+//
+// For most cases, a custom Comparer should be used instead that defines
+// equality as a function of the public API of a type rather than the underlying
+// unexported implementation.
+//
+// For example, the reflect.Type documentation defines equality to be determined
+// by the == operator on the interface (essentially performing a shallow pointer
+// comparison) and most attempts to compare *regexp.Regexp types are interested
+// in only checking that the regular expression strings are equal.
+// Both of these are accomplished using Comparers:
+//	Comparer(func(x, y reflect.Type) bool { return x == y })
+//	Comparer(func(x, y *regexp.Regexp) bool { return x.String() == y.String() })
+//
+// Values of unexported fields are shallow copies of the originals, such that
+// Comparers, Transformers, and methods with pointer receivers called on them
+// cannot write through to the structs being compared.
+// Use AllowUnexportedInPlace if the original fields must be operated on.
+//
+// On platforms where package unsafe is unavailable (e.g., appengine and js),
+// values of unexported fields are instead rebuilt using only what the reflect
+// package permits reading. Values that cannot be rebuilt, such as structs with
+// unexported fields of their own, may still be compared structurally,
+// but Equal panics if such a value would be passed to a user function.
+//
+// NOTE: This feature is experimental and may be removed!
+func AllowUnexported(types ...interface{}) Option {
+	m := make(map[reflect.Type]bool)
+	for _, typ := range types {
+		t, ok := typ.(reflect.Type)
+		if !ok {
+			t = reflect.TypeOf(typ)
+		}
+		if t != nil && t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t == nil || t.Kind() != reflect.Struct {
+			panic(fmt.Sprintf("invalid struct type: %v", t))
+		}
+		m[t] = true // Repeated types are naturally deduplicated
+	}
+	return visibleStructs(m)
+}
+
+type visibleStructs map[reflect.Type]bool
+
+func (visibleStructs) option() {}
+
+func (vs visibleStructs) String() string {
+	return fmt.Sprintf("AllowUnexported(%s)", typeNames(vs))
+}
+
+// typeNames formats the types in m as a sorted, comma-separated list.
+func typeNames(m map[reflect.Type]bool) string {
+	var ss []string
+	for t := range m {
+		ss = append(ss, t.String())
+	}
+	sort.Strings(ss)
+	return strings.Join(ss, ", ")
+}
+
+// AllowUnexportedInPlace is like AllowUnexported, except that values of
+// unexported fields in the specified structs are not copied, but refer
+// directly to the fields within the structs being compared.
+// This is only needed when the address of an unexported field is significant
+// (e.g., a method with a pointer receiver that depends on its own identity).
+// Any modifications made through such values are visible to the caller.
+//
+// NOTE: This feature is experimental and may be removed!
+func AllowUnexportedInPlace(types ...interface{}) Option {
+	return inPlaceStructs(AllowUnexported(types...).(visibleStructs))
+}
+
+type inPlaceStructs map[reflect.Type]bool
+
+func (inPlaceStructs) option() {}
+
+func (vs inPlaceStructs) String() string {
+	return fmt.Sprintf("AllowUnexportedInPlace(%s)", typeNames(vs))
+}
+
+// ReportComparerDetails returns an Option that augments the output of Diff
+// whenever a Comparer reports that two composite values (structs, slices,
+// arrays, maps, or pointers and interfaces to such) are unequal.
+// In addition to the summary produced for the Comparer, the two values are
+// compared again with that Comparer suppressed (all other options still apply)
+// and the resulting differences are reported beneath the summary as a
+// supplemental report.
+//
+// If the supplemental comparison cannot be performed because the Comparer
+// was the only thing guarding against unexported fields, then only the
+// summary is reported. This option has no effect on Equal.
+func ReportComparerDetails() Option {
+	return comparerDetails{}
+}
+
+type comparerDetails struct{}
+
+func (comparerDetails) option() {}
+
+// EquateNilInterfaces returns an Option that treats an interface holding
+// a nil pointer, map, slice, channel, or function (i.e., a typed nil)
+// as being equal to a nil interface. Consequently, two interfaces holding
+// typed nils are equal even if the dynamic types of the nils differ.
+//
+// Without this option, a typed nil and a nil interface are unequal,
+// as they are with the == operator.
+func EquateNilInterfaces() Option {
+	return nilInterfaces{}
+}
+
+type nilInterfaces struct{}
+
+func (nilInterfaces) option() {}
+
+// ReportAliases returns an Option that calls f whenever Equal encounters
+// a pointer, map, or slice in x that references the same memory as its
+// counterpart in y. Such a subtree is trivially equal to itself,
+// which usually indicates that a test is accidentally comparing a value
+// against itself. The function is provided the current Path and the shared
+// address, but is not called again for aliases nested within the subtree.
+//
+// This option is purely diagnostic and does not affect the result of Equal.
+func ReportAliases(f func(p Path, addr uintptr)) Option {
+	if f == nil {
+		panic("invalid alias reporter function")
+	}
+	return aliasReporter(f)
+}
+
+type aliasReporter func(Path, uintptr)
+
+func (aliasReporter) option() {}
+
+// RequireComparisons returns an Option that causes Equal to panic if fewer
+// than n values were actually compared. A value counts as compared when it is
+// evaluated by a Comparer, an Equal method, or the default rules for a leaf
+// value (e.g., numbers, strings, and nil pointers), as opposed to being
+// ignored or merely traversed. This catches vacuous tests, where the set of
+// options ignores everything such that Equal reports true for any inputs.
+// It panics if n is less than one.
+func RequireComparisons(n int) Option {
+	if n < 1 {
+		panic(fmt.Sprintf("invalid minimum comparisons: %d", n))
+	}
+	return minComparisons(n)
+}
+
+type minComparisons int
+
+func (minComparisons) option() {}
+
+// CountComparisons returns an Option that calls f with the number of values
+// that were actually compared once Equal completes, according to the same
+// counting rules as RequireComparisons.
+//
+// This option is purely diagnostic and does not affect the result of Equal.
+func CountComparisons(f func(n int)) Option {
+	if f == nil {
+		panic("invalid comparison counter function")
+	}
+	return comparisonCounter(f)
+}
+
+type comparisonCounter func(int)
+
+func (comparisonCounter) option() {}
+
+// MaxDepth returns an Option that limits how deep Equal descends into
+// the value tree. The depth of a node is the number of steps in its Path
+// after the root, where every step (including pointer indirections,
+// type assertions, and transformations) counts as one.
+// Equal panics with the current Path if the limit is exceeded.
+//
+// By default, the depth is limited to a generous value that should only be
+// exceeded by extremely deep values (e.g., long linked lists), which would
+// otherwise exhaust the goroutine stack. In such situations, either raise
+// the limit or use a Transformer to restructure the comparison
+// (e.g., by converting the list into a slice).
+func MaxDepth(n int) Option {
+	if n <= 0 {
+		panic(fmt.Sprintf("invalid maximum depth: %d", n))
+	}
+	return maxDepth(n)
+}
+
+type maxDepth int
+
+func (maxDepth) option() {}
+
+// DisableFunctionChecks returns an Option that disables the extra calls made
+// to user provided functions (i.e., Comparers and FilterValues) to verify that
+// they are symmetric and deterministic. This avoids the overhead of the checks
+// for functions that are already trusted to behave correctly.
+func DisableFunctionChecks() Option {
+	return functionChecks{mode: checkNever}
+}
+
+// AlwaysCheckFunctions returns an Option that verifies every call made to
+// a user provided function for symmetry and determinism. By default, only
+// a decreasing fraction of calls are verified.
+func AlwaysCheckFunctions() Option {
+	return functionChecks{mode: checkAlways}
+}
+
+// RandomFunctionChecks returns an Option that chooses which calls to
+// user provided functions are verified for symmetry and determinism using
+// a pseudo-random sequence derived from seed. The checks occur about as often
+// as they do by default, but a failure observed with a particular seed
+// is reproducible by using that same seed again.
+func RandomFunctionChecks(seed int64) Option {
+	return functionChecks{mode: checkRandom, seed: seed}
+}
+
+// SampleFunctionChecks returns an Option that sets the number of initial
+// calls to each user provided function that are verified for symmetry and
+// determinism. After the first n calls, calls are verified with exponentially
+// decreasing frequency (i.e., the 2n-th, 4n-th, 8n-th call, and so on).
+// Larger values catch misbehaving functions more reliably at the cost of
+// additional calls. By default, n is 32. It panics if n is less than one.
+//
+// This option has no effect if DisableFunctionChecks or AlwaysCheckFunctions
+// is used. With RandomFunctionChecks, the calls after the first n are
+// verified pseudo-randomly instead.
+func SampleFunctionChecks(n int) Option {
+	if n < 1 {
+		panic(fmt.Sprintf("invalid number of sampled calls: %d", n))
+	}
+	return checkSamples(n)
+}
+
+type checkSamples int
+
+func (checkSamples) option() {}
+
+type checkMode int
+
+const (
+	checkDefault checkMode = iota
+	checkNever
+	checkAlways
+	checkRandom
+)
+
+type functionChecks struct {
+	mode checkMode
+	seed int64
+}
+
+func (functionChecks) option() {}
+
+// reporter is an Option that configures how differences are reported.
+//
+// TODO: Not exported yet, see concerns in defaultReporter.Report.
+type reporter interface {
+	Option
 
 	// Report is called for every comparison made and will be provided with
 	// the two values being compared, the equality result, and the
@@ -294,3 +2279,92 @@ type reporter interface {
 	// a PathStep instead of the full-path? This change allows us to provide
 	// better output closer to what pretty.Compare is able to achieve.
 }
+
+// concurrentReporter is a reporter that may be used with Parallel.
+// Each goroutine reports to its own reporter obtained from fork,
+// and the differences recorded by each forked reporter are then appended
+// by join in the order that they would have been reported sequentially.
+type concurrentReporter interface {
+	reporter
+	fork() reporter
+	join(reporter)
+}
+
+// limitedReporter is a reporter that may stop accepting differences,
+// such as when its report has reached a maximum size. Once isFull reports
+// true, the comparison stops as soon as possible.
+type limitedReporter interface {
+	reporter
+	isFull() bool
+}
+
+// Rule is a declarative description of an Option, which allows comparison
+// rules to be maintained as configuration (e.g., decoded from JSON)
+// rather than as code. See OptionsFromRules.
+type Rule struct {
+	// Path is an optional path expression (see Within) that restricts the
+	// rule to the described node and its descendants.
+	// The wildcard index "[*]" matches any slice or map index.
+	Path string `json:"path,omitempty"`
+
+	// Type is an optional type name (e.g., "float64" or "time.Time") that
+	// restricts the rule to values of that type, as reported by
+	// reflect.Type.String.
+	Type string `json:"type,omitempty"`
+
+	// Rule is the kind of rule, which is one of:
+	//	"ignore": ignore the values (see Ignore)
+	//	"approx": approximate equality of floats (see EquateApprox)
+	Rule string `json:"rule"`
+
+	// Fraction and Margin are the tolerances for an "approx" rule.
+	Fraction float64 `json:"fraction,omitempty"`
+	Margin   float64 `json:"margin,omitempty"`
+}
+
+// OptionsFromRules builds the options described by the rules.
+// It reports an error if any rule is unknown or malformed.
+func OptionsFromRules(rules []Rule) (Options, error) {
+	var opts Options
+	for i, r := range rules {
+		opt, err := r.option()
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %v", i, err)
+		}
+		opts = append(opts, opt)
+	}
+	return opts, nil
+}
+
+func (r Rule) option() (opt Option, err error) {
+	var pe pathExpr
+	if r.Path != "" {
+		if pe, err = parsePathExpr(r.Path); err != nil {
+			return nil, fmt.Errorf("invalid path expression %q: %v", r.Path, err)
+		}
+	}
+	switch r.Rule {
+	case "ignore":
+		if r.Path == "" && r.Type == "" {
+			return nil, fmt.Errorf("ignore rule requires a path or type")
+		}
+		opt = Ignore()
+	case "approx":
+		if r.Fraction < 0 || r.Margin < 0 || math.IsNaN(r.Fraction) || math.IsNaN(r.Margin) {
+			return nil, fmt.Errorf("invalid approx tolerances: fraction=%v, margin=%v", r.Fraction, r.Margin)
+		}
+		opt = EquateApprox(r.Fraction, r.Margin)
+	default:
+		return nil, fmt.Errorf("unknown rule %q", r.Rule)
+	}
+	return FilterPath(func(p Path) bool {
+		if r.Type != "" && p[len(p)-1].Type().String() != r.Type {
+			return false
+		}
+		if pe != nil {
+			_, within := pe.matchPath(p)
+			return within
+		}
+		return true
+	}, opt), nil
+}
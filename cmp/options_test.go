@@ -6,6 +6,7 @@ package cmp
 
 import (
 	"io"
+	"math"
 	"reflect"
 	"strings"
 	"testing"
@@ -13,6 +14,15 @@ import (
 	ts "github.com/google/go-cmp/cmp/internal/teststructs"
 )
 
+func TestAllowUnexportedDedup(t *testing.T) {
+	opt := AllowUnexported(ts.StructA{}, &ts.StructA{}, reflect.TypeOf(ts.StructA{}), ts.StructB{})
+	got := opt.(visibleStructs)
+	want := visibleStructs{reflect.TypeOf(ts.StructA{}): true, reflect.TypeOf(ts.StructB{}): true}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("AllowUnexported() = %v, want %v", got, want)
+	}
+}
+
 // Test that the creation of Option values with non-sensible inputs produces
 // a run-time panic with a decent error message
 func TestOptionPanic(t *testing.T) {
@@ -39,11 +49,61 @@ func TestOptionPanic(t *testing.T) {
 		label: "AllowUnexported",
 		fnc:   AllowUnexported,
 		args:  []interface{}{ts.StructA{}, ts.StructB{}, ts.StructA{}},
+	}, {
+		label: "AllowUnexported",
+		fnc:   AllowUnexported,
+		args:  []interface{}{ts.StructA{}, reflect.TypeOf(ts.StructB{})},
 	}, {
 		label:     "AllowUnexported",
 		fnc:       AllowUnexported,
-		args:      []interface{}{ts.StructA{}, &ts.StructB{}, ts.StructA{}},
-		wantPanic: "invalid struct type",
+		args:      []interface{}{ts.StructA{}, reflect.TypeOf(0)},
+		wantPanic: "invalid struct type: int",
+	}, {
+		label: "AllowUnexported",
+		fnc:   AllowUnexported,
+		args:  []interface{}{ts.StructA{}, &ts.StructB{}, ts.StructA{}},
+	}, {
+		label: "AllowUnexported",
+		fnc:   AllowUnexported,
+		args:  []interface{}{reflect.TypeOf(&ts.StructB{})},
+	}, {
+		label:     "AllowUnexported",
+		fnc:       AllowUnexported,
+		args:      []interface{}{new(*ts.StructB)},
+		wantPanic: "invalid struct type: *teststructs.StructB",
+	}, {
+		label:     "AllowUnexported",
+		fnc:       AllowUnexported,
+		args:      []interface{}{map[string]ts.StructA{}},
+		wantPanic: "invalid struct type: map[string]teststructs.StructA",
+	}, {
+		label:     "ReportAliases",
+		fnc:       ReportAliases,
+		args:      []interface{}{(func(Path, uintptr))(nil)},
+		wantPanic: "invalid alias reporter function",
+	}, {
+		label:     "RequireComparisons",
+		fnc:       RequireComparisons,
+		args:      []interface{}{0},
+		wantPanic: "invalid minimum comparisons: 0",
+	}, {
+		label: "RequireComparisons",
+		fnc:   RequireComparisons,
+		args:  []interface{}{1},
+	}, {
+		label:     "CountComparisons",
+		fnc:       CountComparisons,
+		args:      []interface{}{(func(int))(nil)},
+		wantPanic: "invalid comparison counter function",
+	}, {
+		label:     "MaxDepth",
+		fnc:       MaxDepth,
+		args:      []interface{}{0},
+		wantPanic: "invalid maximum depth",
+	}, {
+		label: "MaxDepth",
+		fnc:   MaxDepth,
+		args:  []interface{}{1},
 	}, {
 		label:     "Comparer",
 		fnc:       Comparer,
@@ -94,6 +154,25 @@ func TestOptionPanic(t *testing.T) {
 		fnc:       Transformer,
 		args:      []interface{}{"", func(int, int) bool { return true }},
 		wantPanic: "invalid transformer function",
+	}, {
+		label: "Transformer",
+		fnc:   Transformer,
+		args:  []interface{}{"", func(int) (int, error) { return 0, nil }},
+	}, {
+		label:     "Transformer",
+		fnc:       Transformer,
+		args:      []interface{}{"", func(int) (int, bool) { return 0, true }},
+		wantPanic: "invalid transformer function",
+	}, {
+		label:     "Transformer",
+		fnc:       Transformer,
+		args:      []interface{}{"", func(int) (int, error, bool) { return 0, nil, true }},
+		wantPanic: "invalid transformer function",
+	}, {
+		label:     "Transformer",
+		fnc:       Transformer,
+		args:      []interface{}{"", func(int) (error, int) { return nil, 0 }},
+		wantPanic: "invalid transformer function",
 	}, {
 		label:     "Transformer",
 		fnc:       Transformer,
@@ -117,6 +196,44 @@ func TestOptionPanic(t *testing.T) {
 		fnc:       Transformer,
 		args:      []interface{}{"_", func(int) bool { return true }},
 		wantPanic: "invalid name",
+	}, {
+		label: "Transformer",
+		fnc:   Transformer,
+		args:  []interface{}{"mypkg.Sort", func(int) bool { return true }},
+	}, {
+		label:     "Transformer",
+		fnc:       Transformer,
+		args:      []interface{}{"mypkg._", func(int) bool { return true }},
+		wantPanic: "invalid name",
+	}, {
+		label:     "Transformer",
+		fnc:       Transformer,
+		args:      []interface{}{"mypkg..Sort", func(int) bool { return true }},
+		wantPanic: "invalid name",
+	}, {
+		label:     "Transformer",
+		fnc:       Transformer,
+		args:      []interface{}{".Sort", func(int) bool { return true }},
+		wantPanic: "invalid name",
+	}, {
+		label:     "Transformer",
+		fnc:       Transformer,
+		args:      []interface{}{"mypkg.1Sort", func(int) bool { return true }},
+		wantPanic: "invalid name",
+	}, {
+		label: "NamedComparer",
+		fnc:   NamedComparer,
+		args:  []interface{}{"mypkg.Approx", func(x, y int) bool { return true }},
+	}, {
+		label:     "NamedComparer",
+		fnc:       NamedComparer,
+		args:      []interface{}{"", func(x, y int) bool { return true }},
+		wantPanic: "invalid name",
+	}, {
+		label:     "NamedComparer",
+		fnc:       NamedComparer,
+		args:      []interface{}{"Approx", func(x int) bool { return true }},
+		wantPanic: "invalid comparer function",
 	}, {
 		label:     "FilterPath",
 		fnc:       FilterPath,
@@ -140,6 +257,138 @@ func TestOptionPanic(t *testing.T) {
 		fnc:       FilterPath,
 		args:      []interface{}{func(Path) bool { return true }, Options{Ignore(), &defaultReporter{}}},
 		wantPanic: "unknown option type",
+	}, {
+		label: "Prioritized",
+		fnc:   Prioritized,
+		args:  []interface{}{1, Options{Ignore(), Ignore()}},
+	}, {
+		label:     "Prioritized",
+		fnc:       Prioritized,
+		args:      []interface{}{1, Options{Ignore(), &defaultReporter{}}},
+		wantPanic: "unknown option type",
+	}, {
+		label: "Within",
+		fnc:   Within,
+		args:  []interface{}{"A.B[0][\"key\"][5]", ".C"},
+	}, {
+		label:     "Within",
+		fnc:       Within,
+		args:      []interface{}{"A[0"},
+		wantPanic: "missing closing bracket",
+	}, {
+		label:     "Within",
+		fnc:       Within,
+		args:      []interface{}{"A..B"},
+		wantPanic: "invalid field name",
+	}, {
+		label:     "Within",
+		fnc:       Within,
+		args:      []interface{}{"[\"key]"},
+		wantPanic: "invalid quoted index",
+	}, {
+		label: "EquateApprox",
+		fnc:   EquateApprox,
+		args:  []interface{}{0.1, 0.0},
+	}, {
+		label:     "EquateApprox",
+		fnc:       EquateApprox,
+		args:      []interface{}{-0.1, 0.0},
+		wantPanic: "margin or fraction must be a non-negative number",
+	}, {
+		label:     "EquateApprox",
+		fnc:       EquateApprox,
+		args:      []interface{}{0.0, math.NaN()},
+		wantPanic: "margin or fraction must be a non-negative number",
+	}, {
+		label: "EquateApproxComplex",
+		fnc:   EquateApproxComplex,
+		args:  []interface{}{0.0, 0.5},
+	}, {
+		label:     "EquateApproxComplex",
+		fnc:       EquateApproxComplex,
+		args:      []interface{}{math.NaN(), 0.0},
+		wantPanic: "margin or fraction must be a non-negative number",
+	}, {
+		label: "ComparePointersByIdentity",
+		fnc:   ComparePointersByIdentity,
+		args:  []interface{}{(*int)(nil), reflect.TypeOf(new(string))},
+	}, {
+		label:     "ComparePointersByIdentity",
+		fnc:       ComparePointersByIdentity,
+		args:      []interface{}{0},
+		wantPanic: "invalid pointer type: int",
+	}, {
+		label: "CompareByString",
+		fnc:   CompareByString,
+	}, {
+		label:     "CompareByString",
+		fnc:       CompareByString,
+		args:      []interface{}{0},
+		wantPanic: "type does not implement fmt.Stringer: int",
+	}, {
+		label:     "CompareFieldsByName",
+		fnc:       CompareFieldsByName,
+		args:      []interface{}{struct{}{}, 0},
+		wantPanic: "invalid struct type: int",
+	}, {
+		label:     "AccessVia",
+		fnc:       AccessVia,
+		args:      []interface{}{reflect.TypeOf(new(error)).Elem(), "Error"},
+		wantPanic: "invalid accessor method: error.Error",
+	}, {
+		label:     "AccessVia",
+		fnc:       AccessVia,
+		args:      []interface{}{reflect.Value{}, "Method"},
+		wantPanic: "invalid accessor method: reflect.Value.Method",
+	}, {
+		label:     "AccessVia",
+		fnc:       AccessVia,
+		args:      []interface{}{0, "String"},
+		wantPanic: "invalid accessor method: int.String",
+	}, {
+		label: "AllowUnexportedInPlace",
+		fnc:   AllowUnexportedInPlace,
+		args:  []interface{}{ts.StructA{}},
+	}, {
+		label:     "AllowUnexportedInPlace",
+		fnc:       AllowUnexportedInPlace,
+		args:      []interface{}{0},
+		wantPanic: "invalid struct type",
+	}, {
+		label: "Parallel",
+		fnc:   Parallel,
+		args:  []interface{}{4},
+	}, {
+		label:     "Parallel",
+		fnc:       Parallel,
+		args:      []interface{}{0},
+		wantPanic: "invalid number of workers: 0",
+	}, {
+		label: "SampleFunctionChecks",
+		fnc:   SampleFunctionChecks,
+		args:  []interface{}{1},
+	}, {
+		label:     "SampleFunctionChecks",
+		fnc:       SampleFunctionChecks,
+		args:      []interface{}{0},
+		wantPanic: "invalid number of sampled calls: 0",
+	}, {
+		label: "MaxReportSize",
+		fnc:   MaxReportSize,
+		args:  []interface{}{256},
+	}, {
+		label:     "MaxReportSize",
+		fnc:       MaxReportSize,
+		args:      []interface{}{255},
+		wantPanic: "invalid maximum report size: 255",
+	}, {
+		label: "EquateBig",
+		fnc:   EquateBig,
+	}, {
+		label:     "EquateApproxBigFloat",
+		fnc:       EquateApproxBigFloat,
+		args:      []interface{}{0.0, -1.0},
+		wantPanic: "margin or fraction must be a non-negative number",
 	}, {
 		label:     "FilterValues",
 		fnc:       FilterValues,
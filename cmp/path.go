@@ -5,8 +5,10 @@
 package cmp
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
 	"unicode"
 	"unicode/utf8"
@@ -45,6 +47,13 @@ type (
 		Key() reflect.Value
 		isMapIndex()
 	}
+	// MapKey represents the key of a map entry, which is compared with the
+	// keys of the other map if some option applies to map keys
+	// (see FilterMapKeys).
+	MapKey interface {
+		PathStep
+		isMapKey()
+	}
 	// TypeAssertion represents a type assertion on an interface.
 	TypeAssertion interface {
 		PathStep
@@ -79,6 +88,40 @@ func (pa *Path) pop() {
 	*pa = (*pa)[:len(*pa)-1]
 }
 
+// clone returns a copy of the path with copies of all steps that Equal may
+// reuse for other nodes, such that the result may be retained.
+func (pa Path) clone() Path {
+	pc := make(Path, len(pa))
+	for i, ps := range pa {
+		switch ps := ps.(type) {
+		case *sliceIndex:
+			c := *ps
+			pc[i] = &c
+		case *mapIndex:
+			c := *ps
+			pc[i] = &c
+		case *mapKey:
+			c := *ps
+			pc[i] = &c
+		case *typeAssertion:
+			c := *ps
+			pc[i] = &c
+		case *structField:
+			c := *ps
+			pc[i] = &c
+		case *indirect:
+			c := *ps
+			pc[i] = &c
+		case *pathStep:
+			c := *ps
+			pc[i] = &c
+		default:
+			pc[i] = ps
+		}
+	}
+	return pc
+}
+
 // String returns the simplified path to a node.
 // The simplified path only contains struct field accesses.
 //
@@ -160,6 +203,9 @@ type (
 		pathStep
 		key reflect.Value
 	}
+	mapKey struct {
+		pathStep
+	}
 	typeAssertion struct {
 		pathStep
 	}
@@ -168,6 +214,11 @@ type (
 		name string
 		idx  int
 
+		// parent is the struct type containing the field if the step was
+		// recorded lazily, in which case name and typ are unset and are
+		// only looked up if needed.
+		parent reflect.Type
+
 		// These fields are used for forcibly accessing an unexported field.
 		// pvx, pvy, and field are only valid if unexported is true.
 		unexported bool
@@ -195,21 +246,37 @@ func (ps pathStep) String() string {
 
 func (si sliceIndex) String() string    { return fmt.Sprintf("[%d]", si.key) }
 func (mi mapIndex) String() string      { return fmt.Sprintf("[%#v]", mi.key) }
+func (mk mapKey) String() string        { return "[key]" }
 func (ta typeAssertion) String() string { return fmt.Sprintf(".(%v)", ta.typ) }
-func (sf structField) String() string   { return fmt.Sprintf(".%s", sf.name) }
+func (sf structField) String() string   { return fmt.Sprintf(".%s", sf.Name()) }
 func (in indirect) String() string      { return "*" }
 func (tf transform) String() string     { return fmt.Sprintf("%s()", tf.trans.name) }
 
 func (si sliceIndex) Key() int           { return si.key }
 func (mi mapIndex) Key() reflect.Value   { return mi.key }
-func (sf structField) Name() string      { return sf.name }
 func (sf structField) Index() int        { return sf.idx }
 func (tf transform) Name() string        { return tf.trans.name }
 func (tf transform) Func() reflect.Value { return tf.trans.fnc }
 
+// Name and Type look up the field in its parent struct type
+// if the step was recorded lazily.
+func (sf structField) Name() string {
+	if sf.parent != nil {
+		return sf.parent.Field(sf.idx).Name
+	}
+	return sf.name
+}
+func (sf structField) Type() reflect.Type {
+	if sf.parent != nil {
+		return sf.parent.Field(sf.idx).Type
+	}
+	return sf.typ
+}
+
 func (pathStep) isPathStep()           {}
 func (sliceIndex) isSliceIndex()       {}
 func (mapIndex) isMapIndex()           {}
+func (mapKey) isMapKey()               {}
 func (typeAssertion) isTypeAssertion() {}
 func (structField) isStructField()     {}
 func (indirect) isIndirect()           {}
@@ -218,6 +285,7 @@ func (transform) isTransform()         {}
 var (
 	_ SliceIndex    = sliceIndex{}
 	_ MapIndex      = mapIndex{}
+	_ MapKey        = mapKey{}
 	_ TypeAssertion = typeAssertion{}
 	_ StructField   = structField{}
 	_ Indirect      = indirect{}
@@ -225,6 +293,7 @@ var (
 
 	_ PathStep = sliceIndex{}
 	_ PathStep = mapIndex{}
+	_ PathStep = mapKey{}
 	_ PathStep = typeAssertion{}
 	_ PathStep = structField{}
 	_ PathStep = indirect{}
@@ -247,3 +316,187 @@ func isValid(id string) bool {
 	}
 	return ok
 }
+
+// isQualified reports whether name is an identifier, optionally qualified
+// by other identifiers (e.g., "Sort" or "mypkg.Sort").
+func isQualified(name string) bool {
+	for _, id := range strings.Split(name, ".") {
+		if !isValid(id) {
+			return false
+		}
+	}
+	return true
+}
+
+// pathExpr is a parsed path expression, which is a sequence of struct field
+// accesses (e.g., ".Field"), slice indexes (e.g., "[2]"), and map indexes
+// (e.g., `["key"]` or "[5]"). The wildcard index "[*]" matches any
+// slice or map index. For example:
+//	Slaps[0].Immutable.LoveRadius
+//	Slaps[*].Immutable.Started
+//
+// Pointer indirections and type assertions are implicit in a path expression.
+type pathExpr []pathElem
+
+type pathElem struct {
+	name string // Name of a struct field; empty for an index
+	key  string // Unquoted index for a slice or map; only valid if name is empty
+}
+
+func (e pathElem) String() string {
+	if e.name != "" {
+		return "." + e.name
+	}
+	return "[" + e.key + "]"
+}
+
+// parsePathExpr parses a path expression.
+func parsePathExpr(s string) (pathExpr, error) {
+	var pe pathExpr
+	if s != "" && s[0] != '.' && s[0] != '[' {
+		s = "." + s // Leading period is optional
+	}
+	for s != "" {
+		switch s[0] {
+		case '.':
+			n := strings.IndexAny(s[1:], ".[") + 1
+			if n == 0 {
+				n = len(s)
+			}
+			name := s[1:n]
+			if !isValid(name) {
+				return nil, fmt.Errorf("invalid field name %q", name)
+			}
+			pe = append(pe, pathElem{name: name})
+			s = s[n:]
+		case '[':
+			var key string
+			if len(s) > 1 && s[1] == '"' {
+				q, err := strconv.QuotedPrefix(s[1:])
+				if err != nil {
+					return nil, fmt.Errorf("invalid quoted index: %s", s[1:])
+				}
+				key, _ = strconv.Unquote(q)
+				s = s[1+len(q):]
+			} else {
+				n := strings.IndexByte(s, ']')
+				if n < 0 {
+					return nil, errors.New("missing closing bracket")
+				}
+				key = s[1:n]
+				s = s[n:]
+			}
+			if s == "" || s[0] != ']' {
+				return nil, errors.New("missing closing bracket")
+			}
+			pe = append(pe, pathElem{key: key})
+			s = s[1:]
+		default:
+			return nil, fmt.Errorf("unexpected character %q", s[0])
+		}
+	}
+	return pe, nil
+}
+
+// match reports whether the path step ps is described by the element.
+func (e pathElem) match(ps PathStep) bool {
+	switch ps := ps.(type) {
+	case StructField:
+		return e.name != "" && e.name == ps.Name()
+	case SliceIndex:
+		return e.name == "" && (e.wildcard() || e.key == strconv.Itoa(ps.Key()))
+	case MapIndex:
+		return e.name == "" && (e.wildcard() || e.key == fmt.Sprint(ps.Key()))
+	default:
+		return false
+	}
+}
+
+// wildcard reports whether the element is the wildcard index "[*]".
+func (e pathElem) wildcard() bool {
+	return e.name == "" && e.key == "*"
+}
+
+// matchPath reports whether the path expression and the steps in p agree
+// on their common prefix, in which case p is either an ancestor or
+// a descendant of the node described by the expression. It also reports
+// whether p is the node itself or one of its descendants.
+// The root step, pointer indirections, type assertions, and transformations
+// in p are skipped since they are implicit in a path expression.
+func (pe pathExpr) matchPath(p Path) (agree, within bool) {
+	var i int
+	for _, ps := range p {
+		switch ps.(type) {
+		case StructField, SliceIndex, MapIndex:
+		default:
+			continue
+		}
+		if i == len(pe) {
+			return true, true // Descendant of the node
+		}
+		if !pe[i].match(ps) {
+			return false, false
+		}
+		i++
+	}
+	return true, i == len(pe) // Ancestor of the node or the node itself
+}
+
+// resolve navigates from the root value v to the node described by the
+// path expression, automatically following pointers and interfaces.
+// It returns the value at that node and the Path used to reach it.
+func (pe pathExpr) resolve(v reflect.Value) (reflect.Value, Path, error) {
+	if !v.IsValid() {
+		return v, nil, errors.New("untyped nil value")
+	}
+	p := Path{&pathStep{v.Type()}}
+	for _, e := range pe {
+		for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+			if v.IsNil() {
+				return v, p, fmt.Errorf("nil %v at %#v", v.Kind(), p)
+			}
+			if v.Kind() == reflect.Ptr {
+				p.push(&indirect{pathStep{v.Type().Elem()}})
+			} else {
+				p.push(&typeAssertion{pathStep{v.Elem().Type()}})
+			}
+			v = v.Elem()
+		}
+		switch {
+		case e.name != "" && v.Kind() == reflect.Struct:
+			sf, ok := v.Type().FieldByName(e.name)
+			if !ok || len(sf.Index) != 1 {
+				return v, p, fmt.Errorf("no field %s in %v at %#v", e.name, v.Type(), p)
+			}
+			if !isExported(sf.Name) {
+				return v, p, fmt.Errorf("unexported field %s in %v at %#v", e.name, v.Type(), p)
+			}
+			p.push(&structField{pathStep: pathStep{sf.Type}, name: sf.Name, idx: sf.Index[0]})
+			v = v.Field(sf.Index[0])
+		case e.wildcard():
+			return v, p, fmt.Errorf("cannot resolve wildcard index at %#v", p)
+		case e.name == "" && (v.Kind() == reflect.Slice || v.Kind() == reflect.Array):
+			i, err := strconv.Atoi(e.key)
+			if err != nil || i < 0 || i >= v.Len() {
+				return v, p, fmt.Errorf("index %s out of range for %v of length %d at %#v", e.key, v.Type(), v.Len(), p)
+			}
+			p.push(&sliceIndex{pathStep{v.Type().Elem()}, i})
+			v = v.Index(i)
+		case e.name == "" && v.Kind() == reflect.Map:
+			var found bool
+			for _, k := range v.MapKeys() {
+				if e.match(&mapIndex{key: k}) {
+					p.push(&mapIndex{pathStep{v.Type().Elem()}, k})
+					v, found = v.MapIndex(k), true
+					break
+				}
+			}
+			if !found {
+				return v, p, fmt.Errorf("missing key %s in %v at %#v", e.key, v.Type(), p)
+			}
+		default:
+			return v, p, fmt.Errorf("cannot apply %v to %v at %#v", e, v.Type(), p)
+		}
+	}
+	return v, p, nil
+}
@@ -0,0 +1,93 @@
+// Copyright 2017, The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+// Package protocmp provides an option for comparing protocol buffer messages.
+//
+// Generated message types have unexported fields that Equal panics on
+// unless an option handles them. Rather than depend on a particular
+// protocol buffer implementation, this package recognizes messages by the
+// methods that every generated message type has.
+package protocmp
+
+import (
+	"reflect"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// Message is the interface implemented by every protocol buffer message,
+// which this package uses to recognize them.
+type Message interface {
+	Reset()
+	String() string
+	ProtoMessage()
+}
+
+var (
+	messageType = reflect.TypeOf((*Message)(nil)).Elem()
+	boolType    = reflect.TypeOf(true)
+)
+
+// Equate returns an Option that compares values of any pointer type *T that
+// implements Message, or of type T if *T implements Message. Two non-nil
+// messages are compared by the first of the following that is available:
+//
+// • An Equal method of *T of the form "(*T) Equal(*T) bool" or
+// "(*T) Equal(I) bool" where *T is assignable to I, as generated by some
+// protocol buffer implementations.
+//
+// • The equal function, if not nil, such as the Equal function of the
+// protocol buffer implementation in use.
+//
+// • Equality of the String forms of both messages.
+//
+// A nil *T is only equal to another nil *T, so a nil message does not equal
+// an empty one. Unknown fields are only compared if the chosen mechanism
+// compares them; String forms typically omit them.
+//
+// Values of type T are compared by the address of a copy, so that methods
+// of *T may be used, which appears as a Transformer named "λref" in the path.
+// When messages differ, the report produced by Diff shows their String forms.
+//
+// This option must not be combined with another option that compares the
+// same message types, such as cmp.Comparer(proto.Equal), since the options
+// would be ambiguous.
+func Equate(equal func(x, y Message) bool) cmp.Option {
+	// Values of type T are replaced with a reference to a copy,
+	// such that they are reported with their String method.
+	ref := cmp.FilterPath(func(p cmp.Path) bool {
+		t := p[len(p)-1].Type()
+		return t.Kind() != reflect.Ptr && t.Kind() != reflect.Interface && reflect.PtrTo(t).Implements(messageType)
+	}, cmp.Transformer("λref", func(x interface{}) interface{} {
+		v := reflect.ValueOf(x)
+		vp := reflect.New(v.Type())
+		vp.Elem().Set(v)
+		return vp.Interface()
+	}))
+	compare := cmp.FilterPath(func(p cmp.Path) bool {
+		t := p[len(p)-1].Type()
+		return t.Kind() == reflect.Ptr && t.Implements(messageType)
+	}, cmp.Comparer(func(x, y interface{}) bool {
+		vx, vy := reflect.ValueOf(x), reflect.ValueOf(y)
+		if vx.IsNil() || vy.IsNil() {
+			return vx.IsNil() && vy.IsNil()
+		}
+		if m, ok := vx.Type().MethodByName("Equal"); ok && isEqualMethod(m) {
+			return m.Func.Call([]reflect.Value{vx, vy})[0].Bool()
+		}
+		if equal != nil {
+			return equal(x.(Message), y.(Message))
+		}
+		return x.(Message).String() == y.(Message).String()
+	}))
+	return cmp.Options{ref, compare}
+}
+
+// isEqualMethod reports whether m is an Equal method of a message type,
+// which accepts another message of the same type.
+func isEqualMethod(m reflect.Method) bool {
+	ft := m.Type
+	return !ft.IsVariadic() && ft.NumIn() == 2 && ft.NumOut() == 1 &&
+		ft.In(0).AssignableTo(ft.In(1)) && ft.Out(0) == boolType
+}
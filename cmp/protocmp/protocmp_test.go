@@ -0,0 +1,93 @@
+// Copyright 2017, The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package protocmp_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	pb "github.com/google/go-cmp/cmp/internal/testprotos"
+	"github.com/google/go-cmp/cmp/protocmp"
+)
+
+// versioned is a message with an Equal method, which ignores the Version.
+type versioned struct {
+	Version int
+	Name    string
+}
+
+func (m *versioned) Reset()         { *m = versioned{} }
+func (m *versioned) String() string { return m.Name }
+func (m *versioned) ProtoMessage()  {}
+func (m *versioned) Equal(n interface{}) bool {
+	return m.Name == n.(*versioned).Name
+}
+
+func TestEquate(t *testing.T) {
+	type container struct {
+		Value   pb.Eagle
+		Pointer *pb.Dreamer
+		Slice   []*pb.Slap
+		Iface   pb.Message
+		Method  versioned
+	}
+	newContainer := func(s string) container {
+		return container{
+			Value:   pb.Eagle{Stringer: pb.Stringer{X: s}},
+			Pointer: &pb.Dreamer{Stringer: pb.Stringer{X: s}},
+			Slice:   []*pb.Slap{{Stringer: pb.Stringer{X: s}}, nil},
+			Iface:   &pb.Goat{Stringer: pb.Stringer{X: s}},
+			Method:  versioned{Version: len(s), Name: "method"},
+		}
+	}
+	x, y := newContainer("a"), newContainer("aa")
+	x2 := newContainer("a")
+
+	opt := protocmp.Equate(nil)
+	if !cmp.Equal(x, x2, opt) {
+		t.Errorf("Equal(x, x) = false, want true:\n%s", cmp.Diff(x, x2, opt))
+	}
+
+	d := cmp.Diff(x, y, opt)
+	for _, want := range []string{
+		"λref({protocmp_test.container}.Value).(*testprotos.Eagle):\n\t-: \"a\"\n\t+: \"aa\"\n",
+		"{protocmp_test.container}.Pointer:\n\t-: \"a\"\n\t+: \"aa\"\n",
+		"{protocmp_test.container}.Slice[0]:\n\t-: \"a\"\n\t+: \"aa\"\n",
+		"{protocmp_test.container}.Iface.(*testprotos.Goat):\n\t-: \"a\"\n\t+: \"aa\"\n",
+	} {
+		if !strings.Contains(d, want) {
+			t.Errorf("Diff does not contain %q:\n%s", want, d)
+		}
+	}
+	if strings.Contains(d, "Method") {
+		t.Errorf("Diff did not use the Equal method:\n%s", d)
+	}
+
+	// The equal function is used for messages without an Equal method.
+	var calls int
+	opt = protocmp.Equate(func(x, y protocmp.Message) bool {
+		calls++
+		return len(x.String()) == len(y.String())
+	})
+	y = newContainer("b")
+	if !cmp.Equal(x, y, opt) || calls == 0 {
+		t.Errorf("Equal(x, y) = false or equal was not called, want true")
+	}
+
+	// A nil message is only equal to another nil message.
+	y.Slice[1] = new(pb.Slap)
+	if cmp.Equal(x, y, opt) {
+		t.Errorf("Equal(nil, empty) = true, want false")
+	}
+}
+
+func TestEquateAmbiguous(t *testing.T) {
+	x := &pb.Eagle{Stringer: pb.Stringer{X: "a"}}
+	_, err := cmp.EqualE(x, x, protocmp.Equate(nil), cmp.Comparer(pb.Equal))
+	if err == nil || !strings.Contains(err.Error(), "ambiguous") {
+		t.Errorf("EqualE with two message comparers = %v, want ambiguity error", err)
+	}
+}
@@ -17,13 +17,48 @@ import (
 
 type defaultReporter struct {
 	Option
-	diffs  []string // List of differences, possibly truncated
-	ndiffs int      // Total number of differences
-	nbytes int      // Number of bytes in diffs
-	nlines int      // Number of lines in diffs
+	buf    strings.Builder // Report of differences, possibly truncated
+	nrecs  int             // Number of differences in buf
+	ndiffs int             // Total number of differences
+	nlines int             // Number of lines in buf
+	forked bool            // Whether starts must be tracked for join
+	starts []reportMark    // Start of each difference in buf
+	limit  int             // Maximum size of the report, if non-zero
+	full   bool            // Whether the report has reached limit
+	record bool            // Whether each difference is recorded in diffs
+	diffs  []Difference    // Every difference, if record is set
 }
 
-var _ reporter = (*defaultReporter)(nil)
+// reportMark is the position in the report where a difference starts.
+type reportMark struct{ off, line int }
+
+// maxReportBytes and maxReportLines limit the size of the report,
+// beyond which differences are only counted.
+// No single value is formatted beyond maxReportBytes either.
+const (
+	maxReportBytes = 4096
+	maxReportLines = 256
+)
+
+// reportNoticeLen is the space reserved at the end of a report with a limit
+// for the notice stating that it was truncated.
+const reportNoticeLen = 128
+
+// minReportLimit is the smallest permitted limit on the size of a report.
+const minReportLimit = 2 * reportNoticeLen
+
+var (
+	_ concurrentReporter = (*defaultReporter)(nil)
+	_ limitedReporter    = (*defaultReporter)(nil)
+	_ limitedReporter    = discardReporter{}
+)
+
+// discardReporter is a limitedReporter that formats nothing and is always
+// full, such that a comparison stops at the first difference.
+type discardReporter struct{ Option }
+
+func (discardReporter) Report(reflect.Value, reflect.Value, bool, Path) {}
+func (discardReporter) isFull() bool                                    { return true }
 
 func (r *defaultReporter) Report(x, y reflect.Value, eq bool, p Path) {
 	// TODO: Is there a way to nicely print added/modified/removed elements
@@ -55,36 +90,186 @@ func (r *defaultReporter) Report(x, y reflect.Value, eq bool, p Path) {
 		// TODO: Maybe print some equal results for context?
 		return // Ignore equal results
 	}
-	const maxBytes = 4096
-	const maxLines = 256
+	if r.full {
+		return // Comparison should have stopped
+	}
 	r.ndiffs++
-	if r.nbytes < maxBytes && r.nlines < maxLines {
-		sx := prettyPrint(x, true)
-		sy := prettyPrint(y, true)
+	if r.record {
+		r.diffs = append(r.diffs, Difference{p.clone(), x, y})
+	}
+	if r.accepts() {
+		ps := p.GoString()
+		n := maxReportBytes
+		if r.limit > 0 {
+			// Share what remains of the report between both values.
+			n = (r.remaining() - len(ps) - len(":\n\t-: \n\t+: \n")) / 2
+			if n < 1 {
+				r.full = true
+				return
+			}
+		}
+		sx, sy, ok := formatTypedNils(x, y)
+		if !ok {
+			sx = prettyPrintLimit(x, true, n)
+			sy = prettyPrintLimit(y, true, n)
+		}
 		if sx == sy {
 			// Use of Stringer is not helpful, so rely on more exact formatting.
-			sx = prettyPrint(x, false)
-			sy = prettyPrint(y, false)
+			sx = prettyPrintLimit(x, false, n)
+			sy = prettyPrintLimit(y, false, n)
+		}
+		if !ok && x.IsValid() && y.IsValid() && x.Kind() == reflect.Interface && x.Type() != reflectTypeType && x.IsNil() != y.IsNil() {
+			// Make the dynamic type of the non-nil interface obvious.
+			if x.IsNil() {
+				sy = formatDynamicType(y, sy)
+			} else {
+				sx = formatDynamicType(x, sx)
+			}
+		}
+		if len(p) == 1 && (!x.IsValid() || !y.IsValid() || x.Type() != y.Type()) {
+			// Differing types can only be observed at the root, so make sure
+			// that the types are printed.
+			sx, sy = formatRoot(x, sx), formatRoot(y, sy)
+		}
+		size := len(ps) + len(sx) + len(sy) + len(":\n\t-: \n\t+: \n")
+		if r.limit > 0 && size > r.remaining() {
+			r.full = true
+			return
+		}
+		if r.forked {
+			r.starts = append(r.starts, reportMark{r.buf.Len(), r.nlines})
+		}
+		r.buf.Grow(size)
+		r.buf.WriteString(ps)
+		r.buf.WriteString(":\n\t-: ")
+		r.buf.WriteString(sx)
+		r.buf.WriteString("\n\t+: ")
+		r.buf.WriteString(sy)
+		r.buf.WriteString("\n")
+		r.nlines += 3 + strings.Count(ps, "\n") + strings.Count(sx, "\n") + strings.Count(sy, "\n")
+		r.nrecs++
+	}
+}
+
+// accepts reports whether another difference would be recorded in full,
+// rather than only counted. A report with a limit instead becomes full
+// once a difference no longer fits.
+func (r *defaultReporter) accepts() bool {
+	if r.limit > 0 {
+		return !r.full
+	}
+	return r.buf.Len() < maxReportBytes && r.nlines < maxReportLines
+}
+
+// remaining is the number of bytes that may still be added to a report
+// with a limit, reserving space for the truncation notice.
+func (r *defaultReporter) remaining() int {
+	return r.limit - reportNoticeLen - r.buf.Len()
+}
+
+// isFull reports whether r discards all further differences,
+// such that the comparison may stop.
+func (r *defaultReporter) isFull() bool {
+	return r.full
+}
+
+func (r *defaultReporter) fork() reporter {
+	return &defaultReporter{forked: true, limit: r.limit, record: r.record}
+}
+
+// join appends the differences recorded by fr, along with their notes
+// and details, as if they had been reported to r directly.
+// Since fr stops recording differences no later than r would have,
+// the result is identical to the sequential report.
+func (r *defaultReporter) join(fr reporter) {
+	r2 := fr.(*defaultReporter)
+	r.diffs = append(r.diffs, r2.diffs...)
+	s := r2.buf.String()
+	for i, m := range r2.starts {
+		end := reportMark{len(s), r2.nlines}
+		if i+1 < len(r2.starts) {
+			end = r2.starts[i+1]
+		}
+		r.ndiffs++
+		if r.limit > 0 && end.off-m.off > r.remaining() {
+			r.full = true
+			return
+		}
+		if r.accepts() {
+			if r.forked {
+				r.starts = append(r.starts, reportMark{r.buf.Len(), r.nlines})
+			}
+			r.buf.WriteString(s[m.off:end.off])
+			r.nlines += end.line - m.line
+			r.nrecs++
 		}
-		s := fmt.Sprintf("%#v:\n\t-: %s\n\t+: %s\n", p, sx, sy)
-		r.diffs = append(r.diffs, s)
-		r.nbytes += len(s)
-		r.nlines += strings.Count(s, "\n")
 	}
+	r.ndiffs += r2.ndiffs - r2.nrecs
+	r.full = r2.full
+}
+
+// reportDetails attaches the differences in sr as a supplemental report
+// beneath the most recently reported difference, which was decided by the
+// option described by label.
+func (r *defaultReporter) reportDetails(label string, sr *defaultReporter) {
+	if !r.acceptsNote() {
+		return // Most recent difference was truncated
+	}
+	d := strings.TrimSuffix(sr.String(), "\n")
+	s := fmt.Sprintf("\t(supplemental report with %s suppressed)\n\t%s\n",
+		label, strings.Replace(d, "\n", "\n\t", -1))
+	if r.limit > 0 && len(s) > r.remaining() {
+		r.full = true
+		return
+	}
+	r.buf.WriteString(s) // Most recent difference is always at the end
+	r.nlines += strings.Count(s, "\n")
+}
+
+// acceptsNote reports whether a note would be attached to the most recently
+// reported difference, which is not the case if it was truncated.
+func (r *defaultReporter) acceptsNote() bool {
+	return r.nrecs > 0 && r.ndiffs == r.nrecs
+}
+
+// reportNote attaches a single line note beneath the most recently
+// reported difference.
+func (r *defaultReporter) reportNote(note string) {
+	if !r.acceptsNote() {
+		return // Most recent difference was truncated
+	}
+	if r.limit > 0 && len(note)+len("\t()\n") > r.remaining() {
+		r.full = true
+		return
+	}
+	r.buf.WriteString("\t(")
+	r.buf.WriteString(note)
+	r.buf.WriteString(")\n")
+	r.nlines++
 }
 
 func (r *defaultReporter) String() string {
-	s := strings.Join(r.diffs, "")
-	if r.ndiffs == len(r.diffs) {
+	s := r.buf.String()
+	if r.full {
+		return fmt.Sprintf("%s... report truncated at %d bytes after %d differences ...",
+			s, r.limit, r.nrecs)
+	}
+	if r.ndiffs == r.nrecs {
 		return s
 	}
-	return fmt.Sprintf("%s... %d more differences ...", s, len(r.diffs)-r.ndiffs)
+	return fmt.Sprintf("%s... %d more differences ...", s, r.nrecs-r.ndiffs)
 }
 
 var stringerIface = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
 
 func prettyPrint(v reflect.Value, useStringer bool) string {
-	return formatAny(v, formatConfig{useStringer, true, true, true}, nil)
+	return prettyPrintLimit(v, useStringer, 0)
+}
+
+// prettyPrintLimit is like prettyPrint, but elides the parts of v
+// that would be formatted beyond roughly n bytes, if n is positive.
+func prettyPrintLimit(v reflect.Value, useStringer bool, n int) string {
+	return formatAny(v, formatConfig{useStringer, true, true, true, n}, nil)
 }
 
 type formatConfig struct {
@@ -92,6 +277,7 @@ type formatConfig struct {
 	printType      bool // Should we print the type before the value?
 	followPointers bool // Should we recursively follow pointers?
 	realPointers   bool // Should we print the real address of pointers?
+	maxLen         int  // Approximate limit on the output length, if non-zero
 }
 
 // formatAny prints the value v in a pretty formatted manner.
@@ -106,11 +292,21 @@ func formatAny(v reflect.Value, conf formatConfig, visited map[uintptr]bool) str
 	if !v.IsValid() {
 		return "<non-existent>"
 	}
+	if v.Type().Implements(reflectTypeType) && !v.IsNil() {
+		// Types are opaque, so only print the name of the type.
+		t := v.Interface().(reflect.Type)
+		if !conf.useStringer && t.PkgPath() != "" {
+			return fmt.Sprintf("%v (in %q)", t, t.PkgPath())
+		}
+		return t.String()
+	}
 	if conf.useStringer && v.Type().Implements(stringerIface) {
 		if v.Kind() == reflect.Ptr && v.IsNil() {
 			return "<nil>"
 		}
-		return fmt.Sprintf("%q", v.Interface().(fmt.Stringer).String())
+		if s, ok := callStringer(v); ok {
+			return formatString(s, conf.maxLen)
+		}
 	}
 
 	switch v.Kind() {
@@ -128,8 +324,18 @@ func formatAny(v reflect.Value, conf formatConfig, visited map[uintptr]bool) str
 	case reflect.Complex64, reflect.Complex128:
 		return fmt.Sprint(v.Complex())
 	case reflect.String:
-		return fmt.Sprintf("%q", v)
-	case reflect.UnsafePointer, reflect.Chan, reflect.Func:
+		return formatString(v.String(), conf.maxLen)
+	case reflect.Func:
+		// The function name is more descriptive than its address.
+		s := "nil"
+		if !v.IsNil() {
+			s = getFuncName(v.Pointer())
+		}
+		if conf.printType {
+			return fmt.Sprintf("(%v)(%s)", v.Type(), s)
+		}
+		return s
+	case reflect.UnsafePointer, reflect.Chan:
 		return formatPointer(v, conf)
 	case reflect.Ptr:
 		if v.IsNil() {
@@ -167,9 +373,16 @@ func formatAny(v reflect.Value, conf formatConfig, visited map[uintptr]bool) str
 		var ss []string
 		subConf := conf
 		subConf.printType = v.Type().Elem().Kind() == reflect.Interface
+		var n int
 		for i := 0; i < v.Len(); i++ {
+			var ok bool
+			if subConf.maxLen, ok = elemLimit(conf.maxLen, n); !ok {
+				ss = append(ss, "...")
+				break
+			}
 			s := formatAny(v.Index(i), subConf, visited)
 			ss = append(ss, s)
+			n += len(s) + len(", ")
 		}
 		s := "{" + strings.Join(ss, ", ") + "}"
 		if conf.printType {
@@ -191,10 +404,17 @@ func formatAny(v reflect.Value, conf formatConfig, visited map[uintptr]bool) str
 		var ss []string
 		subConf := conf
 		subConf.printType = v.Type().Elem().Kind() == reflect.Interface
+		var n int
 		for _, k := range sortKeys(v.MapKeys()) {
-			sk := formatAny(k, formatConfig{realPointers: conf.realPointers}, visited)
+			var ok bool
+			if subConf.maxLen, ok = elemLimit(conf.maxLen, n); !ok {
+				ss = append(ss, "...")
+				break
+			}
+			sk := formatAny(k, formatConfig{realPointers: conf.realPointers, maxLen: subConf.maxLen}, visited)
 			sv := formatAny(v.MapIndex(k), subConf, visited)
 			ss = append(ss, fmt.Sprintf("%s: %s", sk, sv))
+			n += len(ss[len(ss)-1]) + len(", ")
 		}
 		s := "{" + strings.Join(ss, ", ") + "}"
 		if conf.printType {
@@ -205,15 +425,22 @@ func formatAny(v reflect.Value, conf formatConfig, visited map[uintptr]bool) str
 		var ss []string
 		subConf := conf
 		subConf.printType = true
+		var n int
 		for i := 0; i < v.NumField(); i++ {
 			vv := v.Field(i)
 			if isZero(vv) {
 				continue // Elide zero value fields
 			}
+			var ok bool
+			if subConf.maxLen, ok = elemLimit(conf.maxLen, n); !ok {
+				ss = append(ss, "...")
+				break
+			}
 			name := v.Type().Field(i).Name
 			subConf.useStringer = conf.useStringer && isExported(name)
 			s := formatAny(vv, subConf, visited)
 			ss = append(ss, fmt.Sprintf("%s: %s", name, s))
+			n += len(ss[len(ss)-1]) + len(", ")
 		}
 		s := "{" + strings.Join(ss, ", ") + "}"
 		if conf.printType {
@@ -225,6 +452,93 @@ func formatAny(v reflect.Value, conf formatConfig, visited map[uintptr]bool) str
 	}
 }
 
+// elemLimit reports the limit on the length of the next element formatted
+// after n bytes of elements, and false if there is no room left for it.
+func elemLimit(maxLen, n int) (int, bool) {
+	if maxLen == 0 {
+		return 0, true
+	}
+	if n >= maxLen {
+		return 0, false
+	}
+	return maxLen - n, true
+}
+
+// formatString quotes s, eliding as much of s as is needed for the result
+// to not be much longer than maxLen bytes, if maxLen is positive.
+func formatString(s string, maxLen int) string {
+	if maxLen > 0 && len(s) > maxLen {
+		// Escape sequences may make the quoted prefix longer than maxLen.
+		for n := maxLen; ; n /= 2 {
+			if q := fmt.Sprintf("%q", s[:n]); len(q) <= maxLen || n == 0 {
+				return q + "..."
+			}
+		}
+	}
+	return fmt.Sprintf("%q", s)
+}
+
+// formatTypedNils formats a nil interface compared against an interface
+// holding a typed nil, which are otherwise difficult to tell apart.
+// It reports false if x and y are not such a pair.
+func formatTypedNils(x, y reflect.Value) (sx, sy string, ok bool) {
+	isIface := func(v reflect.Value) bool {
+		return v.IsValid() && v.Kind() == reflect.Interface
+	}
+	if !isIface(x) || !isIface(y) || x.IsNil() == y.IsNil() {
+		return "", "", false
+	}
+	if !isNilInterface(x) || !isNilInterface(y) {
+		return "", "", false
+	}
+	format := func(v reflect.Value) string {
+		if v.IsNil() {
+			return "untyped nil"
+		}
+		return fmt.Sprintf("typed nil (%v)(nil)", v.Elem().Type())
+	}
+	return format(x), format(y), true
+}
+
+// callStringer calls the String method on v, reporting false if it panics.
+// This occurs when the method is promoted through a nil embedded field.
+func callStringer(v reflect.Value) (s string, ok bool) {
+	defer func() {
+		if recover() != nil {
+			s, ok = "", false
+		}
+	}()
+	return v.Interface().(fmt.Stringer).String(), true
+}
+
+// formatDynamicType formats the non-nil interface v, which was formatted as s,
+// such that the dynamic type of v is always printed.
+func formatDynamicType(v reflect.Value, s string) string {
+	t := v.Elem().Type().String()
+	if strings.HasPrefix(strings.TrimPrefix(s, "&"), t) || strings.HasPrefix(s, "("+t+")") {
+		return s // Type is already printed
+	}
+	if v.Elem().Kind() == reflect.Ptr {
+		return fmt.Sprintf("(%s)(%s)", t, s)
+	}
+	return fmt.Sprintf("%s(%s)", t, s)
+}
+
+// formatRoot formats a top-level value v, which was formatted as s,
+// such that its type is always printed.
+func formatRoot(v reflect.Value, s string) string {
+	switch {
+	case !v.IsValid():
+		return "<untyped nil>"
+	case v.Kind() == reflect.Ptr && v.IsNil():
+		return fmt.Sprintf("(%v)(nil)", v.Type()) // Avoid "<nil>" from Stringer
+	case v.Kind() == reflect.String || (reflect.Bool <= v.Kind() && v.Kind() <= reflect.Complex128):
+		return fmt.Sprintf("%v(%s)", v.Type(), s)
+	default:
+		return s
+	}
+}
+
 func formatPointer(v reflect.Value, conf formatConfig) string {
 	p := v.Pointer()
 	if !conf.realPointers {
@@ -82,7 +82,7 @@ func TestFormatAny(t *testing.T) {
 	}}
 
 	for i, tt := range tests {
-		got := formatAny(reflect.ValueOf(tt.in), formatConfig{true, true, true, false}, nil)
+		got := formatAny(reflect.ValueOf(tt.in), formatConfig{true, true, true, false, 0}, nil)
 		if got != tt.want {
 			t.Errorf("test %d, pretty print:\ngot  %q\nwant %q", i, got, tt.want)
 		}
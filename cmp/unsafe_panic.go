@@ -8,6 +8,133 @@ package cmp
 
 import "reflect"
 
-func unsafeRetrieveField(reflect.Value, reflect.StructField) reflect.Value {
-	panic("unsafeRetrieveField is not implemented on appengine or gopherjs")
+// unsafeRetrieveField retrieves any field from a struct without the use of
+// package unsafe, which is unavailable on this platform.
+//
+// Since the reflect package forbids the use of values obtained through
+// unexported fields, the value is rebuilt from its constituent parts into
+// a new value that may be used freely. This is not possible for values that
+// contain non-nil channels, functions, or unsafe pointers, or structs with
+// unexported fields. In those cases, the read-only value is returned as is,
+// which may only be compared structurally and causes a panic if passed to
+// any user-provided function.
+//
+// The parent struct, v, need not be addressable, while f must be a StructField
+// describing the field to retrieve.
+func unsafeRetrieveField(v reflect.Value, f reflect.StructField) reflect.Value {
+	fv := v.FieldByIndex(f.Index)
+	if vc, ok := rebuildValue(fv, make(map[visitedPtr]reflect.Value)); ok {
+		return vc
+	}
+	return fv
 }
+
+type visitedPtr struct {
+	typ reflect.Type
+	ptr uintptr
+}
+
+// rebuildValue returns a copy of v that was built using only the read-only
+// accessor methods of reflect.Value, such that the copy does not inherit
+// the read-only flag of v. It reports false if no such copy can be made.
+//
+// Pointers that were already visited are tracked in m to handle cycles,
+// such that the copy has the same reference structure as v.
+func rebuildValue(v reflect.Value, m map[visitedPtr]reflect.Value) (reflect.Value, bool) {
+	t := v.Type()
+	vc := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.Bool:
+		vc.SetBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		vc.SetInt(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		vc.SetUint(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		vc.SetFloat(v.Float())
+	case reflect.Complex64, reflect.Complex128:
+		vc.SetComplex(v.Complex())
+	case reflect.String:
+		vc.SetString(v.String())
+	case reflect.Slice:
+		if v.IsNil() {
+			break
+		}
+		vc.Set(reflect.MakeSlice(t, v.Len(), v.Cap()))
+		fallthrough
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			e, ok := rebuildValue(v.Index(i), m)
+			if !ok {
+				return v, false
+			}
+			vc.Index(i).Set(e)
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			break
+		}
+		vc.Set(reflect.MakeMap(t))
+		for _, k := range v.MapKeys() {
+			kc, ok1 := rebuildValue(k, m)
+			ec, ok2 := rebuildValue(v.MapIndex(k), m)
+			if !ok1 || !ok2 {
+				return v, false
+			}
+			vc.SetMapIndex(kc, ec)
+		}
+	case reflect.Ptr:
+		if v.IsNil() {
+			break
+		}
+		p := visitedPtr{t, v.Pointer()}
+		if pc, ok := m[p]; ok {
+			return pc, true
+		}
+		vc.Set(reflect.New(t.Elem()))
+		m[p] = vc
+		e, ok := rebuildValue(v.Elem(), m)
+		if !ok {
+			return v, false
+		}
+		vc.Elem().Set(e)
+	case reflect.Interface:
+		if v.IsNil() {
+			break
+		}
+		e, ok := rebuildValue(v.Elem(), m)
+		if !ok {
+			return v, false
+		}
+		vc.Set(e)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if !isExported(t.Field(i).Name) {
+				return v, false
+			}
+			e, ok := rebuildValue(v.Field(i), m)
+			if !ok {
+				return v, false
+			}
+			vc.Field(i).Set(e)
+		}
+	default: // Chan, Func, or UnsafePointer
+		if !v.IsNil() {
+			return v, false
+		}
+	}
+	return vc, true
+}
+
+// unsafeFuncPointer cannot identify a function value without package unsafe,
+// since reflect.Value.Pointer only reports the code pointer, which is shared
+// by every closure of the same function literal. Non-nil functions are
+// therefore never equal, even to themselves.
+func unsafeFuncPointer(reflect.Value) (uintptr, bool) {
+	return 0, false
+}
+
+func unsafeFastEqual(reflect.Value, reflect.Value, []fastLeaf) (bool, bool) {
+	return false, false
+}
+
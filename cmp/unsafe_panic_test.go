@@ -0,0 +1,139 @@
+// Copyright 2017, The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+// +build appengine js
+
+package cmp_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	ts "github.com/google/go-cmp/cmp/internal/teststructs"
+)
+
+// readOnly returns tt changed to expect the panic for passing the read-only
+// value of the unexported field at path to a user function, which is how the
+// TestDiff cases of Project3 and Project4 are downgraded without package unsafe.
+func readOnly(tt test, path string) test {
+	tt.wantDiff = ""
+	tt.wantPanic = "cannot pass read-only value of unexported field to function at " + path
+	return tt
+}
+
+// canCompareFuncs reports whether function values may be compared by identity,
+// which requires package unsafe (see unsafeFuncPointer).
+const canCompareFuncs = false
+
+// TestUnexportedFieldFallback documents the cases that are downgraded
+// without package unsafe, in addition to those marked by readOnly.
+func TestUnexportedFieldFallback(t *testing.T) {
+	type Primitive struct{ n int }
+	type Composite struct{ m map[string][]*int }
+	type Channel struct{ c chan int }
+
+	one, two := 1, 2
+	ch := make(chan int)
+	createStructA := func(i int) ts.ParentStructA {
+		var s ts.ParentStructA
+		s.PrivateStruct().Public = i
+		s.PrivateStruct().SetPrivate(i)
+		return s
+	}
+
+	tests := []struct {
+		label     string
+		x, y      interface{}
+		opts      []cmp.Option
+		wantEqual bool
+		wantPanic string
+	}{{
+		// Values that can be rebuilt may be passed to user functions.
+		label: "RebuiltPrimitive",
+		x:     Primitive{1},
+		y:     Primitive{2},
+		opts: []cmp.Option{
+			cmp.AllowUnexported(Primitive{}),
+			cmp.Comparer(func(x, y int) bool { return true }),
+		},
+		wantEqual: true,
+	}, {
+		label: "RebuiltComposite",
+		x:     Composite{map[string][]*int{"a": {&one, &two}}},
+		y:     Composite{map[string][]*int{"a": {&one, &one}}},
+		opts: []cmp.Option{
+			cmp.AllowUnexported(Composite{}),
+			cmp.Transformer("", func(m map[string][]*int) int { return len(m) }),
+		},
+		wantEqual: true,
+	}, {
+		// Structs with unexported fields cannot be rebuilt, but may still
+		// be compared structurally as read-only values.
+		label:     "ReadOnlyStruct",
+		x:         createStructA(1),
+		y:         createStructA(1),
+		opts:      []cmp.Option{cmp.AllowUnexported(ts.ParentStructA{}, *new(ts.ParentStructA).PrivateStruct())},
+		wantEqual: true,
+	}, {
+		label: "ReadOnlyStruct",
+		x:     createStructA(1),
+		y:     createStructA(2),
+		opts:  []cmp.Option{cmp.AllowUnexported(ts.ParentStructA{}, *new(ts.ParentStructA).PrivateStruct())},
+	}, {
+		label: "ReadOnlyStruct",
+		x:     createStructA(1),
+		y:     createStructA(1),
+		opts: []cmp.Option{
+			cmp.AllowUnexported(ts.ParentStructA{}, *new(ts.ParentStructA).PrivateStruct()),
+			cmp.FilterPath(func(p cmp.Path) bool { return p.String() == "privateStruct" },
+				cmp.Comparer(func(x, y interface{}) bool { return true })),
+		},
+		wantPanic: "cannot pass read-only value of unexported field to function at {teststructs.ParentStructA}.privateStruct",
+	}, {
+		// Fields within such structs are rebuilt individually.
+		label: "ReadOnlyStruct",
+		x:     createStructA(1),
+		y:     createStructA(2),
+		opts: []cmp.Option{
+			cmp.AllowUnexported(ts.ParentStructA{}, *new(ts.ParentStructA).PrivateStruct()),
+			cmp.Comparer(func(x, y int) bool { return true }),
+		},
+		wantEqual: true,
+	}, {
+		label:     "ReadOnlyChannel",
+		x:         Channel{ch},
+		y:         Channel{ch},
+		opts:      []cmp.Option{cmp.AllowUnexported(Channel{})},
+		wantEqual: true,
+	}, {
+		label: "ReadOnlyChannel",
+		x:     Channel{ch},
+		y:     Channel{make(chan int)},
+		opts:  []cmp.Option{cmp.AllowUnexported(Channel{})},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.label, func(t *testing.T) {
+			var gotEqual bool
+			gotPanic := func() (s string) {
+				defer func() {
+					if ex := recover(); ex != nil {
+						s = ex.(string)
+					}
+				}()
+				gotEqual = cmp.Equal(tt.x, tt.y, tt.opts...)
+				return ""
+			}()
+			switch {
+			case tt.wantPanic == "" && gotPanic != "":
+				t.Fatalf("unexpected panic message: %s", gotPanic)
+			case !strings.Contains(gotPanic, tt.wantPanic):
+				t.Fatalf("panic message:\ngot:  %s\nwant: %s", gotPanic, tt.wantPanic)
+			case gotPanic == "" && gotEqual != tt.wantEqual:
+				t.Errorf("Equal() = %v, want %v", gotEqual, tt.wantEqual)
+			}
+		})
+	}
+}
@@ -19,3 +19,51 @@ import (
 func unsafeRetrieveField(v reflect.Value, f reflect.StructField) reflect.Value {
 	return reflect.NewAt(f.Type, unsafe.Pointer(v.UnsafeAddr()+f.Offset)).Elem()
 }
+
+// unsafeFuncPointer returns the address of the closure object of the
+// non-nil function v, which uniquely identifies a function value including
+// any captured variables.
+func unsafeFuncPointer(v reflect.Value) (uintptr, bool) {
+	p := reflect.New(v.Type())
+	p.Elem().Set(v)
+	return uintptr(*(*unsafe.Pointer)(unsafe.Pointer(p.Pointer()))), true
+}
+
+// unsafeFastEqual compares the addressable values vx and vy by comparing
+// each of their primitive leaves in memory, which is equivalent to comparing
+// vx.Interface() and vy.Interface() using the == operator without copying
+// the values.
+func unsafeFastEqual(vx, vy reflect.Value, leaves []fastLeaf) (eq, ok bool) {
+	px, py := unsafe.Pointer(vx.UnsafeAddr()), unsafe.Pointer(vy.UnsafeAddr())
+	for _, l := range leaves {
+		x, y := unsafe.Pointer(uintptr(px)+l.off), unsafe.Pointer(uintptr(py)+l.off)
+		switch l.kind {
+		case reflect.Bool, reflect.Int8, reflect.Uint8:
+			eq = *(*uint8)(x) == *(*uint8)(y)
+		case reflect.Int16, reflect.Uint16:
+			eq = *(*uint16)(x) == *(*uint16)(y)
+		case reflect.Int32, reflect.Uint32:
+			eq = *(*uint32)(x) == *(*uint32)(y)
+		case reflect.Int, reflect.Uint, reflect.Uintptr:
+			eq = *(*uint)(x) == *(*uint)(y)
+		case reflect.Int64, reflect.Uint64:
+			eq = *(*uint64)(x) == *(*uint64)(y)
+		case reflect.Float32:
+			eq = *(*float32)(x) == *(*float32)(y)
+		case reflect.Float64:
+			eq = *(*float64)(x) == *(*float64)(y)
+		case reflect.Complex64:
+			eq = *(*complex64)(x) == *(*complex64)(y)
+		case reflect.Complex128:
+			eq = *(*complex128)(x) == *(*complex128)(y)
+		case reflect.String:
+			eq = *(*string)(x) == *(*string)(y)
+		default:
+			return false, false
+		}
+		if !eq {
+			return false, true
+		}
+	}
+	return true, true
+}
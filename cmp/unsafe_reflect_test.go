@@ -0,0 +1,99 @@
+// Copyright 2017, The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+// +build !appengine,!js
+
+package cmp_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// readOnly returns tt unchanged, since values of unexported fields may be
+// passed to user functions with package unsafe.
+func readOnly(tt test, path string) test {
+	return tt
+}
+
+// canCompareFuncs reports whether function values may be compared by identity,
+// which requires package unsafe.
+const canCompareFuncs = true
+
+// meddler is a TextMarshaler that mutates its own private state.
+type meddler struct{ n int }
+
+func (m *meddler) MarshalText() ([]byte, error) {
+	m.n++
+	return []byte("meddler"), nil
+}
+
+func TestUnexportedFieldCopies(t *testing.T) {
+	type Parent struct{ m meddler }
+
+	x, y := Parent{meddler{1}}, Parent{meddler{2}}
+	opts := []cmp.Option{cmp.AllowUnexported(Parent{}), cmp.CompareViaTextMarshaler()}
+	if !cmp.Equal(&x, &y, opts...) {
+		t.Errorf("Equal() = false, want true")
+	}
+	if x.m.n != 1 || y.m.n != 2 {
+		t.Errorf("unexported fields were mutated: got (%d, %d), want (1, 2)", x.m.n, y.m.n)
+	}
+	tr := cmp.Transformer("", func(m meddler) int {
+		m.n = 0
+		return m.n
+	})
+	if !cmp.Equal(&x, &y, cmp.AllowUnexported(Parent{}), tr) {
+		t.Errorf("Equal() = false, want true")
+	}
+	if x.m.n != 1 || y.m.n != 2 {
+		t.Errorf("unexported fields were mutated: got (%d, %d), want (1, 2)", x.m.n, y.m.n)
+	}
+
+	opts = []cmp.Option{cmp.AllowUnexportedInPlace(Parent{}), cmp.CompareViaTextMarshaler()}
+	if !cmp.Equal(&x, &y, opts...) {
+		t.Errorf("Equal(AllowUnexportedInPlace) = false, want true")
+	}
+	if x.m.n != 2 || y.m.n != 3 {
+		t.Errorf("unexported fields were not operated on in place: got (%d, %d), want (2, 3)", x.m.n, y.m.n)
+	}
+}
+
+// Test that the overhead of Equal on a flat struct of primitives without
+// any options is within a small multiple of a handwritten comparison.
+// Without package unsafe, such structs must be copied to be compared.
+func TestEqualFlatStructOverhead(t *testing.T) {
+	x, y := newFlatStruct(), newFlatStruct()
+	if n := testing.AllocsPerRun(100, func() { cmp.Equal(&x, &y) }); n > 0 {
+		t.Errorf("Equal() made %v allocations, want 0", n)
+	}
+	if testing.Short() {
+		t.Skip("skipping timing in short mode")
+	}
+
+	// Take the best of several runs to reduce noise.
+	const n, maxRatio = 100000, 25
+	timeLoop := func(f func()) time.Duration {
+		best := time.Duration(math.MaxInt64)
+		for i := 0; i < 5; i++ {
+			start := time.Now()
+			for j := 0; j < n; j++ {
+				f()
+			}
+			if d := time.Since(start); d < best {
+				best = d
+			}
+		}
+		return best
+	}
+	hand := timeLoop(func() { equalFlatStruct(&x, &y) })
+	eq := timeLoop(func() { cmp.Equal(&x, &y) })
+	ratio := float64(eq) / math.Max(float64(hand), 1)
+	if ratio > maxRatio {
+		t.Errorf("Equal() is %.1fx slower than a handwritten comparison, want at most %dx", ratio, maxRatio)
+	}
+}